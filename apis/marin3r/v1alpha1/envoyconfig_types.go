@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	reconcilerutil "github.com/3scale-ops/basereconciler/util"
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
@@ -39,6 +41,11 @@ const (
 	// tainted
 	RollbackFailedCondition string = "RollbackFailed"
 
+	// PinFailedCondition indicates that the EnvoyConfig object is not able
+	// to publish the pinned revision, either because it does not exist or
+	// because it is tainted
+	PinFailedCondition string = "PinFailed"
+
 	/* State */
 
 	//InSyncState indicates that a EnvoyConfig object has its resources spec
@@ -52,6 +59,36 @@ const (
 	// RollbackFailedState indicates that there is no untainted revision that
 	// can be pusblished in the xds server cache
 	RollbackFailedState string = "RollbackFailed"
+
+	// CanaryState indicates that the revision for the desired resources spec
+	// is being served to a subset of the envoy clients before being promoted
+	CanaryState string = "Canary"
+
+	// PinnedState indicates that the published revision has been manually
+	// pinned and does not follow the desired resources spec
+	PinnedState string = "Pinned"
+
+	/* Defaults */
+
+	// DefaultCanaryAnalysisPeriod is the default time a canary revision
+	// is monitored before being promoted
+	DefaultCanaryAnalysisPeriod time.Duration = 5 * time.Minute
+
+	// DefaultRevisionHistoryLimit is the default number of
+	// revisions kept for an EnvoyConfig
+	DefaultRevisionHistoryLimit int32 = 10
+
+	// MaxOutOfSyncPods is the maximum number of out of sync
+	// pods listed in the status of an EnvoyConfig
+	MaxOutOfSyncPods int = 20
+
+	// DefaultDanglingReferenceCheck is the default policy for
+	// references to resources that are not declared
+	DefaultDanglingReferenceCheck ReferenceCheckPolicy = ReferenceCheckWarn
+
+	// DefaultUnusedReferenceCheck is the default policy for
+	// resources that are not referenced
+	DefaultUnusedReferenceCheck ReferenceCheckPolicy = ReferenceCheckIgnore
 )
 
 // EnvoyConfigSpec defines the desired state of EnvoyConfig
@@ -81,6 +118,183 @@ type EnvoyConfigSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	Resources []Resource `json:"resources,omitempty"`
+	// Libraries is a list of EnvoyResourceLibraries in the same namespace whose resources
+	// are imported into the EnvoyConfig. Imported resources go before the ones in
+	// spec.resources, so these take precedence over imported resources of the same type
+	// and name. Changes to the libraries generate new revisions.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Libraries []LibraryReference `json:"libraries,omitempty"`
+	// Parameters declares values that can be referenced from the string values
+	// of the resources using Go template syntax, e.g. "{{ .name }}". Templates are
+	// only rendered when at least one parameter is declared. Values held in ConfigMaps
+	// are resolved when the revision is created, so a change in a ConfigMap generates
+	// a new revision.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty"`
+	// RolloutStrategy defines how new revisions are published to the envoy clients.
+	// If unset, new revisions are published to all the envoy clients at once.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+	// RevisionHistory configures the retention of old revisions and
+	// the conditions under which a revision gets tainted
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	RevisionHistory *RevisionHistory `json:"revisionHistory,omitempty"`
+	// PinnedVersion pins the published revision to the one with the given version, which
+	// must be one of the versions listed in status.revisions. While set, changes to the
+	// resources spec create new revisions but these are not published. Tainted revisions
+	// and revisions that have already been deleted cannot be pinned. If the pinned revision
+	// cannot be published, the currently published revision is kept and the PinFailed
+	// condition is set.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	PinnedVersion *string `json:"pinnedVersion,omitempty"`
+	// ReferenceChecks configures how the admission webhook reports the references between
+	// the envoy resources of the config. If unset, references to resources that are not
+	// declared are reported as warnings and unused resources are not reported.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	ReferenceChecks *ReferenceChecks `json:"referenceChecks,omitempty"`
+}
+
+// RevisionHistory configures the retention of old revisions and the
+// conditions under which a revision gets tainted
+type RevisionHistory struct {
+	// Limit is the maximum number of revisions kept. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Limit *int32 `json:"limit,omitempty"`
+	// MaxAge is the maximum time a revision is kept since it was last published, or
+	// since it was created if it has never been published. The revision currently
+	// published and the one matching the desired resources are never deleted.
+	// If unset, revisions are only deleted when the limit is reached.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// TaintThreshold configures when a revision gets tainted due to being
+	// rejected by the envoy clients
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	TaintThreshold *TaintThreshold `json:"taintThreshold,omitempty"`
+}
+
+// GetLimit returns the maximum number of revisions kept
+func (rh *RevisionHistory) GetLimit() int {
+	if rh == nil || rh.Limit == nil {
+		return int(DefaultRevisionHistoryLimit)
+	}
+	return int(*rh.Limit)
+}
+
+// GetMaxAge returns the maximum age of a revision, or zero if
+// revisions do not expire
+func (rh *RevisionHistory) GetMaxAge() time.Duration {
+	if rh == nil || rh.MaxAge == nil {
+		return 0
+	}
+	return rh.MaxAge.Duration
+}
+
+// GetTaintThreshold returns the taint threshold or nil if unset
+func (rh *RevisionHistory) GetTaintThreshold() *TaintThreshold {
+	if rh == nil {
+		return nil
+	}
+	return rh.TaintThreshold
+}
+
+// RolloutStrategy defines how new revisions are published to the envoy clients
+type RolloutStrategy struct {
+	// Canary publishes new revisions to a subset of the envoy clients first. If the
+	// canary revision is not rejected by the envoy clients during the analysis period it
+	// gets promoted and published to all the envoy clients. Otherwise it gets tainted and
+	// the previous revision is kept.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Canary *CanaryRolloutStrategy `json:"canary,omitempty"`
+}
+
+// CanaryRolloutStrategy configures the canary publication of new revisions
+type CanaryRolloutStrategy struct {
+	// Percentage of the envoy clients that receive the canary revision. The envoy clients
+	// are selected using a hash of the Pod name.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Percentage *int32 `json:"percentage,omitempty"`
+	// Selector selects the Pods that receive the canary revision. Only one
+	// of Percentage, Selector can be set.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// AnalysisPeriod is the time the canary revision is monitored before
+	// being promoted. Defaults to 5m.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	AnalysisPeriod *metav1.Duration `json:"analysisPeriod,omitempty"`
+}
+
+// GetAnalysisPeriod returns the analysis period of canary revisions
+func (crs *CanaryRolloutStrategy) GetAnalysisPeriod() time.Duration {
+	if crs.AnalysisPeriod == nil {
+		return DefaultCanaryAnalysisPeriod
+	}
+	return crs.AnalysisPeriod.Duration
+}
+
+// ReferenceCheckPolicy sets how the issues found when checking
+// the references between envoy resources are reported
+type ReferenceCheckPolicy string
+
+const (
+	// ReferenceCheckIgnore does not report the issues
+	ReferenceCheckIgnore ReferenceCheckPolicy = "Ignore"
+	// ReferenceCheckWarn reports the issues as admission warnings
+	ReferenceCheckWarn ReferenceCheckPolicy = "Warn"
+	// ReferenceCheckDeny rejects the EnvoyConfig if there are issues
+	ReferenceCheckDeny ReferenceCheckPolicy = "Deny"
+)
+
+// ReferenceChecks configures the checks of the references between the envoy resources
+// of the config. Only references to resources served through ADS are checked: routes
+// referenced from listeners via RDS, clusters referenced from routes, listeners and
+// filters, endpoints of EDS clusters and secrets referenced from transport sockets via SDS.
+type ReferenceChecks struct {
+	// Dangling sets how references to resources that are not declared in the config
+	// are reported. As resources imported from libraries are not known at admission time,
+	// dangling references are only reported as warnings when libraries are imported.
+	// Defaults to Warn.
+	// +kubebuilder:validation:Enum=Ignore;Warn;Deny
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Dangling *ReferenceCheckPolicy `json:"dangling,omitempty"`
+	// Unused sets how routes, clusters, endpoints and secrets that are not referenced
+	// by any other resource of the config are reported. Defaults to Ignore.
+	// +kubebuilder:validation:Enum=Ignore;Warn;Deny
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Unused *ReferenceCheckPolicy `json:"unused,omitempty"`
+}
+
+// GetDangling returns the policy for references to resources that are not declared
+func (rc *ReferenceChecks) GetDangling() ReferenceCheckPolicy {
+	if rc == nil || rc.Dangling == nil {
+		return DefaultDanglingReferenceCheck
+	}
+	return *rc.Dangling
+}
+
+// GetUnused returns the policy for resources that are not referenced
+func (rc *ReferenceChecks) GetUnused() ReferenceCheckPolicy {
+	if rc == nil || rc.Unused == nil {
+		return DefaultUnusedReferenceCheck
+	}
+	return *rc.Unused
 }
 
 // EnvoyConfigStatus defines the observed state of EnvoyConfig
@@ -102,6 +316,11 @@ type EnvoyConfigStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	// +optional
 	DesiredVersion *string `json:"desiredVersion,omitempty"`
+	// CanaryVersion is the config version currently served by the
+	// envoy discovery service to a subset of the envoy clients
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	CanaryVersion *string `json:"canaryVersion,omitempty"`
 	// Conditions represent the latest available observations of an object's state
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	// +optional
@@ -111,6 +330,80 @@ type EnvoyConfigStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	// +optional
 	ConfigRevisions []ConfigRevisionRef `json:"revisions,omitempty"`
+	// SyncStatus summarizes the convergence of the envoy clients
+	// connected to the discovery service towards the published version
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	SyncStatus *SyncStatus `json:"syncStatus,omitempty"`
+}
+
+// SyncStatus summarizes the convergence of the envoy clients towards the
+// published version
+type SyncStatus struct {
+	// ConnectedPods is the number of envoy clients currently subscribed to
+	// the discovery service for the nodeID
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ConnectedPods int32 `json:"connectedPods"`
+	// SyncedPods is the number of envoy clients that have acknowledged the
+	// published version for all the resource types they are subscribed to
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SyncedPods int32 `json:"syncedPods"`
+	// ResourceTypes holds the sync status for each resource type
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	ResourceTypes []ResourceTypeSyncStatus `json:"resourceTypes,omitempty"`
+	// OutOfSyncPods lists the envoy clients that have not yet acknowledged
+	// the published version. The list is capped to MaxOutOfSyncPods items.
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	OutOfSyncPods []PodSyncStatus `json:"outOfSyncPods,omitempty"`
+}
+
+// ResourceTypeSyncStatus holds the sync status of a resource type
+type ResourceTypeSyncStatus struct {
+	// Type is the resource type
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Type envoy.Type `json:"type"`
+	// Version is the published version for the resource type
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Version string `json:"version"`
+	// SubscribedPods is the number of envoy clients subscribed to the resource type
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SubscribedPods int32 `json:"subscribedPods"`
+	// SyncedPods is the number of envoy clients that have acknowledged the
+	// published version of the resource type
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	SyncedPods int32 `json:"syncedPods"`
+}
+
+// PodSyncState represents the sync state of an envoy client
+type PodSyncState string
+
+const (
+	// PodLagging means the envoy client has not yet acknowledged the published version
+	PodLagging PodSyncState = "Lagging"
+	// PodRejecting means the envoy client has rejected the published version
+	PodRejecting PodSyncState = "Rejecting"
+)
+
+// PodSyncStatus holds the sync status of an envoy client that has not
+// acknowledged the published version
+type PodSyncStatus struct {
+	// Name is the name of the envoy client pod
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Name string `json:"name"`
+	// State is either "Lagging" or "Rejecting"
+	// +kubebuilder:validation:Enum=Lagging;Rejecting
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	State PodSyncState `json:"state"`
+	// Types is the list of resource types the envoy client is out of sync for
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Types []envoy.Type `json:"types"`
+	// LastACK is the last time the envoy client acknowledged a
+	// configuration update for any resource type
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	LastACK *metav1.Time `json:"lastACK,omitempty"`
 }
 
 // ConfigRevisionRef holds a reference to EnvoyConfigRevision object
@@ -137,6 +430,8 @@ type ConfigRevisionRef struct {
 // +kubebuilder:printcolumn:JSONPath=".status.desiredVersion",name=Desired Version,type=string
 // +kubebuilder:printcolumn:JSONPath=".status.publishedVersion",name=Published Version,type=string
 // +kubebuilder:printcolumn:JSONPath=".status.cacheState",name=Cache State,type=string
+// +kubebuilder:printcolumn:JSONPath=".status.syncStatus.syncedPods",name=Synced,type=integer
+// +kubebuilder:printcolumn:JSONPath=".status.syncStatus.connectedPods",name=Connected,type=integer
 // +operator-sdk:csv:customresourcedefinitions:displayName="EnvoyConfig"
 // +operator-sdk:csv:customresourcedefinitions:resources={{EnvoyConfigRevision,v1alpha1}}
 type EnvoyConfig struct {
@@ -163,10 +458,32 @@ func (ec *EnvoyConfig) GetSerialization() envoy_serializer.Serialization {
 	return envoy_serializer.Serialization(*ec.Spec.Serialization)
 }
 
+// GetCanaryRolloutStrategy returns the canary rollout strategy or nil
+// if new revisions are not published as canaries
+func (ec *EnvoyConfig) GetCanaryRolloutStrategy() *CanaryRolloutStrategy {
+	if ec.Spec.RolloutStrategy == nil {
+		return nil
+	}
+	return ec.Spec.RolloutStrategy.Canary
+}
+
+// GetRevisionHistory returns the revision history configuration, nil if unset
+func (ec *EnvoyConfig) GetRevisionHistory() *RevisionHistory {
+	return ec.Spec.RevisionHistory
+}
+
 // GetEnvoyResourcesVersion returns the hash of the resources in the spec which
-// univoquely identifies the version of the resources.
+// univoquely identifies the version of the resources. Resources imported from
+// libraries are added to the spec in memory by the controller, so they are
+// covered by the hash.
 func (ec *EnvoyConfig) GetEnvoyResourcesVersion() string {
-	return reconcilerutil.Hash(ec.Spec.Resources)
+	if len(ec.Spec.Parameters) == 0 {
+		return reconcilerutil.Hash(ec.Spec.Resources)
+	}
+	return reconcilerutil.Hash(struct {
+		Resources  []Resource
+		Parameters []Parameter
+	}{ec.Spec.Resources, ec.Spec.Parameters})
 }
 
 // Default implements defaulting for the EnvoyConfig resource
@@ -112,6 +112,20 @@ func TestEnvoyConfig_GetEnvoyResourcesVersion(t *testing.T) {
 			},
 			reconcilerutil.Hash([]Resource{}),
 		},
+		{"With parameters",
+			func() *EnvoyConfig {
+				return &EnvoyConfig{
+					Spec: EnvoyConfigSpec{
+						Resources:  []Resource{},
+						Parameters: []Parameter{{Name: "name", Value: pointer.New("value")}},
+					},
+				}
+			},
+			reconcilerutil.Hash(struct {
+				Resources  []Resource
+				Parameters []Parameter
+			}{[]Resource{}, []Parameter{{Name: "name", Value: pointer.New("value")}}}),
+		},
 	}
 
 	for _, tc := range cases {
@@ -17,13 +17,19 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/3scale-ops/basereconciler/util"
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	envoy_template "github.com/3scale-ops/marin3r/pkg/envoy/template"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -49,7 +55,7 @@ func (r *EnvoyConfig) ValidateCreate() (admission.Warnings, error) {
 	if err := r.Validate(); err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return r.ValidateReferences()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -58,7 +64,7 @@ func (r *EnvoyConfig) ValidateUpdate(old runtime.Object) (admission.Warnings, er
 	if err := r.Validate(); err != nil {
 		return nil, err
 	}
-	return nil, nil
+	return r.ValidateReferences()
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -66,9 +72,23 @@ func (r *EnvoyConfig) ValidateDelete() (admission.Warnings, error) { return nil,
 
 // Validates the EnvoyConfig resource
 func (r *EnvoyConfig) Validate() error {
-	if (r.Spec.EnvoyResources == nil && r.Spec.Resources == nil) || (r.Spec.EnvoyResources != nil && r.Spec.Resources != nil) {
+	// the nodeID is used to build the keys of the xDS cache and the names of
+	// other objects, so it must be a DNS subdomain
+	if errs := validation.IsDNS1123Subdomain(r.Spec.NodeID); len(errs) > 0 {
+		return fmt.Errorf("'spec.nodeID' is invalid: %s", strings.Join(errs, ", "))
+	}
+
+	if r.Spec.EnvoyResources != nil && r.Spec.Resources != nil {
 		return fmt.Errorf("one and only one of 'spec.EnvoyResources', 'spec.Resources' must be set")
 	}
+	// a config can be composed only of resources imported from libraries
+	if r.Spec.EnvoyResources == nil && r.Spec.Resources == nil && len(r.Spec.Libraries) == 0 {
+		return fmt.Errorf("one of 'spec.EnvoyResources', 'spec.Resources', 'spec.libraries' must be set")
+	}
+
+	if err := r.ValidateLibraries(); err != nil {
+		return err
+	}
 
 	if r.Spec.EnvoyResources != nil {
 		if err := r.ValidateEnvoyResources(); err != nil {
@@ -81,14 +101,303 @@ func (r *EnvoyConfig) Validate() error {
 		}
 	}
 
+	if err := r.ValidateParameters(); err != nil {
+		return err
+	}
+
+	if err := r.ValidateRolloutStrategy(); err != nil {
+		return err
+	}
+
+	if err := r.ValidateRevisionHistory(); err != nil {
+		return err
+	}
+
+	if r.Spec.PinnedVersion != nil && *r.Spec.PinnedVersion == "" {
+		return fmt.Errorf("'spec.pinnedVersion' cannot be empty")
+	}
+
+	return nil
+}
+
+// unresolvedParameter is used during validation as the value of the parameters
+// that are read from ConfigMaps, as their values are only known at reconcile time
+const unresolvedParameter = "__marin3r_unresolved_parameter__"
+
+// Validate the references to EnvoyResourceLibraries
+func (r *EnvoyConfig) ValidateLibraries() error {
+	names := map[string]bool{}
+	for _, ref := range r.Spec.Libraries {
+		if ref.Name == "" {
+			return fmt.Errorf("'spec.libraries[].name' cannot be empty")
+		}
+		if names[ref.Name] {
+			return fmt.Errorf("library '%s' is imported more than once", ref.Name)
+		}
+		names[ref.Name] = true
+	}
+	return nil
+}
+
+// Validate the declared parameters
+func (r *EnvoyConfig) ValidateParameters() error {
+	if len(r.Spec.Parameters) == 0 {
+		return nil
+	}
+
+	if r.Spec.EnvoyResources != nil {
+		return fmt.Errorf("'spec.parameters' can only be used with 'spec.resources'")
+	}
+
+	errList := []error{}
+	names := map[string]bool{}
+	for _, param := range r.Spec.Parameters {
+		if names[param.Name] {
+			errList = append(errList, fmt.Errorf("duplicated parameter '%s'", param.Name))
+		}
+		names[param.Name] = true
+		if (param.Value == nil && param.ConfigMapKeyRef == nil) || (param.Value != nil && param.ConfigMapKeyRef != nil) {
+			errList = append(errList, fmt.Errorf("one and only one of 'value', 'configMapKeyRef' must be set for parameter '%s'", param.Name))
+		}
+	}
+
+	if len(errList) > 0 {
+		return NewMultiError(errList)
+	}
+	return nil
+}
+
+// renderResourceValue renders the templates in the resource value, if parameters are declared.
+// Returns false if the value references parameters read from ConfigMaps, as their final value
+// is not known at admission time.
+func (r *EnvoyConfig) renderResourceValue(res Resource) (string, bool, error) {
+	if len(r.Spec.Parameters) == 0 {
+		return string(res.Value.Raw), true, nil
+	}
+
+	values := make(map[string]string, len(r.Spec.Parameters))
+	for _, param := range r.Spec.Parameters {
+		if param.Value != nil {
+			values[param.Name] = *param.Value
+		} else {
+			values[param.Name] = unresolvedParameter
+		}
+	}
+	rendered, err := envoy_template.Render(res.Value.Raw, values)
+	if err != nil {
+		return "", false, err
+	}
+	if strings.Contains(string(rendered), unresolvedParameter) {
+		return "", false, nil
+	}
+	return string(rendered), true, nil
+}
+
+// validateResourceValue renders the templates in the resource value, if parameters are
+// declared, and validates the result. Values that reference parameters read from ConfigMaps
+// are only checked to render correctly, as their final value is not known at admission time.
+func (r *EnvoyConfig) validateResourceValue(res Resource, path *field.Path) field.ErrorList {
+	value, resolved, err := r.renderResourceValue(res)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, field.OmitValueType{}, err.Error())}
+	}
+	if !resolved {
+		return nil
+	}
+
+	return envoy_resources.Validate(value, envoy_serializer.JSON, r.GetEnvoyAPIVersion(), envoy.Type(res.Type), path)
+}
+
+// appendFieldErrors appends the field errors to the list of errors
+func appendFieldErrors(errList []error, fieldErrList field.ErrorList) []error {
+	for _, err := range fieldErrList {
+		errList = append(errList, err)
+	}
+	return errList
+}
+
+// ValidateReferences checks the references between the envoy resources of the config and
+// reports the dangling references and the unused resources as warnings or errors, as
+// configured in 'spec.referenceChecks'
+func (r *EnvoyConfig) ValidateReferences() (admission.Warnings, error) {
+	dangling, unused := r.Spec.ReferenceChecks.GetDangling(), r.Spec.ReferenceChecks.GetUnused()
+	if dangling == ReferenceCheckIgnore && unused == ReferenceCheckIgnore {
+		return nil, nil
+	}
+
+	resources, complete := r.decodeResources()
+	// resources imported from libraries or whose values are not known at admission
+	// time might resolve the issues, so these can only be reported as warnings
+	if !complete {
+		if dangling == ReferenceCheckDeny {
+			dangling = ReferenceCheckWarn
+		}
+		if unused == ReferenceCheckDeny {
+			unused = ReferenceCheckWarn
+		}
+	}
+
+	report := envoy_resources.AnalyzeReferences(resources)
+	warnings := admission.Warnings{}
+	errList := []error{}
+	for _, check := range []struct {
+		policy ReferenceCheckPolicy
+		issues []string
+	}{
+		{dangling, report.Dangling},
+		{unused, report.Unused},
+	} {
+		for _, issue := range check.issues {
+			switch check.policy {
+			case ReferenceCheckWarn:
+				warnings = append(warnings, issue)
+			case ReferenceCheckDeny:
+				errList = append(errList, errors.New(issue))
+			}
+		}
+	}
+
+	if len(errList) > 0 {
+		return warnings, NewMultiError(errList)
+	}
+	if len(warnings) == 0 {
+		return nil, nil
+	}
+	return warnings, nil
+}
+
+// decodeResources returns the envoy resources of the config indexed by type. Resources generated
+// from Kubernetes objects only hold their names. Returns false if not all the resources of the
+// config are known, either because they are imported from libraries or their values cannot be
+// decoded at admission time.
+func (r *EnvoyConfig) decodeResources() (map[envoy.Type][]envoy.Resource, bool) {
+	generator := envoy_resources.NewGenerator(r.GetEnvoyAPIVersion())
+	resources := map[envoy.Type][]envoy.Resource{}
+	complete := len(r.Spec.Libraries) == 0
+
+	decode := func(value string, serialization envoy_serializer.Serialization, rType envoy.Type) {
+		res := generator.New(rType)
+		if res == nil || envoy_serializer.NewResourceUnmarshaller(serialization, r.GetEnvoyAPIVersion()).Unmarshal(value, res) != nil {
+			complete = false
+			return
+		}
+		resources[rType] = append(resources[rType], res)
+	}
+
+	if r.Spec.EnvoyResources != nil {
+		for _, group := range []struct {
+			rType     envoy.Type
+			resources []EnvoyResource
+		}{
+			{envoy.Endpoint, r.Spec.EnvoyResources.Endpoints},
+			{envoy.Cluster, r.Spec.EnvoyResources.Clusters},
+			{envoy.Route, r.Spec.EnvoyResources.Routes},
+			{envoy.ScopedRoute, r.Spec.EnvoyResources.ScopedRoutes},
+			{envoy.Listener, r.Spec.EnvoyResources.Listeners},
+			{envoy.Runtime, r.Spec.EnvoyResources.Runtimes},
+		} {
+			for _, res := range group.resources {
+				decode(res.Value, r.GetSerialization(), group.rType)
+			}
+		}
+		for _, secret := range r.Spec.EnvoyResources.Secrets {
+			resources[envoy.Secret] = append(resources[envoy.Secret], generator.NewTlsCertificateSecret(secret.Name, "", ""))
+		}
+		return resources, complete
+	}
+
+	for _, res := range r.Spec.Resources {
+		switch {
+		case res.Type == envoy.Endpoint && res.GenerateFromEndpointSlices != nil:
+			resources[envoy.Endpoint] = append(resources[envoy.Endpoint],
+				generator.NewClusterLoadAssignment(res.GenerateFromEndpointSlices.ClusterName))
+		case res.Type == envoy.Secret && res.GenerateFromTlsSecret != nil:
+			resources[envoy.Secret] = append(resources[envoy.Secret], generator.NewTlsCertificateSecret(*res.GenerateFromTlsSecret, "", ""))
+		case res.Type == envoy.Secret && res.GenerateFromOpaqueSecret != nil:
+			resources[envoy.Secret] = append(resources[envoy.Secret], generator.NewGenericSecret(res.GenerateFromOpaqueSecret.Alias, ""))
+		case res.Value != nil:
+			value, resolved, err := r.renderResourceValue(res)
+			if err != nil || !resolved {
+				complete = false
+				continue
+			}
+			decode(value, envoy_serializer.JSON, envoy.Type(res.Type))
+		}
+	}
+
+	return resources, complete
+}
+
+// Validate the revision history configuration
+func (r *EnvoyConfig) ValidateRevisionHistory() error {
+	rh := r.GetRevisionHistory()
+	if rh == nil {
+		return nil
+	}
+	errList := []error{}
+
+	if rh.Limit != nil && *rh.Limit < 1 {
+		errList = append(errList, fmt.Errorf("'spec.revisionHistory.limit' must be greater than zero"))
+	}
+	if rh.MaxAge != nil && rh.MaxAge.Duration <= 0 {
+		errList = append(errList, fmt.Errorf("'spec.revisionHistory.maxAge' must be greater than zero"))
+	}
+	if tt := rh.TaintThreshold; tt != nil {
+		if tt.FailingPercentage != nil && (*tt.FailingPercentage < 1 || *tt.FailingPercentage > 100) {
+			errList = append(errList, fmt.Errorf("'spec.revisionHistory.taintThreshold.failingPercentage' must be between 1 and 100"))
+		}
+		if tt.NackCount != nil && *tt.NackCount < 1 {
+			errList = append(errList, fmt.Errorf("'spec.revisionHistory.taintThreshold.nackCount' must be greater than zero"))
+		}
+		if tt.CoolDown != nil && tt.CoolDown.Duration <= 0 {
+			errList = append(errList, fmt.Errorf("'spec.revisionHistory.taintThreshold.coolDown' must be greater than zero"))
+		}
+	}
+
+	if len(errList) > 0 {
+		return NewMultiError(errList)
+	}
+	return nil
+}
+
+// Validate the rollout strategy
+func (r *EnvoyConfig) ValidateRolloutStrategy() error {
+	canary := r.GetCanaryRolloutStrategy()
+	if canary == nil {
+		return nil
+	}
+
+	if (canary.Percentage == nil && canary.Selector == nil) || (canary.Percentage != nil && canary.Selector != nil) {
+		return fmt.Errorf("one and only one of 'spec.rolloutStrategy.canary.percentage', 'spec.rolloutStrategy.canary.selector' must be set")
+	}
+	if canary.Percentage != nil && (*canary.Percentage < 1 || *canary.Percentage > 99) {
+		return fmt.Errorf("'spec.rolloutStrategy.canary.percentage' must be between 1 and 99")
+	}
+	if canary.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(canary.Selector); err != nil {
+			return fmt.Errorf("invalid 'spec.rolloutStrategy.canary.selector': %w", err)
+		}
+	}
+	if canary.AnalysisPeriod != nil && canary.AnalysisPeriod.Duration <= 0 {
+		return fmt.Errorf("'spec.rolloutStrategy.canary.analysisPeriod' must be greater than zero")
+	}
+
 	return nil
 }
 
 // Validate Envoy Resources against schema
 func (r *EnvoyConfig) ValidateResources() error {
+	return validateResources(r.Spec.Resources, field.NewPath("spec", "resources"), r.validateResourceValue)
+}
+
+// validateResources validates a list of resources, using validateValue to
+// validate the resource values against the envoy API schema
+func validateResources(resources []Resource, basePath *field.Path,
+	validateValue func(Resource, *field.Path) field.ErrorList) error {
 	errList := []error{}
 
-	for _, res := range r.Spec.Resources {
+	for idx, res := range resources {
+		path := basePath.Index(idx).Child("value")
 
 		switch res.Type {
 
@@ -111,9 +420,7 @@ func (r *EnvoyConfig) ValidateResources() error {
 				errList = append(errList, fmt.Errorf("one of 'generateFromEndpointSlice', 'value' must be set for type '%s'", envoy.Secret))
 			}
 			if res.Value != nil {
-				if err := envoy_resources.Validate(string(res.Value.Raw), envoy_serializer.JSON, r.GetEnvoyAPIVersion(), envoy.Type(res.Type)); err != nil {
-					errList = append(errList, err)
-				}
+				errList = appendFieldErrors(errList, validateValue(res, path))
 			}
 			if res.GenerateFromTlsSecret != nil {
 				errList = append(errList, fmt.Errorf("'generateFromTlsSecret' can only be used type '%s'", envoy.Secret))
@@ -133,9 +440,7 @@ func (r *EnvoyConfig) ValidateResources() error {
 				errList = append(errList, fmt.Errorf("'blueprint' cannot be empty for type '%s'", envoy.Secret))
 			}
 			if res.Value != nil {
-				if err := envoy_resources.Validate(string(res.Value.Raw), envoy_serializer.JSON, r.GetEnvoyAPIVersion(), envoy.Type(res.Type)); err != nil {
-					errList = append(errList, err)
-				}
+				errList = appendFieldErrors(errList, validateValue(res, path))
 			} else {
 				errList = append(errList, fmt.Errorf("'value' cannot be empty for type '%s'", res.Type))
 			}
@@ -152,40 +457,23 @@ func (r *EnvoyConfig) ValidateResources() error {
 // Validate EnvoyResources against schema
 func (r *EnvoyConfig) ValidateEnvoyResources() error {
 	errList := []error{}
-
-	for _, endpoint := range r.Spec.EnvoyResources.Endpoints {
-		if err := envoy_resources.Validate(endpoint.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(), envoy.Endpoint); err != nil {
-			errList = append(errList, err)
-		}
-	}
-
-	for _, cluster := range r.Spec.EnvoyResources.Clusters {
-		if err := envoy_resources.Validate(cluster.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(), envoy.Cluster); err != nil {
-			errList = append(errList, err)
-		}
-	}
-
-	for _, route := range r.Spec.EnvoyResources.Routes {
-		if err := envoy_resources.Validate(route.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(), envoy.Route); err != nil {
-			errList = append(errList, err)
-		}
-	}
-
-	for _, route := range r.Spec.EnvoyResources.ScopedRoutes {
-		if err := envoy_resources.Validate(route.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(), envoy.ScopedRoute); err != nil {
-			errList = append(errList, err)
-		}
-	}
-
-	for _, listener := range r.Spec.EnvoyResources.Listeners {
-		if err := envoy_resources.Validate(listener.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(), envoy.Listener); err != nil {
-			errList = append(errList, err)
-		}
-	}
-
-	for _, runtime := range r.Spec.EnvoyResources.Runtimes {
-		if err := envoy_resources.Validate(runtime.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(), envoy.Runtime); err != nil {
-			errList = append(errList, err)
+	path := field.NewPath("spec", "envoyResources")
+
+	for _, group := range []struct {
+		name      string
+		rType     envoy.Type
+		resources []EnvoyResource
+	}{
+		{"endpoints", envoy.Endpoint, r.Spec.EnvoyResources.Endpoints},
+		{"clusters", envoy.Cluster, r.Spec.EnvoyResources.Clusters},
+		{"routes", envoy.Route, r.Spec.EnvoyResources.Routes},
+		{"scopedRoutes", envoy.ScopedRoute, r.Spec.EnvoyResources.ScopedRoutes},
+		{"listeners", envoy.Listener, r.Spec.EnvoyResources.Listeners},
+		{"runtimes", envoy.Runtime, r.Spec.EnvoyResources.Runtimes},
+	} {
+		for idx, res := range group.resources {
+			errList = appendFieldErrors(errList, envoy_resources.Validate(res.Value, r.GetSerialization(), r.GetEnvoyAPIVersion(),
+				group.rType, path.Child(group.name).Index(idx).Child("value")))
 		}
 	}
 
@@ -17,13 +17,17 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestEnvoyConfig_ValidateResources(t *testing.T) {
@@ -61,6 +65,75 @@ func TestEnvoyConfig_ValidateResources(t *testing.T) {
 				},
 			}, wantErr: true,
 		},
+		{
+			name: "Succeeds: renders templates with the declared parameters",
+			r: &EnvoyConfig{
+				Spec: EnvoyConfigSpec{
+					NodeID: "test",
+					Resources: []Resource{{
+						Type: "cluster",
+						Value: &runtime.RawExtension{
+							Raw: []byte(`{"name":"{{ .name }}","connect_timeout":"{{ .timeout }}"}`),
+						},
+					}},
+					Parameters: []Parameter{
+						{Name: "name", Value: pointer.New("cluster")},
+						{Name: "timeout", Value: pointer.New("2s")},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fails: rendered resource is invalid",
+			r: &EnvoyConfig{
+				Spec: EnvoyConfigSpec{
+					NodeID: "test",
+					Resources: []Resource{{
+						Type: "cluster",
+						Value: &runtime.RawExtension{
+							Raw: []byte(`{"name":"cluster","connect_timeout":"{{ .timeout }}"}`),
+						},
+					}},
+					Parameters: []Parameter{{Name: "timeout", Value: pointer.New("xx")}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fails: template references an undeclared parameter",
+			r: &EnvoyConfig{
+				Spec: EnvoyConfigSpec{
+					NodeID: "test",
+					Resources: []Resource{{
+						Type: "cluster",
+						Value: &runtime.RawExtension{
+							Raw: []byte(`{"name":"{{ .missing }}"}`),
+						},
+					}},
+					Parameters: []Parameter{{Name: "name", Value: pointer.New("cluster")}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Succeeds: skips validation of values that depend on ConfigMaps",
+			r: &EnvoyConfig{
+				Spec: EnvoyConfigSpec{
+					NodeID: "test",
+					Resources: []Resource{{
+						Type: "cluster",
+						Value: &runtime.RawExtension{
+							Raw: []byte(`{"name":"cluster","connect_timeout":"{{ .timeout }}"}`),
+						},
+					}},
+					Parameters: []Parameter{
+						{Name: "timeout", ConfigMapKeyRef: &ConfigMapKeySelector{Name: "cm", Key: "timeout"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "Fails: missing resource value",
 			r: &EnvoyConfig{
@@ -241,6 +314,33 @@ func TestEnvoyConfig_ValidateResources(t *testing.T) {
 	}
 }
 
+func TestEnvoyConfig_ValidateResources_fieldPaths(t *testing.T) {
+	r := &EnvoyConfig{
+		Spec: EnvoyConfigSpec{
+			NodeID: "test",
+			Resources: []Resource{
+				{Type: "cluster", Value: &runtime.RawExtension{Raw: []byte(`{"name":"","connect_timeout":"-1s"}`)}},
+				{Type: "listener", Value: &runtime.RawExtension{
+					Raw: []byte(`{"name":"listener","filter_chains":[{"filters":[{"name":"filter"},{"name":""}]}]}`)}},
+			},
+		},
+	}
+
+	err := r.ValidateResources()
+	if err == nil {
+		t.Fatalf("EnvoyConfig.ValidateResources() expected an error")
+	}
+	for _, path := range []string{
+		"spec.resources[0].value.connect_timeout",
+		"spec.resources[0].value.name",
+		"spec.resources[1].value.filter_chains[0].filters[1].name",
+	} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("EnvoyConfig.ValidateResources() error = %v, missing violation of field %s", err, path)
+		}
+	}
+}
+
 func TestEnvoyConfig_ValidateEnvoyResources(t *testing.T) {
 	type fields struct {
 		TypeMeta   metav1.TypeMeta
@@ -311,6 +411,66 @@ func TestEnvoyConfig_ValidateEnvoyResources(t *testing.T) {
 	}
 }
 
+func TestEnvoyConfig_ValidateReferences(t *testing.T) {
+	resources := []Resource{
+		{Type: "route", Value: &runtime.RawExtension{Raw: []byte(
+			`{"name":"router","virtual_hosts":[{"name":"all","domains":["*"],"routes":[{"match":{"prefix":"/"},"route":{"cluster":"missing"}}]}]}`)}},
+		{Type: "cluster", Value: &runtime.RawExtension{Raw: []byte(`{"name":"unused"}`)}},
+	}
+	dangling := "route 'router' references cluster 'missing', which is not declared"
+	unused := []string{
+		"route 'router' is not referenced by any other resource",
+		"cluster 'unused' is not referenced by any other resource",
+	}
+
+	tests := []struct {
+		name         string
+		spec         EnvoyConfigSpec
+		wantWarnings admission.Warnings
+		wantErr      bool
+	}{
+		{
+			name:         "Warns about dangling references by default",
+			spec:         EnvoyConfigSpec{NodeID: "test", Resources: resources},
+			wantWarnings: admission.Warnings{dangling},
+		},
+		{
+			name: "Ignores all the issues",
+			spec: EnvoyConfigSpec{NodeID: "test", Resources: resources, ReferenceChecks: &ReferenceChecks{
+				Dangling: pointer.New(ReferenceCheckIgnore),
+			}},
+			wantWarnings: nil,
+		},
+		{
+			name: "Denies dangling references and warns about unused resources",
+			spec: EnvoyConfigSpec{NodeID: "test", Resources: resources, ReferenceChecks: &ReferenceChecks{
+				Dangling: pointer.New(ReferenceCheckDeny),
+				Unused:   pointer.New(ReferenceCheckWarn),
+			}},
+			wantWarnings: unused,
+			wantErr:      true,
+		},
+		{
+			name: "Only warns when libraries are imported",
+			spec: EnvoyConfigSpec{NodeID: "test", Resources: resources, Libraries: []LibraryReference{{Name: "lib"}},
+				ReferenceChecks: &ReferenceChecks{Dangling: pointer.New(ReferenceCheckDeny)}},
+			wantWarnings: admission.Warnings{dangling},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &EnvoyConfig{Spec: tt.spec}
+			warnings, err := r.ValidateReferences()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EnvoyConfig.ValidateReferences() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("EnvoyConfig.ValidateReferences() warnings = %v, want %v", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
 func TestEnvoyConfig_Validate(t *testing.T) {
 	type fields struct {
 		TypeMeta   metav1.TypeMeta
@@ -338,6 +498,21 @@ func TestEnvoyConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Fail, invalid nodeID",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID: "test#canary",
+					Resources: []Resource{{
+						Type: "cluster",
+						Value: &runtime.RawExtension{
+							Raw: []byte(`{"name":"cluster1","type":"STRICT_DNS","connect_timeout":"2s","load_assignment":{"cluster_name":"cluster1"}}`),
+						},
+					}},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Ok, using spec.Resources",
 			fields: fields{
@@ -364,6 +539,156 @@ func TestEnvoyConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Ok, canary rollout strategy",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					RolloutStrategy: &RolloutStrategy{
+						Canary: &CanaryRolloutStrategy{
+							Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"canary": "true"}},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fail, canary rollout strategy with percentage and selector",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					RolloutStrategy: &RolloutStrategy{
+						Canary: &CanaryRolloutStrategy{
+							Percentage: pointer.New(int32(10)),
+							Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"canary": "true"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fail, canary rollout strategy with invalid percentage",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					RolloutStrategy: &RolloutStrategy{
+						Canary: &CanaryRolloutStrategy{
+							Percentage: pointer.New(int32(100)),
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Ok, revision history",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					RevisionHistory: &RevisionHistory{
+						Limit:  pointer.New(int32(5)),
+						MaxAge: &metav1.Duration{Duration: 24 * time.Hour},
+						TaintThreshold: &TaintThreshold{
+							FailingPercentage: pointer.New(int32(50)),
+							NackCount:         pointer.New(int32(3)),
+							CoolDown:          &metav1.Duration{Duration: time.Hour},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fail, invalid revision history",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					RevisionHistory: &RevisionHistory{
+						Limit: pointer.New(int32(0)),
+						TaintThreshold: &TaintThreshold{
+							FailingPercentage: pointer.New(int32(101)),
+							CoolDown:          &metav1.Duration{Duration: 0},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Ok, pinned version",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:        "test",
+					Resources:     []Resource{},
+					PinnedVersion: pointer.New("6758fd786c"),
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fail, empty pinned version",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:        "test",
+					Resources:     []Resource{},
+					PinnedVersion: pointer.New(""),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fail, duplicated parameter",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					Parameters: []Parameter{
+						{Name: "name", Value: pointer.New("a")},
+						{Name: "name", Value: pointer.New("b")},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fail, parameter without value",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:     "test",
+					Resources:  []Resource{},
+					Parameters: []Parameter{{Name: "name"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Ok, resources imported from libraries",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Libraries: []LibraryReference{{Name: "clusters"}, {Name: "listeners"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fail, library imported more than once",
+			fields: fields{
+				Spec: EnvoyConfigSpec{
+					NodeID:    "test",
+					Resources: []Resource{},
+					Libraries: []LibraryReference{{Name: "clusters"}, {Name: "clusters"}},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Fail, must use one of EnvoyResources, Resources",
 			fields: fields{
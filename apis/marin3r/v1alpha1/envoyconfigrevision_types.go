@@ -17,9 +17,13 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"sort"
+	"time"
+
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -38,6 +42,43 @@ const (
 	// problems have been observed with this revision and should not be published
 	RevisionTaintedCondition string = "RevisionTainted"
 
+	// RevisionCanaryCondition is a condition that marks the EnvoyConfigRevision object
+	// as the one being served to a subset of the envoy clients as a canary
+	RevisionCanaryCondition string = "RevisionCanary"
+
+	/* Defaults */
+
+	// DefaultTaintFailingPercentage is the default percentage of envoy clients
+	// that need to reject a revision for it to be tainted
+	DefaultTaintFailingPercentage int32 = 100
+
+	// DefaultTaintNackCount is the default number of NACKs an envoy client needs
+	// to report for a given version for the client to be considered failing
+	DefaultTaintNackCount int32 = 5
+
+	// MaxTaintHistory is the maximum number of taint events kept
+	// in the status of an EnvoyConfigRevision
+	MaxTaintHistory int = 10
+
+	// MaxRecentRejections is the maximum number of rejection reasons
+	// kept in the status of an EnvoyConfigRevision
+	MaxRecentRejections int = 5
+
+	/* Taint events */
+
+	// TaintedEvent records that the revision was tainted
+	TaintedEvent string = "Tainted"
+
+	// UntaintedEvent records that the taint of the revision was removed
+	UntaintedEvent string = "Untainted"
+
+	/* Annotations */
+
+	// RevisionUntaintAnnotation is an annotation that, when set in an EnvoyConfigRevision,
+	// clears its taint and resets the failure statistics of its version. The annotation
+	// is removed once processed.
+	RevisionUntaintAnnotation string = "marin3r.3scale.net/untaint"
+
 	/* Finalizers */
 
 	// EnvoyConfigRevisionFinalizer is the finalizer for EnvoyConfig objects
@@ -73,6 +114,42 @@ type EnvoyConfigRevisionSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	Resources []Resource `json:"resources,omitempty"`
+	// Parameters declares values that can be referenced from the string values
+	// of the resources using Go template syntax
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty"`
+	// TaintThreshold configures when the revision gets tainted due to being
+	// rejected by the envoy clients
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	TaintThreshold *TaintThreshold `json:"taintThreshold,omitempty"`
+}
+
+// TaintThreshold configures when a revision gets tainted due to being
+// rejected by the envoy clients
+type TaintThreshold struct {
+	// FailingPercentage is the percentage of envoy clients that need to reject
+	// a revision for it to be tainted. Defaults to 100.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	FailingPercentage *int32 `json:"failingPercentage,omitempty"`
+	// NackCount is the number of times an envoy client needs to reject a revision
+	// for the client to be considered failing. Defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	NackCount *int32 `json:"nackCount,omitempty"`
+	// CoolDown is the time a revision stays tainted before the taint is re-evaluated.
+	// Once the cool-down expires, the failure statistics of the revision are reset and
+	// the taint is removed, so the revision can be published again and gets tainted
+	// again only if the envoy clients keep rejecting it. Tainted revisions are never
+	// untainted automatically if unset.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	CoolDown *metav1.Duration `json:"coolDown,omitempty"`
 }
 
 // EnvoyConfigRevisionStatus defines the observed state of EnvoyConfigRevision
@@ -97,12 +174,87 @@ type EnvoyConfigRevisionStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	// +optional
 	Tainted *bool `json:"tainted,omitempty"`
+	// Canary selects the envoy clients this revision is served to while
+	// it is being published as a canary
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	Canary *CanaryTarget `json:"canary,omitempty"`
+	// TaintHistory is the list of the most recent times the revision has been
+	// tainted and untainted, oldest first
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	TaintHistory []TaintEvent `json:"taintHistory,omitempty"`
+	// RecentRejections is the list of the most recent reasons given by the envoy
+	// clients for rejecting the resources of this revision, most recent first
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	RecentRejections []Rejection `json:"recentRejections,omitempty"`
 	// Conditions represent the latest available observations of an object's state
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// CanaryTarget selects the envoy clients that a canary revision is served to
+type CanaryTarget struct {
+	// Percentage of the envoy clients that receive the canary revision
+	// +optional
+	Percentage *int32 `json:"percentage,omitempty"`
+	// Pods is the list of Pods that receive the canary revision. Takes precedence
+	// over Percentage.
+	// +optional
+	Pods []string `json:"pods,omitempty"`
+}
+
+// TaintEvent records a change in the taint of a revision
+type TaintEvent struct {
+	// Type is the type of event, either "Tainted" or "Untainted"
+	Type string `json:"type"`
+	// Reason is a brief CamelCase explanation of the event
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable explanation of the event
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Time is the time at which the event occurred
+	Time metav1.Time `json:"time"`
+}
+
+// Rejection is a reason given by the envoy clients for rejecting
+// the resources of a revision
+type Rejection struct {
+	// Type is the type of the rejected resources
+	Type envoy.Type `json:"type"`
+	// Code is the gRPC status code reported by the envoy clients
+	// +optional
+	Code int32 `json:"code,omitempty"`
+	// Message is the error message reported by the envoy clients
+	Message string `json:"message"`
+	// Pods is the list of Pods that reported the error
+	Pods []string `json:"pods"`
+	// LastSeen is the last time the error was reported
+	LastSeen metav1.Time `json:"lastSeen"`
+}
+
+// RecordTaintEvent appends an event to the taint history, keeping
+// only the most recent MaxTaintHistory events
+func (status *EnvoyConfigRevisionStatus) RecordTaintEvent(eventType, reason, msg string, t time.Time) {
+	status.TaintHistory = append(status.TaintHistory, TaintEvent{
+		Type:    eventType,
+		Reason:  reason,
+		Message: msg,
+		Time:    metav1.NewTime(t),
+	})
+	if len(status.TaintHistory) > MaxTaintHistory {
+		status.TaintHistory = status.TaintHistory[len(status.TaintHistory)-MaxTaintHistory:]
+	}
+}
+
+// IsCanary returns true if this revision is being served as a canary, false otherwise
+func (status *EnvoyConfigRevisionStatus) IsCanary() bool {
+	return meta.IsStatusConditionTrue(status.Conditions, RevisionCanaryCondition) && status.Canary != nil
+}
+
 // IsPublished returns true if this revision is published, false otherwise
 func (status *EnvoyConfigRevisionStatus) IsPublished() bool {
 	if status.Published == nil {
@@ -130,6 +282,115 @@ type VersionTracker struct {
 	Secrets          string `json:"secrets,omitempty"`
 	Runtimes         string `json:"runtimes,omitempty"`
 	ExtensionConfigs string `json:"extensionConfigs,omitempty"`
+	// Resources tracks the version of each individual resource, indexed by resource
+	// type and resource name. The types listed in Untracked are not included.
+	// +optional
+	Resources map[envoy.Type]map[string]string `json:"resources,omitempty"`
+	// Untracked lists the resource types whose resources are not individually
+	// tracked in Resources because there are too many of them.
+	// +optional
+	Untracked []envoy.Type `json:"untracked,omitempty"`
+}
+
+// MaxTrackedResourceVersions is the max number of resources of a type whose
+// versions are individually tracked in the VersionTracker, so the size of
+// the status does not grow unbounded with the number of resources
+const MaxTrackedResourceVersions int = 100
+
+// ChangedResources returns the names of the resources that differ between
+// this VersionTracker and the given one, indexed by resource type. A resource
+// is considered changed if its version is different or if it is only present
+// in one of the VersionTrackers. Types that are not individually tracked in
+// any of the VersionTrackers are skipped, use UntrackedChanges to get them.
+func (vt *VersionTracker) ChangedResources(other *VersionTracker) map[envoy.Type][]string {
+	changed := map[envoy.Type][]string{}
+
+	var a, b map[envoy.Type]map[string]string
+	if vt != nil {
+		a = vt.Resources
+	}
+	if other != nil {
+		b = other.Resources
+	}
+
+	for _, rType := range trackedTypes {
+		if vt.isUntracked(rType) || other.isUntracked(rType) {
+			continue
+		}
+		names := []string{}
+		for name, version := range a[rType] {
+			if v, ok := b[rType][name]; !ok || v != version {
+				names = append(names, name)
+			}
+		}
+		for name := range b[rType] {
+			if _, ok := a[rType][name]; !ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			changed[rType] = names
+		}
+	}
+
+	return changed
+}
+
+// UntrackedChanges returns the resource types whose version differs between
+// this VersionTracker and the given one but that are not individually tracked
+// in any of them, so their changed resources cannot be reported by name.
+func (vt *VersionTracker) UntrackedChanges(other *VersionTracker) []envoy.Type {
+	changed := []envoy.Type{}
+	for _, rType := range trackedTypes {
+		if (vt.isUntracked(rType) || other.isUntracked(rType)) && vt.version(rType) != other.version(rType) {
+			changed = append(changed, rType)
+		}
+	}
+	return changed
+}
+
+var trackedTypes = []envoy.Type{envoy.Endpoint, envoy.Cluster, envoy.Route, envoy.ScopedRoute,
+	envoy.Listener, envoy.Secret, envoy.Runtime, envoy.ExtensionConfig}
+
+// isUntracked returns true if the versions of the resources of
+// the given type were not tracked because there were too many
+func (vt *VersionTracker) isUntracked(rType envoy.Type) bool {
+	if vt == nil {
+		return false
+	}
+	for _, t := range vt.Untracked {
+		if t == rType {
+			return true
+		}
+	}
+	return false
+}
+
+// version returns the version of the given resource type
+func (vt *VersionTracker) version(rType envoy.Type) string {
+	if vt == nil {
+		return ""
+	}
+	switch rType {
+	case envoy.Endpoint:
+		return vt.Endpoints
+	case envoy.Cluster:
+		return vt.Clusters
+	case envoy.Route:
+		return vt.Routes
+	case envoy.ScopedRoute:
+		return vt.ScopedRoutes
+	case envoy.Listener:
+		return vt.Listeners
+	case envoy.Secret:
+		return vt.Secrets
+	case envoy.Runtime:
+		return vt.Runtimes
+	case envoy.ExtensionConfig:
+		return vt.ExtensionConfigs
+	}
+	return ""
 }
 
 // +kubebuilder:object:root=true
@@ -163,6 +424,33 @@ func (ecr *EnvoyConfigRevision) GetEnvoyAPIVersion() envoy.APIVersion {
 	return envoy.APIVersion(*ecr.Spec.EnvoyAPI)
 }
 
+// GetTaintFailingPercentage returns the fraction of envoy clients that need
+// to reject the revision for it to be tainted
+func (ecr *EnvoyConfigRevision) GetTaintFailingPercentage() float64 {
+	if ecr.Spec.TaintThreshold == nil || ecr.Spec.TaintThreshold.FailingPercentage == nil {
+		return float64(DefaultTaintFailingPercentage) / 100
+	}
+	return float64(*ecr.Spec.TaintThreshold.FailingPercentage) / 100
+}
+
+// GetTaintNackCount returns the number of NACKs an envoy client needs to
+// report for the revision for the client to be considered failing
+func (ecr *EnvoyConfigRevision) GetTaintNackCount() int64 {
+	if ecr.Spec.TaintThreshold == nil || ecr.Spec.TaintThreshold.NackCount == nil {
+		return int64(DefaultTaintNackCount)
+	}
+	return int64(*ecr.Spec.TaintThreshold.NackCount)
+}
+
+// GetTaintCoolDown returns the time the revision stays tainted before the taint
+// is re-evaluated. A zero value means that the taint is never re-evaluated.
+func (ecr *EnvoyConfigRevision) GetTaintCoolDown() time.Duration {
+	if ecr.Spec.TaintThreshold == nil || ecr.Spec.TaintThreshold.CoolDown == nil {
+		return 0
+	}
+	return ecr.Spec.TaintThreshold.CoolDown.Duration
+}
+
 // GetSerialization returns the encoding of the envoy resources.
 func (ecr *EnvoyConfigRevision) GetSerialization() envoy_serializer.Serialization {
 	if ecr.Spec.Serialization == nil {
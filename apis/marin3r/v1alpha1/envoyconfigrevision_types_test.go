@@ -17,11 +17,15 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestEnvoyConfigRevisionStatus_IsPublished(t *testing.T) {
@@ -159,3 +163,141 @@ func TestEnvoyConfigRevision_GetSerialization(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionTracker_ChangedResources(t *testing.T) {
+	cases := []struct {
+		testName       string
+		a              *VersionTracker
+		b              *VersionTracker
+		expectedResult map[envoy.Type][]string
+	}{
+		{"No changes",
+			&VersionTracker{Resources: map[envoy.Type]map[string]string{envoy.Cluster: {"c1": "aaaa"}}},
+			&VersionTracker{Resources: map[envoy.Type]map[string]string{envoy.Cluster: {"c1": "aaaa"}}},
+			map[envoy.Type][]string{},
+		},
+		{"Modified, added and removed resources",
+			&VersionTracker{Resources: map[envoy.Type]map[string]string{
+				envoy.Cluster:  {"c1": "aaaa", "c2": "bbbb"},
+				envoy.Endpoint: {"e1": "aaaa"},
+			}},
+			&VersionTracker{Resources: map[envoy.Type]map[string]string{
+				envoy.Cluster:  {"c1": "aaaa", "c2": "cccc"},
+				envoy.Listener: {"l1": "aaaa"},
+			}},
+			map[envoy.Type][]string{
+				envoy.Cluster:  {"c2"},
+				envoy.Endpoint: {"e1"},
+				envoy.Listener: {"l1"},
+			},
+		},
+		{"Skips types that are not individually tracked",
+			&VersionTracker{
+				Resources: map[envoy.Type]map[string]string{envoy.Cluster: {"c1": "aaaa"}},
+				Untracked: []envoy.Type{envoy.Endpoint},
+			},
+			&VersionTracker{Resources: map[envoy.Type]map[string]string{
+				envoy.Cluster:  {"c1": "bbbb"},
+				envoy.Endpoint: {"e1": "aaaa"},
+			}},
+			map[envoy.Type][]string{envoy.Cluster: {"c1"}},
+		},
+		{"Compared with nil",
+			&VersionTracker{Resources: map[envoy.Type]map[string]string{envoy.Cluster: {"c1": "aaaa"}}},
+			nil,
+			map[envoy.Type][]string{envoy.Cluster: {"c1"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			receivedResult := tc.a.ChangedResources(tc.b)
+			if !reflect.DeepEqual(receivedResult, tc.expectedResult) {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, receivedResult)
+			}
+		})
+	}
+}
+
+func TestVersionTracker_UntrackedChanges(t *testing.T) {
+	cases := []struct {
+		testName       string
+		a              *VersionTracker
+		b              *VersionTracker
+		expectedResult []envoy.Type
+	}{
+		{"Untracked type changed",
+			&VersionTracker{Endpoints: "aaaa", Clusters: "aaaa", Untracked: []envoy.Type{envoy.Endpoint}},
+			&VersionTracker{Endpoints: "bbbb", Clusters: "bbbb", Resources: map[envoy.Type]map[string]string{
+				envoy.Endpoint: {"e1": "aaaa"},
+				envoy.Cluster:  {"c1": "aaaa"},
+			}},
+			[]envoy.Type{envoy.Endpoint},
+		},
+		{"Untracked type did not change",
+			&VersionTracker{Endpoints: "aaaa", Untracked: []envoy.Type{envoy.Endpoint}},
+			&VersionTracker{Endpoints: "aaaa", Untracked: []envoy.Type{envoy.Endpoint}},
+			[]envoy.Type{},
+		},
+		{"Compared with nil",
+			&VersionTracker{Endpoints: "aaaa", Untracked: []envoy.Type{envoy.Endpoint}},
+			nil,
+			[]envoy.Type{envoy.Endpoint},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			receivedResult := tc.a.UntrackedChanges(tc.b)
+			if !reflect.DeepEqual(receivedResult, tc.expectedResult) {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, receivedResult)
+			}
+		})
+	}
+}
+
+func TestEnvoyConfigRevisionStatus_RecordTaintEvent(t *testing.T) {
+	now := time.Now()
+	history := func(n int) []TaintEvent {
+		events := []TaintEvent{}
+		for i := 0; i < n; i++ {
+			events = append(events, TaintEvent{Type: TaintedEvent, Reason: fmt.Sprintf("reason-%d", i), Time: metav1.NewTime(now)})
+		}
+		return events
+	}
+
+	tests := []struct {
+		name    string
+		history []TaintEvent
+		want    int
+		wantIdx string
+	}{
+		{
+			name:    "Appends the event",
+			history: history(1),
+			want:    2,
+			wantIdx: "reason-0",
+		},
+		{
+			name:    "Trims the history to MaxTaintHistory events",
+			history: history(MaxTaintHistory),
+			want:    MaxTaintHistory,
+			wantIdx: "reason-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &EnvoyConfigRevisionStatus{TaintHistory: tt.history}
+			status.RecordTaintEvent(UntaintedEvent, "new", "msg", now)
+			if got := len(status.TaintHistory); got != tt.want {
+				t.Fatalf("EnvoyConfigRevisionStatus.RecordTaintEvent() got %v events, want %v", got, tt.want)
+			}
+			if got := status.TaintHistory[0].Reason; got != tt.wantIdx {
+				t.Errorf("EnvoyConfigRevisionStatus.RecordTaintEvent() oldest event = %v, want %v", got, tt.wantIdx)
+			}
+			if got := status.TaintHistory[len(status.TaintHistory)-1]; got.Type != UntaintedEvent || got.Reason != "new" {
+				t.Errorf("EnvoyConfigRevisionStatus.RecordTaintEvent() newest event = %v", got)
+			}
+		})
+	}
+}
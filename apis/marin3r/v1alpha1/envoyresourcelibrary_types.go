@@ -0,0 +1,63 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnvoyResourceLibrarySpec defines the desired state of EnvoyResourceLibrary
+type EnvoyResourceLibrarySpec struct {
+	// Resources holds the envoy resources that the library provides to
+	// the EnvoyConfigs that import it
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Resources []Resource `json:"resources"`
+}
+
+// LibraryReference is a reference to an EnvoyResourceLibrary
+type LibraryReference struct {
+	// Name is the name of the EnvoyResourceLibrary in the same namespace
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+
+// EnvoyResourceLibrary holds a set of envoy resources, like common clusters, filters or
+// runtime layers, that can be imported from any EnvoyConfig in the same namespace. Changes
+// to a library generate new revisions for all the EnvoyConfigs that import it.
+// +kubebuilder:resource:path=envoyresourcelibraries,scope=Namespaced,shortName=erl
+// +operator-sdk:csv:customresourcedefinitions:displayName="EnvoyResourceLibrary"
+type EnvoyResourceLibrary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec EnvoyResourceLibrarySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// EnvoyResourceLibraryList contains a list of EnvoyResourceLibrary
+type EnvoyResourceLibraryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []EnvoyResourceLibrary `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&EnvoyResourceLibrary{}, &EnvoyResourceLibraryList{})
+}
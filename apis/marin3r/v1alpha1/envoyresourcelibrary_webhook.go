@@ -0,0 +1,72 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+
+	"github.com/3scale-ops/basereconciler/util"
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (r *EnvoyResourceLibrary) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-marin3r-3scale-net-v1alpha1-envoyresourcelibrary,mutating=false,failurePolicy=fail,sideEffects=None,groups=marin3r.3scale.net,resources=envoyresourcelibraries,verbs=create;update,versions=v1alpha1,name=envoyresourcelibrary.marin3r.3scale.net-v1alpha1,admissionReviewVersions=v1
+
+var _ webhook.Validator = &EnvoyResourceLibrary{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *EnvoyResourceLibrary) ValidateCreate() (admission.Warnings, error) {
+	validationlog.Info("ValidateCreate", "type", "EnvoyResourceLibrary", "resource", util.ObjectKey(r).String())
+	return nil, r.Validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *EnvoyResourceLibrary) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	validationlog.Info("validateUpdate", "type", "EnvoyResourceLibrary", "resource", util.ObjectKey(r).String())
+	return nil, r.Validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *EnvoyResourceLibrary) ValidateDelete() (admission.Warnings, error) { return nil, nil }
+
+// Validates the EnvoyResourceLibrary resource. The resources of a library are not
+// validated by the webhook of the EnvoyConfigs that import it.
+func (r *EnvoyResourceLibrary) Validate() error {
+	return validateResources(r.Spec.Resources, field.NewPath("spec", "resources"), r.validateResourceValue)
+}
+
+// validateResourceValue validates the resource value against the envoy API schema. Values
+// that contain templates are rendered with the parameters of the EnvoyConfigs that import
+// the library, so they can only be validated within each EnvoyConfig.
+func (r *EnvoyResourceLibrary) validateResourceValue(res Resource, path *field.Path) field.ErrorList {
+	if bytes.Contains(res.Value.Raw, []byte("{{")) {
+		return nil
+	}
+	return envoy_resources.Validate(string(res.Value.Raw), envoy_serializer.JSON, envoy.APIv3, envoy.Type(res.Type), path)
+}
@@ -0,0 +1,67 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestEnvoyResourceLibrary_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       *EnvoyResourceLibrary
+		wantErr bool
+	}{
+		{
+			name: "Succeeds: valid resources",
+			r: &EnvoyResourceLibrary{
+				Spec: EnvoyResourceLibrarySpec{
+					Resources: []Resource{
+						{Type: "cluster", Value: &runtime.RawExtension{Raw: []byte(`{"name":"cluster"}`)}},
+						{Type: "secret", GenerateFromTlsSecret: pointer.New("secret")},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fails: invalid resource value",
+			r: &EnvoyResourceLibrary{
+				Spec: EnvoyResourceLibrarySpec{
+					Resources: []Resource{
+						{Type: "cluster", Value: &runtime.RawExtension{Raw: []byte(`{"name":"cluster","connect_timeout":"xx"}`)}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fails: value missing",
+			r: &EnvoyResourceLibrary{
+				Spec: EnvoyResourceLibrarySpec{
+					Resources: []Resource{{Type: "listener"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Succeeds: values with templates are not validated against the schema",
+			r: &EnvoyResourceLibrary{
+				Spec: EnvoyResourceLibrarySpec{
+					Resources: []Resource{
+						{Type: "cluster", Value: &runtime.RawExtension{Raw: []byte(`{"name":"cluster","connect_timeout":"{{ .timeout }}"}`)}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.r.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("EnvoyResourceLibrary.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
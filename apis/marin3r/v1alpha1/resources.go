@@ -89,6 +89,31 @@ type SecretKeySelector struct {
 	Alias string `json:"alias"`
 }
 
+// Parameter declares a value that can be referenced from the string
+// values of the resources using Go template syntax, e.g. "{{ .name }}"
+type Parameter struct {
+	// Name is the name of the parameter
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Name string `json:"name"`
+	// Value is a literal value for the parameter
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Value *string `json:"value,omitempty"`
+	// ConfigMapKeyRef selects a key of a ConfigMap in the same
+	// namespace that holds the value for the parameter
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	ConfigMapKeyRef *ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+type ConfigMapKeySelector struct {
+	// The name of the ConfigMap in the same namespace to select from.
+	Name string `json:"name"`
+	// The key of the ConfigMap to select from.
+	Key string `json:"key"`
+}
+
 type GenerateFromEndpointSlices struct {
 	Selector    *metav1.LabelSelector `json:"selector"`
 	ClusterName string                `json:"clusterName"`
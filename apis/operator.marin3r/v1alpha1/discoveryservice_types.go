@@ -36,6 +36,22 @@ const (
 	// DiscoveryServiceCertificateHashLabelKey is the label in the discovery service Deployment that
 	// stores the hash of the current server certificate
 	DiscoveryServiceCertificateHashLabelKey string = "marin3r.3scale.net/server-certificate-hash"
+	// RootCARotationAnnotation is the annotation that requests a rotation of the root CA
+	// of a DiscoveryService. It is removed once the rotation starts.
+	RootCARotationAnnotation string = "marin3r.3scale.net/rotate-root-ca"
+
+	/* Root CA rotation conditions */
+
+	// NewRootCAIssuedCondition indicates that the new root CA has been issued
+	NewRootCAIssuedCondition string = "NewRootCAIssued"
+	// RootCABundlePublishedCondition indicates that the bundle with the old and
+	// new roots is trusted by the discovery service
+	RootCABundlePublishedCondition string = "RootCABundlePublished"
+	// CertificatesReissuedCondition indicates that all the certificates
+	// signed by the root CA have been reissued under the new root
+	CertificatesReissuedCondition string = "CertificatesReissued"
+	// OldRootCARetiredCondition indicates that the old root CA is no longer trusted
+	OldRootCARetiredCondition string = "OldRootCARetired"
 
 	/* Default values */
 
@@ -43,6 +59,8 @@ const (
 	DefaultMetricsPort uint32 = 8383
 	// DefaultProbePort is the default port where the probe server listens
 	DefaultProbePort uint32 = 8384
+	// DefaultDiscoveryServiceReplicas is the default number of replicas of the discovery service
+	DefaultDiscoveryServiceReplicas int32 = 1
 	// DefaultXdsServerPort is the default port where the discovery service xds server port listens
 	DefaultXdsServerPort uint32 = 18000
 	// DefaultRootCertificateDuration is the default root CA certificate duration
@@ -111,6 +129,42 @@ type DiscoveryServiceSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	PodPriorityClass *string `json:"podPriorityClass,omitempty"`
+	// EnforceNodeIDBinding makes the discovery service reject the requests of envoy clients
+	// whose client certificate is not valid for the node ID they request configuration for.
+	// When disabled, these requests are only reported in the metrics and logs. Defaults to false.
+	// Always enforced when WatchNamespaces is set, as the namespace of an envoy client can only
+	// be verified through its certificate.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	EnforceNodeIDBinding *bool `json:"enforceNodeIDBinding,omitempty"`
+	// Replicas is the number of replicas of the discovery service Deployment. When more
+	// than one replica is configured, the replicas share the discovery stats of their envoy
+	// clients and a leader is elected to write the status of the EnvoyConfigRevisions.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// WatchNamespaces configures additional namespaces, other than the one of the DiscoveryService,
+	// where the discovery service serves EnvoyConfigs from. When set, the nodeIDs are namespaced to
+	// prevent collisions between the EnvoyConfigs of different namespaces: the envoy clients are served
+	// the config of the given nodeID in their own namespace.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	WatchNamespaces *WatchNamespacesConfig `json:"watchNamespaces,omitempty"`
+}
+
+// WatchNamespacesConfig selects the namespaces a discovery service serves EnvoyConfigs from
+type WatchNamespacesConfig struct {
+	// Names is a list of namespaces
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Names []string `json:"names,omitempty"`
+	// Selector selects namespaces by label. An empty selector selects all the
+	// namespaces of the cluster.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 }
 
 // DiscoveryServiceStatus defines the observed state of DiscoveryService
@@ -121,10 +175,50 @@ type DiscoveryServiceStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	*appsv1.DeploymentStatus `json:"deploymentStatus,omitempty"`
+	// RootCARotation holds the state of the root CA rotation in progress, if any
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	RootCARotation *RootCARotationStatus `json:"rootCARotation,omitempty"`
+	// Conditions represent the latest available observations of the DiscoveryService,
+	// including the phases of the root CA rotations
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 	// internal fields
 	reconciler.UnimplementedStatefulSetStatus `json:"-"`
 }
 
+// RootCARotationPhase is the phase of a root CA rotation
+type RootCARotationPhase string
+
+const (
+	// RootCARotationIssuing is the phase where the new root CA is issued
+	RootCARotationIssuing RootCARotationPhase = "IssuingNewRoot"
+	// RootCARotationPublishing is the phase where a bundle with both the old and new roots is
+	// published to the discovery service, so it trusts certificates issued by any of them
+	RootCARotationPublishing RootCARotationPhase = "PublishingBundle"
+	// RootCARotationReissuing is the phase where the new root replaces the old one and all
+	// the server and client certificates are reissued under the new root
+	RootCARotationReissuing RootCARotationPhase = "ReissuingCertificates"
+	// RootCARotationRetiring is the phase where clients pick up their new certificates
+	// before the old root is removed from the bundle
+	RootCARotationRetiring RootCARotationPhase = "RetiringOldRoot"
+)
+
+// RootCARotationStatus holds the state of a root CA rotation
+type RootCARotationStatus struct {
+	// Phase is the current phase of the rotation
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Phase RootCARotationPhase `json:"phase"`
+	// PhaseStartTime is the time when the current phase started
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PhaseStartTime metav1.Time `json:"phaseStartTime"`
+	// PreviousRootCertificate holds the PEM encoded certificate of the
+	// root CA being rotated, which is trusted until the rotation completes
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PreviousRootCertificate string `json:"previousRootCertificate"`
+}
+
 func (dss *DiscoveryServiceStatus) GetDeploymentStatus(key types.NamespacedName) *appsv1.DeploymentStatus {
 	return dss.DeploymentStatus
 }
@@ -140,6 +234,13 @@ type PKIConfig struct {
 	RootCertificateAuthority *CertificateOptions `json:"rootCertificateAuthority"`
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	ServerCertificate *CertificateOptions `json:"serverCertificate"`
+	// PrivateKey configures the algorithm and size of the private keys of all the
+	// certificates of the PKI. If unset, 2048 bits RSA keys are used. Ed25519 keys
+	// are not supported as envoy cannot load them.
+	// +kubebuilder:validation:XValidation:rule="!has(self.algorithm) || self.algorithm != 'Ed25519'",message="Ed25519 keys are not supported by envoy"
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	PrivateKey *PrivateKeyConfig `json:"privateKey,omitempty"`
 }
 
 // CertificateOptions specifies options to generate the server certificate used both
@@ -211,6 +312,32 @@ func (d *DiscoveryService) Debug() bool {
 	return *d.Spec.Debug
 }
 
+// NodeIDBindingEnforced returns a boolean value that indicates if the discovery service
+// rejects clients whose certificate is not valid for the requested node ID
+func (d *DiscoveryService) NodeIDBindingEnforced() bool {
+	if d.IsMultiNamespace() {
+		return true
+	}
+	if d.Spec.EnforceNodeIDBinding == nil {
+		return false
+	}
+	return *d.Spec.EnforceNodeIDBinding
+}
+
+// GetReplicas returns the number of replicas of the discovery service
+func (d *DiscoveryService) GetReplicas() int32 {
+	if d.Spec.Replicas != nil {
+		return *d.Spec.Replicas
+	}
+	return DefaultDiscoveryServiceReplicas
+}
+
+// IsMultiNamespace returns true if the discovery service serves
+// EnvoyConfigs from other namespaces than its own
+func (d *DiscoveryService) IsMultiNamespace() bool {
+	return d.Spec.WatchNamespaces != nil
+}
+
 func (d *DiscoveryService) defaultDeploymentResources() corev1.ResourceRequirements {
 	return corev1.ResourceRequirements{}
 }
@@ -234,6 +361,15 @@ func (d *DiscoveryService) defaultRootCertificateAuthorityOptions() *Certificate
 		}}
 }
 
+// GetPrivateKeyConfig returns the configuration of the private keys of the
+// certificates of the PKI. Returns nil if unset.
+func (d *DiscoveryService) GetPrivateKeyConfig() *PrivateKeyConfig {
+	if d.Spec.PKIConfig != nil {
+		return d.Spec.PKIConfig.PrivateKey
+	}
+	return nil
+}
+
 // GetServerCertificateOptions returns the CertificateOptions for the root CA
 func (d *DiscoveryService) GetServerCertificateOptions() *CertificateOptions {
 	if d.Spec.PKIConfig != nil && d.Spec.PKIConfig.ServerCertificate != nil {
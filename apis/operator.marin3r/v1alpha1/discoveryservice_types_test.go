@@ -322,3 +322,116 @@ func TestDiscoveryService_Debug(t *testing.T) {
 		})
 	}
 }
+
+func TestDiscoveryService_NodeIDBindingEnforced(t *testing.T) {
+	cases := []struct {
+		testName                string
+		discoveryServiceFactory func() *DiscoveryService
+		expectedResult          bool
+	}{
+		{"With default",
+			func() *DiscoveryService {
+				return &DiscoveryService{}
+			},
+			false,
+		},
+		{"With explicitly set value",
+			func() *DiscoveryService {
+				return &DiscoveryService{
+					Spec: DiscoveryServiceSpec{
+						EnforceNodeIDBinding: pointer.New(true),
+					},
+				}
+			},
+			true,
+		},
+		{"Always enforced in multi-namespace mode",
+			func() *DiscoveryService {
+				return &DiscoveryService{
+					Spec: DiscoveryServiceSpec{
+						EnforceNodeIDBinding: pointer.New(false),
+						WatchNamespaces:      &WatchNamespacesConfig{Names: []string{"ns1"}},
+					},
+				}
+			},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			receivedResult := tc.discoveryServiceFactory().NodeIDBindingEnforced()
+			if tc.expectedResult != receivedResult {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, receivedResult)
+			}
+		})
+	}
+}
+
+func TestDiscoveryService_GetReplicas(t *testing.T) {
+	cases := []struct {
+		testName                string
+		discoveryServiceFactory func() *DiscoveryService
+		expectedResult          int32
+	}{
+		{"With default",
+			func() *DiscoveryService {
+				return &DiscoveryService{}
+			},
+			DefaultDiscoveryServiceReplicas,
+		},
+		{"With explicitly set value",
+			func() *DiscoveryService {
+				return &DiscoveryService{
+					Spec: DiscoveryServiceSpec{
+						Replicas: pointer.New(int32(3)),
+					},
+				}
+			},
+			3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			receivedResult := tc.discoveryServiceFactory().GetReplicas()
+			if tc.expectedResult != receivedResult {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, receivedResult)
+			}
+		})
+	}
+}
+
+func TestDiscoveryService_IsMultiNamespace(t *testing.T) {
+	cases := []struct {
+		testName                string
+		discoveryServiceFactory func() *DiscoveryService
+		expectedResult          bool
+	}{
+		{"With default",
+			func() *DiscoveryService {
+				return &DiscoveryService{}
+			},
+			false,
+		},
+		{"With watched namespaces",
+			func() *DiscoveryService {
+				return &DiscoveryService{
+					Spec: DiscoveryServiceSpec{
+						WatchNamespaces: &WatchNamespacesConfig{Names: []string{"ns1"}},
+					},
+				}
+			},
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			receivedResult := tc.discoveryServiceFactory().IsMultiNamespace()
+			if tc.expectedResult != receivedResult {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, receivedResult)
+			}
+		})
+	}
+}
@@ -17,6 +17,13 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3scale-ops/marin3r/pkg/util/pki"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -34,6 +41,9 @@ const (
 	// IssuerCertificateHashLabelKey is the label that stores the hash of the certificate managed
 	// by the DiscoveryServiceCertificate resource
 	IssuerCertificateHashLabelKey string = "issuer-certificate-hash"
+	// DefaultCertificateRenewBefore is the default renewal window of certificates,
+	// as a percentage of the total duration of the certificate
+	DefaultCertificateRenewBefore string = "20%"
 )
 
 // DiscoveryServiceCertificateSpec defines the desired state of DiscoveryServiceCertificate
@@ -53,14 +63,15 @@ type DiscoveryServiceCertificateSpec struct {
 	// ValidFor specifies the validity of the certificate in seconds
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	ValidFor int64 `json:"validFor"`
-	// Hosts is the list of hosts the certificate is valid for. Only
-	// use when 'IsServerCertificate' is true. If unset, the CommonName
-	// field will be used to populate the valid hosts of the certificate.
+	// Hosts is the list of hosts the certificate is valid for. For client
+	// certificates, it holds the envoy node IDs the certificate is valid for.
+	// If unset, the CommonName field will be used to populate the valid hosts
+	// of the certificate.
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	Hosts []string `json:"hosts,omitempty"`
 	// Signer specifies  the signer to use to create this certificate. Supported
-	// signers are CertManager and SelfSigned.
+	// signers are SelfSigned, CASigned and CertManager.
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	Signer DiscoveryServiceCertificateSigner `json:"signer"`
 	// SecretRef is a reference to the secret that will hold the certificate
@@ -72,6 +83,11 @@ type DiscoveryServiceCertificateSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	CertificateRenewalConfig *CertificateRenewalConfig `json:"certificateRenewal,omitempty"`
+	// PrivateKey configures the algorithm and size of the private key of the certificate.
+	// If unset, a 2048 bits RSA key is used.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	PrivateKey *PrivateKeyConfig `json:"privateKey,omitempty"`
 }
 
 // IsServerCertificate returns true if the certificate is issued for server
@@ -113,6 +129,25 @@ func (d *DiscoveryServiceCertificate) defaultCertificateRenewalConfig() Certific
 	return CertificateRenewalConfig{Enabled: true}
 }
 
+// GetPrivateKeyConfig returns the configuration of the private key of the certificate
+func (d *DiscoveryServiceCertificate) GetPrivateKeyConfig() PrivateKeyConfig {
+	if d.Spec.PrivateKey == nil {
+		return d.defaultPrivateKeyConfig()
+	}
+	pk := *d.Spec.PrivateKey
+	if pk.Algorithm == "" {
+		pk.Algorithm = RSAPrivateKeyAlgorithm
+	}
+	if pk.Size == nil {
+		pk.Size = pk.Algorithm.defaultSize()
+	}
+	return pk
+}
+
+func (d *DiscoveryServiceCertificate) defaultPrivateKeyConfig() PrivateKeyConfig {
+	return PrivateKeyConfig{Algorithm: RSAPrivateKeyAlgorithm, Size: RSAPrivateKeyAlgorithm.defaultSize()}
+}
+
 // DiscoveryServiceCertificateSigner specifies the signer to use to provision the certificate
 type DiscoveryServiceCertificateSigner struct {
 	// SelfSigned holds specific configuration for the SelfSigned signer
@@ -125,6 +160,12 @@ type DiscoveryServiceCertificateSigner struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	CASigned *CASignedConfig `json:"caSigned,omitempty"`
+	// CertManager holds specific configuration for the CertManager signer, which
+	// delegates the issuance of the certificate to a cert-manager issuer
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	CertManager *CertManagerConfig `json:"certManager,omitempty"`
 }
 
 // CertificateRenewalConfig configures the certificate renewal process.
@@ -132,6 +173,98 @@ type CertificateRenewalConfig struct {
 	// Enabled is a flag to enable or disable renewal of the certificate
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	Enabled bool `json:"enabled"`
+	// RenewBefore configures how long before its expiration the certificate is
+	// renewed. It can be either a duration (i.e. "24h") or a percentage of the total
+	// duration of the certificate (i.e. "20%"). Defaults to "20%". A duration that is
+	// not lower than the duration of the certificate is clamped to the default.
+	// +kubebuilder:validation:Pattern=`^(([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+|([0-9]|[1-9][0-9])%)$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	RenewBefore string `json:"renewBefore,omitempty"`
+}
+
+// RenewBeforeDuration returns the time before the expiration of a certificate
+// with the given total duration at which the certificate needs to be renewed
+func (c CertificateRenewalConfig) RenewBeforeDuration(duration time.Duration) (time.Duration, error) {
+	rb := c.RenewBefore
+	if rb == "" {
+		rb = DefaultCertificateRenewBefore
+	}
+
+	if strings.HasSuffix(rb, "%") {
+		return renewBeforePercentage(rb, duration)
+	}
+
+	d, err := time.ParseDuration(rb)
+	if err != nil {
+		return 0, fmt.Errorf("invalid renewBefore duration %q: %w", rb, err)
+	}
+	// the certificate would need to be renewed as soon as it is issued,
+	// so the default renewal window is used instead
+	if d >= duration {
+		return renewBeforePercentage(DefaultCertificateRenewBefore, duration)
+	}
+	return d, nil
+}
+
+func renewBeforePercentage(rb string, duration time.Duration) (time.Duration, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(rb, "%"), 64)
+	if err != nil || pct < 0 || pct >= 100 {
+		return 0, fmt.Errorf("invalid renewBefore percentage %q", rb)
+	}
+	return time.Duration(int64(math.Floor(float64(duration) * pct / 100))), nil
+}
+
+// PrivateKeyAlgorithm is the algorithm of the private key of a certificate
+// +kubebuilder:validation:Enum=RSA;ECDSA;Ed25519
+type PrivateKeyAlgorithm string
+
+const (
+	// RSAPrivateKeyAlgorithm is the RSA private key algorithm
+	RSAPrivateKeyAlgorithm PrivateKeyAlgorithm = "RSA"
+	// ECDSAPrivateKeyAlgorithm is the ECDSA private key algorithm
+	ECDSAPrivateKeyAlgorithm PrivateKeyAlgorithm = "ECDSA"
+	// Ed25519PrivateKeyAlgorithm is the Ed25519 private key algorithm
+	Ed25519PrivateKeyAlgorithm PrivateKeyAlgorithm = "Ed25519"
+)
+
+func (pka PrivateKeyAlgorithm) defaultSize() *int {
+	switch pka {
+	case ECDSAPrivateKeyAlgorithm:
+		return pointer.New(256)
+	case Ed25519PrivateKeyAlgorithm:
+		return nil
+	default:
+		return pointer.New(2048)
+	}
+}
+
+// PrivateKeyConfig configures the private key of a certificate
+type PrivateKeyConfig struct {
+	// Algorithm is the algorithm of the private key. Supported algorithms
+	// are RSA, ECDSA and Ed25519. Defaults to RSA.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Algorithm PrivateKeyAlgorithm `json:"algorithm,omitempty"`
+	// Size is the size in bits of the private key. Valid values are 2048, 3072 and 4096
+	// for RSA keys (defaults to 2048) and 256, 384 and 521 for ECDSA keys (defaults to 256).
+	// It is ignored for Ed25519 keys.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Size *int `json:"size,omitempty"`
+}
+
+// KeyOptions returns the pki.KeyOptions for the private key configuration.
+// A nil configuration returns the default options.
+func (pk *PrivateKeyConfig) KeyOptions() pki.KeyOptions {
+	if pk == nil {
+		return pki.KeyOptions{}
+	}
+	opts := pki.KeyOptions{Algorithm: pki.KeyAlgorithm(pk.Algorithm)}
+	if pk.Size != nil {
+		opts.Size = *pk.Size
+	}
+	return opts
 }
 
 // SelfSignedConfig is an empty struct to refer to the selfsiged certificates provisioner
@@ -144,6 +277,30 @@ type CASignedConfig struct {
 	SecretRef corev1.SecretReference `json:"caSecretRef"`
 }
 
+// CertManagerConfig is used to generate certificates using a cert-manager Issuer or ClusterIssuer
+type CertManagerConfig struct {
+	// IssuerRef is a reference to the cert-manager issuer that signs the certificate
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	IssuerRef CertManagerIssuerReference `json:"issuerRef"`
+}
+
+// CertManagerIssuerReference is a reference to a cert-manager issuer
+type CertManagerIssuerReference struct {
+	// Name of the issuer
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Name string `json:"name"`
+	// Kind of the issuer, either Issuer or ClusterIssuer. Defaults to Issuer.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Group of the issuer. Defaults to cert-manager.io. It can be set
+	// to use external issuers that implement the cert-manager API.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
 // DiscoveryServiceCertificateStatus defines the observed state of DiscoveryServiceCertificate
 type DiscoveryServiceCertificateStatus struct {
 	// Ready is a boolean that specifies if the certificate is ready to be used
@@ -222,6 +379,12 @@ func (dsc *DiscoveryServiceCertificate) Default() {
 		crc := dsc.GetCertificateRenewalConfig()
 		dsc.Spec.CertificateRenewalConfig = &crc
 	}
+	// the private key is only defaulted when set, as certificates without
+	// a private key configuration are not checked against it
+	if dsc.Spec.PrivateKey != nil {
+		pk := dsc.GetPrivateKeyConfig()
+		dsc.Spec.PrivateKey = &pk
+	}
 }
 
 // +kubebuilder:object:root=true
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"testing"
+	"time"
 
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
 	"k8s.io/apimachinery/pkg/api/equality"
@@ -159,6 +160,152 @@ func TestDiscoveryServiceCertificate_GetCertificateRenewalConfig(t *testing.T) {
 	}
 }
 
+func TestCertificateRenewalConfig_RenewBeforeDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		renewBefore string
+		duration    time.Duration
+		want        time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "Defaults to 20%",
+			renewBefore: "",
+			duration:    100 * time.Hour,
+			want:        20 * time.Hour,
+			wantErr:     false,
+		},
+		{
+			name:        "Percentage",
+			renewBefore: "50%",
+			duration:    100 * time.Hour,
+			want:        50 * time.Hour,
+			wantErr:     false,
+		},
+		{
+			name:        "Duration",
+			renewBefore: "24h",
+			duration:    100 * time.Hour,
+			want:        24 * time.Hour,
+			wantErr:     false,
+		},
+		{
+			name:        "Duration longer than the certificate's duration is clamped",
+			renewBefore: "200h",
+			duration:    100 * time.Hour,
+			want:        20 * time.Hour,
+			wantErr:     false,
+		},
+		{
+			name:        "Duration equal to the certificate's duration is clamped",
+			renewBefore: "100h",
+			duration:    100 * time.Hour,
+			want:        20 * time.Hour,
+			wantErr:     false,
+		},
+		{
+			name:        "Invalid value",
+			renewBefore: "xx",
+			duration:    100 * time.Hour,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CertificateRenewalConfig{Enabled: true, RenewBefore: tt.renewBefore}
+			got, err := c.RenewBeforeDuration(tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CertificateRenewalConfig.RenewBeforeDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CertificateRenewalConfig.RenewBeforeDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryServiceCertificate_GetPrivateKeyConfig(t *testing.T) {
+	cases := []struct {
+		testName                           string
+		discoveryServiceCertificateFactory func() *DiscoveryServiceCertificate
+		expectedResult                     PrivateKeyConfig
+	}{
+		{"With default options",
+			func() *DiscoveryServiceCertificate {
+				return &DiscoveryServiceCertificate{}
+			},
+			(&DiscoveryServiceCertificate{}).defaultPrivateKeyConfig(),
+		},
+		{"With the algorithm set",
+			func() *DiscoveryServiceCertificate {
+				return &DiscoveryServiceCertificate{
+					Spec: DiscoveryServiceCertificateSpec{
+						PrivateKey: &PrivateKeyConfig{Algorithm: ECDSAPrivateKeyAlgorithm},
+					},
+				}
+			},
+			PrivateKeyConfig{Algorithm: ECDSAPrivateKeyAlgorithm, Size: pointer.New(256)},
+		},
+		{"With explicitly set options",
+			func() *DiscoveryServiceCertificate {
+				return &DiscoveryServiceCertificate{
+					Spec: DiscoveryServiceCertificateSpec{
+						PrivateKey: &PrivateKeyConfig{Algorithm: RSAPrivateKeyAlgorithm, Size: pointer.New(4096)},
+					},
+				}
+			},
+			PrivateKeyConfig{Algorithm: RSAPrivateKeyAlgorithm, Size: pointer.New(4096)},
+		},
+		{"With Ed25519 keys",
+			func() *DiscoveryServiceCertificate {
+				return &DiscoveryServiceCertificate{
+					Spec: DiscoveryServiceCertificateSpec{
+						PrivateKey: &PrivateKeyConfig{Algorithm: Ed25519PrivateKeyAlgorithm},
+					},
+				}
+			},
+			PrivateKeyConfig{Algorithm: Ed25519PrivateKeyAlgorithm},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			receivedResult := tc.discoveryServiceCertificateFactory().GetPrivateKeyConfig()
+			if !equality.Semantic.DeepEqual(tc.expectedResult, receivedResult) {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, receivedResult)
+			}
+		})
+	}
+}
+
+func TestDiscoveryServiceCertificate_Default_PrivateKey(t *testing.T) {
+	cases := []struct {
+		testName       string
+		privateKey     *PrivateKeyConfig
+		expectedResult *PrivateKeyConfig
+	}{
+		{"Unset private key is not defaulted",
+			nil,
+			nil,
+		},
+		{"Set private key is completed with the defaults",
+			&PrivateKeyConfig{Algorithm: ECDSAPrivateKeyAlgorithm},
+			&PrivateKeyConfig{Algorithm: ECDSAPrivateKeyAlgorithm, Size: pointer.New(256)},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.testName, func(subT *testing.T) {
+			dsc := &DiscoveryServiceCertificate{Spec: DiscoveryServiceCertificateSpec{PrivateKey: tc.privateKey}}
+			dsc.Default()
+			if !equality.Semantic.DeepEqual(tc.expectedResult, dsc.Spec.PrivateKey) {
+				subT.Errorf("Expected result differs: Expected: %v, Received: %v", tc.expectedResult, dsc.Spec.PrivateKey)
+			}
+		})
+	}
+}
+
 func TestDiscoveryServiceCertificateStatus_IsReady(t *testing.T) {
 	cases := []struct {
 		testName                           string
@@ -104,6 +104,11 @@ type EnvoyDeploymentSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	// +optional
 	AdminAccessLogPath *string `json:"adminAccessLogPath,omitempty"`
+	// DeltaXds configures envoy to use the incremental (delta) variant of the
+	// xDS protocol to receive its configuration. Defaults to false.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	// +optional
+	DeltaXds *bool `json:"deltaXds,omitempty"`
 	// Replicas configures the number of replicas in the Deployment. One of
 	// 'static', 'dynamic' can be set. If both are set, static has precedence.
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
@@ -176,6 +181,14 @@ func (ed *EnvoyDeployment) AdminAccessLogPath() string {
 	return *ed.Spec.AdminAccessLogPath
 }
 
+// DeltaXds returns true if envoy uses the incremental (delta) variant of the xDS protocol
+func (ed *EnvoyDeployment) DeltaXds() bool {
+	if ed.Spec.DeltaXds == nil {
+		return false
+	}
+	return *ed.Spec.DeltaXds
+}
+
 func (ed *EnvoyDeployment) Replicas() ReplicasSpec {
 	if ed.Spec.Replicas == nil {
 		return ReplicasSpec{Static: pointer.New(DefaultReplicas)}
@@ -47,6 +47,37 @@ func (in *CASignedConfig) DeepCopy() *CASignedConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertManagerConfig) DeepCopyInto(out *CertManagerConfig) {
+	*out = *in
+	out.IssuerRef = in.IssuerRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerConfig.
+func (in *CertManagerConfig) DeepCopy() *CertManagerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CertManagerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertManagerIssuerReference) DeepCopyInto(out *CertManagerIssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerIssuerReference.
+func (in *CertManagerIssuerReference) DeepCopy() *CertManagerIssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CertManagerIssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateOptions) DeepCopyInto(out *CertificateOptions) {
 	*out = *in
@@ -197,6 +228,11 @@ func (in *DiscoveryServiceCertificateSigner) DeepCopyInto(out *DiscoveryServiceC
 		*out = new(CASignedConfig)
 		**out = **in
 	}
+	if in.CertManager != nil {
+		in, out := &in.CertManager, &out.CertManager
+		*out = new(CertManagerConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiscoveryServiceCertificateSigner.
@@ -234,6 +270,11 @@ func (in *DiscoveryServiceCertificateSpec) DeepCopyInto(out *DiscoveryServiceCer
 		*out = new(CertificateRenewalConfig)
 		**out = **in
 	}
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(PrivateKeyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiscoveryServiceCertificateSpec.
@@ -366,6 +407,21 @@ func (in *DiscoveryServiceSpec) DeepCopyInto(out *DiscoveryServiceSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.EnforceNodeIDBinding != nil {
+		in, out := &in.EnforceNodeIDBinding, &out.EnforceNodeIDBinding
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.WatchNamespaces != nil {
+		in, out := &in.WatchNamespaces, &out.WatchNamespaces
+		*out = new(WatchNamespacesConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiscoveryServiceSpec.
@@ -391,6 +447,18 @@ func (in *DiscoveryServiceStatus) DeepCopyInto(out *DiscoveryServiceStatus) {
 		*out = new(appsv1.DeploymentStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RootCARotation != nil {
+		in, out := &in.RootCARotation, &out.RootCARotation
+		*out = new(RootCARotationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.UnimplementedStatefulSetStatus = in.UnimplementedStatefulSetStatus
 }
 
@@ -540,6 +608,11 @@ func (in *EnvoyDeploymentSpec) DeepCopyInto(out *EnvoyDeploymentSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.DeltaXds != nil {
+		in, out := &in.DeltaXds, &out.DeltaXds
+		*out = new(bool)
+		**out = **in
+	}
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(ReplicasSpec)
@@ -646,6 +719,11 @@ func (in *PKIConfig) DeepCopyInto(out *PKIConfig) {
 		*out = new(CertificateOptions)
 		**out = **in
 	}
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(PrivateKeyConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PKIConfig.
@@ -683,6 +761,26 @@ func (in *PodDisruptionBudgetSpec) DeepCopy() *PodDisruptionBudgetSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateKeyConfig) DeepCopyInto(out *PrivateKeyConfig) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateKeyConfig.
+func (in *PrivateKeyConfig) DeepCopy() *PrivateKeyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateKeyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProbeSpec) DeepCopyInto(out *ProbeSpec) {
 	*out = *in
@@ -723,6 +821,22 @@ func (in *ReplicasSpec) DeepCopy() *ReplicasSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RootCARotationStatus) DeepCopyInto(out *RootCARotationStatus) {
+	*out = *in
+	in.PhaseStartTime.DeepCopyInto(&out.PhaseStartTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RootCARotationStatus.
+func (in *RootCARotationStatus) DeepCopy() *RootCARotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RootCARotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SelfSignedConfig) DeepCopyInto(out *SelfSignedConfig) {
 	*out = *in
@@ -787,3 +901,28 @@ func (in *ShutdownManager) DeepCopy() *ShutdownManager {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchNamespacesConfig) DeepCopyInto(out *WatchNamespacesConfig) {
+	*out = *in
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchNamespacesConfig.
+func (in *WatchNamespacesConfig) DeepCopy() *WatchNamespacesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchNamespacesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
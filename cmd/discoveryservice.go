@@ -31,18 +31,23 @@ import (
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
 	marin3rcontroller "github.com/3scale-ops/marin3r/controllers/marin3r"
 	"github.com/3scale-ops/marin3r/pkg/discoveryservice"
+	xdss_cache "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	xdss_v3 "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/v3"
 	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoyconfigrevision "github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfigrevision"
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
@@ -66,6 +71,13 @@ var (
 	xdssTLSServerCertificatePath string
 	xdssTLSClientCertificatePath string
 	xdssTLSCACertificatePath     string
+	xdssEnforceNodeIDBinding     bool
+	xdssTLSReloadInterval        time.Duration
+	xdssClientCAOverlap          time.Duration
+	xdssStatsCheckpoint          string
+	xdssStatsSyncInterval        time.Duration
+	xdssWatchNamespaces          []string
+	xdssMaxConcurrentPushes      int
 	dsScheme                     = apimachineryruntime.NewScheme()
 )
 
@@ -86,6 +98,26 @@ func init() {
 		fmt.Sprintf("The path where the CA certificate '%s' and key '%s' files are located", certificateFile, certificateKeyFile))
 	discoveryServiceCmd.Flags().StringVar(&xdssTLSClientCertificatePath, "client-certificate-path", "/etc/marin3r/tls/client",
 		fmt.Sprintf("The path where the client certificate '%s' and key '%s' files are located", certificateFile, certificateKeyFile))
+	discoveryServiceCmd.Flags().BoolVar(&xdssEnforceNodeIDBinding, "enforce-node-id-binding", false,
+		"Reject discovery requests from clients whose certificate is not valid for the requested node ID. Mismatches are only reported when disabled.")
+	discoveryServiceCmd.Flags().DurationVar(&xdssTLSReloadInterval, "tls-reload-interval", 30*time.Second,
+		"The interval at which the server certificate and the CA files are checked for changes.")
+	discoveryServiceCmd.Flags().DurationVar(&xdssClientCAOverlap, "client-ca-overlap", 0,
+		"The time during which client certificates issued by the previous CA are still accepted after a CA rotation.")
+	discoveryServiceCmd.Flags().StringVar(&xdssStatsCheckpoint, "stats-checkpoint-configmap", "",
+		"The ConfigMap where the discovery stats are persisted so they survive the replacement of the pod. "+
+			"Stats are not persisted if empty. Only supported with a single replica.")
+	discoveryServiceCmd.Flags().BoolVar(&leaderElect, "leader-elect", false,
+		"Enable leader election and share the discovery stats with the other replicas. Required to run more than one replica. "+
+			"Only the leader writes the status of the EnvoyConfigRevisions.")
+	discoveryServiceCmd.Flags().DurationVar(&xdssStatsSyncInterval, "stats-sync-interval", 10*time.Second,
+		"The interval at which the discovery stats are shared with the other replicas when leader election is enabled.")
+	discoveryServiceCmd.Flags().StringSliceVar(&xdssWatchNamespaces, "watch-namespaces", []string{},
+		"The namespaces where EnvoyConfigs are watched, in addition to the discovery service's own namespace. "+
+			"When set, the nodeIDs of the envoy clients are prefixed with their namespace. Requires --enforce-node-id-binding.")
+	discoveryServiceCmd.Flags().IntVar(&xdssMaxConcurrentPushes, "max-concurrent-pushes", xdss_v3.DefaultMaxConcurrentPushes,
+		"The max number of envoy clients per node ID with responses pending acknowledgement. Responses to other clients "+
+			"are queued until the pushes are acknowledged. Zero disables the limit.")
 
 }
 
@@ -97,18 +129,46 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 	cfg := ctrl.GetConfigOrDie()
 	ctx := signals.SetupSignalHandler()
 
+	// in multi-namespace mode envoy clients of different namespaces
+	// can use the same nodeID, so nodeIDs are prefixed with the namespace
+	namespacedNodeIDs := len(xdssWatchNamespaces) > 0
+	// the namespace is reported by the clients themselves, so only the client
+	// certificate can prevent them from requesting another namespace's config
+	if namespacedNodeIDs && !xdssEnforceNodeIDBinding {
+		setupLog.Error(fmt.Errorf("--watch-namespaces requires --enforce-node-id-binding"), "invalid flags")
+		os.Exit(1)
+	}
+	// all the replicas would write their own stats to the same checkpoint
+	if xdssStatsCheckpoint != "" && leaderElect {
+		setupLog.Error(fmt.Errorf("--stats-checkpoint-configmap is not supported with --leader-elect"), "invalid flags")
+		os.Exit(1)
+	}
+	watchNamespaces := []string{os.Getenv("WATCH_NAMESPACE")}
+	seen := map[string]bool{os.Getenv("WATCH_NAMESPACE"): true}
+	for _, ns := range xdssWatchNamespaces {
+		if !seen[ns] {
+			seen[ns] = true
+			watchNamespaces = append(watchNamespaces, ns)
+		}
+	}
+	cacheNamespaces := map[string]cache.Config{}
+	for _, ns := range watchNamespaces {
+		cacheNamespaces[ns] = cache.Config{}
+	}
+
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme: dsScheme,
 		Metrics: metricsserver.Options{
 			BindAddress: "0",
 		},
-		HealthProbeBindAddress:     probeAddr,
-		LeaderElectionID:           "2cfbe7d6.marin3r.3scale.net",
-		LeaderElectionResourceLock: "leases",
+		HealthProbeBindAddress:        probeAddr,
+		LeaderElection:                leaderElect,
+		LeaderElectionID:              "2cfbe7d6.marin3r.3scale.net",
+		LeaderElectionNamespace:       os.Getenv("WATCH_NAMESPACE"),
+		LeaderElectionResourceLock:    "leases",
+		LeaderElectionReleaseOnCancel: true,
 		Cache: cache.Options{
-			DefaultNamespaces: map[string]cache.Config{
-				os.Getenv("WATCH_NAMESPACE"): {},
-			},
+			DefaultNamespaces: cacheNamespaces,
 		},
 	})
 	if err != nil {
@@ -118,11 +178,26 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 
 	var wait sync.WaitGroup
 
+	// Watch the server certificate and the CA so renewals and
+	// rotations are picked up without restarting the server
+	tlsReloader, err := discoveryservice.NewTLSReloader(
+		fmt.Sprintf("%s/%s", xdssTLSServerCertificatePath, certificateFile),
+		fmt.Sprintf("%s/%s", xdssTLSServerCertificatePath, certificateKeyFile),
+		fmt.Sprintf("%s/%s", xdssTLSCACertificatePath, certificateFile),
+		xdssClientCAOverlap,
+		ctrl.Log.WithName("tls_reloader"),
+	)
+	if err != nil {
+		setupLog.Error(err, "unable to load xDS server certificates")
+		os.Exit(1)
+	}
+	tlsReloader.Start(ctx, xdssTLSReloadInterval)
+
 	// Start envoy's aggregated discovery service
 	xdss := discoveryservice.NewXdsServer(
 		ctx,
 		uint(xdssPort),
-		&tls.Config{
+		tlsReloader.ServerTLSConfig(&tls.Config{
 			MinVersion:               tls.VersionTLS12,
 			CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
 			PreferServerCipherSuites: true,
@@ -131,10 +206,12 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
 				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
 			},
-			Certificates: []tls.Certificate{loadCertificate(xdssTLSServerCertificatePath, setupLog)},
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-			ClientCAs:    loadCA(xdssTLSCACertificatePath, setupLog),
-		},
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		}),
+		xdssEnforceNodeIDBinding,
+		namespacedNodeIDs,
+		xdssMaxConcurrentPushes,
+		types.NamespacedName{Name: xdssStatsCheckpoint, Namespace: os.Getenv("WATCH_NAMESPACE")},
 		setupLog,
 	)
 
@@ -146,7 +223,24 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 			setupLog.Error(err, "unable to create k8s client for xdss")
 			os.Exit(1)
 		}
-		if err := xdss.Start(client, os.Getenv("WATCH_NAMESPACE")); err != nil {
+		// the manager's cache is not available until the manager starts, so
+		// the xDS cache is warmed up using a client that reads from the API
+		apiClient, err := newAPIClient(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to create k8s client for the xDS cache warm up")
+			os.Exit(1)
+		}
+		warmUp := func(ctx context.Context, cache xdss_cache.Cache) error {
+			return envoyconfigrevision.WarmUp(ctx, ctrl.Log.WithName("warmup"), apiClient, cache,
+				watchNamespaces, namespacedNodeIDs, envoy.APIv3)
+		}
+		// share the stats with the other replicas so all of them, and in particular
+		// the leader, see the ACKs and NACKs of every envoy client
+		if leaderElect {
+			xdss.GetDiscoveryStats(envoy.APIv3).RunReplicaSync(client, os.Getenv("WATCH_NAMESPACE"), os.Getenv("POD_NAME"),
+				xdssStatsSyncInterval, ctrl.Log.WithName("stats_sync"), ctx.Done())
+		}
+		if err := xdss.Start(client, watchNamespaces, warmUp); err != nil {
 			setupLog.Error(err, "xDS server returned an unrecoverable error, shutting down")
 			os.Exit(1)
 		}
@@ -156,6 +250,8 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 	if err := (&marin3rcontroller.EnvoyConfigReconciler{
 		Reconciler: reconciler.NewFromManager(mgr).
 			WithLogger(ctrl.Log.WithName("controllers").WithName("envoyconfig")),
+		DiscoveryStats:    xdss.GetDiscoveryStats(envoy.APIv3),
+		NamespacedNodeIDs: namespacedNodeIDs,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "envoyconfig")
 		os.Exit(1)
@@ -164,16 +260,19 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 	if err := (&marin3rcontroller.EnvoyConfigRevisionReconciler{
 		Reconciler: reconciler.NewFromManager(mgr).
 			WithLogger(ctrl.Log.WithName("controllers").WithName(fmt.Sprintf("envoyconfigrevision_%s", string(envoy.APIv3)))),
-		XdsCache:       xdss.GetCache(envoy.APIv3),
-		APIVersion:     envoy.APIv3,
-		DiscoveryStats: xdss.GetDiscoveryStats(envoy.APIv3),
+		XdsCache:          xdss.GetCache(envoy.APIv3),
+		APIVersion:        envoy.APIv3,
+		DiscoveryStats:    xdss.GetDiscoveryStats(envoy.APIv3),
+		Recorder:          mgr.GetEventRecorderFor(fmt.Sprintf("envoyconfigrevision_%s", string(envoy.APIv3))),
+		LeaderElected:     mgr.Elected(),
+		NamespacedNodeIDs: namespacedNodeIDs,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", fmt.Sprintf("envoyconfigrevision_%s", string(envoy.APIv3)))
 		os.Exit(1)
 	}
 
 	// register healthz and readyz checks
-	if err := mgr.AddHealthzCheck("gRPC", xdssHealthzCheck(ctrl.Log.WithName("XdssHealthzCheck"))); err != nil {
+	if err := mgr.AddHealthzCheck("gRPC", xdssHealthzCheck(xdss, false, ctrl.Log.WithName("XdssHealthzCheck"))); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
@@ -182,7 +281,7 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if err := mgr.AddReadyzCheck("gRPC", xdssHealthzCheck(ctrl.Log.WithName("XdssHealthzCheck"))); err != nil {
+	if err := mgr.AddReadyzCheck("gRPC", xdssHealthzCheck(xdss, true, ctrl.Log.WithName("XdssHealthzCheck"))); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
@@ -206,9 +305,19 @@ func runDiscoveryService(cmd *cobra.Command, args []string) {
 	setupLog.Info("Controller has shut down")
 }
 
-func xdssHealthzCheck(logger logr.Logger) healthz.Checker {
+// xdssHealthzCheck checks the health of the gRPC server. The server does not listen until
+// the xDS cache has been warmed up: the check fails in the meantime if requireWarm is true
+// (readiness), otherwise it passes so the process is not restarted while warming up (liveness).
+func xdssHealthzCheck(xdss *discoveryservice.XdsServer, requireWarm bool, logger logr.Logger) healthz.Checker {
 	return func(_ *http.Request) error {
 
+		if !xdss.IsWarm() {
+			if requireWarm {
+				return fmt.Errorf("xDS cache is not warm yet")
+			}
+			return nil
+		}
+
 		tlsConfig := &tls.Config{
 			Certificates:       []tls.Certificate{loadCertificate(xdssTLSClientCertificatePath, setupLog)},
 			ClientCAs:          loadCA(xdssTLSCACertificatePath, setupLog),
@@ -238,6 +347,11 @@ func xdssHealthzCheck(logger logr.Logger) healthz.Checker {
 	}
 }
 
+// newAPIClient returns a client that reads directly from the API server
+func newAPIClient(mgr ctrl.Manager) (client.Client, error) {
+	return client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+}
+
 func loadCertificate(directory string, logger logr.Logger) tls.Certificate {
 	certificate, err := tls.LoadX509KeyPair(
 		fmt.Sprintf("%s/%s", directory, certificateFile),
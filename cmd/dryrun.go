@@ -0,0 +1,128 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	envoyconfig "github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfig"
+	"github.com/spf13/cobra"
+	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// Dry run flags
+	dryRunName      string
+	dryRunNamespace string
+	dryRunFilename  string
+	dryRunOutput    string
+)
+
+var (
+	// Dry run subcommand
+	dryRunCmd = &cobra.Command{
+		Use:   "dry-run",
+		Short: "Render the xDS resources that the discovery service would serve for an EnvoyConfig",
+		Long: "Render the xDS resources that the discovery service would serve for an EnvoyConfig, including " +
+			"the resources imported from libraries and the ones generated from Secrets and EndpointSlices, " +
+			"without modifying anything in the cluster. The EnvoyConfig can be read from the cluster or from a " +
+			"file, to check it before applying it. The secret material is redacted from the output.",
+		Args: cobra.NoArgs,
+		Run:  runDryRun,
+	}
+)
+
+var (
+	dryRunScheme = apimachineryruntime.NewScheme()
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(dryRunScheme))
+	utilruntime.Must(marin3rv1alpha1.AddToScheme(dryRunScheme))
+
+	// Dry run subcommand
+	rootCmd.AddCommand(dryRunCmd)
+
+	// Dry run flags
+	dryRunCmd.Flags().StringVar(&dryRunName, "name", "", "The name of the EnvoyConfig to render from the cluster")
+	dryRunCmd.Flags().StringVarP(&dryRunNamespace, "namespace", "n", "default",
+		"The namespace of the EnvoyConfig. Secrets, EndpointSlices and libraries are looked up in this namespace")
+	dryRunCmd.Flags().StringVarP(&dryRunFilename, "filename", "f", "",
+		"A file with the EnvoyConfig to render, instead of reading it from the cluster")
+	dryRunCmd.Flags().StringVarP(&dryRunOutput, "output", "o", string(envoy_serializer.YAML), "The output format, one of 'yaml' or 'json'")
+	dryRunCmd.MarkFlagsMutuallyExclusive("name", "filename")
+	dryRunCmd.MarkFlagsOneRequired("name", "filename")
+}
+
+func runDryRun(cmd *cobra.Command, args []string) {
+
+	ctrl.SetLogger(zap.New(zap.UseDevMode(debug), zap.WriteTo(os.Stderr)))
+	ctx := context.Background()
+
+	cl, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: dryRunScheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create kubernetes client")
+		os.Exit(1)
+	}
+
+	ec := &marin3rv1alpha1.EnvoyConfig{}
+	if dryRunFilename != "" {
+		data, err := os.ReadFile(dryRunFilename)
+		if err != nil {
+			setupLog.Error(err, "unable to read EnvoyConfig file")
+			os.Exit(1)
+		}
+		if err := yaml.UnmarshalStrict(data, ec); err != nil {
+			setupLog.Error(err, "unable to decode EnvoyConfig file")
+			os.Exit(1)
+		}
+		if ec.GetNamespace() == "" || cmd.Flags().Changed("namespace") {
+			ec.SetNamespace(dryRunNamespace)
+		}
+	} else {
+		key := types.NamespacedName{Name: dryRunName, Namespace: dryRunNamespace}
+		if err := cl.Get(ctx, key, ec); err != nil {
+			setupLog.Error(err, "unable to get EnvoyConfig")
+			os.Exit(1)
+		}
+	}
+	ec.Default()
+
+	snap, err := envoyconfig.DryRun(ctx, ctrl.Log.WithName("dry-run"), cl, ec)
+	if err != nil {
+		setupLog.Error(err, "unable to generate the snapshot")
+		os.Exit(1)
+	}
+
+	out, err := envoyconfig.RenderSnapshot(snap, envoy_serializer.Serialization(dryRunOutput))
+	if err != nil {
+		setupLog.Error(err, "unable to render the snapshot")
+		os.Exit(1)
+	}
+	fmt.Fprint(cmd.OutOrStdout(), out)
+}
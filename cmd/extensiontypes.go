@@ -0,0 +1,66 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	envoy_protos_v3 "github.com/3scale-ops/marin3r/pkg/envoy/protos/v3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Extension types flags
+	extensionTypesAll    bool
+	extensionTypesFilter string
+)
+
+var (
+	// Extension types subcommand
+	extensionTypesCmd = &cobra.Command{
+		Use:   "extension-types",
+		Short: "List the envoy extension types that can be used in typed_config fields",
+		Args:  cobra.NoArgs,
+		Run:   runExtensionTypes,
+	}
+)
+
+func init() {
+
+	// Extension types subcommand
+	rootCmd.AddCommand(extensionTypesCmd)
+
+	// Extension types flags
+	extensionTypesCmd.Flags().BoolVar(&extensionTypesAll, "all", false,
+		"List all the registered proto messages, not only the envoy extensions")
+	extensionTypesCmd.Flags().StringVar(&extensionTypesFilter, "filter", "",
+		"Only list the types whose type URL contains the given string")
+}
+
+func runExtensionTypes(cmd *cobra.Command, args []string) {
+	types := envoy_protos_v3.ExtensionTypes()
+	if extensionTypesAll {
+		types = envoy_protos_v3.Types()
+	}
+
+	for _, typeURL := range types {
+		if strings.Contains(typeURL, extensionTypesFilter) {
+			fmt.Fprintln(cmd.OutOrStdout(), typeURL)
+		}
+	}
+}
@@ -47,6 +47,7 @@ var (
 	initmgrAdminAccessLogPath       string
 	initmgrAPIVersion               string
 	initmgrEnvoyImage               string
+	initmgrDeltaXds                 bool
 )
 
 var (
@@ -75,6 +76,7 @@ func init() {
 	initManagerServiceCmd.Flags().StringVar(&initmgrAdminAccessLogPath, "admin-access-log-path", defaults.EnvoyAdminAccessLogPath, "Path for the admin access logs.")
 	initManagerServiceCmd.Flags().StringVar(&initmgrAPIVersion, "api-version", "v3", "Envoy API version to use.")
 	initManagerServiceCmd.Flags().StringVar(&initmgrEnvoyImage, "envoy-image", "", "Envoy image being used.")
+	initManagerServiceCmd.Flags().BoolVar(&initmgrDeltaXds, "delta-xds", false, "Use the incremental (delta) variant of the xDS protocol.")
 }
 
 func runInitManager(cmd *cobra.Command, args []string) {
@@ -112,6 +114,7 @@ func runInitManager(cmd *cobra.Command, args []string) {
 		AdminAddress:                host,
 		AdminPort:                   port,
 		AdminAccessLogPath:          initmgrAdminAccessLogPath,
+		DeltaXds:                    initmgrDeltaXds,
 		Metadata: map[string]string{
 			"pod_name":      os.Getenv("POD_NAME"),
 			"pod_namespace": os.Getenv("POD_NAMESPACE"),
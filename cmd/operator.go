@@ -123,6 +123,7 @@ func runOperator(cmd *cobra.Command, args []string) {
 	if err := (&operatorcontroller.DiscoveryServiceCertificateReconciler{
 		Reconciler: reconciler.NewFromManager(mgr).
 			WithLogger(ctrl.Log.WithName("controllers").WithName("discoveryservicecertificate")),
+		Recorder: mgr.GetEventRecorderFor("discoveryservicecertificate"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "discoveryservicecertificate")
 		os.Exit(1)
@@ -143,6 +143,12 @@ func runWebhook(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Register the EnvoyResourceLibrary v1alpha1 webhooks
+	if err = (&marin3rv1alpha1.EnvoyResourceLibrary{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "EnvoyResourceLibrary", "version", "v1alpha1")
+		os.Exit(1)
+	}
+
 	// Register the EnvoyDeployment validating webhook
 	if err = (&operatorv1alpha1.EnvoyDeployment{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "EnvoyDeployment")
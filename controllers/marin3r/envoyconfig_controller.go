@@ -18,19 +18,29 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/3scale-ops/basereconciler/reconciler"
 	reconciler_util "github.com/3scale-ops/basereconciler/util"
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	envoyconfig "github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfig"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // EnvoyConfigReconciler reconciles a EnvoyConfig object
 type EnvoyConfigReconciler struct {
 	*reconciler.Reconciler
+	DiscoveryStats *stats.Stats
+	// NamespacedNodeIDs must be set when the discovery service watches several
+	// namespaces, as the nodeIDs of the envoy clients are prefixed with their namespace
+	NamespacedNodeIDs bool
 }
 
 // Reconcile progresses EnvoyConfig resources to its desired state
@@ -38,6 +48,9 @@ type EnvoyConfigReconciler struct {
 // +kubebuilder:rbac:groups=marin3r.3scale.net,namespace=placeholder,resources=envoyconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=marin3r.3scale.net,namespace=placeholder,resources=envoyconfigrevisions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=marin3r.3scale.net,namespace=placeholder,resources=envoyconfigrevisions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=marin3r.3scale.net,namespace=placeholder,resources=envoyresourcelibraries,verbs=get;list;watch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=configmaps,verbs=get;list;watch
 
 func (r *EnvoyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
@@ -59,6 +72,14 @@ func (r *EnvoyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			}
 			return nil
 		}),
+		// import the resources of the referenced EnvoyResourceLibraries
+		reconciler.WithInMemoryInitializationFunc(func(ctx context.Context, c client.Client, o client.Object) error {
+			return envoyconfig.ImportLibraries(ctx, c, o.(*marin3rv1alpha1.EnvoyConfig))
+		}),
+		// resolve the values of the parameters held in ConfigMaps
+		reconciler.WithInMemoryInitializationFunc(func(ctx context.Context, c client.Client, o client.Object) error {
+			return envoyconfig.ResolveParameters(ctx, c, o.(*marin3rv1alpha1.EnvoyConfig))
+		}),
 	)
 	if result.ShouldReturn() {
 		return result.Values()
@@ -69,13 +90,20 @@ func (r *EnvoyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	revisionReconciler := envoyconfig.NewRevisionReconciler(
 		ctx, logger, r.Client, r.Scheme, ec,
 	)
+	revisionReconciler.SetDiscoveryStats(r.DiscoveryStats, r.xdsNodeID(ec))
 
 	reconcilerResult, err := revisionReconciler.Reconcile()
 	if reconcilerResult.Requeue || err != nil {
 		return reconcilerResult, err
 	}
 
-	if ok := envoyconfig.IsStatusReconciled(ec, revisionReconciler.GetCacheState(), revisionReconciler.PublishedVersion(), revisionReconciler.GetRevisionList()); !ok {
+	// both functions reconcile parts of the status, so both must be
+	// evaluated before checking whether the status needs an update
+	statusOK := envoyconfig.IsStatusReconciled(ec, revisionReconciler.GetCacheState(), revisionReconciler.PublishedVersion(),
+		revisionReconciler.CanaryVersion(), revisionReconciler.PinError(), revisionReconciler.GetRevisionList())
+	syncStatusOK := envoyconfig.IsSyncStatusReconciled(ec, r.xdsNodeID(ec), revisionReconciler.PublishedVersion(),
+		revisionReconciler.GetRevisionList(), r.DiscoveryStats)
+	if !statusOK || !syncStatusOK {
 		if err := r.Client.Status().Update(ctx, ec); err != nil {
 			logger.Error(err, "unable to update EnvoyConfig status")
 			return ctrl.Result{}, err
@@ -84,7 +112,65 @@ func (r *EnvoyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, nil
 	}
 
-	return ctrl.Result{}, nil
+	// requeue periodically so the sync status of the envoy clients is refreshed. A canary
+	// revision being analyzed might have requested an earlier requeue.
+	if reconcilerResult.RequeueAfter == 0 || reconcilerResult.RequeueAfter > 30*time.Second {
+		reconcilerResult.RequeueAfter = 30 * time.Second
+	}
+	return reconcilerResult, nil
+}
+
+// xdsNodeID returns the nodeID that the discovery service uses
+// for the envoy clients of the EnvoyConfig
+func (r *EnvoyConfigReconciler) xdsNodeID(ec *marin3rv1alpha1.EnvoyConfig) string {
+	if r.NamespacedNodeIDs {
+		return xdss.NamespacedNodeID(ec.GetNamespace(), ec.Spec.NodeID)
+	}
+	return ec.Spec.NodeID
+}
+
+// LibrariesEventHandler returns an EventHandler that generates
+// reconcile requests for EnvoyResourceLibraries
+func (r *EnvoyConfigReconciler) LibrariesEventHandler() handler.EventHandler {
+	return r.FilteredEventHandler(
+		&marin3rv1alpha1.EnvoyConfigList{},
+		func(event client.Object, o client.Object) bool {
+			ec := o.(*marin3rv1alpha1.EnvoyConfig)
+			if ec.GetNamespace() != event.GetNamespace() {
+				return false
+			}
+			// check if the library is imported by this EnvoyConfig
+			for _, ref := range ec.Spec.Libraries {
+				if ref.Name == event.GetName() {
+					return true
+				}
+			}
+			return false
+		},
+		logr.Discard(),
+	)
+}
+
+// ConfigMapsEventHandler returns an EventHandler that generates
+// reconcile requests for ConfigMaps
+func (r *EnvoyConfigReconciler) ConfigMapsEventHandler() handler.EventHandler {
+	return r.FilteredEventHandler(
+		&marin3rv1alpha1.EnvoyConfigList{},
+		func(event client.Object, o client.Object) bool {
+			ec := o.(*marin3rv1alpha1.EnvoyConfig)
+			if ec.GetNamespace() != event.GetNamespace() {
+				return false
+			}
+			// check if the ConfigMap holds the value of a parameter of this EnvoyConfig
+			for _, p := range ec.Spec.Parameters {
+				if p.ConfigMapKeyRef != nil && p.ConfigMapKeyRef.Name == event.GetName() {
+					return true
+				}
+			}
+			return false
+		},
+		logr.Discard(),
+	)
 }
 
 // SetupWithManager adds the controller to the manager
@@ -92,5 +178,7 @@ func (r *EnvoyConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&marin3rv1alpha1.EnvoyConfig{}).
 		Owns(&marin3rv1alpha1.EnvoyConfigRevision{}).
+		Watches(&marin3rv1alpha1.EnvoyResourceLibrary{}, r.LibrariesEventHandler()).
+		Watches(&corev1.ConfigMap{}, r.ConfigMapsEventHandler()).
 		Complete(r)
 }
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/3scale-ops/basereconciler/reconciler"
@@ -39,11 +40,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // EnvoyConfigRevisionReconciler reconciles a EnvoyConfigRevision object
@@ -52,50 +57,106 @@ type EnvoyConfigRevisionReconciler struct {
 	XdsCache       xdss.Cache
 	APIVersion     envoy.APIVersion
 	DiscoveryStats *stats.Stats
+	Recorder       record.EventRecorder
+	// LeaderElected is closed once this replica of the discovery service is elected as
+	// leader. A nil channel means that leader election is not used.
+	LeaderElected <-chan struct{}
+	// NamespacedNodeIDs must be set when the discovery service watches several
+	// namespaces, as the nodeIDs of the envoy clients are prefixed with their namespace
+	NamespacedNodeIDs bool
+	// lastSeen keeps the last version of each EnvoyConfigRevision seen by a replica
+	// that is not the leader, so it can clear its xDS cache when the revision is gone
+	lastSeen sync.Map
 }
 
 // Reconcile progresses EnvoyConfigRevision resources to its desired state
 // +kubebuilder:rbac:groups=marin3r.3scale.net,namespace=placeholder,resources=envoyconfigrevisions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=marin3r.3scale.net,namespace=placeholder,resources=envoyconfigrevisions/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="core",namespace=placeholder,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups="discovery.k8s.io",namespace=placeholder,resources=endpointslices,verbs=get;list;watch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=events,verbs=create;patch
 func (r *EnvoyConfigRevisionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	ctx, logger := r.Logger(ctx, "name", req.Name, "namespace", req.Namespace)
 	ecr := &marin3rv1alpha1.EnvoyConfigRevision{}
-	result := r.ManageResourceLifecycle(ctx, req, ecr,
-		// Apply defaults
-		reconciler.WithInitializationFunc(reconciler_util.ResourceDefaulter(ecr)),
-		// convert spec.EnvoyResources to spec.Resources
-		reconciler.WithInMemoryInitializationFunc(func(ctx context.Context, c client.Client, o client.Object) error {
-			if ecr.Spec.EnvoyResources != nil {
-				ecr := o.(*marin3rv1alpha1.EnvoyConfigRevision)
-				if resources, err := (ecr.Spec.EnvoyResources).Resources(ecr.GetSerialization()); err != nil {
-					return err
-				} else {
-					ecr.Spec.Resources = resources
-					ecr.Spec.EnvoyResources = nil
+	// convert spec.EnvoyResources to spec.Resources
+	inMemoryInitialization := func(ctx context.Context, c client.Client, o client.Object) error {
+		if ecr.Spec.EnvoyResources != nil {
+			ecr := o.(*marin3rv1alpha1.EnvoyConfigRevision)
+			if resources, err := (ecr.Spec.EnvoyResources).Resources(ecr.GetSerialization()); err != nil {
+				return err
+			} else {
+				ecr.Spec.Resources = resources
+				ecr.Spec.EnvoyResources = nil
+			}
+		}
+		// the nodeID is only modified in memory, so the revision is
+		// written to the xDS cache under the nodeID of its envoy clients
+		if r.NamespacedNodeIDs {
+			ecr := o.(*marin3rv1alpha1.EnvoyConfigRevision)
+			ecr.Spec.NodeID = xdss.NamespacedNodeID(ecr.GetNamespace(), ecr.Spec.NodeID)
+		}
+		return nil
+	}
+
+	leader := r.isLeader()
+	if leader {
+		result := r.ManageResourceLifecycle(ctx, req, ecr,
+			// Apply defaults
+			reconciler.WithInitializationFunc(reconciler_util.ResourceDefaulter(ecr)),
+			reconciler.WithInMemoryInitializationFunc(inMemoryInitialization),
+			// set finalizer
+			reconciler.WithFinalizer(marin3rv1alpha1.EnvoyConfigRevisionFinalizer),
+			// cleanup logic
+			reconciler.WithFinalizationFunc(func(context.Context, client.Client) error {
+				envoyconfigrevision.CleanupLogic(r.withXdsNodeID(ecr), r.XdsCache, r.DiscoveryStats, logger)
+				logger.Info("finalized EnvoyConfigRevision resource")
+				return nil
+			}),
+		)
+		if result.ShouldReturn() {
+			return result.Values()
+		}
+	} else {
+		// The replicas that are not the leader don't write to the EnvoyConfigRevision,
+		// they just keep their xDS cache in sync with it. The finalizer is managed by
+		// the leader.
+		if err := r.Client.Get(ctx, req.NamespacedName, ecr); err != nil {
+			if errors.IsNotFound(err) {
+				// the leader removed the finalizer before this replica saw the deletion
+				if last, ok := r.lastSeen.LoadAndDelete(req.NamespacedName); ok {
+					envoyconfigrevision.CleanupLogic(last.(*marin3rv1alpha1.EnvoyConfigRevision), r.XdsCache, r.DiscoveryStats, logger)
 				}
+				return ctrl.Result{}, nil
 			}
-			return nil
-		}),
-		// set finalizer
-		reconciler.WithFinalizer(marin3rv1alpha1.EnvoyConfigRevisionFinalizer),
-		// cleanup logic
-		reconciler.WithFinalizationFunc(func(context.Context, client.Client) error {
-			envoyconfigrevision.CleanupLogic(ecr, r.XdsCache, r.DiscoveryStats, logger)
-			logger.Info("finalized EnvoyConfigRevision resource")
-			return nil
-		}),
-	)
-	if result.ShouldReturn() {
-		return result.Values()
+			return ctrl.Result{}, err
+		}
+		if !ecr.GetDeletionTimestamp().IsZero() {
+			r.lastSeen.Delete(req.NamespacedName)
+			envoyconfigrevision.CleanupLogic(r.withXdsNodeID(ecr), r.XdsCache, r.DiscoveryStats, logger)
+			return ctrl.Result{}, nil
+		}
+		if err := inMemoryInitialization(ctx, r.Client, ecr); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.lastSeen.Store(req.NamespacedName, ecr.DeepCopy())
+	}
+
+	// Clear the taint if requested by the user through the untaint annotation. The
+	// reset of the failure stats reaches the other replicas through the stats sync.
+	if _, ok := ecr.GetAnnotations()[marin3rv1alpha1.RevisionUntaintAnnotation]; ok && leader {
+		if err := r.untaintSelf(ctx, ecr, logger); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
 	}
 
 	var vt *marin3rv1alpha1.VersionTracker = nil
 
 	// If this ecr has the RevisionPublishedCondition set to "True" pusblish the resources
-	// to the xds server cache
+	// to the xds server cache. If it has the RevisionCanaryCondition set to "True" publish
+	// the resources as a canary, only for the envoy clients selected by the canary target.
 	if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
 		var err error
 		decoder := envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, r.APIVersion)
@@ -106,7 +167,11 @@ func (r *EnvoyConfigRevisionReconciler) Reconcile(ctx context.Context, req ctrl.
 			envoy_resources.NewGenerator(r.APIVersion),
 		)
 
-		vt, err = cacheReconciler.Reconcile(ctx, req.NamespacedName, ecr.Spec.Resources, ecr.Spec.NodeID, ecr.Spec.Version)
+		if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
+			vt, err = cacheReconciler.Reconcile(ctx, req.NamespacedName, ecr.Spec.Resources, ecr.Spec.Parameters, ecr.Spec.NodeID, ecr.Spec.Version)
+		} else if ecr.Status.IsCanary() {
+			vt, err = cacheReconciler.ReconcileCanary(ctx, req.NamespacedName, ecr.Spec.Resources, ecr.Spec.Parameters, ecr.Spec.NodeID, envoyconfigrevision.CanaryTarget(ecr))
+		}
 
 		// If a type errors.StatusError is returned it means that the config in spec.resources is wrong
 		// and cannot be written into the xDS cache. This is true for any error loading all types of resources
@@ -116,8 +181,10 @@ func (r *EnvoyConfigRevisionReconciler) Reconcile(ctx context.Context, req ctrl.
 			switch err.(type) {
 			case *errors.StatusError:
 				logger.Error(err, fmt.Sprintf("%v", err))
-				if err := r.taintSelf(ctx, ecr, "FailedLoadingResources", err.Error(), logger); err != nil {
-					return ctrl.Result{}, err
+				if leader {
+					if err := r.taintSelf(ctx, ecr, "FailedLoadingResources", err.Error(), logger); err != nil {
+						return ctrl.Result{}, err
+					}
 				}
 			default:
 				return ctrl.Result{}, err
@@ -125,21 +192,70 @@ func (r *EnvoyConfigRevisionReconciler) Reconcile(ctx context.Context, req ctrl.
 		}
 	}
 
-	if ok := envoyconfigrevision.IsStatusReconciled(ecr, vt, r.XdsCache, r.DiscoveryStats); !ok {
+	// Stop serving this revision as a canary once it is no longer one. This is done
+	// after the revision has been published in case it has been promoted.
+	if !ecr.Status.IsCanary() {
+		if _, target, err := r.XdsCache.GetCanarySnapshot(ecr.Spec.NodeID); err == nil && target.Version == ecr.Spec.Version {
+			r.XdsCache.ClearCanary(ecr.Spec.NodeID)
+			logger.Info("cleared canary from xDS cache", "NodeID", ecr.Spec.NodeID)
+		}
+	}
+
+	previousRejections := ecr.Status.RecentRejections
+	if ok := envoyconfigrevision.IsStatusReconciled(ecr, vt, r.XdsCache, r.DiscoveryStats); !ok && leader {
 		if err := r.Client.Status().Update(ctx, ecr); err != nil {
 			logger.Error(err, "unable to update EnvoyConfigRevision status")
+		} else {
+			logger.Info("status updated for EnvoyConfigRevision resource")
+			// the rejections are only reported once they are recorded in the status,
+			// otherwise the events would be emitted again in the next reconcile
+			for _, rejection := range envoyconfigrevision.NewRejections(previousRejections, ecr.Status.RecentRejections) {
+				r.Recorder.Eventf(ecr, corev1.EventTypeWarning, "ResourcesRejected",
+					"%s resources rejected by %d envoy client(s) (code %d): %s",
+					rejection.Type, len(rejection.Pods), rejection.Code, rejection.Message)
+			}
 		}
-		logger.Info("status updated for EnvoyConfigRevision resource")
 	}
 
 	if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
 		return ctrl.Result{Requeue: true, RequeueAfter: 30 * time.Second}, nil
 	}
 
+	// Requeue tainted revisions so the taint is re-evaluated when the cool-down expires
+	if remaining := envoyconfigrevision.TaintCoolDownRemaining(ecr, time.Now()); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
 	return ctrl.Result{}, nil
 
 }
 
+// isLeader returns true if this replica of the discovery service is the
+// leader, which is the only one that writes to the EnvoyConfigRevisions
+func (r *EnvoyConfigRevisionReconciler) isLeader() bool {
+	if r.LeaderElected == nil {
+		return true
+	}
+	select {
+	case <-r.LeaderElected:
+		return true
+	default:
+		return false
+	}
+}
+
+// withXdsNodeID returns a copy of the EnvoyConfigRevision with the nodeID that the
+// discovery service uses for its envoy clients. A copy is used so the nodeID is not
+// written to the API when the finalizer is removed.
+func (r *EnvoyConfigRevisionReconciler) withXdsNodeID(ecr *marin3rv1alpha1.EnvoyConfigRevision) *marin3rv1alpha1.EnvoyConfigRevision {
+	if !r.NamespacedNodeIDs {
+		return ecr
+	}
+	ecr = ecr.DeepCopy()
+	ecr.Spec.NodeID = xdss.NamespacedNodeID(ecr.GetNamespace(), ecr.Spec.NodeID)
+	return ecr
+}
+
 func (r *EnvoyConfigRevisionReconciler) taintSelf(ctx context.Context, ecr *marin3rv1alpha1.EnvoyConfigRevision,
 	reason, msg string, logger logr.Logger) error {
 
@@ -152,6 +268,7 @@ func (r *EnvoyConfigRevisionReconciler) taintSelf(ctx context.Context, ecr *mari
 			Message: msg,
 		})
 		ecr.Status.Tainted = pointer.New(true)
+		ecr.Status.RecordTaintEvent(marin3rv1alpha1.TaintedEvent, reason, msg, time.Now())
 
 		if err := r.Client.Status().Patch(ctx, ecr, patch); err != nil {
 			return err
@@ -162,6 +279,35 @@ func (r *EnvoyConfigRevisionReconciler) taintSelf(ctx context.Context, ecr *mari
 	return nil
 }
 
+// untaintSelf removes the taint from the revision, resetting the NACK counters of
+// its version so it doesn't get tainted again right away, and removes the untaint annotation
+func (r *EnvoyConfigRevisionReconciler) untaintSelf(ctx context.Context, ecr *marin3rv1alpha1.EnvoyConfigRevision,
+	logger logr.Logger) error {
+
+	envoyconfigrevision.ResetFailureStats(ecr, r.DiscoveryStats)
+
+	if meta.IsStatusConditionPresentAndEqual(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition, metav1.ConditionTrue) ||
+		(ecr.Status.Tainted != nil && *ecr.Status.Tainted) {
+		patch := client.MergeFrom(ecr.DeepCopy())
+		meta.RemoveStatusCondition(&ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition)
+		ecr.Status.Tainted = pointer.New(false)
+		ecr.Status.RecordTaintEvent(marin3rv1alpha1.UntaintedEvent, "UntaintRequested",
+			fmt.Sprintf("Taint removed through the %q annotation", marin3rv1alpha1.RevisionUntaintAnnotation), time.Now())
+
+		if err := r.Client.Status().Patch(ctx, ecr, patch); err != nil {
+			return err
+		}
+		logger.Info("Untainted revision")
+	}
+
+	patch := client.MergeFrom(ecr.DeepCopy())
+	annotations := ecr.GetAnnotations()
+	delete(annotations, marin3rv1alpha1.RevisionUntaintAnnotation)
+	ecr.SetAnnotations(annotations)
+
+	return r.Client.Patch(ctx, ecr, patch)
+}
+
 func filterByAPIVersion(obj runtime.Object, version envoy.APIVersion) bool {
 	switch o := obj.(type) {
 	case *marin3rv1alpha1.EnvoyConfigRevision:
@@ -192,6 +338,13 @@ func filterByAPIVersionPredicate(version envoy.APIVersion,
 	}
 }
 
+// isServedRevision returns true if the revision is loaded in the xDS cache, either
+// as the published revision or as a canary, so its snapshot needs to be regenerated
+// when the Secrets or EndpointSlices it uses change
+func isServedRevision(ecr *marin3rv1alpha1.EnvoyConfigRevision) bool {
+	return meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) || ecr.Status.IsCanary()
+}
+
 // SecretsEventHandler returns an EventHandler that generates
 // reconcile requests for Secrets
 func (r *EnvoyConfigRevisionReconciler) SecretsEventHandler() handler.EventHandler {
@@ -203,7 +356,7 @@ func (r *EnvoyConfigRevisionReconciler) SecretsEventHandler() handler.EventHandl
 				return false
 			}
 			ecr := o.(*marin3rv1alpha1.EnvoyConfigRevision)
-			if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
+			if isServedRevision(ecr) {
 				// check if the k8s Secret is relevant for this EnvoyConfigRevision
 				for _, s := range ecr.Spec.Resources {
 					if s.Type == envoy.Secret {
@@ -229,7 +382,7 @@ func (r *EnvoyConfigRevisionReconciler) EndpointSlicesEventHandler() handler.Eve
 		func(event client.Object, o client.Object) bool {
 			endpointSlice := event.(*discoveryv1.EndpointSlice)
 			ecr := o.(*marin3rv1alpha1.EnvoyConfigRevision)
-			if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
+			if isServedRevision(ecr) {
 				// check if the k8s EndpointSlice is relevant for this EnvoyConfigRevision
 				for _, r := range ecr.Spec.Resources {
 					if r.Type == envoy.Endpoint && r.GenerateFromEndpointSlices != nil {
@@ -253,12 +406,50 @@ func (r *EnvoyConfigRevisionReconciler) EndpointSlicesEventHandler() handler.Eve
 	)
 }
 
+// requeueOnLeaderElected returns a channel that receives an event for each
+// EnvoyConfigRevision when this replica is elected as leader, so the new leader writes
+// the status and the finalizers of the revisions without waiting for their next resync
+func (r *EnvoyConfigRevisionReconciler) requeueOnLeaderElected(mgr ctrl.Manager) (<-chan event.GenericEvent, error) {
+	events := make(chan event.GenericEvent)
+	if r.LeaderElected == nil {
+		return events, nil
+	}
+
+	// runnables that don't implement LeaderElectionRunnable only run in the leader
+	err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		list := &marin3rv1alpha1.EnvoyConfigRevisionList{}
+		if err := mgr.GetClient().List(ctx, list); err != nil {
+			r.Log.Error(err, "unable to list EnvoyConfigRevisions after being elected as leader")
+			return nil
+		}
+		for i := range list.Items {
+			select {
+			case events <- event.GenericEvent{Object: &list.Items[i]}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		return nil
+	}))
+
+	return events, err
+}
+
 // SetupWithManager adds the controller to the manager
 func (r *EnvoyConfigRevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	elected, err := r.requeueOnLeaderElected(mgr)
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&marin3rv1alpha1.EnvoyConfigRevision{}).
 		WithEventFilter(filterByAPIVersionPredicate(r.APIVersion, filterByAPIVersion)).
 		Watches(&corev1.Secret{}, r.SecretsEventHandler()).
 		Watches(&discoveryv1.EndpointSlice{}, r.EndpointSlicesEventHandler()).
+		WatchesRawSource(&source.Channel{Source: elected}, &handler.EnqueueRequestForObject{}).
+		// all the replicas of the discovery service need to load
+		// the revisions in their xDS cache, not only the leader
+		WithOptions(controller.Options{NeedLeaderElection: pointer.New(false)}).
 		Complete(r)
 }
@@ -2,20 +2,30 @@ package controllers
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/3scale-ops/basereconciler/reconciler"
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	xdss_v3 "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/v3"
 	"github.com/3scale-ops/marin3r/pkg/envoy"
+	k8sutil "github.com/3scale-ops/marin3r/pkg/util/k8s"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestEnvoyConfigRevisionReconciler_taintSelf(t *testing.T) {
@@ -55,6 +65,145 @@ func TestEnvoyConfigRevisionReconciler_taintSelf(t *testing.T) {
 	})
 }
 
+func TestEnvoyConfigRevisionReconciler_untaintSelf(t *testing.T) {
+
+	err := marin3rv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the stats are kept by the version of each resource type,
+	// which is not the version of the revision
+	ecr := &marin3rv1alpha1.EnvoyConfigRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "ecr", Namespace: "default",
+			Annotations: map[string]string{marin3rv1alpha1.RevisionUntaintAnnotation: ""}},
+		Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{NodeID: "node1", Version: "bbbb"},
+		Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+			Tainted:          pointer.New(true),
+			ProvidesVersions: &marin3rv1alpha1.VersionTracker{Clusters: "5d7c9f"},
+			Conditions: []metav1.Condition{{
+				Type: marin3rv1alpha1.RevisionTaintedCondition, Status: metav1.ConditionTrue, Reason: "test"}},
+		},
+	}
+	dStats := stats.New()
+	dStats.WriteResponseNonce("node1", "cluster", "5d7c9f", "pod1", "1")
+	if _, err := dStats.ReportNACK("node1", "cluster", "pod1", "1", 3, "error"); err != nil {
+		t.Fatal(err)
+	}
+	r := &EnvoyConfigRevisionReconciler{
+		Reconciler: &reconciler.Reconciler{
+			Client: fake.NewClientBuilder().WithObjects(ecr).WithStatusSubresource(&marin3rv1alpha1.EnvoyConfigRevision{}).Build(),
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("test"),
+		},
+		DiscoveryStats: dStats,
+	}
+
+	if err := r.untaintSelf(context.TODO(), ecr, r.Log); err != nil {
+		t.Fatalf("EnvoyConfigRevisionReconciler.untaintSelf() error = %v", err)
+	}
+	if ecr.Status.IsTainted() {
+		t.Errorf("EnvoyConfigRevisionReconciler.untaintSelf() revision is still tainted")
+	}
+	if _, ok := ecr.GetAnnotations()[marin3rv1alpha1.RevisionUntaintAnnotation]; ok {
+		t.Errorf("EnvoyConfigRevisionReconciler.untaintSelf() untaint annotation not removed")
+	}
+	if _, err := dStats.GetCounter("node1", "cluster", "5d7c9f", "pod1", "nack_counter"); err == nil {
+		t.Errorf("EnvoyConfigRevisionReconciler.untaintSelf() NACKs of the resource version not reset")
+	}
+}
+
+func TestEnvoyConfigRevisionReconciler_Reconcile_notLeader(t *testing.T) {
+
+	err := marin3rv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ecr := &marin3rv1alpha1.EnvoyConfigRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: "ecr", Namespace: "default"},
+		Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+			NodeID:  "node1",
+			Version: "bbbb",
+			Resources: []marin3rv1alpha1.Resource{
+				{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension(`{"name": "cluster"}`)},
+			},
+		},
+		Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+			Conditions: []metav1.Condition{{
+				Type:   marin3rv1alpha1.RevisionPublishedCondition,
+				Status: metav1.ConditionTrue,
+				Reason: "test",
+			}},
+		},
+	}
+	r := &EnvoyConfigRevisionReconciler{
+		Reconciler: &reconciler.Reconciler{
+			Client: fake.NewClientBuilder().WithObjects(ecr).WithStatusSubresource(&marin3rv1alpha1.EnvoyConfigRevision{}).Build(),
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("test"),
+		},
+		XdsCache:       xdss_v3.NewCache(),
+		APIVersion:     envoy.APIv3,
+		DiscoveryStats: stats.New(),
+		LeaderElected:  make(chan struct{}),
+	}
+
+	key := types.NamespacedName{Name: "ecr", Namespace: "default"}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("EnvoyConfigRevisionReconciler.Reconcile() error = %v", err)
+	}
+
+	if snap, err := r.XdsCache.GetSnapshot("node1"); err != nil {
+		t.Errorf("EnvoyConfigRevisionReconciler.Reconcile() revision not loaded in the xDS cache: %v", err)
+	} else if len(snap.GetResources(envoy.Cluster)) != 1 {
+		t.Errorf("EnvoyConfigRevisionReconciler.Reconcile() unexpected resources in the xDS cache: %v", snap.GetResources(envoy.Cluster))
+	}
+
+	got := &marin3rv1alpha1.EnvoyConfigRevision{}
+	if err := r.Client.Get(context.TODO(), key, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.GetFinalizers()) > 0 || got.Status.ProvidesVersions != nil {
+		t.Errorf("EnvoyConfigRevisionReconciler.Reconcile() a replica that is not the leader wrote to the revision")
+	}
+
+	// the revision is deleted before this replica sees the deletion timestamp
+	if err := r.Client.Delete(context.TODO(), got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("EnvoyConfigRevisionReconciler.Reconcile() error = %v", err)
+	}
+	if _, err := r.XdsCache.GetSnapshot("node1"); err == nil {
+		t.Errorf("EnvoyConfigRevisionReconciler.Reconcile() deleted revision not cleared from the xDS cache")
+	}
+}
+
+func TestEnvoyConfigRevisionReconciler_isLeader(t *testing.T) {
+	elected := make(chan struct{})
+	close(elected)
+	tests := []struct {
+		name    string
+		elected <-chan struct{}
+		want    bool
+	}{
+		{"Without leader election", nil, true},
+		{"Not elected", make(chan struct{}), false},
+		{"Elected", elected, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &EnvoyConfigRevisionReconciler{LeaderElected: tt.elected}
+			if got := r.isLeader(); got != tt.want {
+				t.Errorf("EnvoyConfigRevisionReconciler.isLeader() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_filterByAPIVersion(t *testing.T) {
 	type args struct {
 		obj     runtime.Object
@@ -100,3 +249,84 @@ func Test_filterByAPIVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestEnvoyConfigRevisionReconciler_EventHandlers(t *testing.T) {
+
+	err := marin3rv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	revision := func(name string, conditions []metav1.Condition, canary *marin3rv1alpha1.CanaryTarget) *marin3rv1alpha1.EnvoyConfigRevision {
+		return &marin3rv1alpha1.EnvoyConfigRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+				NodeID:  "node1",
+				Version: name,
+				Resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Secret, GenerateFromTlsSecret: pointer.New("secret")},
+					{Type: envoy.Endpoint, GenerateFromEndpointSlices: &marin3rv1alpha1.GenerateFromEndpointSlices{
+						Selector:    &metav1.LabelSelector{MatchLabels: map[string]string{"app": "backend"}},
+						ClusterName: "backend",
+					}},
+				},
+			},
+			Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{Conditions: conditions, Canary: canary},
+		}
+	}
+	published := revision("published", []metav1.Condition{{
+		Type: marin3rv1alpha1.RevisionPublishedCondition, Status: metav1.ConditionTrue, Reason: "test"}}, nil)
+	canary := revision("canary", []metav1.Condition{{
+		Type: marin3rv1alpha1.RevisionCanaryCondition, Status: metav1.ConditionTrue, Reason: "test"}},
+		&marin3rv1alpha1.CanaryTarget{Percentage: pointer.New(int32(10))})
+	inactive := revision("inactive", nil, nil)
+
+	r := &EnvoyConfigRevisionReconciler{
+		Reconciler: &reconciler.Reconciler{
+			Client: fake.NewClientBuilder().WithObjects(published, canary, inactive).Build(),
+			Scheme: scheme.Scheme,
+			Log:    ctrl.Log.WithName("test"),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		handler handler.EventHandler
+		object  client.Object
+	}{
+		{
+			name:    "Secret changes enqueue published and canary revisions",
+			handler: r.SecretsEventHandler(),
+			object: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"},
+				Type:       corev1.SecretTypeTLS,
+			},
+		},
+		{
+			name:    "EndpointSlice changes enqueue published and canary revisions",
+			handler: r.EndpointSlicesEventHandler(),
+			object: &discoveryv1.EndpointSlice{
+				ObjectMeta: metav1.ObjectMeta{Name: "backend-xxxx", Namespace: "default", Labels: map[string]string{"app": "backend"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer q.ShutDown()
+			tt.handler.Create(context.TODO(), event.CreateEvent{Object: tt.object}, q)
+
+			got := map[string]bool{}
+			for q.Len() > 0 {
+				item, _ := q.Get()
+				got[item.(reconcile.Request).Name] = true
+				q.Done(item)
+			}
+			want := map[string]bool{"published": true, "canary": true}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("EventHandler enqueued = %v, want %v", got, want)
+			}
+		})
+	}
+}
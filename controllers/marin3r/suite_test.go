@@ -107,6 +107,7 @@ var _ = BeforeSuite(func() {
 			Log:    ctrl.Log.WithName("controllers").WithName("envoyconfig"),
 			Scheme: mgr.GetScheme(),
 		},
+		DiscoveryStats: stats.New(),
 	}).SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -120,6 +121,7 @@ var _ = BeforeSuite(func() {
 		XdsCache:       xdss_v3.NewCache(),
 		APIVersion:     envoy.APIv3,
 		DiscoveryStats: stats.New(),
+		Recorder:       mgr.GetEventRecorderFor("envoyconfigrevision_v3"),
 	}
 	err = ecrV3Reconciler.SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
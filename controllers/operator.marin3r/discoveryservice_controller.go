@@ -18,21 +18,27 @@ package controllers
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/3scale-ops/basereconciler/mutators"
 	"github.com/3scale-ops/basereconciler/reconciler"
 	"github.com/3scale-ops/basereconciler/resource"
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	dsreconcilers "github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservice"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservice/generators"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 )
 
 // DiscoveryServiceReconciler reconciles a DiscoveryService object
@@ -50,59 +56,98 @@ type DiscoveryServiceReconciler struct {
 // +kubebuilder:rbac:groups=operator.marin3r.3scale.net,namespace=placeholder,resources=discoveryservicecertificates,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="core",namespace=placeholder,resources=pods,verbs=list;watch;get
 // +kubebuilder:rbac:groups="core",namespace=placeholder,resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=configmaps,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="coordination.k8s.io",namespace=placeholder,resources=leases,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="discovery.k8s.io",namespace=placeholder,resources=endpointslices,verbs=get;list;watch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="core",resources=namespaces,verbs=get;list;watch
 
 func (r *DiscoveryServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
-	ctx, _ = r.Logger(ctx, "name", req.Name, "namespace", req.Namespace)
+	ctx, logger := r.Logger(ctx, "name", req.Name, "namespace", req.Namespace)
 	ds := &operatorv1alpha1.DiscoveryService{}
-	result := r.ManageResourceLifecycle(ctx, req, ds)
+	result := r.ManageResourceLifecycle(ctx, req, ds,
+		reconciler.WithFinalizer(operatorv1alpha1.Finalizer),
+		// delete the resources in the watched namespaces, which are not owned by the DiscoveryService
+		reconciler.WithFinalizationFunc(func(ctx context.Context, c client.Client) error {
+			gen := r.generatorOptions(ds)
+			watched := dsreconcilers.NewWatchNamespacesReconciler(ctx, logger.WithName("watch-namespaces"), c, gen)
+			return watched.Cleanup()
+		}),
+	)
 	if result.ShouldReturn() {
 		return result.Values()
 	}
 
-	gen := generators.GeneratorOptions{
-		InstanceName:                      ds.GetName(),
-		Namespace:                         ds.GetNamespace(),
-		RootCertificateNamePrefix:         "marin3r-ca-cert",
-		RootCertificateCommonNamePrefix:   "marin3r-ca",
-		RootCertificateDuration:           ds.GetRootCertificateAuthorityOptions().Duration.Duration,
-		ServerCertificateNamePrefix:       "marin3r-server-cert",
-		ServerCertificateCommonNamePrefix: "marin3r-server",
-		ServerCertificateDuration:         ds.GetServerCertificateOptions().Duration.Duration,
-		ClientCertificateDuration:         func() (d time.Duration) { d, _ = time.ParseDuration("48h"); return }(),
-		XdsServerPort:                     int32(ds.GetXdsServerPort()),
-		MetricsServerPort:                 int32(ds.GetMetricsPort()),
-		ProbePort:                         int32(ds.GetProbePort()),
-		ServiceType:                       operatorv1alpha1.ClusterIPType,
-		DeploymentImage:                   ds.GetImage(),
-		DeploymentResources:               ds.Resources(),
-		Debug:                             ds.Debug(),
-		PodPriorityClass:                  ds.GetPriorityClass(),
+	watchNamespaces, err := dsreconcilers.WatchNamespaces(ctx, r.Client, ds)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
+	gen := r.generatorOptions(ds)
+	gen.WatchNamespaces = watchNamespaces
+
+	// drive the root CA rotation, which decides the bundle of root CAs that the
+	// discovery service trusts and whether the next root CA is required
+	rotation := dsreconcilers.NewRootCARotationReconciler(ctx, logger.WithName("root-ca-rotation"), r.Client, ds,
+		gen.RootCertName(), gen.NextRootCertName())
+	rotationResult, err := rotation.Reconcile()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	bundle := rotation.GetBundle()
+
 	serverCertHash, err := r.calculateServerCertificateHash(ctx, types.NamespacedName{Name: gen.ServerCertName(), Namespace: gen.Namespace})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// client certificates are only issued per nodeID when the discovery service
+	// enforces the binding, otherwise the sidecars use the shared client certificate
+	var nodeIDs []string
+	if gen.EnforceNodeIDBinding {
+		if nodeIDs, err = dsreconcilers.NodeIDs(ctx, r.Client, ds.GetNamespace()); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	resources := []resource.TemplateInterface{
 		resource.NewTemplateFromObjectFunction(gen.RootCertificationAuthority).Apply(dscDefaulter),
+		resource.NewTemplateFromObjectFunction(gen.NextRootCertificationAuthority).Apply(dscDefaulter).
+			WithEnabled(rotation.IsNextRootRequired()),
+		resource.NewTemplateFromObjectFunction(gen.CABundle(bundle)).WithEnabled(bundle != nil),
 		resource.NewTemplateFromObjectFunction(gen.ServerCertificate).Apply(dscDefaulter),
 		resource.NewTemplateFromObjectFunction(gen.ClientCertificate).Apply(dscDefaulter),
 		resource.NewTemplateFromObjectFunction(gen.ServiceAccount),
 		resource.NewTemplateFromObjectFunction(gen.Role),
 		resource.NewTemplateFromObjectFunction(gen.RoleBinding),
 		resource.NewTemplateFromObjectFunction(gen.Service).WithMutation(mutators.SetServiceLiveValues()),
-		resource.NewTemplateFromObjectFunction(gen.Deployment(serverCertHash)).WithEnabled(serverCertHash != ""),
+		// the data of the checkpoint is written by the discovery service
+		resource.NewTemplateFromObjectFunction(gen.StatsCheckpoint).
+			WithEnsureProperties([]resource.Property{"metadata.labels"}).
+			WithEnabled(gen.StatsCheckpointEnabled()),
+		resource.NewTemplateFromObjectFunction(gen.Deployment(serverCertHash)).WithEnabled(serverCertHash != "" && bundle != nil),
+	}
+	// issue a client certificate for the sidecars of each nodeID
+	for _, nodeID := range nodeIDs {
+		resources = append(resources, resource.NewTemplateFromObjectFunction(gen.NodeClientCertificate(nodeID)).Apply(dscDefaulter))
 	}
 
 	result = r.ReconcileOwnedResources(ctx, ds, resources)
 	if result.ShouldReturn() {
 		return result.Values()
 	}
-	// requeue if the server certificate is not ready
-	if serverCertHash == "" {
+
+	watched := dsreconcilers.NewWatchNamespacesReconciler(ctx, logger.WithName("watch-namespaces"), r.Client, gen)
+	if err := watched.Reconcile(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.deleteStaleNodeClientCertificates(ctx, ds, nodeIDs); err != nil {
+		return ctrl.Result{}, err
+	}
+	// requeue if the server certificate or the CA bundle are not ready
+	if serverCertHash == "" || bundle == nil {
 		return ctrl.Result{Requeue: true}, nil
 	}
 
@@ -114,12 +159,40 @@ func (r *DiscoveryServiceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 				return true
 			}
 			return false
-		})
+		},
+		rotation.MutateStatus)
 	if result.ShouldReturn() {
 		return result.Values()
 	}
 
-	return ctrl.Result{}, nil
+	return rotationResult, nil
+}
+
+// generatorOptions returns the options to generate the resources of the DiscoveryService
+func (r *DiscoveryServiceReconciler) generatorOptions(ds *operatorv1alpha1.DiscoveryService) generators.GeneratorOptions {
+	return generators.GeneratorOptions{
+		InstanceName:                      ds.GetName(),
+		Namespace:                         ds.GetNamespace(),
+		RootCertificateNamePrefix:         "marin3r-ca-cert",
+		RootCertificateCommonNamePrefix:   "marin3r-ca",
+		RootCertificateDuration:           ds.GetRootCertificateAuthorityOptions().Duration.Duration,
+		ServerCertificateNamePrefix:       "marin3r-server-cert",
+		ServerCertificateCommonNamePrefix: "marin3r-server",
+		ServerCertificateDuration:         ds.GetServerCertificateOptions().Duration.Duration,
+		ClientCertificateDuration:         func() (d time.Duration) { d, _ = time.ParseDuration("48h"); return }(),
+		PrivateKey:                        ds.GetPrivateKeyConfig(),
+		XdsServerPort:                     int32(ds.GetXdsServerPort()),
+		MetricsServerPort:                 int32(ds.GetMetricsPort()),
+		ProbePort:                         int32(ds.GetProbePort()),
+		ServiceType:                       operatorv1alpha1.ClusterIPType,
+		DeploymentImage:                   ds.GetImage(),
+		DeploymentResources:               ds.Resources(),
+		Debug:                             ds.Debug(),
+		PodPriorityClass:                  ds.GetPriorityClass(),
+		EnforceNodeIDBinding:              ds.NodeIDBindingEnforced(),
+		Replicas:                          ds.GetReplicas(),
+		MultiNamespace:                    ds.IsMultiNamespace(),
+	}
 }
 
 func (r *DiscoveryServiceReconciler) calculateServerCertificateHash(ctx context.Context, key types.NamespacedName) (string, error) {
@@ -138,12 +211,63 @@ func (r *DiscoveryServiceReconciler) calculateServerCertificateHash(ctx context.
 	return serverDSC.Status.GetCertificateHash(), nil
 }
 
+// deleteStaleNodeClientCertificates deletes the client certificates issued for nodeIDs that
+// are no longer used by any EnvoyConfig. This is required because the resource pruner is disabled.
+func (r *DiscoveryServiceReconciler) deleteStaleNodeClientCertificates(ctx context.Context,
+	ds *operatorv1alpha1.DiscoveryService, nodeIDs []string) error {
+
+	list := &operatorv1alpha1.DiscoveryServiceCertificateList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(ds.GetNamespace())); err != nil {
+		return err
+	}
+
+	for idx := range list.Items {
+		dsc := &list.Items[idx]
+		nodeID, ok := dsc.GetAnnotations()[generators.NodeIDAnnotation]
+		if !ok || !metav1.IsControlledBy(dsc, ds) {
+			continue
+		}
+		if i := sort.SearchStrings(nodeIDs, nodeID); i < len(nodeIDs) && nodeIDs[i] == nodeID {
+			continue
+		}
+		if err := r.Client.Delete(ctx, dsc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func dscDefaulter(o client.Object) (*operatorv1alpha1.DiscoveryServiceCertificate, error) {
 	dsc := o.(*operatorv1alpha1.DiscoveryServiceCertificate)
 	dsc.Default()
 	return dsc, nil
 }
 
+// EnvoyConfigHandler returns an EventHandler to watch for EnvoyConfigs, so the
+// client certificates of the nodeIDs in the namespace are kept up to date
+func (r *DiscoveryServiceReconciler) EnvoyConfigHandler() handler.EventHandler {
+	return r.FilteredEventHandler(
+		&operatorv1alpha1.DiscoveryServiceList{},
+		func(event client.Object, o client.Object) bool {
+			return event.GetNamespace() == o.GetNamespace() || o.(*operatorv1alpha1.DiscoveryService).IsMultiNamespace()
+		},
+		logr.Discard(),
+	)
+}
+
+// NamespaceHandler returns an EventHandler to watch for Namespaces, so the
+// namespaces selected by the DiscoveryServices in multi-namespace mode are kept up to date
+func (r *DiscoveryServiceReconciler) NamespaceHandler() handler.EventHandler {
+	return r.FilteredEventHandler(
+		&operatorv1alpha1.DiscoveryServiceList{},
+		func(event client.Object, o client.Object) bool {
+			return o.(*operatorv1alpha1.DiscoveryService).IsMultiNamespace()
+		},
+		logr.Discard(),
+	)
+}
+
 // SetupWithManager adds the controller to the manager
 func (r *DiscoveryServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
@@ -154,6 +278,9 @@ func (r *DiscoveryServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
 		Owns(&corev1.ServiceAccount{}).
+		Owns(&corev1.Secret{}).
 		Owns(&operatorv1alpha1.DiscoveryServiceCertificate{}).
+		Watches(&marin3rv1alpha1.EnvoyConfig{}, r.EnvoyConfigHandler()).
+		Watches(&corev1.Namespace{}, r.NamespaceHandler()).
 		Complete(r)
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"time"
 
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/envoy"
 	"github.com/3scale-ops/marin3r/pkg/envoy/container/defaults"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
 	. "github.com/onsi/ginkgo/v2"
@@ -97,6 +99,20 @@ var _ = Describe("DiscoveryService controller", func() {
 				Expect(dsc.Spec.ValidFor).To(Equal(int64(ds.GetRootCertificateAuthorityOptions().Duration.Seconds())))
 			}
 
+			By("waiting for the CA bundle Secret to be created")
+			{
+				secret := &corev1.Secret{}
+				Eventually(func() error {
+					return k8sClient.Get(
+						context.Background(),
+						types.NamespacedName{Name: "marin3r-ca-bundle-instance", Namespace: namespace},
+						secret,
+					)
+				}, 60*time.Second, 5*time.Second).ShouldNot(HaveOccurred())
+
+				Expect(secret.Data).To(HaveKey("tls.crt"))
+			}
+
 			By("waiting for the server DiscoveryServiceCertificate to be created")
 			{
 				dsc := &operatorv1alpha1.DiscoveryServiceCertificate{}
@@ -188,6 +204,48 @@ var _ = Describe("DiscoveryService controller", func() {
 				}, 60*time.Second, 5*time.Second).ShouldNot(HaveOccurred())
 			}
 		})
+
+		It("issues a client certificate for each nodeID if binding is enforced", func() {
+
+			By("enforcing the nodeID binding")
+			Eventually(func() error {
+				if err := k8sClient.Get(context.Background(), types.NamespacedName{Name: "instance", Namespace: namespace}, ds); err != nil {
+					return err
+				}
+				ds.Spec.EnforceNodeIDBinding = pointer.New(true)
+				return k8sClient.Update(context.Background(), ds)
+			}, 60*time.Second, 5*time.Second).ShouldNot(HaveOccurred())
+
+			By("creating an EnvoyConfig")
+			ec := &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: namespace},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					EnvoyAPI:       pointer.New(envoy.APIv3),
+					NodeID:         "test-node",
+					EnvoyResources: &marin3rv1alpha1.EnvoyResources{},
+				},
+			}
+			err := k8sClient.Create(context.Background(), ec)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("waiting for the client certificate of the nodeID to be created")
+			key := types.NamespacedName{Name: defaults.SidecarNodeClientCertificate("test-node"), Namespace: namespace}
+			dsc := &operatorv1alpha1.DiscoveryServiceCertificate{}
+			Eventually(func() error {
+				return k8sClient.Get(context.Background(), key, dsc)
+			}, 60*time.Second, 5*time.Second).ShouldNot(HaveOccurred())
+			Expect(dsc.Spec.Hosts).To(Equal([]string{"test-node"}))
+
+			By("deleting the EnvoyConfig")
+			err = k8sClient.Delete(context.Background(), ec)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("waiting for the client certificate of the nodeID to be deleted")
+			Eventually(func() bool {
+				err := k8sClient.Get(context.Background(), key, dsc)
+				return errors.IsNotFound(err)
+			}, 60*time.Second, 5*time.Second).Should(BeTrue())
+		})
 	})
 
 })
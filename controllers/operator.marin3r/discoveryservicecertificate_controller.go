@@ -23,9 +23,13 @@ import (
 	reconciler_util "github.com/3scale-ops/basereconciler/util"
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
 	discoveryservicecertificate "github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate"
+	"github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers"
+	certmanager_provider "github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers/certmanager"
 	marin3r_provider "github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers/marin3r"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -34,11 +38,15 @@ import (
 // DiscoveryServiceCertificateReconciler reconciles a DiscoveryServiceCertificate object
 type DiscoveryServiceCertificateReconciler struct {
 	*reconciler.Reconciler
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=operator.marin3r.3scale.net,namespace=placeholder,resources=discoveryservicecertificates,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=operator.marin3r.3scale.net,namespace=placeholder,resources=discoveryservicecertificates/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="core",namespace=placeholder,resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="core",namespace=placeholder,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=cert-manager.io,namespace=placeholder,resources=certificates,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=cert-manager.io,namespace=placeholder,resources=certificates/status,verbs=get;update;patch
 
 func (r *DiscoveryServiceCertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
@@ -48,13 +56,22 @@ func (r *DiscoveryServiceCertificateReconciler) Reconcile(ctx context.Context, r
 		reconciler.WithInitializationFunc(reconciler_util.ResourceDefaulter(dsc)),
 	)
 	if result.ShouldReturn() {
+		// The resource is gone if it could not be found without
+		// any other error, so its metrics can be removed
+		if result.Error == nil && dsc.GetUID() == "" {
+			discoveryservicecertificate.DeleteMetrics(req.Namespace, req.Name)
+		}
 		return result.Values()
 	}
 
-	// Only the internal certificate provider is currently supported
-	provider := marin3r_provider.NewCertificateProvider(ctx, log, r.Client, r.Scheme, dsc)
+	var provider providers.CertificateProvider
+	if dsc.Spec.Signer.CertManager != nil {
+		provider = certmanager_provider.NewCertificateProvider(ctx, log, r.Client, r.Scheme, dsc)
+	} else {
+		provider = marin3r_provider.NewCertificateProvider(ctx, log, r.Client, r.Scheme, dsc)
+	}
 
-	certificateReconciler := discoveryservicecertificate.NewCertificateReconciler(ctx, log, r.Client, r.Scheme, dsc, provider)
+	certificateReconciler := discoveryservicecertificate.NewCertificateReconciler(ctx, log, r.Client, r.Scheme, dsc, provider, r.Recorder)
 	reconcilerResult, err := certificateReconciler.Reconcile()
 	if reconcilerResult.Requeue || err != nil {
 		return reconcilerResult, err
@@ -94,11 +111,35 @@ func (r *DiscoveryServiceCertificateReconciler) IssuerChangedHandler() handler.E
 	)
 }
 
+// SecretChangedHandler returns an EventHandler that generates reconcile requests
+// for the DiscoveryServiceCertificates that store their certificate in the Secret.
+// The Secrets written by cert-manager are not owned by the DiscoveryServiceCertificate,
+// so renewals done by cert-manager on its own are only seen through this handler.
+func (r *DiscoveryServiceCertificateReconciler) SecretChangedHandler() handler.EventHandler {
+	return r.FilteredEventHandler(
+		&operatorv1alpha1.DiscoveryServiceCertificateList{},
+		func(event client.Object, o client.Object) bool {
+			cert := o.(*operatorv1alpha1.DiscoveryServiceCertificate)
+			return cert.GetNamespace() == event.GetNamespace() && cert.Spec.SecretRef.Name == event.GetName()
+		},
+		logr.Discard(),
+	)
+}
+
 // SetupWithManager adds the controller to the manager
 func (r *DiscoveryServiceCertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&operatorv1alpha1.DiscoveryServiceCertificate{}).
-		Owns(&corev1.Secret{}).
-		Watches(&operatorv1alpha1.DiscoveryServiceCertificate{}, r.IssuerChangedHandler()).
-		Complete(r)
+		Watches(&corev1.Secret{}, r.SecretChangedHandler()).
+		Watches(&operatorv1alpha1.DiscoveryServiceCertificate{}, r.IssuerChangedHandler())
+
+	// cert-manager is optional, so its Certificates are only watched when the CRD is installed
+	gvk := certmanager_provider.CertificateGVK
+	if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+		cert := &unstructured.Unstructured{}
+		cert.SetGroupVersionKind(gvk)
+		b = b.Owns(cert)
+	}
+
+	return b.Complete(r)
 }
@@ -91,6 +91,7 @@ func (r *EnvoyDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		XdssAdress:           fmt.Sprintf("%s.%s.%s", ds.GetServiceConfig().Name, ds.GetNamespace(), "svc"),
 		XdssPort:             int(ds.GetXdsServerPort()),
 		EnvoyAPIVersion:      ec.GetEnvoyAPIVersion(),
+		DeltaXds:             ed.DeltaXds(),
 		EnvoyNodeID:          ec.Spec.NodeID,
 		EnvoyClusterID: func() string {
 			if ed.Spec.ClusterID != nil {
@@ -101,6 +102,8 @@ func (r *EnvoyDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		ClientCertificateName:     fmt.Sprintf("%s-%s", defaults.DeploymentClientCertificate, ed.GetName()),
 		ClientCertificateDuration: ed.ClientCertificateDuration(),
 		SigningCertificateName:    ds.GetRootCertificateAuthorityOptions().SecretName,
+		PrivateKey:                ds.GetPrivateKeyConfig(),
+		EnforceNodeIDBinding:      ds.NodeIDBindingEnforced(),
 		DeploymentImage:           ed.Image(),
 		DeploymentResources:       ed.Resources(),
 		ExposedPorts:              ed.Spec.Ports,
@@ -22,6 +22,16 @@ func init() {
 				"spec.clusterIPs",
 			},
 		})
+	config.SetDefaultReconcileConfigForGVK(
+		schema.FromAPIVersionAndKind("v1", "Secret"),
+		config.ReconcileConfigForGVK{
+			EnsureProperties: []string{
+				"metadata.annotations",
+				"metadata.labels",
+				"type",
+				"data",
+			},
+		})
 	config.SetDefaultReconcileConfigForGVK(
 		schema.FromAPIVersionAndKind("apps/v1", "Deployment"),
 		config.ReconcileConfigForGVK{
@@ -105,6 +105,7 @@ var _ = BeforeSuite(func() {
 	err = (&DiscoveryServiceCertificateReconciler{
 		Reconciler: reconciler.NewFromManager(mgr).
 			WithLogger(ctrl.Log.WithName("controllers").WithName("discoveryservicecertificate")),
+		Recorder: mgr.GetEventRecorderFor("discoveryservicecertificate"),
 	}).SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -18,6 +18,7 @@ var (
 	notBefore      string
 	isServer       bool
 	isCA           bool
+	keyAlgorithm   string
 	keySize        int
 	outFileName    string
 )
@@ -37,7 +38,8 @@ func init() {
 	cmd.Flags().StringVar(&notAfter, "not-after", "", "End of the the certificate's validity period, in RFC3339 format as in '2006-01-02T15:04:05Z'")
 	cmd.Flags().BoolVar(&isServer, "is-server-certificate", false, "Set true if the certificate is issued for server purposes (defaults to false)")
 	cmd.Flags().BoolVar(&isCA, "is-ca-certificate", false, "Set true if the certificate is a certification authority (defaults to false)")
-	cmd.Flags().IntVar(&keySize, "key-size", 2048, "Size of the RSA key")
+	cmd.Flags().StringVar(&keyAlgorithm, "key-algorithm", "RSA", "Algorithm of the private key, one of RSA, ECDSA or Ed25519")
+	cmd.Flags().IntVar(&keySize, "key-size", 0, "Size of the private key (defaults to 2048 for RSA and 256 for ECDSA keys)")
 	cmd.Flags().StringVar(&outFileName, "out", "", "Name of the output file. The extension '.crt' will be appended to the certificate file name and the "+
 		"extension '.key' will be appended to the key file name. Stdout output if unset.")
 
@@ -95,6 +97,7 @@ func run(cmd *cobra.Command, args []string) {
 		after.Sub(before),
 		isServer,
 		isCA,
+		pki.KeyOptions{Algorithm: pki.KeyAlgorithm(keyAlgorithm), Size: keySize},
 		func() []string {
 			if isServer {
 				return []string{commonName}
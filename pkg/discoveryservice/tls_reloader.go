@@ -0,0 +1,227 @@
+package discoveryservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/3scale-ops/marin3r/pkg/util/clock"
+	"github.com/go-logr/logr"
+)
+
+// retiredRoot is a CA certificate that has been removed from the
+// CA file but is still trusted until the overlap window expires
+type retiredRoot struct {
+	cert    *x509.Certificate
+	expires time.Time
+}
+
+// TLSReloader keeps the server certificate and the client CA of the xDS server
+// in sync with the files in disk, so certificate renewals and CA rotations are
+// picked up without restarting the server. When the CA changes, the previous
+// roots are still trusted during the configured overlap window so clients have
+// time to get certificates issued by the new CA.
+type TLSReloader struct {
+	certPath  string
+	keyPath   string
+	caPath    string
+	caOverlap time.Duration
+	logger    logr.Logger
+	clock     clock.Clock
+
+	mu          sync.RWMutex
+	certBytes   []byte
+	keyBytes    []byte
+	caBytes     []byte
+	certificate *tls.Certificate
+	roots       []*x509.Certificate
+	retired     []retiredRoot
+}
+
+// NewTLSReloader returns a TLSReloader for the given files. It fails if the
+// certificate or the CA cannot be loaded.
+func NewTLSReloader(certPath, keyPath, caPath string, caOverlap time.Duration, logger logr.Logger) (*TLSReloader, error) {
+	r := &TLSReloader{
+		certPath:  certPath,
+		keyPath:   keyPath,
+		caPath:    caPath,
+		caOverlap: caOverlap,
+		logger:    logger,
+		clock:     clock.Real{},
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start polls the files for changes with the given interval until the context is cancelled.
+// Polling is used instead of filesystem notifications because Secret volumes are updated
+// by swapping symlinks, which is not reliably reported.
+func (r *TLSReloader) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Reload(); err != nil {
+					r.logger.Error(err, "unable to reload TLS certificates, keeping the previous ones")
+				}
+			}
+		}
+	}()
+}
+
+// Reload reads the files from disk and updates the server certificate and
+// the client CA if they have changed
+func (r *TLSReloader) Reload() error {
+	certBytes, err := os.ReadFile(r.certPath)
+	if err != nil {
+		return err
+	}
+	keyBytes, err := os.ReadFile(r.keyPath)
+	if err != nil {
+		return err
+	}
+	caBytes, err := os.ReadFile(r.caPath)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !bytes.Equal(certBytes, r.certBytes) || !bytes.Equal(keyBytes, r.keyBytes) {
+		certificate, err := tls.X509KeyPair(certBytes, keyBytes)
+		if err != nil {
+			return err
+		}
+		if r.certificate != nil {
+			r.logger.Info("reloaded server certificate")
+		}
+		r.certificate = &certificate
+		r.certBytes = certBytes
+		r.keyBytes = keyBytes
+	}
+
+	if !bytes.Equal(caBytes, r.caBytes) {
+		roots, err := parseCertificates(caBytes)
+		if err != nil {
+			return err
+		}
+		if r.roots != nil {
+			r.retire(roots)
+			r.logger.Info("reloaded client CA", "overlap", r.caOverlap.String())
+		}
+		r.roots = roots
+		r.caBytes = caBytes
+	}
+
+	return nil
+}
+
+// retire keeps trusting the current roots not present in the new
+// ones until the overlap window expires. Must be called with the lock held.
+func (r *TLSReloader) retire(roots []*x509.Certificate) {
+	if r.caOverlap <= 0 {
+		r.retired = nil
+		return
+	}
+
+	now := r.clock.Now()
+	retired := []retiredRoot{}
+	// drop the roots that have expired or are trusted again
+	for _, rr := range r.retired {
+		if now.Before(rr.expires) && !containsCertificate(roots, rr.cert) {
+			retired = append(retired, rr)
+		}
+	}
+	for _, old := range r.roots {
+		if !containsCertificate(roots, old) {
+			retired = append(retired, retiredRoot{cert: old, expires: now.Add(r.caOverlap)})
+		}
+	}
+	r.retired = retired
+}
+
+// GetCertificate returns the current server certificate.
+// It can be used as tls.Config.GetCertificate.
+func (r *TLSReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+// ClientCAs returns a pool with the current roots and the retired
+// roots whose overlap window has not expired yet
+func (r *TLSReloader) ClientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pool := x509.NewCertPool()
+	for _, root := range r.roots {
+		pool.AddCert(root)
+	}
+	now := r.clock.Now()
+	for _, retired := range r.retired {
+		if now.Before(retired.expires) {
+			pool.AddCert(retired.cert)
+		}
+	}
+	return pool
+}
+
+// ServerTLSConfig returns a copy of the given tls.Config that uses the
+// current server certificate and client CA for each new connection
+func (r *TLSReloader) ServerTLSConfig(base *tls.Config) *tls.Config {
+	cfg := base.Clone()
+	cfg.GetCertificate = r.GetCertificate
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.GetCertificate = r.GetCertificate
+		c.ClientCAs = r.ClientCAs()
+		return c, nil
+	}
+	return cfg
+}
+
+func parseCertificates(pemBytes []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in CA file")
+	}
+	return certs, nil
+}
+
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package discoveryservice
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/3scale-ops/marin3r/pkg/util/clock"
+	"github.com/3scale-ops/marin3r/pkg/util/pki"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type testCA struct {
+	pem  []byte
+	cert *x509.Certificate
+	key  interface{}
+}
+
+func newTestCA(t *testing.T, cn string) testCA {
+	crt, key, err := pki.GenerateCertificate(nil, nil, cn, time.Hour, false, true, pki.KeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := pki.LoadX509Certificate(crt)
+	signer, _ := pki.DecodePrivateKeyBytes(key)
+	return testCA{pem: crt, cert: cert, key: signer}
+}
+
+func (ca testCA) issue(t *testing.T, cn string, isServer bool) ([]byte, []byte, *x509.Certificate) {
+	crt, key, err := pki.GenerateCertificate(ca.cert, ca.key, cn, time.Hour, isServer, false, pki.KeyOptions{}, cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := pki.LoadX509Certificate(crt)
+	return crt, key, cert
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func trusts(pool *x509.CertPool, cert *x509.Certificate) bool {
+	_, err := cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	return err == nil
+}
+
+func TestTLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	caPath := filepath.Join(dir, "ca.crt")
+
+	oldCA := newTestCA(t, "old-ca")
+	newCA := newTestCA(t, "new-ca")
+	_, _, oldClient := oldCA.issue(t, "old-client", false)
+	_, _, newClient := newCA.issue(t, "new-client", false)
+
+	setup := func(t *testing.T, overlap time.Duration) *TLSReloader {
+		crt, key, _ := oldCA.issue(t, "server", true)
+		writeFile(t, certPath, crt)
+		writeFile(t, keyPath, key)
+		writeFile(t, caPath, oldCA.pem)
+		r, err := NewTLSReloader(certPath, keyPath, caPath, overlap, ctrl.Log)
+		if err != nil {
+			t.Fatalf("NewTLSReloader() error = %v", err)
+		}
+		r.clock = clock.NewTest(time.Unix(0, 0))
+		return r
+	}
+
+	t.Run("Reloads the server certificate", func(t *testing.T) {
+		r := setup(t, 0)
+		crt, key, want := oldCA.issue(t, "renewed-server", true)
+		writeFile(t, certPath, crt)
+		writeFile(t, keyPath, key)
+		if err := r.Reload(); err != nil {
+			t.Fatalf("TLSReloader.Reload() error = %v", err)
+		}
+		got, _ := r.GetCertificate(&tls.ClientHelloInfo{})
+		if leaf, _ := x509.ParseCertificate(got.Certificate[0]); !leaf.Equal(want) {
+			t.Errorf("TLSReloader.GetCertificate() = %v, want %v", leaf.Subject.CommonName, want.Subject.CommonName)
+		}
+	})
+
+	t.Run("Keeps the previous certificate if the new one is invalid", func(t *testing.T) {
+		r := setup(t, 0)
+		before, _ := r.GetCertificate(&tls.ClientHelloInfo{})
+		writeFile(t, certPath, []byte("invalid"))
+		if err := r.Reload(); err == nil {
+			t.Errorf("TLSReloader.Reload() expected error")
+		}
+		if got, _ := r.GetCertificate(&tls.ClientHelloInfo{}); got != before {
+			t.Errorf("TLSReloader.GetCertificate() = certificate was replaced")
+		}
+	})
+
+	t.Run("Replaces the CA without overlap", func(t *testing.T) {
+		r := setup(t, 0)
+		writeFile(t, caPath, newCA.pem)
+		if err := r.Reload(); err != nil {
+			t.Fatalf("TLSReloader.Reload() error = %v", err)
+		}
+		pool := r.ClientCAs()
+		if trusts(pool, oldClient) || !trusts(pool, newClient) {
+			t.Errorf("TLSReloader.ClientCAs() = expected to only trust the new CA")
+		}
+	})
+
+	t.Run("Trusts old and new CAs during the overlap window", func(t *testing.T) {
+		r := setup(t, time.Hour)
+		writeFile(t, caPath, newCA.pem)
+		if err := r.Reload(); err != nil {
+			t.Fatalf("TLSReloader.Reload() error = %v", err)
+		}
+		pool := r.ClientCAs()
+		if !trusts(pool, oldClient) || !trusts(pool, newClient) {
+			t.Errorf("TLSReloader.ClientCAs() = expected to trust both CAs")
+		}
+
+		r.clock = clock.NewTest(time.Unix(0, 0).Add(2 * time.Hour))
+		pool = r.ClientCAs()
+		if trusts(pool, oldClient) || !trusts(pool, newClient) {
+			t.Errorf("TLSReloader.ClientCAs() = expected to only trust the new CA after the overlap window")
+		}
+	})
+
+	t.Run("Uses the current CA for new connections", func(t *testing.T) {
+		r := setup(t, 0)
+		cfg := r.ServerTLSConfig(&tls.Config{ClientAuth: tls.RequireAndVerifyClientCert})
+		writeFile(t, caPath, newCA.pem)
+		if err := r.Reload(); err != nil {
+			t.Fatalf("TLSReloader.Reload() error = %v", err)
+		}
+		got, _ := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+		if got.ClientAuth != tls.RequireAndVerifyClientCert || got.GetCertificate == nil || !trusts(got.ClientCAs, newClient) {
+			t.Errorf("TLSReloader.ServerTLSConfig() = unexpected config for client")
+		}
+	})
+}
@@ -19,6 +19,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
@@ -28,6 +29,7 @@ import (
 	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	server_v3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -47,6 +49,8 @@ const (
 	grpcMaxConnectionAgeGrace                         = 300   // 5 min
 	grpcKeepaliveEnforcementPolicyMinTime             = 50
 	grpcKeepaliveEnforcementPolicyPermitWithoutStream = false
+	statsCheckpointInterval                           = 30 * time.Second
+	warmUpRetryInterval                               = 5 * time.Second
 )
 
 var (
@@ -63,10 +67,24 @@ type XdsServer struct {
 	snapshotCacheV3  cache_v3.SnapshotCache
 	callbacksV3      *xdss_v3.Callbacks
 	discoveryStatsV3 *stats.Stats
+	// statsCheckpoint is the ConfigMap where the discovery stats are persisted
+	// so they survive the replacement of the pod. Empty disables checkpoints.
+	statsCheckpoint types.NamespacedName
+	warm            atomic.Bool
 }
 
-// NewXdsServer creates a new XdsServer object fron the given params
-func NewXdsServer(ctx context.Context, xDSPort uint, tlsConfig *tls.Config, logger logr.Logger) *XdsServer {
+// WarmUpFunc loads the current config into the xDS cache
+// before the server starts accepting connections
+type WarmUpFunc func(ctx context.Context, cache xdss.Cache) error
+
+// NewXdsServer creates a new XdsServer object fron the given params. If enforceNodeIDBinding
+// is true, requests from clients whose certificate is not valid for the requested nodeID are rejected.
+// If namespacedNodeIDs is true, the nodeIDs of the clients are prefixed with their namespace.
+// maxConcurrentPushes caps the number of envoy clients per nodeID with responses pending
+// acknowledgement, zero disables the limit. If the name of statsCheckpoint is not empty, the
+// discovery stats are restored from and periodically persisted to that ConfigMap.
+func NewXdsServer(ctx context.Context, xDSPort uint, tlsConfig *tls.Config, enforceNodeIDBinding bool,
+	namespacedNodeIDs bool, maxConcurrentPushes int, statsCheckpoint types.NamespacedName, logger logr.Logger) *XdsServer {
 
 	xdsLogger := logger.WithName("xds")
 
@@ -76,18 +94,24 @@ func NewXdsServer(ctx context.Context, xDSPort uint, tlsConfig *tls.Config, logg
 	// prometheus registry
 	metrics.Registry.MustRegister(discoveryStatsV3)
 
-	snapshotCacheV3 := cache_v3.NewSnapshotCache(
-		true,
-		cache_v3.IDHash{},
+	// the canary router wraps the snapshot cache to be able to serve
+	// canary snapshots to a subset of the envoy clients of a nodeID
+	snapshotCacheV3 := xdss_v3.NewCanaryRouter(
 		clogger{Logger: xdsLogger.WithName("cache").WithName("v3")},
 	)
+	snapshotCacheV3.NamespacedNodeIDs = namespacedNodeIDs
 
 	callbacksV3 := &xdss_v3.Callbacks{
-		Stats:  discoveryStatsV3,
-		Logger: xdsLogger.WithName("server").WithName("v3"),
+		Stats:                discoveryStatsV3,
+		Logger:               xdsLogger.WithName("server").WithName("v3"),
+		EnforceNodeIDBinding: enforceNodeIDBinding,
+		NamespacedNodeIDs:    namespacedNodeIDs,
+		Throttler:            xdss_v3.NewThrottler(maxConcurrentPushes),
 	}
 
-	srvV3 := server_v3.NewServer(ctx, snapshotCacheV3, callbacksV3)
+	// the throttler delays the responses of the cache to the streams
+	// that NACK and caps the concurrent pushes per nodeID
+	srvV3 := server_v3.NewServer(ctx, callbacksV3.Throttler.Cache(snapshotCacheV3), callbacksV3)
 
 	return &XdsServer{
 		ctx:              ctx,
@@ -97,11 +121,36 @@ func NewXdsServer(ctx context.Context, xDSPort uint, tlsConfig *tls.Config, logg
 		snapshotCacheV3:  snapshotCacheV3,
 		callbacksV3:      callbacksV3,
 		discoveryStatsV3: discoveryStatsV3,
+		statsCheckpoint:  statsCheckpoint,
 	}
 }
 
-// Start starts an xDS server at the given port.
-func (xdss *XdsServer) Start(client kubernetes.Interface, namespace string) error {
+// IsWarm returns true once the xDS cache has been warmed up
+// and the server is accepting connections
+func (xdss *XdsServer) IsWarm() bool {
+	return xdss.warm.Load()
+}
+
+// Start starts an xDS server at the given port. If warmUp is not nil, it is run
+// before the server starts listening so envoy clients are not served an empty cache.
+// The stats of the envoy clients are garbage collected when their pods are deleted
+// from any of the given namespaces.
+func (xdss *XdsServer) Start(client kubernetes.Interface, namespaces []string, warmUp WarmUpFunc) error {
+
+	if xdss.statsCheckpoint.Name != "" {
+		if err := xdss.discoveryStatsV3.RestoreCheckpoint(xdss.ctx, client,
+			xdss.statsCheckpoint.Namespace, xdss.statsCheckpoint.Name); err != nil {
+			setupLog.Error(err, "unable to restore discovery stats from checkpoint, starting with empty stats")
+		}
+	}
+
+	if warmUp != nil {
+		if err := xdss.warmUp(warmUp); err != nil {
+			// the context was cancelled
+			return nil
+		}
+		setupLog.Info("xDS cache warmed up")
+	}
 
 	// gRPC golang library sets a very small upper bound for the number gRPC/h2
 	// streams over a single TCP connection. If a proxy multiplexes requests over
@@ -144,10 +193,23 @@ func (xdss *XdsServer) Start(client kubernetes.Interface, namespace string) erro
 
 	setupLog.Info(fmt.Sprintf("Aggregated discovery service listening on %d\n", xdss.xDSPort))
 
+	xdss.warm.Store(true)
+
 	// start the stats garbage collector
 	stopGC := make(chan struct{})
-	if err := xdss.callbacksV3.Stats.RunGC(client, namespace, stopGC); err != nil {
-		return err
+	if xdss.statsCheckpoint.Name != "" {
+		// stats restored from the checkpoint might belong to pods
+		// deleted while the server was not running
+		if err := xdss.discoveryStatsV3.PruneMissingPods(xdss.ctx, client, namespaces...); err != nil {
+			setupLog.Error(err, "unable to prune the discovery stats of deleted pods")
+		}
+		xdss.discoveryStatsV3.RunCheckpointer(client, xdss.statsCheckpoint.Namespace, xdss.statsCheckpoint.Name,
+			statsCheckpointInterval, setupLog.WithName("stats"), stopGC)
+	}
+	for _, namespace := range namespaces {
+		if err := xdss.callbacksV3.Stats.RunGC(client, namespace, stopGC); err != nil {
+			return err
+		}
 	}
 
 	// wait until channel stopCh closed or an error is received
@@ -171,6 +233,16 @@ func (xdss *XdsServer) Start(client kubernetes.Interface, namespace string) erro
 		case <-stopped:
 			t.Stop()
 		}
+
+		if xdss.statsCheckpoint.Name != "" {
+			// the context of the server is already cancelled
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := xdss.discoveryStatsV3.Checkpoint(ctx, client,
+				xdss.statsCheckpoint.Namespace, xdss.statsCheckpoint.Name); err != nil {
+				setupLog.Error(err, "unable to write stats checkpoint")
+			}
+		}
 		return nil
 
 	case err := <-errCh:
@@ -180,6 +252,22 @@ func (xdss *XdsServer) Start(client kubernetes.Interface, namespace string) erro
 
 }
 
+// warmUp runs the given WarmUpFunc until it succeeds or the context is cancelled
+func (xdss *XdsServer) warmUp(fn WarmUpFunc) error {
+	for {
+		err := fn(xdss.ctx, xdss.GetCache(envoy.APIv3))
+		if err == nil {
+			return nil
+		}
+		setupLog.Error(err, "unable to warm up the xDS cache, retrying", "interval", warmUpRetryInterval)
+		select {
+		case <-xdss.ctx.Done():
+			return xdss.ctx.Err()
+		case <-time.After(warmUpRetryInterval):
+		}
+	}
+}
+
 // GetCache returns the Cache
 func (xdss *XdsServer) GetCache(version envoy.APIVersion) xdss.Cache {
 	return xdss_v3.NewCacheFromSnapshotCache(xdss.snapshotCacheV3)
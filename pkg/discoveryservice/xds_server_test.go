@@ -18,6 +18,7 @@ import (
 	"context"
 	"crypto/tls"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -25,24 +26,30 @@ import (
 	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	xdss_v3 "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/v3"
 	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
-	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	server_v3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 var (
-	snapshotCacheV3 = cache_v3.NewSnapshotCache(true, cache_v3.IDHash{}, nil)
+	snapshotCacheV3 = xdss_v3.NewCanaryRouter(nil)
 )
 
 func TestNewXdsServer(t *testing.T) {
 
 	type args struct {
-		ctx       context.Context
-		adsPort   uint
-		tlsConfig *tls.Config
-		logger    logr.Logger
+		ctx        context.Context
+		adsPort    uint
+		tlsConfig  *tls.Config
+		enforce    bool
+		namespaced bool
+		maxPushes  int
+		checkpoint types.NamespacedName
+		logger     logr.Logger
 	}
 	tests := []struct {
 		name string
@@ -50,12 +57,12 @@ func TestNewXdsServer(t *testing.T) {
 	}{
 		{
 			"Returns a new XdsServer from the given params",
-			args{context.Background(), 10000, &tls.Config{}, ctrl.Log},
+			args{context.Background(), 10000, &tls.Config{}, false, false, 100, types.NamespacedName{}, ctrl.Log},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewXdsServer(tt.args.ctx, tt.args.adsPort, tt.args.tlsConfig, tt.args.logger)
+			got := NewXdsServer(tt.args.ctx, tt.args.adsPort, tt.args.tlsConfig, tt.args.enforce, tt.args.namespaced, tt.args.maxPushes, tt.args.checkpoint, tt.args.logger)
 			if got.snapshotCacheV3 == nil || got.serverV3 == nil || got.callbacksV3 == nil {
 				t.Errorf("TestNewXdsServer = expected non-empty caches")
 			}
@@ -76,16 +83,66 @@ func TestXdsServer_Start(t *testing.T) {
 			snapshotCacheV3,
 			&xdss_v3.Callbacks{Logger: ctrl.Log},
 			stats.New(),
+			types.NamespacedName{},
+			atomic.Bool{},
 		}
 
 		go func() {
-			if err := xdss.Start(fake.NewSimpleClientset(), "ns"); err != nil {
+			if err := xdss.Start(fake.NewSimpleClientset(), []string{"ns"}, nil); err != nil {
 				t.Errorf("TestXdsServer_Start = non nil error: '%s'", err)
 			}
 		}()
 
 		<-xdss.ctx.Done()
 	})
+
+	t.Run("Warms up the cache and checkpoints the stats", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(100*time.Millisecond))
+		defer cancel()
+		checkpoint := types.NamespacedName{Name: "stats", Namespace: "ns"}
+		discoveryStats := stats.New()
+		server := &XdsServer{
+			ctx,
+			10001,
+			&tls.Config{},
+			server_v3.NewServer(context.Background(), snapshotCacheV3, &xdss_v3.Callbacks{Logger: ctrl.Log}),
+			snapshotCacheV3,
+			&xdss_v3.Callbacks{Logger: ctrl.Log, Stats: discoveryStats},
+			discoveryStats,
+			checkpoint,
+			atomic.Bool{},
+		}
+		discoveryStats.ReportRequest("node", "cluster", "pod")
+
+		warmedUp := false
+		done := make(chan struct{})
+		client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"}})
+		go func() {
+			defer close(done)
+			err := server.Start(client, []string{"ns"}, func(ctx context.Context, cache xdss.Cache) error {
+				warmedUp = !server.IsWarm()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("TestXdsServer_Start = non nil error: '%s'", err)
+			}
+		}()
+		<-done
+
+		if !warmedUp {
+			t.Errorf("TestXdsServer_Start = warm up not run before the server was ready")
+		}
+		if !server.IsWarm() {
+			t.Errorf("TestXdsServer_Start = server not warm after warm up")
+		}
+		restored := stats.New()
+		if err := restored.RestoreCheckpoint(context.Background(), client, checkpoint.Namespace, checkpoint.Name); err != nil {
+			t.Fatalf("TestXdsServer_Start = unable to load checkpoint: '%s'", err)
+		}
+		if len(restored.DumpAll()) == 0 {
+			t.Errorf("TestXdsServer_Start = stats not checkpointed on shutdown")
+		}
+	})
 }
 
 func TestXdsServer_GetCache(t *testing.T) {
@@ -105,6 +162,8 @@ func TestXdsServer_GetCache(t *testing.T) {
 				snapshotCacheV3,
 				&xdss_v3.Callbacks{Logger: ctrl.Log},
 				stats.New(),
+				types.NamespacedName{},
+				atomic.Bool{},
 			},
 			xdss_v3.NewCache(),
 			envoy.APIv3,
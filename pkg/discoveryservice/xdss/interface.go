@@ -2,6 +2,7 @@ package discoveryservice
 
 import (
 	"context"
+	"hash/fnv"
 
 	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
 )
@@ -19,6 +20,48 @@ type Cache interface {
 	GetSnapshot(string) (Snapshot, error)
 	ClearSnapshot(string)
 	NewSnapshot() Snapshot
+	SetCanarySnapshot(context.Context, string, CanaryTarget, Snapshot) error
+	GetCanarySnapshot(string) (Snapshot, CanaryTarget, error)
+	ClearCanary(string)
+}
+
+// NamespaceSeparator separates the namespace from the nodeID in namespaced nodeIDs
+const NamespaceSeparator string = "/"
+
+// NamespacedNodeID returns the nodeID prefixed with the given namespace. This is the nodeID
+// used in the xDS cache and the discovery stats when the discovery service serves several
+// namespaces, so EnvoyConfigs of different namespaces can use the same nodeID.
+func NamespacedNodeID(namespace, nodeID string) string {
+	return namespace + NamespaceSeparator + nodeID
+}
+
+// CanaryTarget selects the envoy clients of a given nodeID that are served
+// the canary snapshot instead of the regular one.
+type CanaryTarget struct {
+	// Version is the version of the revision being served as canary
+	Version string
+	// Percentage of the envoy clients that are selected, based on
+	// a hash of the pod name
+	Percentage int32
+	// Pods explicitly lists the names of the selected pods. Takes
+	// precedence over Percentage.
+	Pods []string
+}
+
+// Selects returns true if the given pod is selected by the CanaryTarget
+func (t CanaryTarget) Selects(pod string) bool {
+	if len(t.Pods) > 0 {
+		for _, p := range t.Pods {
+			if p == pod {
+				return true
+			}
+		}
+		return false
+	}
+
+	hasher := fnv.New32a()
+	hasher.Write([]byte(pod))
+	return int32(hasher.Sum32()%100) < t.Percentage
 }
 
 // Snapshot is an internally consistent snapshot of xDS resources.
@@ -30,4 +73,5 @@ type Snapshot interface {
 	GetResources(envoy.Type) map[string]envoy.Resource
 	GetVersion(envoy.Type) string
 	SetVersion(envoy.Type, string)
+	GetResourceVersions(envoy.Type) map[string]string
 }
@@ -0,0 +1,163 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	kv "github.com/patrickmn/go-cache"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func init() {
+	// stats of type NACKError are stored as interface{} values
+	// so gob needs to know the concrete type to encode them
+	gob.Register(NACKError{})
+}
+
+const (
+	checkpointDataKey string = "stats"
+	// checkpointHashAnnotation holds the hash of the stats in the
+	// checkpoint, so it is only updated when the stats change
+	checkpointHashAnnotation string = replicaStatsHashAnnotation
+)
+
+// RestoreCheckpoint loads into the stats store the checkpoint held in the given ConfigMap.
+// Nothing is restored if the ConfigMap does not exist or does not hold a checkpoint.
+func (s *Stats) RestoreCheckpoint(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	data, ok := cm.BinaryData[checkpointDataKey]
+	if !ok {
+		return nil
+	}
+
+	items := map[string]kv.Item{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return fmt.Errorf("unable to decode stats checkpoint from ConfigMap %s: %w", name, err)
+	}
+
+	// drop the items that expired while the checkpoint was not in use
+	now := time.Now().UnixNano()
+	for k, item := range items {
+		switch {
+		case item.Expiration == 0:
+			s.store.Set(k, item.Object, kv.NoExpiration)
+		case item.Expiration > now:
+			s.store.Set(k, item.Object, time.Duration(item.Expiration-now))
+		}
+	}
+
+	return nil
+}
+
+// Checkpoint writes the contents of the stats store to the given ConfigMap, so the stats
+// survive the replacement of the pod. The ConfigMap is only updated if the stats have changed.
+func (s *Stats) Checkpoint(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+
+	items := s.store.Items()
+	hash, err := statsHash(items)
+	if err != nil {
+		return err
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && cm.GetAnnotations()[checkpointHashAnnotation] == hash {
+		return nil
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(items); err != nil {
+		return err
+	}
+
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespace,
+				Annotations: map[string]string{checkpointHashAnnotation: hash},
+			},
+			BinaryData: map[string][]byte{checkpointDataKey: data.Bytes()},
+		}
+		_, err = client.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+
+	// keep the metadata of the ConfigMap, which is owned by the DiscoveryService
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[checkpointHashAnnotation] = hash
+	cm.BinaryData = map[string][]byte{checkpointDataKey: data.Bytes()}
+	_, err = client.CoreV1().ConfigMaps(namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// RunCheckpointer periodically writes a checkpoint of the stats store to the
+// given ConfigMap until the stop channel is closed
+func (s *Stats) RunCheckpointer(client kubernetes.Interface, namespace, name string, interval time.Duration,
+	logger logr.Logger, stopCh <-chan struct{}) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer cancel()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Checkpoint(ctx, client, namespace, name); err != nil {
+					logger.Error(err, "unable to write stats checkpoint", "configmap", name)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// PruneMissingPods deletes the stats of the pods that no longer exist in the
+// namespaces. The garbage collector only sees the pods deleted while it runs, so
+// this is required to cleanup the stats restored from a checkpoint.
+func (s *Stats) PruneMissingPods(ctx context.Context, client kubernetes.Interface, namespaces ...string) error {
+
+	pods := map[string]struct{}{}
+	for _, namespace := range namespaces {
+		list, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, pod := range list.Items {
+			pods[pod.GetName()] = struct{}{}
+		}
+	}
+
+	for k := range s.store.Items() {
+		podID := NewKeyFromString(k).PodID
+		if podID == "*" {
+			continue
+		}
+		if _, ok := pods[podID]; !ok {
+			s.store.Delete(k)
+		}
+	}
+
+	return nil
+}
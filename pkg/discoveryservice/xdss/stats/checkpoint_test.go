@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kv "github.com/patrickmn/go-cache"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStats_Checkpoint(t *testing.T) {
+	nackTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		cacheItems map[string]kv.Item
+		wantItems  map[string]kv.Item
+	}{
+		{
+			name: "Restores the stats from the checkpoint",
+			cacheItems: map[string]kv.Item{
+				"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-xxxx:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: nackTime}, Expiration: int64(0),
+				},
+				"node:endpoint:xxxx:pod-xxxx:nonce:7": {Object: "", Expiration: time.Now().Add(time.Hour).UnixNano()},
+			},
+			wantItems: map[string]kv.Item{
+				"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-xxxx:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: nackTime}, Expiration: int64(0),
+				},
+				"node:endpoint:xxxx:pod-xxxx:nonce:7": {Object: "", Expiration: time.Now().Add(time.Hour).UnixNano()},
+			},
+		},
+		{
+			name: "Drops expired stats",
+			cacheItems: map[string]kv.Item{
+				"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-xxxx:nonce:7":      {Object: "", Expiration: time.Now().Add(time.Millisecond * 50).UnixNano()},
+			},
+			wantItems: map[string]kv.Item{
+				"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if err := NewWithItems(tt.cacheItems, time.Now()).Checkpoint(context.TODO(), client, "ns", "stats"); err != nil {
+				t.Fatalf("Stats.Checkpoint() error = %v", err)
+			}
+			time.Sleep(time.Millisecond * 100)
+
+			s := New()
+			if err := s.RestoreCheckpoint(context.TODO(), client, "ns", "stats"); err != nil {
+				t.Fatalf("Stats.RestoreCheckpoint() error = %v", err)
+			}
+			got := s.store.Items()
+			if len(got) != len(tt.wantItems) {
+				t.Fatalf("Stats.RestoreCheckpoint() got %v, want %v", got, tt.wantItems)
+			}
+			for k, v := range tt.wantItems {
+				if !reflect.DeepEqual(got[k].Object, v.Object) {
+					t.Errorf("Stats.RestoreCheckpoint() key %s = %v, want %v", k, got[k].Object, v.Object)
+				}
+			}
+		})
+	}
+}
+
+func TestStats_Checkpoint_existingConfigMap(t *testing.T) {
+	labels := map[string]string{"app.kubernetes.io/instance": "test"}
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "stats", Namespace: "ns", Labels: labels},
+	})
+	s := NewWithItems(map[string]kv.Item{
+		"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+	}, time.Now())
+
+	if err := s.Checkpoint(context.TODO(), client, "ns", "stats"); err != nil {
+		t.Fatalf("Stats.Checkpoint() error = %v", err)
+	}
+	cm, err := client.CoreV1().ConfigMaps("ns").Get(context.TODO(), "stats", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unable to get ConfigMap: %v", err)
+	}
+	if !reflect.DeepEqual(cm.GetLabels(), labels) {
+		t.Errorf("Stats.Checkpoint() labels = %v, want %v", cm.GetLabels(), labels)
+	}
+	if _, ok := cm.BinaryData[checkpointDataKey]; !ok {
+		t.Errorf("Stats.Checkpoint() did not write the stats to the ConfigMap")
+	}
+
+	// the ConfigMap is not updated if the stats have not changed
+	client.ClearActions()
+	if err := s.Checkpoint(context.TODO(), client, "ns", "stats"); err != nil {
+		t.Fatalf("Stats.Checkpoint() error = %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("Stats.Checkpoint() unexpected action %s with unchanged stats", action.GetVerb())
+		}
+	}
+}
+
+func TestStats_RestoreCheckpoint_missingConfigMap(t *testing.T) {
+	s := New()
+	if err := s.RestoreCheckpoint(context.TODO(), fake.NewSimpleClientset(), "ns", "stats"); err != nil {
+		t.Fatalf("Stats.RestoreCheckpoint() error = %v", err)
+	}
+	if len(s.DumpAll()) != 0 {
+		t.Errorf("Stats.RestoreCheckpoint() returned a non empty store")
+	}
+}
+
+func TestStats_PruneMissingPods(t *testing.T) {
+	s := NewWithItems(map[string]kv.Item{
+		"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+		"node:endpoint:*:pod-aaaa:request_counter": {Object: int64(3), Expiration: int64(0)},
+	}, time.Now())
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-aaaa", Namespace: "ns"}})
+
+	if err := s.PruneMissingPods(context.TODO(), client, "ns"); err != nil {
+		t.Fatalf("Stats.PruneMissingPods() error = %v", err)
+	}
+	want := map[string]kv.Item{
+		"node:endpoint:*:pod-aaaa:request_counter": {Object: int64(3), Expiration: int64(0)},
+	}
+	if got := s.DumpAll(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats.PruneMissingPods() got %v, want %v", got, want)
+	}
+}
@@ -46,9 +46,21 @@ func (k *Key) String() string {
 	return strings.Join([]string{k.NodeID, k.ResourceType, k.Version, k.PodID, k.StatName}, ":")
 }
 
+// get returns the value of the key, looking up the stats published
+// by the other replicas if the key is not found in the local stats
+func (s *Stats) get(k string) (interface{}, bool) {
+	if v, ok := s.store.Get(k); ok {
+		return v, true
+	}
+	if s.remote == nil {
+		return nil, false
+	}
+	return s.remote.Get(k)
+}
+
 func (s *Stats) GetString(nodeID, rtype, version, podID, statName string) (string, error) {
 	k := NewKey(nodeID, rtype, version, podID, statName).String()
-	if v, ok := s.store.Get(k); ok {
+	if v, ok := s.get(k); ok {
 		if value, ok := v.(string); !ok {
 			return "", fmt.Errorf("value of key '%s' is not a string", k)
 		} else {
@@ -74,7 +86,7 @@ func (s *Stats) SetInt64(nodeID, rType, version, podID, statName string, value i
 
 func (s *Stats) GetCounter(nodeID, rtype, version, podID, statName string) (int64, error) {
 	k := NewKey(nodeID, rtype, version, podID, statName).String()
-	if v, ok := s.store.Get(k); ok {
+	if v, ok := s.get(k); ok {
 		if value, ok := v.(int64); !ok {
 			return 0, fmt.Errorf("value of key '%s' is not an int", k)
 		} else {
@@ -94,8 +106,16 @@ func (s *Stats) IncrementCounter(nodeID, rType, version, podID, statName string,
 	s.store.SetDefault(NewKey(nodeID, rType, version, podID, statName).String(), counter+increment)
 }
 
+// FilterKeys returns the stats whose key contains all the given filters, including
+// the stats published by the other replicas. Local stats take precedence.
 func (s *Stats) FilterKeys(filters ...string) map[string]kv.Item {
-	all := s.store.Items()
+	all := map[string]kv.Item{}
+	if s.remote != nil {
+		all = s.remote.Items()
+	}
+	for k, v := range s.store.Items() {
+		all[k] = v
+	}
 	selected := map[string]kv.Item{}
 	var isSelected bool
 	for key, value := range all {
@@ -116,9 +136,14 @@ func (s *Stats) DeleteKeysByFilter(filters ...string) {
 	keys := s.FilterKeys(filters...)
 	for k := range keys {
 		s.store.Delete(k)
+		if s.remote != nil {
+			s.remote.Delete(k)
+		}
 	}
 }
 
+// DumpAll returns the local stats, without the ones
+// published by the other replicas
 func (s *Stats) DumpAll() map[string]kv.Item {
 	return s.store.Items()
 }
@@ -24,6 +24,11 @@ var (
 		"Number of discovery NACK responses",
 		[]string{"node_id", "resource_type", "pod_name"}, nil,
 	)
+	unauthorizedCountDesc = prometheus.NewDesc(
+		"marin3r_xdss_discovery_unauthorized_requests_total",
+		"Number of discovery requests from clients whose certificate does not match the requested node ID",
+		[]string{"node_id", "resource_type", "pod_name"}, nil,
+	)
 	infoDesc = prometheus.NewDesc(
 		"marin3r_xdss_discovery_info",
 		"Information about the version a certain resource type is at",
@@ -73,6 +78,14 @@ func (s Stats) Collect(ch chan<- prometheus.Metric) {
 				key.NodeID, key.ResourceType, key.PodID,
 			)
 
+		case "unauthorized_counter/*":
+			ch <- prometheus.MustNewConstMetric(
+				unauthorizedCountDesc,
+				prometheus.CounterValue,
+				float64(v.Object.(int64)),
+				key.NodeID, key.ResourceType, key.PodID,
+			)
+
 		}
 
 	}
@@ -73,6 +73,18 @@ func TestStats_Collect(t *testing.T) {
 				marin3r_xdss_discovery_requests_total{node_id="node",pod_name="pod-dddd",resource_type="endpoint"} 1
 			`)),
 		},
+		{
+			name: "Exposes unauthorized request counters",
+			cacheItems: map[string]kv.Item{
+				"node:" + "endpoint" + ":*:pod-aaaa:unauthorized_counter": {Object: int64(4), Expiration: int64(0)},
+			},
+			ts: time.UnixMilli(100),
+			want: strings.NewReader(heredoc.Doc(`
+				# HELP marin3r_xdss_discovery_unauthorized_requests_total Number of discovery requests from clients whose certificate does not match the requested node ID
+				# TYPE marin3r_xdss_discovery_unauthorized_requests_total counter
+				marin3r_xdss_discovery_unauthorized_requests_total{node_id="node",pod_name="pod-aaaa",resource_type="endpoint"} 4
+			`)),
+		},
 		{
 			name: "Ignores per version stats",
 			cacheItems: map[string]kv.Item{
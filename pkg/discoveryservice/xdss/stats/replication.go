@@ -0,0 +1,306 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kv "github.com/patrickmn/go-cache"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ReplicaStatsLabelKey is the label of the ConfigMaps where the replicas
+	// of the discovery service publish their stats
+	ReplicaStatsLabelKey string = "marin3r.3scale.net/discovery-stats"
+
+	replicaStatsDataKey string = "stats"
+	// replicaStatsHashAnnotation holds the hash of the stats published in
+	// a ConfigMap, so it is only updated when the stats change
+	replicaStatsHashAnnotation string = "marin3r.3scale.net/discovery-stats-hash"
+	// replicaStatsShards is the number of ConfigMaps the stats of a replica are split
+	// into, by nodeID, so they stay far from the size limit of the ConfigMaps with a
+	// large number of envoy clients and a change only rewrites a fraction of them
+	replicaStatsShards int    = 8
+	nackResetStatName  string = "nack_reset"
+	// nackResetExpiration is the time a NACK reset is kept, which needs to be
+	// long enough for the reset to be propagated to all the replicas
+	nackResetExpiration time.Duration = 10 * time.Minute
+)
+
+func nackResetKey(nodeID, version string) string {
+	return NewKey(nodeID, "*", version, "*", nackResetStatName).String()
+}
+
+// Export returns the local stats to share with the other replicas of the discovery
+// service. Nonces are not exported as they only make sense for the replica that sent
+// the response.
+func (s *Stats) Export() map[string]kv.Item {
+	items := s.store.Items()
+	for k := range items {
+		if strings.HasPrefix(NewKeyFromString(k).StatName, "nonce:") {
+			delete(items, k)
+		}
+	}
+	return items
+}
+
+// Merge replaces the stats of the other replicas of the discovery service with the
+// given ones. The NACK resets done by other replicas are applied to the local stats
+// and the NACKs of the other replicas older than the last reset of their version are
+// ignored, so a reset is not undone by replicas that have not applied it yet.
+func (s *Stats) Merge(items map[string]kv.Item) {
+	now := time.Now().UnixNano()
+
+	for k, item := range items {
+		key := NewKeyFromString(k)
+		ts, ok := item.Object.(int64)
+		if key.StatName != nackResetStatName || !ok || ts <= s.lastNACKReset(key.NodeID, key.Version) {
+			continue
+		}
+		s.resetNACKsBefore(key.NodeID, key.Version, ts)
+		expiration := nackResetExpiration
+		if item.Expiration > 0 {
+			expiration = time.Duration(item.Expiration - now)
+		}
+		s.store.Set(k, ts, expiration)
+	}
+
+	remote := map[string]kv.Item{}
+	for k, item := range items {
+		key := NewKeyFromString(k)
+		if key.StatName == nackResetStatName || (item.Expiration > 0 && item.Expiration < now) {
+			continue
+		}
+		if strings.HasPrefix(key.StatName, "nack_") && key.Version != "*" {
+			var last int64
+			errKey := NewKey(key.NodeID, key.ResourceType, key.Version, key.PodID, "nack_error").String()
+			if nackErr, ok := items[errKey].Object.(NACKError); ok {
+				last = nackErr.Time.UnixNano()
+			}
+			if last <= s.lastNACKReset(key.NodeID, key.Version) {
+				continue
+			}
+		}
+		remote[k] = item
+	}
+
+	for k := range s.remote.Items() {
+		if _, ok := remote[k]; !ok {
+			s.remote.Delete(k)
+		}
+	}
+	for k, item := range remote {
+		s.remote.Set(k, item.Object, kv.NoExpiration)
+	}
+}
+
+// lastNACKReset returns the time of the last reset of the NACKs
+// of the given version, as a unix timestamp in nanoseconds
+func (s *Stats) lastNACKReset(nodeID, version string) int64 {
+	if v, ok := s.store.Get(nackResetKey(nodeID, version)); ok {
+		if ts, ok := v.(int64); ok {
+			return ts
+		}
+	}
+	return 0
+}
+
+// resetNACKsBefore deletes the local NACK counters and errors of the given
+// version that have not been updated since the given time
+func (s *Stats) resetNACKsBefore(nodeID, version string, ts int64) {
+	for k, item := range s.store.Items() {
+		key := NewKeyFromString(k)
+		if key.NodeID != nodeID || key.Version != version || key.StatName != "nack_error" {
+			continue
+		}
+		if nackErr, ok := item.Object.(NACKError); ok && nackErr.Time.UnixNano() < ts {
+			s.store.Delete(k)
+			s.store.Delete(NewKey(key.NodeID, key.ResourceType, key.Version, key.PodID, "nack_counter").String())
+		}
+	}
+}
+
+// SyncReplicas publishes the local stats in ConfigMaps owned by the pod of this replica,
+// so they are garbage collected along with the pod, and merges the stats published by the
+// other replicas of the discovery service. The stats are split by nodeID in several
+// ConfigMaps and only the ConfigMaps whose stats have changed are updated.
+func (s *Stats) SyncReplicas(ctx context.Context, client kubernetes.Interface, namespace, podName string) error {
+
+	if err := s.publish(ctx, client, namespace, podName); err != nil {
+		return fmt.Errorf("unable to publish stats: %w", err)
+	}
+
+	list, err := client.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{LabelSelector: ReplicaStatsLabelKey})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	items := map[string]kv.Item{}
+	for _, cm := range list.Items {
+		if isOwnedBy(&cm, podName) {
+			continue
+		}
+		replicaItems := map[string]kv.Item{}
+		if err := gob.NewDecoder(bytes.NewReader(cm.BinaryData[replicaStatsDataKey])).Decode(&replicaItems); err != nil {
+			errs = append(errs, fmt.Errorf("unable to decode stats from ConfigMap %s: %w", cm.GetName(), err))
+			continue
+		}
+		for k, v := range replicaItems {
+			items[k] = v
+		}
+	}
+	s.Merge(items)
+
+	return errors.Join(errs...)
+}
+
+func (s *Stats) publish(ctx context.Context, client kubernetes.Interface, namespace, podName string) error {
+
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	shards := make([]map[string]kv.Item, replicaStatsShards)
+	for i := range shards {
+		shards[i] = map[string]kv.Item{}
+	}
+	for k, item := range s.Export() {
+		shards[shard(NewKeyFromString(k).NodeID)][k] = item
+	}
+
+	var errs []error
+	for i, items := range shards {
+		if err := publishShard(ctx, client, pod, replicaStatsConfigMapName(podName, i), items); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func publishShard(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, name string, items map[string]kv.Item) error {
+
+	hash, err := statsHash(items)
+	if err != nil {
+		return err
+	}
+
+	current, err := client.CoreV1().ConfigMaps(pod.GetNamespace()).Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && current.GetAnnotations()[replicaStatsHashAnnotation] == hash {
+		return nil
+	}
+
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(items); err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   pod.GetNamespace(),
+			Labels:      map[string]string{ReplicaStatsLabelKey: "true"},
+			Annotations: map[string]string{replicaStatsHashAnnotation: hash},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "Pod",
+				Name:       pod.GetName(),
+				UID:        pod.GetUID(),
+			}},
+		},
+		BinaryData: map[string][]byte{replicaStatsDataKey: data.Bytes()},
+	}
+
+	if apierrors.IsNotFound(err) {
+		_, err = client.CoreV1().ConfigMaps(pod.GetNamespace()).Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+
+	cm.SetResourceVersion(current.GetResourceVersion())
+	_, err = client.CoreV1().ConfigMaps(pod.GetNamespace()).Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// statsHash returns a hash of the stats. The items are encoded in
+// order, as the encoding of a map is not deterministic.
+func statsHash(items map[string]kv.Item) (string, error) {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	enc := gob.NewEncoder(h)
+	for _, k := range keys {
+		if err := enc.Encode(k); err != nil {
+			return "", err
+		}
+		if err := enc.Encode(items[k]); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// shard returns the shard the stats of a nodeID are published in
+func shard(nodeID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(nodeID))
+	return int(h.Sum32() % uint32(replicaStatsShards))
+}
+
+func replicaStatsConfigMapName(podName string, shard int) string {
+	return fmt.Sprintf("%s-stats-%d", podName, shard)
+}
+
+// isOwnedBy returns true if the ConfigMap holds the stats of the given pod
+func isOwnedBy(cm *corev1.ConfigMap, podName string) bool {
+	for _, ref := range cm.GetOwnerReferences() {
+		if ref.Kind == "Pod" && ref.Name == podName {
+			return true
+		}
+	}
+	return false
+}
+
+// RunReplicaSync periodically syncs the stats with the other replicas
+// of the discovery service until the stop channel is closed
+func (s *Stats) RunReplicaSync(client kubernetes.Interface, namespace, podName string, interval time.Duration,
+	logger logr.Logger, stopCh <-chan struct{}) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer cancel()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.SyncReplicas(ctx, client, namespace, podName); err != nil {
+					logger.Error(err, "unable to sync stats with the other replicas")
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
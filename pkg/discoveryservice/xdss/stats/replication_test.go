@@ -0,0 +1,210 @@
+package stats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kv "github.com/patrickmn/go-cache"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStats_Export(t *testing.T) {
+	s := NewWithItems(map[string]kv.Item{
+		"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+		"node:endpoint:xxxx:pod-xxxx:ack_counter":  {Object: int64(1), Expiration: int64(0)},
+		"node:endpoint:xxxx:pod-xxxx:nonce:7":      {Object: "", Expiration: time.Now().Add(time.Hour).UnixNano()},
+	}, time.Now())
+
+	want := map[string]kv.Item{
+		"node:endpoint:*:pod-xxxx:request_counter": {Object: int64(5), Expiration: int64(0)},
+		"node:endpoint:xxxx:pod-xxxx:ack_counter":  {Object: int64(1), Expiration: int64(0)},
+	}
+	if got := s.Export(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats.Export() = %v, want %v", got, want)
+	}
+}
+
+func TestStats_Merge(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		localItems  map[string]kv.Item
+		remoteItems map[string]kv.Item
+		items       map[string]kv.Item
+		wantLocal   map[string]kv.Item
+		wantRemote  map[string]kv.Item
+	}{
+		{
+			name: "Replaces the stats of the other replicas",
+			localItems: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-xxxx:ack_counter": {Object: int64(1), Expiration: int64(0)},
+			},
+			remoteItems: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-gone:ack_counter": {Object: int64(1), Expiration: int64(0)},
+			},
+			items: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-aaaa:ack_counter": {Object: int64(3), Expiration: int64(0)},
+			},
+			wantLocal: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-xxxx:ack_counter": {Object: int64(1), Expiration: int64(0)},
+			},
+			wantRemote: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-aaaa:ack_counter": {Object: int64(3), Expiration: int64(0)},
+			},
+		},
+		{
+			name: "Applies the NACK resets of the other replicas",
+			localItems: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-xxxx:nack_counter": {Object: int64(5), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-xxxx:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: now.Add(-time.Minute)}, Expiration: int64(0),
+				},
+				"node:endpoint:xxxx:pod-bbbb:nack_counter": {Object: int64(1), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-bbbb:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: now.Add(time.Minute)}, Expiration: int64(0),
+				},
+			},
+			items: map[string]kv.Item{
+				"node:*:xxxx:*:nack_reset": {Object: now.UnixNano(), Expiration: int64(0)},
+			},
+			wantLocal: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-bbbb:nack_counter": {Object: int64(1), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-bbbb:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: now.Add(time.Minute)}, Expiration: int64(0),
+				},
+				"node:*:xxxx:*:nack_reset": {Object: now.UnixNano(), Expiration: int64(0)},
+			},
+			wantRemote: map[string]kv.Item{},
+		},
+		{
+			name: "Ignores the NACKs of the other replicas older than the last reset",
+			localItems: map[string]kv.Item{
+				"node:*:xxxx:*:nack_reset": {Object: now.UnixNano(), Expiration: int64(0)},
+			},
+			items: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-aaaa:nack_counter": {Object: int64(5), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-aaaa:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: now.Add(-time.Minute)}, Expiration: int64(0),
+				},
+				"node:endpoint:xxxx:pod-bbbb:nack_counter": {Object: int64(1), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-bbbb:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: now.Add(time.Minute)}, Expiration: int64(0),
+				},
+				"node:endpoint:*:pod-aaaa:nack_counter": {Object: int64(5), Expiration: int64(0)},
+			},
+			wantLocal: map[string]kv.Item{
+				"node:*:xxxx:*:nack_reset": {Object: now.UnixNano(), Expiration: int64(0)},
+			},
+			wantRemote: map[string]kv.Item{
+				"node:endpoint:xxxx:pod-bbbb:nack_counter": {Object: int64(1), Expiration: int64(0)},
+				"node:endpoint:xxxx:pod-bbbb:nack_error": {
+					Object: NACKError{Code: 3, Message: "error", Time: now.Add(time.Minute)}, Expiration: int64(0),
+				},
+				"node:endpoint:*:pod-aaaa:nack_counter": {Object: int64(5), Expiration: int64(0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewWithItems(tt.localItems, now)
+			for k, v := range tt.remoteItems {
+				s.remote.Set(k, v.Object, kv.NoExpiration)
+			}
+			s.Merge(tt.items)
+			if got := objects(s.store.Items()); !reflect.DeepEqual(got, objects(tt.wantLocal)) {
+				t.Errorf("Stats.Merge() local = %v, want %v", got, objects(tt.wantLocal))
+			}
+			if got := objects(s.remote.Items()); !reflect.DeepEqual(got, objects(tt.wantRemote)) {
+				t.Errorf("Stats.Merge() remote = %v, want %v", got, objects(tt.wantRemote))
+			}
+		})
+	}
+}
+
+// objects strips the expiration of the items, as it is
+// relative to the time the test runs
+func objects(items map[string]kv.Item) map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range items {
+		m[k] = v.Object
+	}
+	return m
+}
+
+func TestStats_SyncReplicas(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ds-a", Namespace: "ns", UID: "uid-a"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ds-b", Namespace: "ns", UID: "uid-b"}},
+	)
+
+	a := New()
+	a.ReportRequest("node", "endpoint", "envoy-a")
+	a.ReportACK("node", "endpoint", "xxxx", "envoy-a")
+	b := New()
+	b.ReportRequest("node", "endpoint", "envoy-b")
+	b.WriteResponseNonce("node", "endpoint", "xxxx", "envoy-b", "1")
+	if _, err := b.ReportNACK("node", "endpoint", "envoy-b", "1", 3, "error"); err != nil {
+		t.Fatal(err)
+	}
+
+	// sync twice so both replicas see the stats of the other one
+	for i := 0; i < 2; i++ {
+		if err := a.SyncReplicas(context.TODO(), client, "ns", "ds-a"); err != nil {
+			t.Fatalf("Stats.SyncReplicas() error = %v", err)
+		}
+		if err := b.SyncReplicas(context.TODO(), client, "ns", "ds-b"); err != nil {
+			t.Fatalf("Stats.SyncReplicas() error = %v", err)
+		}
+	}
+
+	for name, s := range map[string]*Stats{"ds-a": a, "ds-b": b} {
+		if got := s.GetSubscribedPods("node", "endpoint"); !reflect.DeepEqual(got, map[string]int8{"envoy-a": 1, "envoy-b": 1}) {
+			t.Errorf("replica %s: Stats.GetSubscribedPods() = %v", name, got)
+		}
+		if got := s.GetPercentageFailing("node", "endpoint", "xxxx", 1); got != 0.5 {
+			t.Errorf("replica %s: Stats.GetPercentageFailing() = %v, want 0.5", name, got)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ns").Get(context.TODO(), replicaStatsConfigMapName("ds-a", shard("node")), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("stats ConfigMap not found: %v", err)
+	}
+	if refs := cm.GetOwnerReferences(); len(refs) != 1 || refs[0].UID != "uid-a" {
+		t.Errorf("stats ConfigMap owner references = %v", refs)
+	}
+	if list, _ := client.CoreV1().ConfigMaps("ns").List(context.TODO(), metav1.ListOptions{}); len(list.Items) != 2*replicaStatsShards {
+		t.Errorf("stats ConfigMaps = %v, want %v", len(list.Items), 2*replicaStatsShards)
+	}
+
+	// unchanged stats are not published again
+	client.ClearActions()
+	if err := a.SyncReplicas(context.TODO(), client, "ns", "ds-a"); err != nil {
+		t.Fatalf("Stats.SyncReplicas() error = %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("stats ConfigMap updated without changes")
+		}
+	}
+
+	// a reset in one replica is propagated to the other
+	b.ResetNACKs("node", "xxxx")
+	for _, sync := range []struct {
+		s   *Stats
+		pod string
+	}{{b, "ds-b"}, {a, "ds-a"}, {b, "ds-b"}} {
+		if err := sync.s.SyncReplicas(context.TODO(), client, "ns", sync.pod); err != nil {
+			t.Fatalf("Stats.SyncReplicas() error = %v", err)
+		}
+	}
+	for name, s := range map[string]*Stats{"ds-a": a, "ds-b": b} {
+		if got := s.GetPercentageFailing("node", "endpoint", "xxxx", 1); got != 0 {
+			t.Errorf("replica %s: Stats.GetPercentageFailing() after reset = %v, want 0", name, got)
+		}
+	}
+}
@@ -14,20 +14,25 @@ import (
 //	<node-id>:<version>:<resource-type>:<pod-id>:<stat-name>
 type Stats struct {
 	store *kv.Cache
-	clock clock.Clock
+	// remote holds the stats published by the other replicas
+	// of the discovery service, see Merge()
+	remote *kv.Cache
+	clock  clock.Clock
 }
 
 func New() *Stats {
 	return &Stats{
-		store: kv.New(defaultExpiration, cleanupInterval),
-		clock: clock.Real{},
+		store:  kv.New(defaultExpiration, cleanupInterval),
+		remote: kv.New(defaultExpiration, cleanupInterval),
+		clock:  clock.Real{},
 	}
 }
 
 func NewWithItems(items map[string]kv.Item, now time.Time) *Stats {
 	return &Stats{
-		store: kv.NewFrom(defaultExpiration, cleanupInterval, items),
-		clock: clock.NewTest(now),
+		store:  kv.NewFrom(defaultExpiration, cleanupInterval, items),
+		remote: kv.New(defaultExpiration, cleanupInterval),
+		clock:  clock.NewTest(now),
 	}
 }
 
@@ -35,27 +40,69 @@ func (s *Stats) WriteResponseNonce(nodeID, rType, version, podID, nonce string)
 	s.SetStringWithExpiration(nodeID, rType, version, podID, "nonce:"+nonce, "", 10*time.Second)
 }
 
-func (s *Stats) ReportNACK(nodeID, rType, podID, nonce string) (int64, error) {
+// GetVersionFromNonce returns the version that was sent to the given pod in
+// the response identified by the nonce
+func (s *Stats) GetVersionFromNonce(nodeID, rType, podID, nonce string) (string, error) {
 	keys := s.FilterKeys(nodeID, rType, podID, "nonce:"+nonce)
 	if len(keys) != 1 {
-		return 0, fmt.Errorf("error reporting failure: unexpected number of nonces in the cache")
+		return "", fmt.Errorf("unexpected number of nonces in the cache")
 	}
 
 	// The value of version is contained in the key of the corresponding nonce stored
 	// in the cache
-	version := NewKeyFromString(func() string {
+	return NewKeyFromString(func() string {
 		for k := range keys {
 			return k
 		}
 		return ""
-	}()).Version
+	}()).Version, nil
+}
+
+// NACKError holds the error details reported by an envoy client when
+// rejecting a discovery response
+type NACKError struct {
+	Code    int32
+	Message string
+	Time    time.Time
+}
+
+// ReportNACK increments the NACK counters of the version sent in the response identified
+// by the nonce and stores the error details reported by the client
+func (s *Stats) ReportNACK(nodeID, rType, podID, nonce string, code int32, msg string) (int64, error) {
+	version, err := s.GetVersionFromNonce(nodeID, rType, podID, nonce)
+	if err != nil {
+		return 0, fmt.Errorf("error reporting failure: %w", err)
+	}
 
 	s.IncrementCounter(nodeID, rType, version, podID, "nack_counter", 1)
 	// aggregated counter, with lower cardinality, to expose as prometheus metric
 	s.IncrementCounter(nodeID, rType, "*", podID, "nack_counter", 1)
+	s.store.SetDefault(NewKey(nodeID, rType, version, podID, "nack_error").String(),
+		NACKError{Code: code, Message: msg, Time: s.clock.Now()})
 	return s.GetCounter(nodeID, rType, version, podID, "nack_counter")
 }
 
+// GetNACKErrors returns the last error reported by each of the pods that
+// rejected the given version, indexed by pod
+func (s *Stats) GetNACKErrors(nodeID, rType, version string) map[string]NACKError {
+	errs := map[string]NACKError{}
+	for k, v := range s.FilterKeys(nodeID+":"+rType+":"+version+":", ":nack_error") {
+		if nackErr, ok := v.Object.(NACKError); ok {
+			errs[NewKeyFromString(k).PodID] = nackErr
+		}
+	}
+	return errs
+}
+
+// ResetNACKs deletes the NACK counters and errors of the given version for all resource
+// types and pods. The aggregated counters exposed as metrics are not modified. The time
+// of the reset is recorded so it can be propagated to the other replicas of the discovery
+// service.
+func (s *Stats) ResetNACKs(nodeID, version string) {
+	s.DeleteKeysByFilter(nodeID+":", ":"+version+":", ":nack_")
+	s.store.Set(nackResetKey(nodeID, version), s.clock.Now().UnixNano(), nackResetExpiration)
+}
+
 func (s *Stats) ReportACK(nodeID, rType, version, podID string) {
 	s.IncrementCounter(nodeID, rType, version, podID, "ack_counter", 1)
 	// aggregated counter, with lower cardinality, to expose as prometheus metric
@@ -64,10 +111,40 @@ func (s *Stats) ReportACK(nodeID, rType, version, podID string) {
 	s.SetInt64(nodeID, rType, version, podID, "info", s.clock.Now().UnixMilli())
 }
 
+// ACK holds the version last acknowledged by an envoy client
+// for a resource type and the time it was acknowledged
+type ACK struct {
+	Version string
+	Time    time.Time
+}
+
+// GetLastACKs returns the last version acknowledged by each pod
+// for the given resource type, indexed by pod
+func (s *Stats) GetLastACKs(nodeID, rType string) map[string]ACK {
+	acks := map[string]ACK{}
+	for k, v := range s.FilterKeys(nodeID+":"+rType+":", ":info") {
+		ts, ok := v.Object.(int64)
+		if !ok {
+			continue
+		}
+		key := NewKeyFromString(k)
+		if ack, ok := acks[key.PodID]; !ok || ts > ack.Time.UnixMilli() {
+			acks[key.PodID] = ACK{Version: key.Version, Time: time.UnixMilli(ts)}
+		}
+	}
+	return acks
+}
+
 func (s *Stats) ReportRequest(nodeID, rType, podID string) {
 	s.IncrementCounter(nodeID, rType, "*", podID, "request_counter", 1)
 }
 
+// ReportUnauthorizedRequest increments the counter of requests received for the given
+// nodeID from a client whose certificate is not valid for that nodeID
+func (s *Stats) ReportUnauthorizedRequest(nodeID, rType, podID string) {
+	s.IncrementCounter(nodeID, rType, "*", podID, "unauthorized_counter", 1)
+}
+
 func GetStringValueFromMetadata(meta map[string]interface{}, key string) (string, error) {
 
 	v, ok := meta[key]
@@ -94,21 +171,34 @@ func (s *Stats) GetSubscribedPods(nodeID, rType string) map[string]int8 {
 	items := s.FilterKeys(filters...)
 
 	for k := range items {
-		podID := NewKeyFromString(k).PodID
-		if _, ok := m[podID]; !ok {
-			m[podID] = 1
+		key := NewKeyFromString(k)
+		// the filters match substrings, so a nodeID is also
+		// matched by the nodeIDs that it is a prefix of
+		if (nodeID != "" && key.NodeID != nodeID) || (rType != "" && key.ResourceType != rType) {
+			continue
+		}
+		if _, ok := m[key.PodID]; !ok {
+			m[key.PodID] = 1
 		}
 	}
 
 	return m
 }
 
-func (s *Stats) GetPercentageFailing(nodeID, rType, version string) float64 {
+// GetPercentageFailing returns the percentage of the subscribed pods that are failing
+// to apply the given version. A pod is considered failing once it has reported
+// nackThreshold NACKs for the version.
+func (s *Stats) GetPercentageFailing(nodeID, rType, version string, nackThreshold int64) float64 {
+	return s.GetPercentageFailingForPods(nodeID, rType, version, s.GetSubscribedPods(nodeID, rType), nackThreshold)
+}
+
+// GetPercentageFailingForPods returns the percentage of the given pods that
+// are failing to apply the given version
+func (s *Stats) GetPercentageFailingForPods(nodeID, rType, version string, pods map[string]int8, nackThreshold int64) float64 {
 
 	failing := 0
-	pods := s.GetSubscribedPods(nodeID, rType)
 	for pod := range pods {
-		if v, err := s.GetCounter(nodeID, rType, version, pod, "nack_counter"); err == nil && v >= 5 {
+		if v, err := s.GetCounter(nodeID, rType, version, pod, "nack_counter"); err == nil && v >= nackThreshold {
 			failing++
 		}
 	}
@@ -52,6 +52,8 @@ func TestStats_ReportNACK(t *testing.T) {
 		rType  string
 		podID  string
 		nonce  string
+		code   int32
+		msg    string
 	}
 	tests := []struct {
 		name       string
@@ -72,11 +74,15 @@ func TestStats_ReportNACK(t *testing.T) {
 				rType:  "endpoint",
 				podID:  "pod-xxxx",
 				nonce:  "7",
+				code:   3,
+				msg:    "error",
 			},
 			want: map[string]kv.Item{
 				"node:endpoint:aaaa:pod-xxxx:nonce:7":      {Object: "", Expiration: int64(defaultExpiration)},
 				"node:endpoint:aaaa:pod-xxxx:nack_counter": {Object: int64(6), Expiration: int64(defaultExpiration)},
 				"node:endpoint:*:pod-xxxx:nack_counter":    {Object: int64(6), Expiration: int64(defaultExpiration)},
+				"node:endpoint:aaaa:pod-xxxx:nack_error": {Object: NACKError{Code: 3, Message: "error", Time: time.Unix(100, 0)},
+					Expiration: int64(defaultExpiration)},
 			},
 		},
 		{
@@ -89,18 +95,22 @@ func TestStats_ReportNACK(t *testing.T) {
 				rType:  "endpoint",
 				podID:  "pod-xxxx",
 				nonce:  "xyz",
+				code:   3,
+				msg:    "error",
 			},
 			want: map[string]kv.Item{
 				"node:endpoint:aaaa:pod-xxxx:nonce:xyz":    {Object: "", Expiration: int64(defaultExpiration)},
 				"node:endpoint:aaaa:pod-xxxx:nack_counter": {Object: int64(1), Expiration: int64(defaultExpiration)},
 				"node:endpoint:*:pod-xxxx:nack_counter":    {Object: int64(1), Expiration: int64(defaultExpiration)},
+				"node:endpoint:aaaa:pod-xxxx:nack_error": {Object: NACKError{Code: 3, Message: "error", Time: time.Unix(100, 0)},
+					Expiration: int64(defaultExpiration)},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := Stats{store: kv.NewFrom(defaultExpiration, cleanupInterval, tt.cacheItems)}
-			_, err := s.ReportNACK(tt.args.nodeID, tt.args.rType, tt.args.podID, tt.args.nonce)
+			s := NewWithItems(tt.cacheItems, time.Unix(100, 0))
+			_, err := s.ReportNACK(tt.args.nodeID, tt.args.rType, tt.args.podID, tt.args.nonce, tt.args.code, tt.args.msg)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Stats.ReportNACK() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -112,6 +122,93 @@ func TestStats_ReportNACK(t *testing.T) {
 	}
 }
 
+func TestStats_GetNACKErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		cacheItems map[string]kv.Item
+		want       map[string]NACKError
+	}{
+		{
+			name: "Returns the errors of the version indexed by pod",
+			cacheItems: map[string]kv.Item{
+				"node:endpoint:aaaa:pod-xxxx:nack_error":   {Object: NACKError{Code: 3, Message: "error-x"}},
+				"node:endpoint:aaaa:pod-yyyy:nack_error":   {Object: NACKError{Code: 3, Message: "error-y"}},
+				"node:endpoint:aaaa:pod-yyyy:nack_counter": {Object: int64(5)},
+				"node:endpoint:bbbb:pod-zzzz:nack_error":   {Object: NACKError{Code: 3, Message: "error-z"}},
+				"node:cluster:aaaa:pod-zzzz:nack_error":    {Object: NACKError{Code: 3, Message: "error-z"}},
+			},
+			want: map[string]NACKError{
+				"pod-xxxx": {Code: 3, Message: "error-x"},
+				"pod-yyyy": {Code: 3, Message: "error-y"},
+			},
+		},
+		{
+			name:       "Returns an empty map if there are no errors",
+			cacheItems: map[string]kv.Item{},
+			want:       map[string]NACKError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewWithItems(tt.cacheItems, time.Now())
+			if got := s.GetNACKErrors("node", "endpoint", "aaaa"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stats.GetNACKErrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStats_ResetNACKs(t *testing.T) {
+	type args struct {
+		nodeID  string
+		version string
+	}
+	tests := []struct {
+		name       string
+		cacheItems map[string]kv.Item
+		args       args
+		want       map[string]kv.Item
+	}{
+		{
+			name: "Deletes the nack counters and errors of the resource version",
+			cacheItems: map[string]kv.Item{
+				"node:endpoint:5d7c9f:pod-xxxx:nack_counter":  {Object: int64(5), Expiration: int64(defaultExpiration)},
+				"node:cluster:5d7c9f:pod-aaaa:nack_counter":   {Object: int64(2), Expiration: int64(defaultExpiration)},
+				"node:endpoint:5d7c9f:pod-xxxx:ack_counter":   {Object: int64(1), Expiration: int64(defaultExpiration)},
+				"node:endpoint:*:pod-xxxx:nack_counter":       {Object: int64(7), Expiration: int64(defaultExpiration)},
+				"node:endpoint:zzzz:pod-xxxx:nack_counter":    {Object: int64(2), Expiration: int64(defaultExpiration)},
+				"other:endpoint:5d7c9f:pod-xxxx:nack_counter": {Object: int64(1), Expiration: int64(defaultExpiration)},
+			},
+			args: args{
+				nodeID:  "node",
+				version: "5d7c9f",
+			},
+			want: map[string]kv.Item{
+				"node:endpoint:5d7c9f:pod-xxxx:ack_counter":   {Object: int64(1), Expiration: int64(defaultExpiration)},
+				"node:endpoint:*:pod-xxxx:nack_counter":       {Object: int64(7), Expiration: int64(defaultExpiration)},
+				"node:endpoint:zzzz:pod-xxxx:nack_counter":    {Object: int64(2), Expiration: int64(defaultExpiration)},
+				"other:endpoint:5d7c9f:pod-xxxx:nack_counter": {Object: int64(1), Expiration: int64(defaultExpiration)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			s := NewWithItems(tt.cacheItems, now)
+			s.ResetNACKs(tt.args.nodeID, tt.args.version)
+			got := s.store.Items()
+			resetKey := nackResetKey(tt.args.nodeID, tt.args.version)
+			if got[resetKey].Object != now.UnixNano() {
+				t.Errorf("Stats.ResetNACKs() reset time = %v, want %v", got[resetKey].Object, now.UnixNano())
+			}
+			delete(got, resetKey)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stats.ResetNACKs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestStats_ReportACK(t *testing.T) {
 	type args struct {
 		nodeID  string
@@ -176,6 +273,37 @@ func TestStats_ReportACK(t *testing.T) {
 	}
 }
 
+func TestStats_GetLastACKs(t *testing.T) {
+	tests := []struct {
+		name       string
+		cacheItems map[string]kv.Item
+		want       map[string]ACK
+	}{
+		{
+			name: "Returns the last version acknowledged by each pod",
+			cacheItems: map[string]kv.Item{
+				"node:endpoint:aaaa:pod-xxxx:info":        {Object: int64(100)},
+				"node:endpoint:bbbb:pod-xxxx:info":        {Object: int64(200)},
+				"node:endpoint:aaaa:pod-yyyy:info":        {Object: int64(100)},
+				"node:endpoint:aaaa:pod-yyyy:ack_counter": {Object: int64(1)},
+				"node:cluster:cccc:pod-yyyy:info":         {Object: int64(300)},
+			},
+			want: map[string]ACK{
+				"pod-xxxx": {Version: "bbbb", Time: time.UnixMilli(200)},
+				"pod-yyyy": {Version: "aaaa", Time: time.UnixMilli(100)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewWithItems(tt.cacheItems, time.Now())
+			if got := s.GetLastACKs("node", "endpoint"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stats.GetLastACKs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestStats_ReportRequest(t *testing.T) {
 	type args struct {
 		nodeID string
@@ -229,6 +357,58 @@ func TestStats_ReportRequest(t *testing.T) {
 	}
 }
 
+func TestStats_ReportUnauthorizedRequest(t *testing.T) {
+	type args struct {
+		nodeID string
+		rType  string
+		podID  string
+	}
+	tests := []struct {
+		name       string
+		cacheItems map[string]kv.Item
+		args       args
+		want       map[string]kv.Item
+	}{
+		{
+			name: "Increases counter",
+			cacheItems: map[string]kv.Item{
+				"node:endpoint:*:pod-xxxx:request_counter":      {Object: int64(23), Expiration: int64(defaultExpiration)},
+				"node:endpoint:*:pod-xxxx:unauthorized_counter": {Object: int64(2), Expiration: int64(defaultExpiration)},
+			},
+			args: args{
+				nodeID: "node",
+				rType:  "endpoint",
+				podID:  "pod-xxxx",
+			},
+			want: map[string]kv.Item{
+				"node:endpoint:*:pod-xxxx:request_counter":      {Object: int64(23), Expiration: int64(defaultExpiration)},
+				"node:endpoint:*:pod-xxxx:unauthorized_counter": {Object: int64(3), Expiration: int64(defaultExpiration)},
+			},
+		},
+		{
+			name:       "Creates new counter",
+			cacheItems: map[string]kv.Item{},
+			args: args{
+				nodeID: "node",
+				rType:  "endpoint",
+				podID:  "pod-aaaa",
+			},
+			want: map[string]kv.Item{
+				"node:endpoint:*:pod-aaaa:unauthorized_counter": {Object: int64(1), Expiration: int64(defaultExpiration)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Stats{store: kv.NewFrom(defaultExpiration, cleanupInterval, tt.cacheItems)}
+			s.ReportUnauthorizedRequest(tt.args.nodeID, tt.args.rType, tt.args.podID)
+			if got := s.store.Items(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stats.ReportUnauthorizedRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetStringValueFromMetadata(t *testing.T) {
 	type args struct {
 		meta map[string]interface{}
@@ -312,6 +492,18 @@ func TestStats_GetSubscribedPods(t *testing.T) {
 			},
 			want: map[string]int8{"pod-xxxx": 1, "pod-yyyy": 1},
 		},
+		{
+			name: "Does not match nodeIDs that share a prefix",
+			cacheItems: map[string]kv.Item{
+				"gw:cluster:*:pod-xxxx:request_counter":          {Object: int64(1), Expiration: int64(defaultExpiration)},
+				"gw-internal:cluster:*:pod-yyyy:request_counter": {Object: int64(1), Expiration: int64(defaultExpiration)},
+			},
+			args: args{
+				nodeID: "gw",
+				rType:  "",
+			},
+			want: map[string]int8{"pod-xxxx": 1},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -411,9 +603,27 @@ func TestStats_GetPercentageFailing(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := Stats{store: kv.NewFrom(defaultExpiration, cleanupInterval, tt.cacheItems)}
-			if got := s.GetPercentageFailing(tt.args.nodeID, tt.args.rType, tt.args.version); got != tt.want {
+			if got := s.GetPercentageFailing(tt.args.nodeID, tt.args.rType, tt.args.version, 5); got != tt.want {
 				t.Errorf("Stats.GetPercentageFailing() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestStats_GetPercentageFailingForPods(t *testing.T) {
+	s := Stats{store: kv.NewFrom(defaultExpiration, cleanupInterval, map[string]kv.Item{
+		"node:endpoint:*:pod-aaaa:request_counter": {Object: int64(2), Expiration: int64(defaultExpiration)},
+		"node:endpoint:*:pod-bbbb:request_counter": {Object: int64(5), Expiration: int64(defaultExpiration)},
+		"node:endpoint:*:pod-cccc:request_counter": {Object: int64(1), Expiration: int64(defaultExpiration)},
+		"node:endpoint:xxxx:pod-aaaa:nack_counter": {Object: int64(5), Expiration: int64(defaultExpiration)},
+	})}
+	if got := s.GetPercentageFailingForPods("node", "endpoint", "xxxx", map[string]int8{"pod-aaaa": 1}, 5); got != 1 {
+		t.Errorf("Stats.GetPercentageFailingForPods() = %v, want %v", got, 1)
+	}
+	if got := s.GetPercentageFailingForPods("node", "endpoint", "xxxx", map[string]int8{"pod-aaaa": 1, "pod-bbbb": 1}, 5); got != 0.5 {
+		t.Errorf("Stats.GetPercentageFailingForPods() = %v, want %v", got, 0.5)
+	}
+	if got := s.GetPercentageFailingForPods("node", "endpoint", "xxxx", map[string]int8{"pod-aaaa": 1}, 6); got != 0 {
+		t.Errorf("Stats.GetPercentageFailingForPods() = %v, want %v", got, 0)
+	}
+}
@@ -2,6 +2,7 @@ package discoveryservice
 
 import (
 	"context"
+	"fmt"
 
 	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
 	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
@@ -11,16 +12,21 @@ var _ xdss.Cache = Cache{}
 
 // Cache implements "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss".Cache for envoy API v3.
 type Cache struct {
-	v3 cache_v3.SnapshotCache
+	v3     cache_v3.SnapshotCache
+	router *CanaryRouter
 }
 
 // NewCache returns a Cache object.
 func NewCache() Cache {
-	return Cache{v3: cache_v3.NewSnapshotCache(true, cache_v3.IDHash{}, nil)}
+	router := NewCanaryRouter(nil)
+	return Cache{v3: router, router: router}
 }
 
 // NewCache returns a Cache object.
 func NewCacheFromSnapshotCache(v3 cache_v3.SnapshotCache) Cache {
+	if router, ok := v3.(*CanaryRouter); ok {
+		return Cache{v3: router, router: router}
+	}
 	return Cache{v3: v3}
 }
 
@@ -50,3 +56,46 @@ func (c Cache) NewSnapshot() xdss.Snapshot {
 
 	return NewSnapshot()
 }
+
+// SetCanarySnapshot updates the canary snapshot for a node and routes the envoy
+// clients selected by the CanaryTarget to it.
+func (c Cache) SetCanarySnapshot(ctx context.Context, nodeID string, target xdss.CanaryTarget, snap xdss.Snapshot) error {
+	if c.router == nil {
+		return fmt.Errorf("canary publication is not supported by this cache")
+	}
+
+	if err := c.v3.SetSnapshot(ctx, CanaryNodeID(nodeID), snap.(Snapshot).v3); err != nil {
+		return err
+	}
+	c.router.SetTarget(nodeID, target)
+	return nil
+}
+
+// GetCanarySnapshot gets the canary snapshot and CanaryTarget for a node, and returns
+// an error if the node has no canary.
+func (c Cache) GetCanarySnapshot(nodeID string) (xdss.Snapshot, xdss.CanaryTarget, error) {
+	if c.router == nil {
+		return &Snapshot{}, xdss.CanaryTarget{}, fmt.Errorf("canary publication is not supported by this cache")
+	}
+
+	target, ok := c.router.GetTarget(nodeID)
+	if !ok {
+		return &Snapshot{}, xdss.CanaryTarget{}, fmt.Errorf("no canary for node %s", nodeID)
+	}
+	snap, err := c.v3.GetSnapshot(CanaryNodeID(nodeID))
+	if err != nil {
+		return &Snapshot{}, xdss.CanaryTarget{}, err
+	}
+	return &Snapshot{v3: snap.(*cache_v3.Snapshot)}, target, nil
+}
+
+// ClearCanary routes all the envoy clients of a node back to the
+// regular snapshot and clears the canary snapshot.
+func (c Cache) ClearCanary(nodeID string) {
+	if c.router == nil {
+		return
+	}
+
+	c.router.ClearTarget(nodeID)
+	c.v3.ClearSnapshot(CanaryNodeID(nodeID))
+}
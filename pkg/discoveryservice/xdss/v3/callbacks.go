@@ -16,23 +16,41 @@ package discoveryservice
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"sync"
 
 	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_resources_v3 "github.com/3scale-ops/marin3r/pkg/envoy/resources/v3"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
-	"github.com/3scale-ops/marin3r/pkg/util/backoff"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	server_v3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
 	"github.com/go-logr/logr"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 // Callbacks is a type that implements go-control-plane/pkg/server/Callbacks
 type Callbacks struct {
 	Stats  *stats.Stats
 	Logger logr.Logger
+	// EnforceNodeIDBinding makes the server reject the requests of clients whose
+	// certificate is not valid for the requested nodeID. When false, these requests
+	// are only logged and counted.
+	EnforceNodeIDBinding bool
+	// NamespacedNodeIDs prefixes the nodeIDs of the envoy clients with their
+	// namespace, so the stats of different namespaces don't collide
+	NamespacedNodeIDs bool
+	// Throttler delays the responses to streams that NACK and caps the concurrent
+	// pushes per nodeID. Responses are not throttled if nil.
+	Throttler *Throttler
+	// deltaStreamNodes keeps track of the node of each delta stream, as
+	// envoy only sends the node information in the first request of the stream
+	deltaStreamNodes sync.Map
+	// streamIdentities keeps track of the identities present in the
+	// client certificate of the peer that opened each stream
+	streamIdentities sync.Map
 }
 
 var _ server_v3.Callbacks = &Callbacks{}
@@ -40,6 +58,8 @@ var _ server_v3.Callbacks = &Callbacks{}
 // OnStreamOpen implements go-control-plane/pkg/server/Callbacks.OnStreamOpen
 // Returning an error will end processing and close the stream. OnStreamClosed will still be called.
 func (cb *Callbacks) OnStreamOpen(ctx context.Context, id int64, typ string) error {
+	cb.streamIdentities.Store(id, peerIdentities(ctx))
+	cb.Throttler.OnStreamOpen(ctx, id)
 	cb.Logger.V(1).Info("Stream opened", "StreamId", id)
 	return nil
 }
@@ -47,6 +67,8 @@ func (cb *Callbacks) OnStreamOpen(ctx context.Context, id int64, typ string) err
 // OnStreamClosed implements go-control-plane/pkg/server/Callbacks.OnStreamClosed
 // OnStreamClosed is called immediately prior to closing an xDS stream with a stream ID.
 func (cb *Callbacks) OnStreamClosed(id int64, node *envoy_config_core_v3.Node) {
+	cb.streamIdentities.Delete(id)
+	cb.Throttler.OnStreamClosed(id)
 	cb.Logger.V(1).Info("Stream closed", "StreamID", id)
 }
 
@@ -54,41 +76,51 @@ func (cb *Callbacks) OnStreamClosed(id int64, node *envoy_config_core_v3.Node) {
 // OnStreamRequest is called once a request is received on a stream.
 // Returning an error will end processing and close the stream. OnStreamClosed will still be called.
 func (cb *Callbacks) OnStreamRequest(id int64, req *envoy_service_discovery_v3.DiscoveryRequest) error {
+	nodeID := cb.nodeID(req.GetNode())
+
 	// Try to get the Pod name associated with the request
 	podName, err := stats.GetStringValueFromMetadata(req.GetNode().Metadata.AsMap(), "pod_name")
 	if err != nil {
-		cb.Logger.Error(err, "an error ocurred, Pod name could not be retrieved", "NodeID", req.GetNode().GetId(), "StreamID", id)
+		cb.Logger.Error(err, "an error ocurred, Pod name could not be retrieved", "NodeID", nodeID, "StreamID", id)
 		podName = "unknown"
 	}
 
-	log := cb.Logger.WithValues("TypeURL", req.GetTypeUrl(), "NodeID", req.GetNode().GetId(), "StreamID", id,
+	log := cb.Logger.WithValues("TypeURL", req.GetTypeUrl(), "NodeID", nodeID, "StreamID", id,
 		"Pod", podName, "ResourceNames", req.GetResourceNames(), "LastAcceptedVersion", req.GetVersionInfo())
 
+	if err := ValidateNodeID(req.GetNode()); err != nil {
+		log.Info("Discovery Request rejected", "Reason", err.Error())
+		return err
+	}
+	if err := cb.authorize(id, nodeID, req.GetTypeUrl(), podName, log); err != nil {
+		return err
+	}
+
+	var nack bool
+	var failures int64
 	if req.GetResponseNonce() != "" {
 		if req.GetErrorDetail() != nil {
-			log.Info("Discovery NACK")
-			failures, err := cb.Stats.ReportNACK(req.GetNode().GetId(), req.GetTypeUrl(), podName, req.GetResponseNonce())
+			log.Info("Discovery NACK", "ErrorCode", req.GetErrorDetail().GetCode(), "ErrorMessage", req.GetErrorDetail().GetMessage())
+			nack = true
+			failures, err = cb.Stats.ReportNACK(nodeID, req.GetTypeUrl(), podName, req.GetResponseNonce(),
+				req.GetErrorDetail().GetCode(), req.GetErrorDetail().GetMessage())
 			if err != nil {
 				log.Error(err, "error trying to report a response NACK")
 			}
 
-			// Backoff
-			if failures == 0 {
-				time.Sleep(100 * time.Millisecond)
-			} else {
-				time.Sleep(backoff.Default.Duration(int(failures)))
-			}
-
 		} else {
 			log.Info("Discovery ACK")
-			cb.Stats.ReportACK(req.GetNode().GetId(), req.GetTypeUrl(), req.GetVersionInfo(), podName)
+			cb.Stats.ReportACK(nodeID, req.GetTypeUrl(), req.GetVersionInfo(), podName)
 		}
 
 	} else {
 		log.Info("Discovery Request")
-		cb.Stats.ReportRequest(req.GetNode().GetId(), req.GetTypeUrl(), podName)
+		cb.Stats.ReportRequest(nodeID, req.GetTypeUrl(), podName)
 	}
 
+	// NACKs back off the stream, delaying its next response without blocking it
+	cb.Throttler.OnRequest(id, nodeID, req.GetTypeUrl(), req, nack, int(failures))
+
 	return nil
 }
 
@@ -97,14 +129,15 @@ func (cb *Callbacks) OnStreamRequest(id int64, req *envoy_service_discovery_v3.D
 func (cb *Callbacks) OnStreamResponse(ctx context.Context, id int64, req *envoy_service_discovery_v3.DiscoveryRequest,
 	rsp *envoy_service_discovery_v3.DiscoveryResponse) {
 
-	log := cb.Logger.WithValues("TypeURL", req.GetTypeUrl(), "NodeID", req.GetNode().GetId(), "StreamID", id, "Version", rsp.GetVersionInfo())
+	nodeID := cb.nodeID(req.GetNode())
+	log := cb.Logger.WithValues("TypeURL", req.GetTypeUrl(), "NodeID", nodeID, "StreamID", id, "Version", rsp.GetVersionInfo())
 
 	// Track the nonce of this response in the stats cache
 	podName, err := stats.GetStringValueFromMetadata(req.GetNode().Metadata.AsMap(), "pod_name")
 	if err != nil {
 		log.Error(err, "an error ocurred, nonce won't be tracked")
 	} else {
-		cb.Stats.WriteResponseNonce(req.GetNode().GetId(), rsp.GetTypeUrl(), rsp.GetVersionInfo(), podName, rsp.GetNonce())
+		cb.Stats.WriteResponseNonce(nodeID, rsp.GetTypeUrl(), rsp.GetVersionInfo(), podName, rsp.GetNonce())
 	}
 
 	// Log resources when in debug mode
@@ -133,19 +166,163 @@ func (cb *Callbacks) OnFetchRequest(context.Context, *envoy_service_discovery_v3
 func (cb *Callbacks) OnFetchResponse(*envoy_service_discovery_v3.DiscoveryRequest, *envoy_service_discovery_v3.DiscoveryResponse) {
 }
 
+// OnDeltaStreamOpen implements go-control-plane/pkg/server/Callbacks.OnDeltaStreamOpen
 // OnDeltaStreamOpen is called once an incremental xDS stream is open with a stream ID and the type URL (or "" for ADS).
-// Returning an error will end processing and close the stream. OnStreamClosed will still be called.
-func (cb *Callbacks) OnDeltaStreamOpen(context.Context, int64, string) error { return nil }
+// Returning an error will end processing and close the stream. OnDeltaStreamClosed will still be called.
+func (cb *Callbacks) OnDeltaStreamOpen(ctx context.Context, id int64, typ string) error {
+	cb.streamIdentities.Store(id, peerIdentities(ctx))
+	cb.Throttler.OnStreamOpen(ctx, id)
+	cb.Logger.V(1).Info("Delta stream opened", "StreamId", id)
+	return nil
+}
 
+// OnDeltaStreamClosed implements go-control-plane/pkg/server/Callbacks.OnDeltaStreamClosed
 // OnDeltaStreamClosed is called immediately prior to closing an xDS stream with a stream ID.
-func (cb *Callbacks) OnDeltaStreamClosed(int64, *envoy_config_core_v3.Node) {}
+func (cb *Callbacks) OnDeltaStreamClosed(id int64, node *envoy_config_core_v3.Node) {
+	cb.deltaStreamNodes.Delete(id)
+	cb.streamIdentities.Delete(id)
+	cb.Throttler.OnStreamClosed(id)
+	cb.Logger.V(1).Info("Delta stream closed", "StreamID", id)
+}
 
+// OnStreamDeltaRequest implements go-control-plane/pkg/server/Callbacks.OnStreamDeltaRequest
 // OnStreamDeltaRequest is called once a request is received on a stream.
-// Returning an error will end processing and close the stream. OnStreamClosed will still be called.
-func (cb *Callbacks) OnStreamDeltaRequest(int64, *envoy_service_discovery_v3.DeltaDiscoveryRequest) error {
+// Returning an error will end processing and close the stream. OnDeltaStreamClosed will still be called.
+func (cb *Callbacks) OnStreamDeltaRequest(id int64, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) error {
+	// The node is only guaranteed to be present in the first request
+	// of the stream, so keep track of it for subsequent requests
+	node := req.GetNode()
+	if node != nil {
+		cb.deltaStreamNodes.Store(id, node)
+	} else if v, ok := cb.deltaStreamNodes.Load(id); ok {
+		node = v.(*envoy_config_core_v3.Node)
+	}
+	nodeID := cb.nodeID(node)
+
+	// Try to get the Pod name associated with the request
+	podName, err := stats.GetStringValueFromMetadata(node.GetMetadata().AsMap(), "pod_name")
+	if err != nil {
+		cb.Logger.Error(err, "an error ocurred, Pod name could not be retrieved", "NodeID", nodeID, "StreamID", id)
+		podName = "unknown"
+	}
+
+	log := cb.Logger.WithValues("TypeURL", req.GetTypeUrl(), "NodeID", nodeID, "StreamID", id, "Pod", podName,
+		"ResourceNamesSubscribe", req.GetResourceNamesSubscribe(), "ResourceNamesUnsubscribe", req.GetResourceNamesUnsubscribe())
+
+	if err := ValidateNodeID(node); err != nil {
+		log.Info("Delta discovery Request rejected", "Reason", err.Error())
+		return err
+	}
+	if err := cb.authorize(id, nodeID, req.GetTypeUrl(), podName, log); err != nil {
+		return err
+	}
+
+	var nack bool
+	var failures int64
+	if req.GetResponseNonce() != "" {
+		if req.GetErrorDetail() != nil {
+			log.Info("Delta discovery NACK", "ErrorCode", req.GetErrorDetail().GetCode(), "ErrorMessage", req.GetErrorDetail().GetMessage())
+			nack = true
+			failures, err = cb.Stats.ReportNACK(nodeID, req.GetTypeUrl(), podName, req.GetResponseNonce(),
+				req.GetErrorDetail().GetCode(), req.GetErrorDetail().GetMessage())
+			if err != nil {
+				log.Error(err, "error trying to report a response NACK")
+			}
+
+		} else {
+			// Delta requests do not carry the accepted version, so it
+			// needs to be looked up using the response nonce
+			version, err := cb.Stats.GetVersionFromNonce(nodeID, req.GetTypeUrl(), podName, req.GetResponseNonce())
+			if err != nil {
+				log.Error(err, "error trying to report a response ACK")
+			} else {
+				log.Info("Delta discovery ACK", "Version", version)
+				cb.Stats.ReportACK(nodeID, req.GetTypeUrl(), version, podName)
+			}
+		}
+
+	} else {
+		log.Info("Delta discovery Request")
+		cb.Stats.ReportRequest(nodeID, req.GetTypeUrl(), podName)
+	}
+
+	// NACKs back off the stream, delaying its next response without blocking it
+	cb.Throttler.OnRequest(id, nodeID, req.GetTypeUrl(), req, nack, int(failures))
+
+	return nil
+}
+
+// OnStreamDeltaResponse implements go-control-plane/pkg/server/Callbacks.OnStreamDeltaResponse
+// OnStreamDeltaResponse is called immediately prior to sending a response on a stream.
+func (cb *Callbacks) OnStreamDeltaResponse(id int64, req *envoy_service_discovery_v3.DeltaDiscoveryRequest,
+	rsp *envoy_service_discovery_v3.DeltaDiscoveryResponse) {
+
+	nodeID := cb.nodeID(req.GetNode())
+	log := cb.Logger.WithValues("TypeURL", req.GetTypeUrl(), "NodeID", nodeID, "StreamID", id, "Version", rsp.GetSystemVersionInfo())
+
+	// Track the nonce of this response in the stats cache
+	podName, err := stats.GetStringValueFromMetadata(req.GetNode().GetMetadata().AsMap(), "pod_name")
+	if err != nil {
+		log.Error(err, "an error ocurred, nonce won't be tracked")
+	} else {
+		cb.Stats.WriteResponseNonce(nodeID, rsp.GetTypeUrl(), rsp.GetSystemVersionInfo(), podName, rsp.GetNonce())
+	}
+
+	// Log resources when in debug mode
+	names := make([]string, 0, len(rsp.GetResources()))
+	resources := make([]string, 0, len(rsp.GetResources()))
+	for _, r := range rsp.GetResources() {
+		names = append(names, r.GetName())
+		j, _ := envoy_serializer.NewResourceMarshaller(envoy_serializer.JSON, envoy.APIv3).Marshal(r.GetResource())
+		resources = append(resources, string(j))
+	}
+	if rsp.GetTypeUrl() == envoy_resources_v3.Mappings()[envoy.Secret] {
+		// Do not log secret contents
+		log.V(1).Info("Delta discovery Response", "ResourcesNames", names, "RemovedResources", rsp.GetRemovedResources(), "Pod", podName)
+	} else {
+		log.V(1).Info("Delta discovery Response", "Resources", resources, "RemovedResources", rsp.GetRemovedResources(), "Pod", podName)
+	}
+}
+
+func (cb *Callbacks) nodeID(node *envoy_config_core_v3.Node) string {
+	return NodeID(node, cb.NamespacedNodeIDs)
+}
+
+// authorize checks that the client certificate used to open the stream is valid for
+// the given nodeID. Mismatches are counted and, if EnforceNodeIDBinding is set, an error
+// is returned so the stream is closed.
+func (cb *Callbacks) authorize(id int64, nodeID, typeURL, podName string, log logr.Logger) error {
+	var identities []string
+	if v, ok := cb.streamIdentities.Load(id); ok {
+		identities = v.([]string)
+	}
+
+	for _, identity := range identities {
+		if identity == nodeID {
+			return nil
+		}
+	}
+
+	cb.Stats.ReportUnauthorizedRequest(nodeID, typeURL, podName)
+	if cb.EnforceNodeIDBinding {
+		log.Info("Discovery Request rejected, client certificate is not valid for the node", "CertificateIdentities", identities)
+		return fmt.Errorf("client certificate is not valid for node '%s'", nodeID)
+	}
+	log.Info("Discovery Request from a client certificate that is not valid for the node", "CertificateIdentities", identities)
 	return nil
 }
 
-// OnStreamDelatResponse is called immediately prior to sending a response on a stream.
-func (cb *Callbacks) OnStreamDeltaResponse(int64, *envoy_service_discovery_v3.DeltaDiscoveryRequest, *envoy_service_discovery_v3.DeltaDiscoveryResponse) {
+// peerIdentities returns the identities the client certificate of the
+// peer is valid for: the subject's common name and the DNS SANs
+func peerIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := tlsInfo.State.PeerCertificates[0]
+	return append([]string{cert.Subject.CommonName}, cert.DNSNames...)
 }
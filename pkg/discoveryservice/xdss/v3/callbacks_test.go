@@ -16,13 +16,19 @@ package discoveryservice
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"testing"
 
 	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -240,3 +246,147 @@ func TestCallbacks_OnFetchResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestCallbacks_DeltaStream(t *testing.T) {
+	node := &envoy_config_core_v3.Node{
+		Id:       "node1",
+		Cluster:  "cluster1",
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{"pod_name": structpb.NewStringValue("pod1")}},
+	}
+
+	t.Run("Tracks ACKs for delta streams", func(t *testing.T) {
+		cb := &Callbacks{Stats: stats.New(), Logger: ctrl.Log}
+		if err := cb.OnDeltaStreamOpen(context.Background(), 1, ""); err != nil {
+			t.Fatalf("Callbacks.OnDeltaStreamOpen() error = %v", err)
+		}
+		req := &envoy_service_discovery_v3.DeltaDiscoveryRequest{Node: node, TypeUrl: "some-type"}
+		if err := cb.OnStreamDeltaRequest(1, req); err != nil {
+			t.Fatalf("Callbacks.OnStreamDeltaRequest() error = %v", err)
+		}
+		cb.OnStreamDeltaResponse(1, req, &envoy_service_discovery_v3.DeltaDiscoveryResponse{
+			SystemVersionInfo: "xxxx", TypeUrl: "some-type", Nonce: "1",
+		})
+		// subsequent requests in a delta stream do not carry the node
+		if err := cb.OnStreamDeltaRequest(1, &envoy_service_discovery_v3.DeltaDiscoveryRequest{
+			TypeUrl: "some-type", ResponseNonce: "1",
+		}); err != nil {
+			t.Fatalf("Callbacks.OnStreamDeltaRequest() error = %v", err)
+		}
+		if v, err := cb.Stats.GetCounter("node1", "some-type", "xxxx", "pod1", "ack_counter"); err != nil || v != 1 {
+			t.Errorf("Callbacks.OnStreamDeltaRequest() ack_counter = %v, error = %v", v, err)
+		}
+		cb.OnDeltaStreamClosed(1, node)
+		if _, ok := cb.deltaStreamNodes.Load(int64(1)); ok {
+			t.Errorf("Callbacks.OnDeltaStreamClosed() = node of closed stream is still tracked")
+		}
+	})
+
+	t.Run("Tracks NACKs for delta streams", func(t *testing.T) {
+		cb := &Callbacks{Stats: stats.New(), Logger: ctrl.Log}
+		req := &envoy_service_discovery_v3.DeltaDiscoveryRequest{Node: node, TypeUrl: "some-type"}
+		if err := cb.OnStreamDeltaRequest(1, req); err != nil {
+			t.Fatalf("Callbacks.OnStreamDeltaRequest() error = %v", err)
+		}
+		cb.OnStreamDeltaResponse(1, req, &envoy_service_discovery_v3.DeltaDiscoveryResponse{
+			SystemVersionInfo: "xxxx", TypeUrl: "some-type", Nonce: "1",
+		})
+		if err := cb.OnStreamDeltaRequest(1, &envoy_service_discovery_v3.DeltaDiscoveryRequest{
+			TypeUrl: "some-type", ResponseNonce: "1", ErrorDetail: &status.Status{Code: 0, Message: "xxxx"},
+		}); err != nil {
+			t.Fatalf("Callbacks.OnStreamDeltaRequest() error = %v", err)
+		}
+		if v, err := cb.Stats.GetCounter("node1", "some-type", "xxxx", "pod1", "nack_counter"); err != nil || v != 1 {
+			t.Errorf("Callbacks.OnStreamDeltaRequest() nack_counter = %v, error = %v", v, err)
+		}
+	})
+}
+
+func TestCallbacks_NodeIDBinding(t *testing.T) {
+	peerCtx := func(cn string, dnsNames ...string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}, DNSNames: dnsNames}},
+			}},
+		})
+	}
+	node := &envoy_config_core_v3.Node{
+		Id:       "node1",
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{"pod_name": structpb.NewStringValue("pod1")}},
+	}
+
+	tests := []struct {
+		name             string
+		enforce          bool
+		ctx              context.Context
+		wantErr          bool
+		wantUnauthorized int64
+	}{
+		{
+			name:             "Authorizes nodeID in the certificate's SANs",
+			enforce:          true,
+			ctx:              peerCtx("envoy-client-cert", "node1"),
+			wantErr:          false,
+			wantUnauthorized: 0,
+		},
+		{
+			name:             "Authorizes nodeID in the certificate's CN",
+			enforce:          true,
+			ctx:              peerCtx("node1"),
+			wantErr:          false,
+			wantUnauthorized: 0,
+		},
+		{
+			name:             "Rejects mismatching nodeID",
+			enforce:          true,
+			ctx:              peerCtx("envoy-client-cert", "node2"),
+			wantErr:          true,
+			wantUnauthorized: 1,
+		},
+		{
+			name:             "Rejects streams without client certificate",
+			enforce:          true,
+			ctx:              context.Background(),
+			wantErr:          true,
+			wantUnauthorized: 1,
+		},
+		{
+			name:             "Only counts mismatches when not enforced",
+			enforce:          false,
+			ctx:              peerCtx("envoy-client-cert", "node2"),
+			wantErr:          false,
+			wantUnauthorized: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &Callbacks{Stats: stats.New(), Logger: ctrl.Log, EnforceNodeIDBinding: tt.enforce}
+
+			if err := cb.OnStreamOpen(tt.ctx, 1, ""); err != nil {
+				t.Fatalf("Callbacks.OnStreamOpen() error = %v", err)
+			}
+			err := cb.OnStreamRequest(1, &envoy_service_discovery_v3.DiscoveryRequest{Node: node, TypeUrl: "some-type"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Callbacks.OnStreamRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err := cb.OnDeltaStreamOpen(tt.ctx, 2, ""); err != nil {
+				t.Fatalf("Callbacks.OnDeltaStreamOpen() error = %v", err)
+			}
+			err = cb.OnStreamDeltaRequest(2, &envoy_service_discovery_v3.DeltaDiscoveryRequest{Node: node, TypeUrl: "some-type"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Callbacks.OnStreamDeltaRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, _ := cb.Stats.GetCounter("node1", "some-type", "*", "pod1", "unauthorized_counter")
+			if got != tt.wantUnauthorized*2 {
+				t.Errorf("unauthorized_counter = %v, want %v", got, tt.wantUnauthorized*2)
+			}
+
+			cb.OnStreamClosed(1, node)
+			cb.OnDeltaStreamClosed(2, node)
+			if _, ok := cb.streamIdentities.Load(int64(1)); ok {
+				t.Errorf("Callbacks.OnStreamClosed() = identities of closed stream are still tracked")
+			}
+		})
+	}
+}
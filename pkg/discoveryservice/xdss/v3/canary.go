@@ -0,0 +1,227 @@
+package discoveryservice
+
+import (
+	"sync"
+
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/log"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+)
+
+const (
+	// canaryNodeIDSeparator separates the nodeID from the canary suffix in the keys
+	// of the canary snapshots. It is not allowed in nodeIDs so the keys of canary
+	// snapshots never collide with the nodeID of an envoy client, see ValidateNodeID().
+	canaryNodeIDSeparator = "#"
+	canaryNodeIDSuffix    = canaryNodeIDSeparator + "canary"
+)
+
+// CanaryNodeID returns the key used to store the canary snapshot of a nodeID
+func CanaryNodeID(nodeID string) string {
+	return nodeID + canaryNodeIDSuffix
+}
+
+// CanaryRouter is a snapshot cache that routes the envoy clients selected by the
+// CanaryTarget of their nodeID to the canary snapshot of that nodeID. It keeps track of
+// the open watches so envoy clients are re-routed as soon as the CanaryTarget changes,
+// without having to wait for the client to issue a new request.
+type CanaryRouter struct {
+	cache_v3.SnapshotCache
+	// NamespacedNodeIDs prefixes the nodeIDs of the envoy
+	// clients with their namespace, see NodeID()
+	NamespacedNodeIDs bool
+
+	tmu     sync.RWMutex
+	targets map[string]xdss.CanaryTarget
+
+	wmu     sync.Mutex
+	watches map[*routedWatch]struct{}
+}
+
+var _ cache_v3.NodeHash = &CanaryRouter{}
+
+// NewCanaryRouter returns a CanaryRouter backed by an ADS snapshot cache
+func NewCanaryRouter(logger log.Logger) *CanaryRouter {
+	r := &CanaryRouter{
+		targets: map[string]xdss.CanaryTarget{},
+		watches: map[*routedWatch]struct{}{},
+	}
+	r.SnapshotCache = cache_v3.NewSnapshotCache(true, r, logger)
+	return r
+}
+
+// ID implements go-control-plane/pkg/cache/v3.NodeHash
+func (r *CanaryRouter) ID(node *envoy_config_core_v3.Node) string {
+	if node == nil {
+		return ""
+	}
+
+	nodeID := NodeID(node, r.NamespacedNodeIDs)
+	r.tmu.RLock()
+	target, ok := r.targets[nodeID]
+	r.tmu.RUnlock()
+
+	if ok {
+		pod, err := stats.GetStringValueFromMetadata(node.GetMetadata().AsMap(), "pod_name")
+		if err == nil && target.Selects(pod) {
+			return CanaryNodeID(nodeID)
+		}
+	}
+	return nodeID
+}
+
+// GetTarget returns the CanaryTarget for the given nodeID, if any
+func (r *CanaryRouter) GetTarget(nodeID string) (xdss.CanaryTarget, bool) {
+	r.tmu.RLock()
+	defer r.tmu.RUnlock()
+	target, ok := r.targets[nodeID]
+	return target, ok
+}
+
+// SetTarget sets the CanaryTarget for the given nodeID and re-routes the
+// open watches of the nodeID
+func (r *CanaryRouter) SetTarget(nodeID string, target xdss.CanaryTarget) {
+	r.tmu.Lock()
+	r.targets[nodeID] = target
+	r.tmu.Unlock()
+	r.reroute(nodeID)
+}
+
+// ClearTarget removes the CanaryTarget for the given nodeID and re-routes the
+// open watches of the nodeID
+func (r *CanaryRouter) ClearTarget(nodeID string) {
+	r.tmu.Lock()
+	delete(r.targets, nodeID)
+	r.tmu.Unlock()
+	r.reroute(nodeID)
+}
+
+// CreateWatch implements go-control-plane/pkg/cache/v3.ConfigWatcher
+func (r *CanaryRouter) CreateWatch(req *cache_v3.Request, state stream.StreamState, out chan cache_v3.Response) func() {
+	return routeWatch(r, req.GetNode(), out, func(in chan cache_v3.Response) func() {
+		return r.SnapshotCache.CreateWatch(req, state, in)
+	})
+}
+
+// CreateDeltaWatch implements go-control-plane/pkg/cache/v3.ConfigWatcher
+func (r *CanaryRouter) CreateDeltaWatch(req *cache_v3.DeltaRequest, state stream.StreamState, out chan cache_v3.DeltaResponse) func() {
+	return routeWatch(r, req.GetNode(), out, func(in chan cache_v3.DeltaResponse) func() {
+		return r.SnapshotCache.CreateDeltaWatch(req, state, in)
+	})
+}
+
+func (r *CanaryRouter) reroute(nodeID string) {
+	r.wmu.Lock()
+	watches := make([]*routedWatch, 0, len(r.watches))
+	for w := range r.watches {
+		if NodeID(w.node, r.NamespacedNodeIDs) == nodeID {
+			watches = append(watches, w)
+		}
+	}
+	r.wmu.Unlock()
+
+	for _, w := range watches {
+		w.reroute(r.ID(w.node))
+	}
+}
+
+func (r *CanaryRouter) track(w *routedWatch) {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	r.watches[w] = struct{}{}
+}
+
+func (r *CanaryRouter) untrack(w *routedWatch) {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	delete(r.watches, w)
+}
+
+// routedWatch is a watch in the underlying snapshot cache that can be moved
+// from one cache key to another while it has not been responded
+type routedWatch struct {
+	mu      sync.Mutex
+	node    *envoy_config_core_v3.Node
+	key     string
+	create  func() func()
+	cancel  func()
+	pending func() int
+	done    bool
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// routeWatch creates a watch in the underlying cache using an intermediate channel, so
+// the watch can be re-created under a different key without sending more than one
+// response to the stream
+func routeWatch[R any](r *CanaryRouter, node *envoy_config_core_v3.Node, out chan R, create func(chan R) func()) func() {
+	// the intermediate channel can hold responses from both the
+	// old and the new watch if a response races with a re-route
+	in := make(chan R, 2)
+
+	w := &routedWatch{
+		node:    node,
+		create:  func() func() { return create(in) },
+		pending: func() int { return len(in) },
+		closed:  make(chan struct{}),
+	}
+	// track the watch before creating it so a re-route
+	// that happens in between is not lost
+	w.mu.Lock()
+	r.track(w)
+	w.key = r.ID(node)
+	w.cancel = w.create()
+	w.mu.Unlock()
+
+	go func() {
+		select {
+		case resp := <-in:
+			w.finish()
+			r.untrack(w)
+			select {
+			case out <- resp:
+			case <-w.closed:
+			}
+		case <-w.closed:
+		}
+	}()
+
+	return func() {
+		r.untrack(w)
+		w.close()
+	}
+}
+
+func (w *routedWatch) reroute(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done || w.pending() > 0 || w.key == key {
+		return
+	}
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.key = key
+	w.cancel = w.create()
+}
+
+func (w *routedWatch) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+}
+
+func (w *routedWatch) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.done = true
+	w.once.Do(func() { close(w.closed) })
+}
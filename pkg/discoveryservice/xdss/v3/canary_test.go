@@ -0,0 +1,123 @@
+package discoveryservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func testNode(nodeID, pod string) *envoy_config_core_v3.Node {
+	return &envoy_config_core_v3.Node{
+		Id:       nodeID,
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{"pod_name": structpb.NewStringValue(pod)}},
+	}
+}
+
+func TestCanaryRouter_ID(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets map[string]xdss.CanaryTarget
+		node    *envoy_config_core_v3.Node
+		want    string
+	}{
+		{
+			name:    "No canary for the node",
+			targets: map[string]xdss.CanaryTarget{},
+			node:    testNode("node", "pod1"),
+			want:    "node",
+		},
+		{
+			name:    "Pod selected by the canary target",
+			targets: map[string]xdss.CanaryTarget{"node": {Version: "v2", Pods: []string{"pod1"}}},
+			node:    testNode("node", "pod1"),
+			want:    "node#canary",
+		},
+		{
+			name:    "Pod not selected by the canary target",
+			targets: map[string]xdss.CanaryTarget{"node": {Version: "v2", Pods: []string{"pod2"}}},
+			node:    testNode("node", "pod1"),
+			want:    "node",
+		},
+		{
+			name:    "Node without metadata",
+			targets: map[string]xdss.CanaryTarget{"node": {Version: "v2", Percentage: 99}},
+			node:    &envoy_config_core_v3.Node{Id: "node"},
+			want:    "node",
+		},
+		{
+			name:    "Nil node",
+			targets: map[string]xdss.CanaryTarget{},
+			node:    nil,
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCanaryRouter(nil)
+			r.targets = tt.targets
+			if got := r.ID(tt.node); got != tt.want {
+				t.Errorf("CanaryRouter.ID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanaryRouter_CreateWatch(t *testing.T) {
+	stable := NewSnapshot().SetResources(envoy.Endpoint, []envoy.Resource{
+		&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "stable"}})
+	canary := NewSnapshot().SetResources(envoy.Endpoint, []envoy.Resource{
+		&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "canary"}})
+
+	c := NewCache()
+	if err := c.SetSnapshot(context.TODO(), "node", stable); err != nil {
+		t.Fatalf("Cache.SetSnapshot() error = %v", err)
+	}
+
+	// open a watch for a client that is already up to date with the stable snapshot
+	out := make(chan cache_v3.Response, 1)
+	cancel := c.router.CreateWatch(&cache_v3.Request{
+		Node:        testNode("node", "pod1"),
+		TypeUrl:     resource.EndpointType,
+		VersionInfo: stable.GetVersion(envoy.Endpoint),
+	}, stream.NewStreamState(true, map[string]string{}), out)
+	defer cancel()
+
+	select {
+	case <-out:
+		t.Fatalf("CanaryRouter.CreateWatch() unexpected response before publishing the canary")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// publishing the canary re-routes the open watch
+	target := xdss.CanaryTarget{Version: "v2", Pods: []string{"pod1"}}
+	if err := c.SetCanarySnapshot(context.TODO(), "node", target, canary); err != nil {
+		t.Fatalf("Cache.SetCanarySnapshot() error = %v", err)
+	}
+
+	select {
+	case resp := <-out:
+		if got, _ := resp.GetVersion(); got != canary.GetVersion(envoy.Endpoint) {
+			t.Errorf("CanaryRouter.CreateWatch() got version = %v, want %v", got, canary.GetVersion(envoy.Endpoint))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("CanaryRouter.CreateWatch() no response after publishing the canary")
+	}
+
+	if _, got, err := c.GetCanarySnapshot("node"); err != nil || got.Version != target.Version {
+		t.Errorf("Cache.GetCanarySnapshot() got = %v, err %v", got, err)
+	}
+
+	c.ClearCanary("node")
+	if _, _, err := c.GetCanarySnapshot("node"); err == nil {
+		t.Errorf("Cache.ClearCanary() canary still present")
+	}
+}
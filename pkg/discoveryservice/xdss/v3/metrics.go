@@ -0,0 +1,41 @@
+package discoveryservice
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	throttleReasonBackoff             = "backoff"
+	throttleReasonMaxConcurrentPushes = "max_concurrent_pushes"
+)
+
+var (
+	throttledResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "marin3r_xdss_throttled_responses_total",
+			Help: "Number of discovery responses delayed by the throttler, by reason",
+		},
+		[]string{"node_id", "reason"},
+	)
+
+	delayedResponses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "marin3r_xdss_delayed_responses",
+			Help: "Number of discovery responses currently held back by the throttler",
+		},
+		[]string{"node_id"},
+	)
+
+	inflightPushes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "marin3r_xdss_inflight_pushes",
+			Help: "Number of envoy clients with discovery responses pending acknowledgement",
+		},
+		[]string{"node_id"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(throttledResponses, delayedResponses, inflightPushes)
+}
@@ -0,0 +1,41 @@
+package discoveryservice
+
+import (
+	"fmt"
+	"strings"
+
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+// NodeID returns the nodeID of an envoy client. If namespaced is true, the nodeID is prefixed
+// with the namespace the client reports in the "pod_namespace" key of its metadata. Clients that
+// don't report their namespace keep their nodeID, so they don't match any namespaced nodeID. The
+// reported namespace is not trusted: the client certificate must be valid for the namespaced nodeID
+// (see Callbacks.EnforceNodeIDBinding) and ValidateNodeID() must be called first.
+func NodeID(node *envoy_config_core_v3.Node, namespaced bool) string {
+	if !namespaced {
+		return node.GetId()
+	}
+	namespace, err := stats.GetStringValueFromMetadata(node.GetMetadata().AsMap(), "pod_namespace")
+	if err != nil || namespace == "" {
+		return node.GetId()
+	}
+	return xdss.NamespacedNodeID(namespace, node.GetId())
+}
+
+// reservedNodeIDChars are the characters used to build the keys of the xDS cache
+var reservedNodeIDChars = []string{xdss.NamespaceSeparator, canaryNodeIDSeparator}
+
+// ValidateNodeID checks that the nodeID reported by an envoy client does not contain any
+// of the characters used to build the keys of the xDS cache, so a client cannot claim the
+// snapshots of another nodeID
+func ValidateNodeID(node *envoy_config_core_v3.Node) error {
+	for _, c := range reservedNodeIDChars {
+		if strings.Contains(node.GetId(), c) {
+			return fmt.Errorf("nodeID '%s' contains the reserved character '%s'", node.GetId(), c)
+		}
+	}
+	return nil
+}
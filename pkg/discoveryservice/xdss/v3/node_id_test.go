@@ -0,0 +1,80 @@
+package discoveryservice
+
+import (
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNodeID(t *testing.T) {
+	tests := []struct {
+		name       string
+		node       *envoy_config_core_v3.Node
+		namespaced bool
+		want       string
+	}{
+		{
+			name: "Returns the node id",
+			node: &envoy_config_core_v3.Node{
+				Id:       "node1",
+				Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{"pod_namespace": structpb.NewStringValue("ns")}},
+			},
+			namespaced: false,
+			want:       "node1",
+		},
+		{
+			name: "Returns the node id prefixed with the namespace",
+			node: &envoy_config_core_v3.Node{
+				Id:       "node1",
+				Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{"pod_namespace": structpb.NewStringValue("ns")}},
+			},
+			namespaced: true,
+			want:       "ns/node1",
+		},
+		{
+			name:       "Returns the node id if the client doesn't report its namespace",
+			node:       &envoy_config_core_v3.Node{Id: "node1"},
+			namespaced: true,
+			want:       "node1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NodeID(tt.node, tt.namespaced); got != tt.want {
+				t.Errorf("NodeID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNodeID(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    *envoy_config_core_v3.Node
+		wantErr bool
+	}{
+		{
+			name:    "Valid nodeID",
+			node:    &envoy_config_core_v3.Node{Id: "node1"},
+			wantErr: false,
+		},
+		{
+			name:    "Rejects nodeIDs that collide with the canary snapshot keys",
+			node:    &envoy_config_core_v3.Node{Id: CanaryNodeID("node1")},
+			wantErr: true,
+		},
+		{
+			name:    "Rejects nodeIDs that claim another namespace",
+			node:    &envoy_config_core_v3.Node{Id: "otherns/node1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateNodeID(tt.node); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNodeID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
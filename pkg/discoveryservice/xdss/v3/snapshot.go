@@ -19,19 +19,25 @@ type Snapshot struct {
 // NewSnapshot returns a Snapshot object
 func NewSnapshot() Snapshot {
 
-	snap, _ := cache_v3.NewSnapshot("",
-		map[resource_v3.Type][]cache_types.Resource{
-			resource_v3.EndpointType:        {},
-			resource_v3.ClusterType:         {},
-			resource_v3.RouteType:           {},
-			resource_v3.ScopedRouteType:     {},
-			resource_v3.VirtualHostType:     {},
-			resource_v3.ListenerType:        {},
-			resource_v3.SecretType:          {},
-			resource_v3.RuntimeType:         {},
-			resource_v3.ExtensionConfigType: {},
-		},
-	)
+	resources := map[resource_v3.Type][]cache_types.Resource{
+		resource_v3.EndpointType:        {},
+		resource_v3.ClusterType:         {},
+		resource_v3.RouteType:           {},
+		resource_v3.ScopedRouteType:     {},
+		resource_v3.VirtualHostType:     {},
+		resource_v3.ListenerType:        {},
+		resource_v3.SecretType:          {},
+		resource_v3.RuntimeType:         {},
+		resource_v3.ExtensionConfigType: {},
+	}
+	snap, _ := cache_v3.NewSnapshot("", resources)
+
+	// Initialize the version map so the per resource versions calculated
+	// by SetResources are the ones used by the server in delta xDS streams
+	snap.VersionMap = make(map[string]map[string]string, len(resources))
+	for typeURL := range resources {
+		snap.VersionMap[typeURL] = map[string]string{}
+	}
 
 	return Snapshot{v3: snap}
 }
@@ -58,6 +64,7 @@ func (s Snapshot) SetResources(rType envoy.Type, resources []envoy.Resource) xds
 	cv3resources := cache_v3.NewResources("", items)
 	s.v3.Resources[v3CacheResources(rType)] = cv3resources
 
+	s.setResourceVersions(rType)
 	s.SetVersion(rType, s.recalculateVersion(rType))
 
 	return s
@@ -85,6 +92,32 @@ func (s Snapshot) SetVersion(rType envoy.Type, version string) {
 	s.v3.Resources[v3CacheResources(rType)].Version = version
 }
 
+// GetResourceVersions returns the version of each resource of the given type,
+// indexed by resource name.
+func (s Snapshot) GetResourceVersions(rType envoy.Type) map[string]string {
+	versions := map[string]string{}
+	if s.v3 == nil {
+		return versions
+	}
+	for k, v := range s.v3.GetVersionMap(envoy_resources_v3.Mappings()[rType]) {
+		versions[k] = v
+	}
+	return versions
+}
+
+func (s Snapshot) setResourceVersions(rType envoy.Type) {
+	if s.v3.VersionMap == nil {
+		s.v3.VersionMap = map[string]map[string]string{}
+	}
+	versions := map[string]string{}
+	encoder := envoy_serializer.NewResourceMarshaller(envoy_serializer.JSON, envoy.APIv3)
+	for n, r := range s.v3.Resources[v3CacheResources(rType)].Items {
+		j, _ := encoder.Marshal(r.Resource)
+		versions[n] = reconcilerutil.Hash(string(j))
+	}
+	s.v3.VersionMap[envoy_resources_v3.Mappings()[rType]] = versions
+}
+
 func (s Snapshot) recalculateVersion(rType envoy.Type) string {
 	resources := map[string]string{}
 	encoder := envoy_serializer.NewResourceMarshaller(envoy_serializer.JSON, envoy.APIv3)
@@ -123,6 +123,34 @@ func TestSnapshot_GetVersion(t *testing.T) {
 	}
 }
 
+func TestSnapshot_GetResourceVersions(t *testing.T) {
+	t.Run("Returns a version per resource that only changes when the resource changes", func(t *testing.T) {
+		a := NewSnapshot().SetResources(envoy.Endpoint, []envoy.Resource{
+			&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "endpoint1"},
+			&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "endpoint2"},
+		})
+		b := NewSnapshot().SetResources(envoy.Endpoint, []envoy.Resource{
+			&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "endpoint1"},
+			&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "endpoint2",
+				Endpoints: []*envoy_config_endpoint_v3.LocalityLbEndpoints{{Priority: 1}}},
+		})
+
+		va, vb := a.GetResourceVersions(envoy.Endpoint), b.GetResourceVersions(envoy.Endpoint)
+		if len(va) != 2 || len(vb) != 2 {
+			t.Fatalf("Snapshot.GetResourceVersions() = %v, %v, want 2 versions each", va, vb)
+		}
+		if va["endpoint1"] != vb["endpoint1"] {
+			t.Errorf("Snapshot.GetResourceVersions() = version of unchanged resource differs: %v != %v", va["endpoint1"], vb["endpoint1"])
+		}
+		if va["endpoint2"] == vb["endpoint2"] {
+			t.Errorf("Snapshot.GetResourceVersions() = version of changed resource is equal: %v", va["endpoint2"])
+		}
+		if got := a.GetResourceVersions(envoy.Cluster); len(got) != 0 {
+			t.Errorf("Snapshot.GetResourceVersions() = %v, want empty map", got)
+		}
+	})
+}
+
 func TestSnapshot_SetVersion(t *testing.T) {
 	type args struct {
 		rType   envoy.Type
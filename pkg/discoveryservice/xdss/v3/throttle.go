@@ -0,0 +1,432 @@
+package discoveryservice
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/3scale-ops/marin3r/pkg/util/backoff"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+)
+
+const (
+	// DefaultMaxConcurrentPushes is the default max number of envoy clients per
+	// nodeID that can have responses pending acknowledgement at the same time
+	DefaultMaxConcurrentPushes int = 100
+	// DefaultPushTimeout is the time after which a response that has not been
+	// acknowledged no longer counts against the concurrent pushes of the nodeID
+	DefaultPushTimeout time.Duration = 10 * time.Second
+	// nackMinBackoff is the delay applied to the first NACK of a stream
+	nackMinBackoff time.Duration = 100 * time.Millisecond
+)
+
+// Throttler applies backpressure to the envoy clients of the discovery service so a bad
+// config pushed to a large number of clients does not overload the control plane:
+//   - streams that NACK a response are backed off, so the next response they receive
+//     is delayed. The delay happens outside of the stream goroutine so the stream keeps
+//     processing requests while backed off.
+//   - the number of envoy clients of a nodeID with responses pending acknowledgement is
+//     capped, queueing the responses to other clients until the pushes are ACKed/NACKed.
+//
+// The Throttler is fed with the requests received in each stream by the Callbacks and
+// delays the responses of the cache returned by Cache().
+type Throttler struct {
+	// Policy is the backoff policy applied to streams that NACK responses
+	Policy backoff.BackoffPolicy
+	// MaxConcurrentPushes is the max number of streams per nodeID that can have
+	// responses pending acknowledgement at the same time. Zero disables the limit.
+	MaxConcurrentPushes int
+	// PushTimeout is the time after which a response that has not been acknowledged
+	// no longer counts against the concurrent pushes of the nodeID
+	PushTimeout time.Duration
+
+	mu       sync.Mutex
+	streams  map[int64]*throttledStream
+	requests map[any]*throttledStream
+	nodes    map[string]*throttledNode
+}
+
+// throttledNode keeps the throttling state of a nodeID
+type throttledNode struct {
+	// sem limits the concurrent pushes of the nodeID
+	sem chan struct{}
+	// streams is the number of streams of the nodeID, so the
+	// state is dropped when the last one is closed
+	streams int
+}
+
+// throttledStream keeps the throttling state of a stream
+type throttledStream struct {
+	// ctx is the context of the stream, done when the stream ends
+	ctx       context.Context
+	nodeID    string
+	node      *throttledNode
+	notBefore time.Time
+	// pending is the last request of each type URL, waiting for a watch to be created
+	pending map[string]any
+	// pushes holds the type URLs with a response pending acknowledgement
+	// and the sequence number of the push, used to expire them
+	pushes map[string]uint64
+	seq    uint64
+	// release frees the concurrent push slot held by the stream, if any
+	release func()
+	closed  bool
+}
+
+// NewThrottler returns a Throttler that uses the default backoff policy and caps
+// the concurrent pushes per nodeID to maxConcurrentPushes
+func NewThrottler(maxConcurrentPushes int) *Throttler {
+	return &Throttler{
+		Policy:              backoff.Default,
+		MaxConcurrentPushes: maxConcurrentPushes,
+		PushTimeout:         DefaultPushTimeout,
+		streams:             map[int64]*throttledStream{},
+		requests:            map[any]*throttledStream{},
+		nodes:               map[string]*throttledNode{},
+	}
+}
+
+// OnStreamOpen registers the context of a stream, so no responses
+// are sent to the stream once it is done. It is a no-op on a nil Throttler.
+func (t *Throttler) OnStreamOpen(ctx context.Context, id int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stream(id).ctx = ctx
+}
+
+// OnRequest registers a request received in a stream. Any response of the type URL of the
+// request that was pending acknowledgement is released. If the request is a NACK, the stream
+// is backed off according to the number of consecutive failures reported for the client. Any
+// other request resets the backoff of the stream. It is a no-op on a nil Throttler.
+func (t *Throttler) OnRequest(id int64, nodeID, typeURL string, req any, nack bool, failures int) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.stream(id)
+	if s.node == nil || s.nodeID != nodeID {
+		t.leaveNode(s)
+		t.joinNode(s, nodeID)
+	}
+	s.done(typeURL)
+
+	if nack {
+		delay := nackMinBackoff
+		if failures > 0 {
+			delay = t.Policy.Duration(failures)
+		}
+		s.notBefore = time.Now().Add(delay)
+	} else {
+		s.notBefore = time.Time{}
+	}
+
+	// only the last request of each type URL can be waiting for a watch
+	if prev, ok := s.pending[typeURL]; ok {
+		delete(t.requests, prev)
+	}
+	s.pending[typeURL] = req
+	t.requests[req] = s
+}
+
+// OnStreamClosed releases all the resources held by the stream
+func (t *Throttler) OnStreamClosed(id int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.streams[id]
+	if !ok {
+		return
+	}
+	for _, req := range s.pending {
+		delete(t.requests, req)
+	}
+	s.pushes = map[string]uint64{}
+	s.done("")
+	s.closed = true
+	t.leaveNode(s)
+	delete(t.streams, id)
+}
+
+// Cache returns a cache that delivers the responses of the given cache
+// respecting the backoff and the concurrent pushes limit of the streams
+func (t *Throttler) Cache(c cache_v3.Cache) cache_v3.Cache {
+	return &throttledCache{Cache: c, throttler: t}
+}
+
+// stream returns the stream with the given id, registering it if it does not exist.
+// Must be called with the Throttler's lock held.
+func (t *Throttler) stream(id int64) *throttledStream {
+	s, ok := t.streams[id]
+	if !ok {
+		s = &throttledStream{ctx: context.Background(), pending: map[string]any{}, pushes: map[string]uint64{}}
+		t.streams[id] = s
+	}
+	return s
+}
+
+// requestStream returns the stream a request was received in
+func (t *Throttler) requestStream(req any, typeURL string) (*throttledStream, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.requests[req]
+	if ok {
+		delete(t.requests, req)
+		delete(s.pending, typeURL)
+	}
+	return s, ok
+}
+
+// joinNode adds the stream to the streams of the nodeID.
+// Must be called with the Throttler's lock held.
+func (t *Throttler) joinNode(s *throttledStream, nodeID string) {
+	node, ok := t.nodes[nodeID]
+	if !ok {
+		node = &throttledNode{sem: make(chan struct{}, t.MaxConcurrentPushes)}
+		t.nodes[nodeID] = node
+	}
+	node.streams++
+	s.nodeID, s.node = nodeID, node
+}
+
+// leaveNode removes the stream from the streams of its nodeID, dropping the
+// state of the nodeID if no streams are left. The slots held in the semaphore
+// are freed by their release funcs. Must be called with the Throttler's lock held.
+func (t *Throttler) leaveNode(s *throttledStream) {
+	if s.node == nil {
+		return
+	}
+	s.node.streams--
+	if s.node.streams == 0 {
+		delete(t.nodes, s.nodeID)
+	}
+	s.node = nil
+}
+
+// wait blocks until a response of the given type URL can be sent to the stream: the backoff
+// of the stream has expired and the nodeID has not reached the concurrent pushes limit. Returns
+// false if closed is closed, or the stream is closed, while waiting.
+func (t *Throttler) wait(s *throttledStream, typeURL string, closed <-chan struct{}) bool {
+	t.mu.Lock()
+	nodeID, node, notBefore := s.nodeID, s.node, s.notBefore
+	t.mu.Unlock()
+	if node == nil {
+		return false
+	}
+
+	if delay := time.Until(notBefore); delay > 0 {
+		throttledResponses.WithLabelValues(nodeID, throttleReasonBackoff).Inc()
+		delayedResponses.WithLabelValues(nodeID).Inc()
+		defer delayedResponses.WithLabelValues(nodeID).Dec()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-closed:
+			return false
+		}
+	}
+
+	// a stream only takes one slot, no matter how many
+	// type URLs it has pending acknowledgement
+	var release func()
+	for {
+		t.mu.Lock()
+		if s.closed {
+			t.mu.Unlock()
+			if release != nil {
+				release()
+			}
+			return false
+		}
+		if release != nil {
+			if s.release == nil {
+				s.release = release
+			} else {
+				// another push of the stream took a slot meanwhile
+				release()
+			}
+		}
+		if s.release != nil || t.MaxConcurrentPushes <= 0 {
+			break
+		}
+		t.mu.Unlock()
+
+		var ok bool
+		if release, ok = acquire(nodeID, node.sem, closed); !ok {
+			return false
+		}
+	}
+	defer t.mu.Unlock()
+
+	s.seq++
+	seq := s.seq
+	s.pushes[typeURL] = seq
+
+	// the push is done when the client replies or the stream is
+	// closed, or after a timeout if the client never replies
+	time.AfterFunc(t.PushTimeout, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if s.pushes[typeURL] == seq {
+			s.done(typeURL)
+		}
+	})
+
+	return true
+}
+
+// acquire takes a concurrent push slot of the nodeID from its semaphore, waiting
+// for one to be free if required. Returns the func that frees the slot, or false
+// if closed is closed while waiting.
+func acquire(nodeID string, sem chan struct{}, closed <-chan struct{}) (func(), bool) {
+	select {
+	case sem <- struct{}{}:
+	default:
+		throttledResponses.WithLabelValues(nodeID, throttleReasonMaxConcurrentPushes).Inc()
+		delayedResponses.WithLabelValues(nodeID).Inc()
+		defer delayedResponses.WithLabelValues(nodeID).Dec()
+		select {
+		case sem <- struct{}{}:
+		case <-closed:
+			return nil, false
+		}
+	}
+	inflightPushes.WithLabelValues(nodeID).Inc()
+
+	return func() {
+		<-sem
+		inflightPushes.WithLabelValues(nodeID).Dec()
+	}, true
+}
+
+// done marks the push of the given type URL as done, freeing the
+// concurrent push slot of the stream if there are no pushes left.
+// Must be called with the Throttler's lock held.
+func (s *throttledStream) done(typeURL string) {
+	delete(s.pushes, typeURL)
+	if len(s.pushes) == 0 && s.release != nil {
+		s.release()
+		s.release = nil
+	}
+}
+
+// throttledCache is a cache that delays the responses of
+// the underlying cache as instructed by the Throttler
+type throttledCache struct {
+	cache_v3.Cache
+	throttler *Throttler
+}
+
+// CreateWatch implements go-control-plane/pkg/cache/v3.ConfigWatcher
+func (c *throttledCache) CreateWatch(req *cache_v3.Request, state stream.StreamState, out chan cache_v3.Response) func() {
+	s, ok := c.throttler.requestStream(req, req.GetTypeUrl())
+	if !ok {
+		return c.Cache.CreateWatch(req, state, out)
+	}
+	return throttleWatch(c.throttler, s, req.GetTypeUrl(), out, func(in chan cache_v3.Response) func() {
+		return c.Cache.CreateWatch(req, state, in)
+	})
+}
+
+// CreateDeltaWatch implements go-control-plane/pkg/cache/v3.ConfigWatcher
+func (c *throttledCache) CreateDeltaWatch(req *cache_v3.DeltaRequest, state stream.StreamState, out chan cache_v3.DeltaResponse) func() {
+	s, ok := c.throttler.requestStream(req, req.GetTypeUrl())
+	if !ok {
+		return c.Cache.CreateDeltaWatch(req, state, out)
+	}
+	return throttleWatch(c.throttler, s, req.GetTypeUrl(), out, func(in chan cache_v3.DeltaResponse) func() {
+		return c.Cache.CreateDeltaWatch(req, state, in)
+	})
+}
+
+// throttleWatch creates a watch in the underlying cache using an intermediate channel, so
+// the response can be held back without blocking the stream goroutine
+func throttleWatch[R any](t *Throttler, s *throttledStream, typeURL string, out chan R, create func(chan R) func()) func() {
+	in := make(chan R, 1)
+	closed := make(chan struct{})
+	var mu sync.Mutex
+	cancelled := false
+	t.mu.Lock()
+	streamDone := s.ctx.Done()
+	t.mu.Unlock()
+	cancel := create(in)
+
+	go func() {
+		var resp R
+		select {
+		case resp = <-in:
+		case <-closed:
+			return
+		case <-streamDone:
+			return
+		}
+		if !t.wait(s, typeURL, closed) {
+			return
+		}
+		forward(&mu, &cancelled, out, resp, closed, streamDone)
+	}()
+
+	var once sync.Once
+	return func() {
+		if cancel != nil {
+			cancel()
+		}
+		once.Do(func() { close(closed) })
+		// wait for any ongoing forward, so the server can close
+		// the channel as soon as the watch is cancelled
+		mu.Lock()
+		cancelled = true
+		mu.Unlock()
+	}
+}
+
+// forward sends a response to the channel of the stream unless the watch has been cancelled or
+// the stream is done. With ADS the channel is shared by all the watches of the stream and the
+// server closes it when the stream ends, in no particular order with respect to the cancellation
+// of the watches. The context of the stream is done by then if the client went away, but not if
+// the server ended the stream, so a send on the closed channel is still recovered.
+func forward[R any](mu *sync.Mutex, cancelled *bool, out chan R, resp R, closed, streamDone <-chan struct{}) {
+	defer recoverClosedChannelSend()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if *cancelled {
+		return
+	}
+	select {
+	case <-streamDone:
+		return
+	default:
+	}
+	select {
+	case out <- resp:
+	case <-closed:
+	case <-streamDone:
+	}
+}
+
+// recoverClosedChannelSend recovers from the panic caused by a send on
+// a closed channel. Any other panic is propagated.
+func recoverClosedChannelSend() {
+	if r := recover(); r != nil {
+		if err, ok := r.(runtime.Error); !ok || err.Error() != "send on closed channel" {
+			panic(r)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package discoveryservice
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/3scale-ops/marin3r/pkg/util/backoff"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+)
+
+// respondingCache is a cache that responds to the watches as soon as they are created
+type respondingCache struct {
+	cache_v3.Cache
+}
+
+func (respondingCache) CreateWatch(req *cache_v3.Request, _ stream.StreamState, out chan cache_v3.Response) func() {
+	out <- &cache_v3.RawResponse{Request: req}
+	return nil
+}
+
+func (respondingCache) CreateDeltaWatch(req *cache_v3.DeltaRequest, _ stream.StreamState, out chan cache_v3.DeltaResponse) func() {
+	out <- &cache_v3.RawDeltaResponse{DeltaRequest: req}
+	return nil
+}
+
+const testTypeURL = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+
+func testRequest() *envoy_service_discovery_v3.DiscoveryRequest {
+	return &envoy_service_discovery_v3.DiscoveryRequest{TypeUrl: testTypeURL}
+}
+
+func received(out chan cache_v3.Response, timeout time.Duration) bool {
+	select {
+	case <-out:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestThrottler_OnRequest(t *testing.T) {
+	th := NewThrottler(0)
+	th.Policy = backoff.BackoffPolicy{Millis: []int{1000}}
+
+	first, second := testRequest(), testRequest()
+	th.OnRequest(1, "node", testTypeURL, first, true, 1)
+	if d := time.Until(th.streams[1].notBefore); d < 400*time.Millisecond {
+		t.Errorf("Throttler.OnRequest() NACK backoff = %v, want > 400ms", d)
+	}
+
+	th.OnRequest(1, "node", testTypeURL, second, false, 0)
+	if !th.streams[1].notBefore.IsZero() {
+		t.Errorf("Throttler.OnRequest() ACK did not reset the backoff")
+	}
+	if _, ok := th.requests[first]; ok {
+		t.Errorf("Throttler.OnRequest() the previous request of the type URL was not discarded")
+	}
+	if _, ok := th.requestStream(second, testTypeURL); !ok {
+		t.Errorf("Throttler.OnRequest() the request was not registered")
+	}
+
+	th.OnRequest(1, "other", testTypeURL, first, false, 0)
+	if _, ok := th.nodes["node"]; ok || len(th.nodes) != 1 {
+		t.Errorf("Throttler.OnRequest() the stream was not moved to the new nodeID: %v", th.nodes)
+	}
+	th.OnStreamClosed(1)
+	if len(th.streams) != 0 || len(th.requests) != 0 || len(th.nodes) != 0 {
+		t.Errorf("Throttler.OnStreamClosed() the stream was not released")
+	}
+
+	// a nil Throttler is a no-op
+	var nilThrottler *Throttler
+	nilThrottler.OnStreamOpen(context.Background(), 1)
+	nilThrottler.OnRequest(1, "node", testTypeURL, first, true, 1)
+	nilThrottler.OnStreamClosed(1)
+}
+
+func TestThrottledCache_backoff(t *testing.T) {
+	th := NewThrottler(0)
+	th.Policy = backoff.BackoffPolicy{Millis: []int{400}}
+	c := th.Cache(respondingCache{})
+
+	t.Run("Requests from unknown streams are not throttled", func(t *testing.T) {
+		out := make(chan cache_v3.Response, 1)
+		c.CreateWatch(testRequest(), stream.StreamState{}, out)
+		if !received(out, 10*time.Millisecond) {
+			t.Errorf("throttledCache.CreateWatch() response was delayed")
+		}
+	})
+
+	t.Run("Responses to NACKing streams are delayed without blocking", func(t *testing.T) {
+		req := testRequest()
+		th.OnRequest(1, "node", testTypeURL, req, true, 1)
+		out := make(chan cache_v3.Response, 1)
+
+		start := time.Now()
+		cancel := c.CreateWatch(req, stream.StreamState{}, out)
+		defer cancel()
+		if time.Since(start) > 50*time.Millisecond {
+			t.Errorf("throttledCache.CreateWatch() blocked the caller")
+		}
+		if received(out, 100*time.Millisecond) {
+			t.Fatalf("throttledCache.CreateWatch() response was not delayed")
+		}
+		if !received(out, time.Second) {
+			t.Errorf("throttledCache.CreateWatch() response was not delivered after the backoff")
+		}
+	})
+
+	t.Run("Cancelled watches are not responded", func(t *testing.T) {
+		req := testRequest()
+		th.OnRequest(2, "node", testTypeURL, req, true, 1)
+		out := make(chan cache_v3.Response, 1)
+		cancel := c.CreateWatch(req, stream.StreamState{}, out)
+		cancel()
+		if received(out, time.Second) {
+			t.Errorf("throttledCache.CreateWatch() responded a cancelled watch")
+		}
+	})
+
+	t.Run("The channel can be closed once the watch is cancelled", func(t *testing.T) {
+		req := testRequest()
+		th.OnRequest(3, "node", testTypeURL, req, false, 0)
+		// the channel is full, so the response is being forwarded when the watch is cancelled
+		out := make(chan cache_v3.Response, 1)
+		out <- &cache_v3.RawResponse{}
+		cancel := c.CreateWatch(req, stream.StreamState{}, out)
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-out
+		close(out)
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	t.Run("Responses to closed streams are not sent", func(t *testing.T) {
+		req := testRequest()
+		th.OnRequest(4, "node", testTypeURL, req, true, 1)
+		out := make(chan cache_v3.Response, 1)
+		cancel := c.CreateWatch(req, stream.StreamState{}, out)
+		defer cancel()
+		th.OnStreamClosed(4)
+		if received(out, time.Second) {
+			t.Errorf("throttledCache.CreateWatch() responded a closed stream")
+		}
+	})
+
+	t.Run("Responses to streams whose context is done are not sent", func(t *testing.T) {
+		ctx, cancelCtx := context.WithCancel(context.Background())
+		th.OnStreamOpen(ctx, 5)
+		req := testRequest()
+		th.OnRequest(5, "node", testTypeURL, req, true, 1)
+		out := make(chan cache_v3.Response, 1)
+		cancel := c.CreateWatch(req, stream.StreamState{}, out)
+		defer cancel()
+		cancelCtx()
+		if received(out, time.Second) {
+			t.Errorf("throttledCache.CreateWatch() responded a done stream")
+		}
+	})
+}
+
+func Test_forward(t *testing.T) {
+	var mu sync.Mutex
+	cancelled := false
+
+	out := make(chan cache_v3.Response, 1)
+	close(out)
+	// a send on the closed channel is recovered
+	forward(&mu, &cancelled, out, cache_v3.Response(&cache_v3.RawResponse{}), nil, nil)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("forward() recovered from an unrelated panic")
+		}
+	}()
+	var nilMu *sync.Mutex
+	forward(nilMu, &cancelled, out, cache_v3.Response(&cache_v3.RawResponse{}), nil, nil)
+}
+
+func TestThrottledCache_maxConcurrentPushes(t *testing.T) {
+	th := NewThrottler(1)
+	c := th.Cache(respondingCache{})
+
+	watch := func(id int64, nodeID string) chan cache_v3.Response {
+		req := testRequest()
+		th.OnRequest(id, nodeID, testTypeURL, req, false, 0)
+		out := make(chan cache_v3.Response, 1)
+		c.CreateWatch(req, stream.StreamState{}, out)
+		return out
+	}
+
+	if !received(watch(1, "node"), time.Second) {
+		t.Fatalf("throttledCache.CreateWatch() first push was not delivered")
+	}
+	if !received(watch(2, "other"), time.Second) {
+		t.Errorf("throttledCache.CreateWatch() push to another nodeID was throttled")
+	}
+	queued := watch(3, "node")
+	if received(queued, 100*time.Millisecond) {
+		t.Fatalf("throttledCache.CreateWatch() push was not queued")
+	}
+
+	// the ACK of the first stream frees the slot
+	th.OnRequest(1, "node", testTypeURL, testRequest(), false, 0)
+	if !received(queued, time.Second) {
+		t.Fatalf("throttledCache.CreateWatch() queued push was not delivered after the ACK")
+	}
+
+	// closing the stream frees the slot
+	queued = watch(4, "node")
+	if received(queued, 100*time.Millisecond) {
+		t.Fatalf("throttledCache.CreateWatch() push was not queued")
+	}
+	th.OnStreamClosed(3)
+	if !received(queued, time.Second) {
+		t.Fatalf("throttledCache.CreateWatch() queued push was not delivered after closing the stream")
+	}
+
+	// pushes that are never acknowledged expire
+	th.PushTimeout = 100 * time.Millisecond
+	th.OnStreamClosed(4)
+	watch(5, "node")
+	if !received(watch(6, "node"), time.Second) {
+		t.Errorf("throttledCache.CreateWatch() queued push was not delivered after the push timeout")
+	}
+
+	// the state of a nodeID is dropped when its last stream is closed
+	for _, id := range []int64{1, 2, 5, 6} {
+		th.OnStreamClosed(id)
+	}
+	if len(th.nodes) != 0 {
+		t.Errorf("Throttler.OnStreamClosed() the state of the nodeIDs was not dropped: %v", th.nodes)
+	}
+}
@@ -19,4 +19,7 @@ type ConfigOptions struct {
 	AdminPort                   uint32
 	AdminAccessLogPath          string
 	Metadata                    map[string]string
+	// DeltaXds configures envoy to use the incremental (delta) variant
+	// of the xDS protocol instead of state-of-the-world
+	DeltaXds bool
 }
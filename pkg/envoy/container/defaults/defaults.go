@@ -1,8 +1,12 @@
 package defaults
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	"github.com/3scale-ops/marin3r/pkg/image"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 type DrainStrategy string
@@ -77,3 +81,22 @@ func ShtdnMgrImage() string {
 func InitMgrImage() string {
 	return image.Current()
 }
+
+// SidecarNodeClientCertificate returns the name of the client
+// certificate issued for the sidecars of the given nodeID
+func SidecarNodeClientCertificate(nodeID string) string {
+	return fmt.Sprintf("%s-%s", SidecarClientCertificate, nodeID)
+}
+
+// ValidateSidecarNodeClientCertificate returns an error if a client certificate
+// cannot be issued for the given nodeID, as the nodeID is not a DNS-1123 subdomain
+// or the name of the certificate is too long
+func ValidateSidecarNodeClientCertificate(nodeID string) error {
+	if errs := validation.IsDNS1123Subdomain(nodeID); len(errs) > 0 {
+		return fmt.Errorf("invalid nodeID '%s': %s", nodeID, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Subdomain(SidecarNodeClientCertificate(nodeID)); len(errs) > 0 {
+		return fmt.Errorf("invalid client certificate name for nodeID '%s': %s", nodeID, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -38,6 +38,7 @@ type ContainerConfig struct {
 	XdssHost         string
 	XdssPort         int
 	APIVersion       string
+	DeltaXds         bool
 
 	// Shutdown manager container configuration
 	ShutdownManagerEnabled       bool
@@ -217,19 +218,25 @@ func (cc *ContainerConfig) InitContainers() []corev1.Container {
 				},
 			},
 		},
-		Args: []string{
-			"init-manager",
-			"--admin-access-log-path", cc.AdminAccessLogPath,
-			"--admin-bind-address", fmt.Sprintf("%s:%d", cc.AdminBindAddress, cc.AdminPort),
-			"--api-version", cc.APIVersion,
-			"--client-certificate-path", cc.TLSBasePath,
-			"--config-file", fmt.Sprintf("%s/%s", cc.ConfigBasePath, cc.ConfigFileName),
-			"--resources-path", cc.ConfigBasePath,
-			"--rtds-resource-name", defaults.InitMgrRtdsLayerResourceName,
-			"--xdss-host", cc.XdssHost,
-			"--xdss-port", fmt.Sprintf("%d", cc.XdssPort),
-			"--envoy-image", cc.Image,
-		},
+		Args: func() []string {
+			args := []string{
+				"init-manager",
+				"--admin-access-log-path", cc.AdminAccessLogPath,
+				"--admin-bind-address", fmt.Sprintf("%s:%d", cc.AdminBindAddress, cc.AdminPort),
+				"--api-version", cc.APIVersion,
+				"--client-certificate-path", cc.TLSBasePath,
+				"--config-file", fmt.Sprintf("%s/%s", cc.ConfigBasePath, cc.ConfigFileName),
+				"--resources-path", cc.ConfigBasePath,
+				"--rtds-resource-name", defaults.InitMgrRtdsLayerResourceName,
+				"--xdss-host", cc.XdssHost,
+				"--xdss-port", fmt.Sprintf("%d", cc.XdssPort),
+				"--envoy-image", cc.Image,
+			}
+			if cc.DeltaXds {
+				args = append(args, "--delta-xds")
+			}
+			return args
+		}(),
 		VolumeMounts: []corev1.VolumeMount{
 			{
 				Name:      cc.ConfigVolume,
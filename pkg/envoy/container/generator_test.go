@@ -453,6 +453,84 @@ func TestContainerConfig_InitContainers(t *testing.T) {
 				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
 			}},
 		},
+		{
+			name: "Generates init manager init-container with delta xDS enabled",
+			cc: ContainerConfig{
+				Image:              "envoy:test",
+				ConfigBasePath:     "/config",
+				ConfigFileName:     "config.json",
+				ConfigVolume:       "config",
+				TLSBasePath:        "/tls",
+				NodeID:             "test-id",
+				ClusterID:          "test-id",
+				ClientCertSecret:   "client-secret",
+				AdminAccessLogPath: "/dev/stdout",
+				AdminBindAddress:   "127.0.0.1",
+				AdminPort:          5000,
+				XdssHost:           "discovery-service.com",
+				XdssPort:           30000,
+				APIVersion:         "v3",
+				InitManagerImage:   "init-manager:test",
+				DeltaXds:           true,
+			},
+			want: []corev1.Container{{
+				Name:  "envoy-init-mgr",
+				Image: "init-manager:test",
+				Env: []corev1.EnvVar{
+					{
+						Name: "POD_NAME",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath:  "metadata.name",
+								APIVersion: "v1",
+							},
+						},
+					},
+					{
+						Name: "POD_NAMESPACE",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath:  "metadata.namespace",
+								APIVersion: "v1",
+							},
+						},
+					},
+					{
+						Name: "HOST_NAME",
+						ValueFrom: &corev1.EnvVarSource{
+							FieldRef: &corev1.ObjectFieldSelector{
+								FieldPath:  "spec.nodeName",
+								APIVersion: "v1",
+							},
+						},
+					},
+				},
+				Args: []string{
+					"init-manager",
+					"--admin-access-log-path", "/dev/stdout",
+					"--admin-bind-address", "127.0.0.1:5000",
+					"--api-version", "v3",
+					"--client-certificate-path", "/tls",
+					"--config-file", "/config/config.json",
+					"--resources-path", "/config",
+					"--rtds-resource-name", defaults.InitMgrRtdsLayerResourceName,
+					"--xdss-host", "discovery-service.com",
+					"--xdss-port", "30000",
+					"--envoy-image", "envoy:test",
+					"--delta-xds",
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					{
+						Name:      "config",
+						ReadOnly:  false,
+						MountPath: "/config",
+					},
+				},
+				ImagePullPolicy:          corev1.PullIfNotPresent,
+				TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+				TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+			}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
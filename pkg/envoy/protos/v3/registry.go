@@ -0,0 +1,94 @@
+package envoy
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// TypeURLPrefix is the prefix of the type URLs used in the '@type' field of Any messages
+	TypeURLPrefix string = "type.googleapis.com/"
+	// extensionsPackage is the proto package of the envoy extensions
+	extensionsPackage string = "envoy.extensions."
+)
+
+// Types returns the sorted type URLs of all the proto messages registered in the binary
+func Types() []string {
+	return types(func(protoreflect.MessageDescriptor) bool { return true })
+}
+
+// ExtensionTypes returns the sorted type URLs of the envoy extensions registered in
+// the binary. These are the types that can be used in the typed_config fields. Messages
+// nested in other messages are not included.
+func ExtensionTypes() []string {
+	return types(func(md protoreflect.MessageDescriptor) bool {
+		_, nested := md.Parent().(protoreflect.MessageDescriptor)
+		return !nested && strings.HasPrefix(string(md.FullName()), extensionsPackage)
+	})
+}
+
+func types(filter func(protoreflect.MessageDescriptor) bool) []string {
+	list := []string{}
+	protoregistry.GlobalTypes.RangeMessages(func(mt protoreflect.MessageType) bool {
+		if md := mt.Descriptor(); filter(md) {
+			list = append(list, TypeURLPrefix+string(md.FullName()))
+		}
+		return true
+	})
+	sort.Strings(list)
+	return list
+}
+
+// IsRegistered returns true if the message of the type URL is registered in the binary
+func IsRegistered(typeURL string) bool {
+	_, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL)
+	return err == nil
+}
+
+// ClosestType returns the type URL of the registered message whose name is the closest
+// to the one of the given type URL, or an empty string if there are no registered messages
+func ClosestType(typeURL string) string {
+	name := typeURL[strings.LastIndex(typeURL, "/")+1:]
+
+	closest, min := "", -1
+	for _, candidate := range Types() {
+		if d := distance(name, strings.TrimPrefix(candidate, TypeURLPrefix)); min < 0 || d < min {
+			closest, min = candidate, d
+		}
+	}
+	return closest
+}
+
+// distance returns the levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minOf(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minOf(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
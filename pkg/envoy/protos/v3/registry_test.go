@@ -0,0 +1,63 @@
+package envoy
+
+import (
+	"testing"
+)
+
+func TestExtensionTypes(t *testing.T) {
+	types := ExtensionTypes()
+	found := false
+	for _, url := range types {
+		if url == "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router" {
+			found = true
+		}
+		if !IsRegistered(url) {
+			t.Errorf("ExtensionTypes() returned the unregistered type %s", url)
+		}
+	}
+	if !found {
+		t.Errorf("ExtensionTypes() does not include the http router filter")
+	}
+}
+
+func TestClosestType(t *testing.T) {
+	tests := []struct {
+		name    string
+		typeURL string
+		want    string
+	}{
+		{
+			name:    "Typo in the message name",
+			typeURL: "type.googleapis.com/envoy.extensions.filters.http.router.v3.Routr",
+			want:    "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router",
+		},
+		{
+			name:    "Wrong API version",
+			typeURL: "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v2.HttpConnectionManager",
+			want:    "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClosestType(tt.typeURL); got != tt.want {
+				t.Errorf("ClosestType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_distance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package envoy
+
+import (
+	"fmt"
+
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources_v3 "github.com/3scale-ops/marin3r/pkg/envoy/resources/v3"
+)
+
+// ReferenceReport is the result of analyzing the references between a set of envoy resources
+type ReferenceReport struct {
+	// Dangling lists the references to resources that are not in the set
+	Dangling []string
+	// Unused lists the resources that are not referenced by any other resource in the set
+	Unused []string
+}
+
+// analyzedTypes is the order in which resource types are analyzed, so reports are stable
+var analyzedTypes = []envoy.Type{envoy.Listener, envoy.ScopedRoute, envoy.Route, envoy.Cluster,
+	envoy.Endpoint, envoy.Secret, envoy.Runtime, envoy.ExtensionConfig}
+
+// unusedTypes are the resource types that are only requested by envoy when referenced from
+// another resource. Listeners, scoped routes, runtimes and extension configs are not reported
+// as unused as they are requested directly or referenced from the bootstrap config.
+var unusedTypes = []envoy.Type{envoy.Route, envoy.Cluster, envoy.Endpoint, envoy.Secret}
+
+// AnalyzeReferences builds the graph of references between the given resources, indexed by type,
+// and reports the references to resources that are not in the set and the resources that no other
+// resource references. Only the v3 envoy API is supported.
+func AnalyzeReferences(resources map[envoy.Type][]envoy.Resource) ReferenceReport {
+	declared := map[envoy.Reference]bool{}
+	for rType, list := range resources {
+		for _, res := range list {
+			declared[envoy.Reference{Type: rType, Name: envoy_resources_v3.ResourceName(res)}] = true
+		}
+	}
+
+	report := ReferenceReport{}
+	referenced := map[envoy.Reference]bool{}
+	for _, rType := range analyzedTypes {
+		for _, res := range resources[rType] {
+			name := envoy_resources_v3.ResourceName(res)
+			reported := map[envoy.Reference]bool{}
+
+			for _, ref := range envoy_resources_v3.References(res) {
+				referenced[ref] = true
+				if declared[ref] || reported[ref] {
+					continue
+				}
+				reported[ref] = true
+				report.Dangling = append(report.Dangling,
+					fmt.Sprintf("%s '%s' references %s '%s', which is not declared", rType, name, ref.Type, ref.Name))
+			}
+		}
+	}
+
+	for _, rType := range unusedTypes {
+		for _, res := range resources[rType] {
+			name := envoy_resources_v3.ResourceName(res)
+			if !referenced[envoy.Reference{Type: rType, Name: name}] {
+				report.Unused = append(report.Unused, fmt.Sprintf("%s '%s' is not referenced by any other resource", rType, name))
+			}
+		}
+	}
+
+	return report
+}
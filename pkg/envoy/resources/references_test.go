@@ -0,0 +1,77 @@
+package envoy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+)
+
+func testDecode(t *testing.T, rType envoy.Type, value string) envoy.Resource {
+	res := NewGenerator(envoy.APIv3).New(rType)
+	if err := envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, envoy.APIv3).Unmarshal(value, res); err != nil {
+		t.Fatalf("error decoding resource: %v", err)
+	}
+	return res
+}
+
+func TestAnalyzeReferences(t *testing.T) {
+	listener := `{"name":"http","filter_chains":[{"filters":[{"name":"envoy.filters.network.http_connection_manager","typed_config":{
+		"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+		"stat_prefix":"http","rds":{"route_config_name":"router","config_source":{"ads":{}}}}}]}]}`
+	route := `{"name":"router","virtual_hosts":[{"name":"all","domains":["*"],"routes":[
+		{"match":{"prefix":"/a"},"route":{"cluster":"a"}},
+		{"match":{"prefix":"/b"},"route":{"cluster":"b"}},
+		{"match":{"prefix":"/c"},"route":{"cluster":"b"}}]}]}`
+
+	tests := []struct {
+		name      string
+		resources func(t *testing.T) map[envoy.Type][]envoy.Resource
+		want      ReferenceReport
+	}{
+		{
+			name: "All references are resolved",
+			resources: func(t *testing.T) map[envoy.Type][]envoy.Resource {
+				return map[envoy.Type][]envoy.Resource{
+					envoy.Listener: {testDecode(t, envoy.Listener, listener)},
+					envoy.Route:    {testDecode(t, envoy.Route, route)},
+					envoy.Cluster: {
+						testDecode(t, envoy.Cluster, `{"name":"a","type":"EDS","eds_cluster_config":{"eds_config":{"ads":{}}}}`),
+						testDecode(t, envoy.Cluster, `{"name":"b"}`),
+					},
+					envoy.Endpoint: {NewGenerator(envoy.APIv3).NewClusterLoadAssignment("a")},
+				}
+			},
+			want: ReferenceReport{},
+		},
+		{
+			name: "Reports dangling references once and unused resources",
+			resources: func(t *testing.T) map[envoy.Type][]envoy.Resource {
+				return map[envoy.Type][]envoy.Resource{
+					envoy.Listener: {testDecode(t, envoy.Listener, listener)},
+					envoy.Route:    {testDecode(t, envoy.Route, route), testDecode(t, envoy.Route, `{"name":"unused"}`)},
+					envoy.Cluster:  {testDecode(t, envoy.Cluster, `{"name":"a","type":"EDS","eds_cluster_config":{"eds_config":{"ads":{}}}}`)},
+					envoy.Secret:   {NewGenerator(envoy.APIv3).NewTlsCertificateSecret("cert", "", "")},
+				}
+			},
+			want: ReferenceReport{
+				Dangling: []string{
+					"route 'router' references cluster 'b', which is not declared",
+					"cluster 'a' references endpoint 'a', which is not declared",
+				},
+				Unused: []string{
+					"route 'unused' is not referenced by any other resource",
+					"secret 'cert' is not referenced by any other resource",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AnalyzeReferences(tt.resources(t)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AnalyzeReferences() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package envoy
+
+import (
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Redacted is the value that replaces the redacted data
+const Redacted string = "**REDACTED**"
+
+// secretMessages are the messages whose data sources hold secret material
+var secretMessages = map[protoreflect.FullName]bool{
+	fullName(&envoy_extensions_transport_sockets_tls_v3.TlsCertificate{}):               true,
+	fullName(&envoy_extensions_transport_sockets_tls_v3.GenericSecret{}):                true,
+	fullName(&envoy_extensions_transport_sockets_tls_v3.CertificateValidationContext{}): true,
+	fullName(&envoy_extensions_transport_sockets_tls_v3.TlsSessionTicketKeys{}):         true,
+}
+
+func fullName(m proto.Message) protoreflect.FullName {
+	return m.ProtoReflect().Descriptor().FullName()
+}
+
+// Redact returns a copy of the resource with the inline data sources of its TLS
+// certificates, validation contexts, session ticket keys and generic secrets replaced
+// by the Redacted string, so it can be safely displayed. These messages are redacted
+// wherever they appear, including the transport sockets of listeners and clusters.
+// Data sources that point to files or environment variables are left untouched as
+// they do not hold the secret material, as are the data sources of other messages,
+// like direct response bodies or Lua code.
+func Redact(res envoy.Resource) envoy.Resource {
+	redacted := proto.Clone(res)
+	redact(redacted.ProtoReflect(), false)
+	return redacted
+}
+
+// redact redacts the secret data sources found in the message and returns
+// true if it was modified. Any messages are unpacked and packed again if
+// their contents are modified.
+func redact(m protoreflect.Message, secret bool) bool {
+	switch v := m.Interface().(type) {
+	case *envoy_config_core_v3.DataSource:
+		if !secret {
+			return false
+		}
+		switch v.GetSpecifier().(type) {
+		case *envoy_config_core_v3.DataSource_InlineBytes, *envoy_config_core_v3.DataSource_InlineString:
+			v.Specifier = &envoy_config_core_v3.DataSource_InlineString{InlineString: Redacted}
+			return true
+		}
+		return false
+
+	case *anypb.Any:
+		inner, err := v.UnmarshalNew()
+		if err != nil || !redact(inner.ProtoReflect(), false) {
+			return false
+		}
+		if err := v.MarshalFrom(inner); err != nil {
+			// never leave the secret material in place
+			v.Value = nil
+		}
+		return true
+	}
+
+	secret = secretMessages[m.Descriptor().FullName()]
+	modified := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			if fd.Message() != nil {
+				list := v.List()
+				for i := 0; i < list.Len(); i++ {
+					modified = redact(list.Get(i).Message(), secret) || modified
+				}
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					modified = redact(mv.Message(), secret) || modified
+					return true
+				})
+			}
+		case fd.Message() != nil:
+			modified = redact(v.Message(), secret) || modified
+		}
+		return true
+	})
+	return modified
+}
@@ -0,0 +1,83 @@
+package envoy
+
+import (
+	"testing"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestRedact(t *testing.T) {
+	secret := &envoy_extensions_transport_sockets_tls_v3.Secret{
+		Name: "secret",
+		Type: &envoy_extensions_transport_sockets_tls_v3.Secret_TlsCertificate{
+			TlsCertificate: &envoy_extensions_transport_sockets_tls_v3.TlsCertificate{
+				CertificateChain: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineBytes{InlineBytes: []byte("cert")},
+				},
+				PrivateKey: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_Filename{Filename: "/etc/key.pem"},
+				},
+			},
+		},
+	}
+	original := proto.Clone(secret)
+
+	got := Redact(secret).(*envoy_extensions_transport_sockets_tls_v3.Secret)
+
+	if v := got.GetTlsCertificate().GetCertificateChain().GetInlineString(); v != Redacted {
+		t.Errorf("Redact() inline data source = %q, want %q", v, Redacted)
+	}
+	if v := got.GetTlsCertificate().GetPrivateKey().GetFilename(); v != "/etc/key.pem" {
+		t.Errorf("Redact() file data source = %q, want it untouched", v)
+	}
+	if !proto.Equal(secret, original) {
+		t.Errorf("Redact() modified the given resource")
+	}
+}
+
+func TestRedact_transportSockets(t *testing.T) {
+	tlsContext, _ := anypb.New(&envoy_extensions_transport_sockets_tls_v3.UpstreamTlsContext{
+		CommonTlsContext: &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext{
+			TlsCertificates: []*envoy_extensions_transport_sockets_tls_v3.TlsCertificate{{
+				PrivateKey: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "key"},
+				},
+			}},
+		},
+	})
+	cluster := &envoy_config_cluster_v3.Cluster{
+		Name: "cluster",
+		TransportSocket: &envoy_config_core_v3.TransportSocket{
+			Name:       "envoy.transport_sockets.tls",
+			ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{TypedConfig: tlsContext},
+		},
+	}
+
+	got := Redact(cluster).(*envoy_config_cluster_v3.Cluster)
+	upstream := &envoy_extensions_transport_sockets_tls_v3.UpstreamTlsContext{}
+	if err := got.GetTransportSocket().GetTypedConfig().UnmarshalTo(upstream); err != nil {
+		t.Fatalf("Redact() transport socket error = %v", err)
+	}
+	if v := upstream.GetCommonTlsContext().GetTlsCertificates()[0].GetPrivateKey().GetInlineString(); v != Redacted {
+		t.Errorf("Redact() inline private key = %q, want %q", v, Redacted)
+	}
+
+	route := &envoy_config_route_v3.Route{
+		Action: &envoy_config_route_v3.Route_DirectResponse{
+			DirectResponse: &envoy_config_route_v3.DirectResponseAction{
+				Status: 200,
+				Body: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "body"},
+				},
+			},
+		},
+	}
+	if v := Redact(route).(*envoy_config_route_v3.Route).GetDirectResponse().GetBody().GetInlineString(); v != "body" {
+		t.Errorf("Redact() direct response body = %q, want it untouched", v)
+	}
+}
@@ -0,0 +1,131 @@
+package envoy
+
+import (
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_filters_network_tcp_proxy_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	cache_v3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ResourceName returns the name of the given resource
+func ResourceName(res envoy.Resource) string {
+	return cache_v3.GetResourceName(res)
+}
+
+// References returns the references to other resources found in the given resource. All the
+// fields of the resource are inspected, including the messages packed in Any fields, looking for
+// references to clusters, routes served through RDS, endpoints served through EDS and secrets
+// served through SDS. Only references to resources served through ADS are returned, as the rest
+// are not delivered by the discovery service. Config sources are not inspected, as the clusters
+// of the management servers they point to are declared in the bootstrap config.
+func References(res envoy.Resource) []envoy.Reference {
+	refs := []envoy.Reference{}
+	add := func(rType envoy.Type, name string) {
+		if name != "" {
+			refs = append(refs, envoy.Reference{Type: rType, Name: name})
+		}
+	}
+
+	walk(res.ProtoReflect(), func(m protoreflect.Message) bool {
+		switch o := m.Interface().(type) {
+
+		case *envoy_config_core_v3.ConfigSource, *envoy_config_core_v3.ApiConfigSource:
+			return false
+
+		case *envoy_config_cluster_v3.Cluster:
+			if o.GetType() == envoy_config_cluster_v3.Cluster_EDS && isADS(o.GetEdsClusterConfig().GetEdsConfig()) {
+				if name := o.GetEdsClusterConfig().GetServiceName(); name != "" {
+					add(envoy.Endpoint, name)
+				} else {
+					add(envoy.Endpoint, o.GetName())
+				}
+			}
+
+		case *envoy_extensions_filters_network_http_connection_manager_v3.Rds:
+			if isADS(o.GetConfigSource()) {
+				add(envoy.Route, o.GetRouteConfigName())
+			}
+
+		case *envoy_config_route_v3.ScopedRouteConfiguration:
+			add(envoy.Route, o.GetRouteConfigurationName())
+
+		case *envoy_config_route_v3.RouteAction:
+			add(envoy.Cluster, o.GetCluster())
+
+		case *envoy_config_route_v3.WeightedCluster_ClusterWeight:
+			add(envoy.Cluster, o.GetName())
+
+		case *envoy_config_route_v3.RouteAction_RequestMirrorPolicy:
+			add(envoy.Cluster, o.GetCluster())
+
+		case *envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy:
+			add(envoy.Cluster, o.GetCluster())
+
+		case *envoy_extensions_filters_network_tcp_proxy_v3.TcpProxy_WeightedCluster_ClusterWeight:
+			add(envoy.Cluster, o.GetName())
+
+		case *envoy_config_core_v3.GrpcService_EnvoyGrpc:
+			add(envoy.Cluster, o.GetClusterName())
+
+		case *envoy_config_core_v3.HttpUri:
+			add(envoy.Cluster, o.GetCluster())
+
+		case *envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig:
+			if isADS(o.GetSdsConfig()) {
+				add(envoy.Secret, o.GetName())
+			}
+		}
+		return true
+	})
+
+	return refs
+}
+
+// isADS returns true if the config source points to the aggregated discovery service
+func isADS(source *envoy_config_core_v3.ConfigSource) bool {
+	return source.GetAds() != nil
+}
+
+// walk calls visit for the message and for all the messages nested in it,
+// unpacking the messages held in Any fields when their type is known. The
+// messages nested in a message are skipped if visit returns false for it.
+func walk(m protoreflect.Message, visit func(protoreflect.Message) bool) {
+	if a, ok := m.Interface().(*anypb.Any); ok {
+		if inner, err := a.UnmarshalNew(); err == nil {
+			walk(inner.ProtoReflect(), visit)
+		}
+		return
+	}
+
+	if !visit(m) {
+		return
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			if fd.Message() != nil {
+				list := v.List()
+				for i := 0; i < list.Len(); i++ {
+					walk(list.Get(i).Message(), visit)
+				}
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					walk(mv.Message(), visit)
+					return true
+				})
+			}
+		case fd.Message() != nil:
+			walk(v.Message(), visit)
+		}
+		return true
+	})
+}
@@ -0,0 +1,96 @@
+package envoy
+
+import (
+	"reflect"
+	"testing"
+
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+)
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		rType    envoy.Type
+		resource string
+		want     []envoy.Reference
+	}{
+		{
+			name:  "Listener with RDS and SDS served through ADS",
+			rType: envoy.Listener,
+			resource: `{"name":"https","filter_chains":[{
+				"filters":[{"name":"envoy.filters.network.http_connection_manager","typed_config":{
+					"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"stat_prefix":"https","rds":{"route_config_name":"router","config_source":{"ads":{}}}}}],
+				"transport_socket":{"name":"envoy.transport_sockets.tls","typed_config":{
+					"@type":"type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext",
+					"common_tls_context":{"tls_certificate_sds_secret_configs":[{"name":"cert","sds_config":{"ads":{}}}]}}}}]}`,
+			want: []envoy.Reference{{Type: envoy.Route, Name: "router"}, {Type: envoy.Secret, Name: "cert"}},
+		},
+		{
+			name:  "Listener with an inline route config and a tcp proxy",
+			rType: envoy.Listener,
+			resource: `{"name":"mixed","filter_chains":[
+				{"filters":[{"name":"envoy.filters.network.http_connection_manager","typed_config":{
+					"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"stat_prefix":"http","route_config":{"virtual_hosts":[{"name":"all","domains":["*"],
+						"routes":[{"match":{"prefix":"/"},"route":{"cluster":"backend"}}]}]}}}]},
+				{"filters":[{"name":"envoy.filters.network.tcp_proxy","typed_config":{
+					"@type":"type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy",
+					"stat_prefix":"tcp","cluster":"tcp"}}]}]}`,
+			want: []envoy.Reference{{Type: envoy.Cluster, Name: "backend"}, {Type: envoy.Cluster, Name: "tcp"}},
+		},
+		{
+			name:  "References not served through ADS are ignored",
+			rType: envoy.Listener,
+			resource: `{"name":"http","filter_chains":[{"filters":[{"name":"envoy.filters.network.http_connection_manager","typed_config":{
+					"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"stat_prefix":"http","rds":{"route_config_name":"router","config_source":{"path_config_source":{"path":"/routes.yaml"}}}}}]}]}`,
+			want: []envoy.Reference{},
+		},
+		{
+			name:  "Clusters of the management servers in config sources are ignored",
+			rType: envoy.Listener,
+			resource: `{"name":"http","filter_chains":[{"filters":[{"name":"envoy.filters.network.http_connection_manager","typed_config":{
+					"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"stat_prefix":"http","rds":{"route_config_name":"router","config_source":{"api_config_source":{
+						"api_type":"GRPC","transport_api_version":"V3",
+						"grpc_services":[{"envoy_grpc":{"cluster_name":"xds_cluster"}}]}}}}}]}]}`,
+			want: []envoy.Reference{},
+		},
+		{
+			name:  "Route with weighted clusters and mirrors",
+			rType: envoy.Route,
+			resource: `{"name":"router","virtual_hosts":[{"name":"all","domains":["*"],"routes":[
+				{"match":{"prefix":"/a"},"route":{"weighted_clusters":{"clusters":[{"name":"a1","weight":50},{"name":"a2","weight":50}]}}},
+				{"match":{"prefix":"/b"},"route":{"cluster":"b","request_mirror_policies":[{"cluster":"mirror"}]}}]}]}`,
+			want: []envoy.Reference{
+				{Type: envoy.Cluster, Name: "a1"}, {Type: envoy.Cluster, Name: "a2"},
+				{Type: envoy.Cluster, Name: "b"}, {Type: envoy.Cluster, Name: "mirror"},
+			},
+		},
+		{
+			name:     "EDS cluster",
+			rType:    envoy.Cluster,
+			resource: `{"name":"backend","type":"EDS","eds_cluster_config":{"eds_config":{"ads":{}}}}`,
+			want:     []envoy.Reference{{Type: envoy.Endpoint, Name: "backend"}},
+		},
+		{
+			name:     "EDS cluster with service name",
+			rType:    envoy.Cluster,
+			resource: `{"name":"backend","type":"EDS","eds_cluster_config":{"service_name":"svc","eds_config":{"ads":{}}}}`,
+			want:     []envoy.Reference{{Type: envoy.Endpoint, Name: "svc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Generator{}.New(tt.rType)
+			if err := envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, envoy.APIv3).Unmarshal(tt.resource, res); err != nil {
+				t.Fatalf("error decoding resource: %v", err)
+			}
+			if got := References(res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("References() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
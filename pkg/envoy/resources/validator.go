@@ -1,17 +1,250 @@
 package envoy
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_protos_v3 "github.com/3scale-ops/marin3r/pkg/envoy/protos/v3"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	"github.com/ghodss/yaml"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
-func Validate(resource string, encoding envoy_serializer.Serialization, version envoy.APIVersion, rType envoy.Type) error {
+// Validate checks that the resource can be unmarshalled into the envoy type and that it
+// complies with the protoc-gen-validate rules of the type and of the types held in its Any
+// fields. All the violations are returned, each one with the path of the offending field
+// appended to the given path.
+func Validate(resource string, encoding envoy_serializer.Serialization, version envoy.APIVersion, rType envoy.Type,
+	path *field.Path) field.ErrorList {
+
 	decoder := envoy_serializer.NewResourceUnmarshaller(encoding, version)
 	generator := NewGenerator(version)
 	res := generator.New(rType)
 	if err := decoder.Unmarshal(resource, res); err != nil {
-		return err
+		// report the Any messages that could not be resolved, if that is the cause
+		if errs := unknownTypes(resource, encoding, path); len(errs) > 0 {
+			return errs
+		}
+		return field.ErrorList{field.Invalid(path, field.OmitValueType{}, err.Error())}
+	}
+
+	if errs := validate(res.ProtoReflect(), path); len(errs) > 0 {
+		return errs
 	}
 
 	return nil
 }
+
+// validate runs the protoc-gen-validate rules of the message and of the messages held in its
+// Any fields at any depth, which are not unpacked by the ValidateAll() method of the message
+func validate(m protoreflect.Message, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if v, ok := m.Interface().(interface{ ValidateAll() error }); ok {
+		if err := v.ValidateAll(); err != nil {
+			errs = append(errs, violations(path, m.Descriptor(), err)...)
+		}
+	}
+
+	walkAnys(m, path, func(inner protoreflect.Message, path *field.Path) {
+		errs = append(errs, validate(inner, path)...)
+	})
+	return errs
+}
+
+// walkAnys calls visit for each message held in an Any field of the message, in a stable
+// order, with the path of the Any field. The Any fields of the unpacked messages are not
+// visited, as visit is expected to walk them.
+func walkAnys(m protoreflect.Message, path *field.Path, visit func(protoreflect.Message, *field.Path)) {
+	if a, ok := m.Interface().(*anypb.Any); ok {
+		if inner, err := a.UnmarshalNew(); err == nil {
+			visit(inner.ProtoReflect(), path)
+		}
+		return
+	}
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+		fdPath := path.Child(string(fd.Name()))
+		v := m.Get(fd)
+		switch {
+		case fd.IsList():
+			if fd.Message() != nil {
+				list := v.List()
+				for idx := 0; idx < list.Len(); idx++ {
+					walkAnys(list.Get(idx).Message(), fdPath.Index(idx), visit)
+				}
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				keys := []string{}
+				values := map[string]protoreflect.Message{}
+				v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+					keys = append(keys, k.String())
+					values[k.String()] = mv.Message()
+					return true
+				})
+				sort.Strings(keys)
+				for _, key := range keys {
+					walkAnys(values[key], fdPath.Key(key), visit)
+				}
+			}
+		case fd.Message() != nil:
+			walkAnys(v.Message(), fdPath, visit)
+		}
+	}
+}
+
+// validationError is the interface implemented by the
+// errors of the protoc-gen-validate generated code
+type validationError interface {
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// multiError is the interface implemented by the errors returned by the
+// ValidateAll() methods of the protoc-gen-validate generated code
+type multiError interface {
+	AllErrors() []error
+}
+
+// violations flattens the errors returned by ValidateAll() for a message of the given
+// descriptor, which nest the errors of embedded messages, into a list of errors with
+// the full field path
+func violations(path *field.Path, desc protoreflect.MessageDescriptor, err error) field.ErrorList {
+	switch e := err.(type) {
+	case multiError:
+		list := field.ErrorList{}
+		for _, err := range e.AllErrors() {
+			list = append(list, violations(path, desc, err)...)
+		}
+		return list
+
+	case validationError:
+		path, desc = fieldPath(path, desc, e.Field())
+		switch e.Cause().(type) {
+		case nil:
+			return field.ErrorList{field.Invalid(path, field.OmitValueType{}, e.Reason())}
+		case multiError, validationError:
+			return violations(path, desc, e.Cause())
+		default:
+			return field.ErrorList{field.Invalid(path, field.OmitValueType{}, e.Reason()+": "+e.Cause().Error())}
+		}
+
+	default:
+		return field.ErrorList{field.Invalid(path, field.OmitValueType{}, err.Error())}
+	}
+}
+
+// fieldPath appends a field of a message of the given descriptor, as reported by protoc-gen-validate,
+// to the path. protoc-gen-validate uses the Go names of the fields, with the index or key for repeated
+// and map fields, so they are converted to the proto names used in the resources. The descriptor
+// of the field's message, if any, is also returned.
+func fieldPath(path *field.Path, desc protoreflect.MessageDescriptor, name string) (*field.Path, protoreflect.MessageDescriptor) {
+	name, subscript, found := strings.Cut(name, "[")
+
+	var fdDesc protoreflect.MessageDescriptor
+	if fd := fieldByGoName(desc, name); fd != nil {
+		name = string(fd.Name())
+		fdDesc = fd.Message()
+		if fd.IsMap() {
+			fdDesc = fd.MapValue().Message()
+		}
+	}
+	path = path.Child(name)
+
+	if found {
+		subscript = strings.TrimSuffix(subscript, "]")
+		if idx, err := strconv.Atoi(subscript); err == nil {
+			return path.Index(idx), fdDesc
+		}
+		return path.Key(subscript), fdDesc
+	}
+	return path, fdDesc
+}
+
+// fieldByGoName returns the field of the message whose Go name is the given one. protoc-gen-go
+// generates the Go names converting the proto names to camel case, so they are compared
+// ignoring the case and the underscores.
+func fieldByGoName(desc protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if desc == nil {
+		return nil
+	}
+	name = strings.ReplaceAll(name, "_", "")
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if strings.EqualFold(strings.ReplaceAll(string(fields.Get(i).Name()), "_", ""), name) {
+			return fields.Get(i)
+		}
+	}
+	return nil
+}
+
+// unknownTypes looks for the '@type' fields of the Any messages in the resource, at any
+// depth, and returns an error for each type URL that is not registered, suggesting the
+// closest registered type. The path of each error uses the field names of the resource.
+func unknownTypes(resource string, encoding envoy_serializer.Serialization, path *field.Path) field.ErrorList {
+	data := []byte(resource)
+	var err error
+	switch encoding {
+	case envoy_serializer.YAML:
+		data, err = yaml.YAMLToJSON(data)
+	case envoy_serializer.B64JSON:
+		data, err = base64.StdEncoding.DecodeString(resource)
+	}
+	if err != nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+
+	errs := field.ErrorList{}
+	walkTypes(value, path, func(typeURL string, path *field.Path) {
+		if envoy_protos_v3.IsRegistered(typeURL) {
+			return
+		}
+		msg := "unknown type"
+		if closest := envoy_protos_v3.ClosestType(typeURL); closest != "" {
+			msg = fmt.Sprintf("unknown type, did you mean '%s'?", closest)
+		}
+		errs = append(errs, field.Invalid(path, typeURL, msg))
+	})
+	return errs
+}
+
+// walkTypes calls visit for each '@type' field found in the value, in a stable order
+func walkTypes(value interface{}, path *field.Path, visit func(string, *field.Path)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if typeURL, ok := v[key].(string); ok && key == "@type" {
+				visit(typeURL, path.Child(key))
+				continue
+			}
+			walkTypes(v[key], path.Child(key), visit)
+		}
+	case []interface{}:
+		for idx, item := range v {
+			walkTypes(item, path.Index(idx), visit)
+		}
+	}
+}
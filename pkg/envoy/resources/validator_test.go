@@ -0,0 +1,108 @@
+package envoy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		resource  string
+		rType     envoy.Type
+		wantPaths []string
+	}{
+		{
+			name:      "Valid resource",
+			resource:  `{"name":"cluster","connect_timeout":"1s"}`,
+			rType:     envoy.Cluster,
+			wantPaths: []string{},
+		},
+		{
+			name:      "Resource that cannot be unmarshalled",
+			resource:  `{"name":"cluster","unknown":"field"}`,
+			rType:     envoy.Cluster,
+			wantPaths: []string{"value"},
+		},
+		{
+			name:      "Returns all the violations",
+			resource:  `{"name":"","connect_timeout":"-1s"}`,
+			rType:     envoy.Cluster,
+			wantPaths: []string{"value.name", "value.connect_timeout"},
+		},
+		{
+			name:      "Returns the path of violations in embedded messages",
+			resource:  `{"name":"listener","filter_chains":[{"filters":[{"name":"filter"},{"name":""}]}]}`,
+			rType:     envoy.Listener,
+			wantPaths: []string{"value.filter_chains[0].filters[1].name"},
+		},
+		{
+			name: "Returns the violations of the messages held in Any fields",
+			resource: `{"name":"listener","filter_chains":[{"filters":[{"name":"envoy.filters.network.http_connection_manager",
+				"typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+				"stat_prefix":"","rds":{"config_source":{"ads":{}},"route_config_name":"route"}}}]}]}`,
+			rType:     envoy.Listener,
+			wantPaths: []string{"value.filter_chains[0].filters[0].typed_config.stat_prefix"},
+		},
+		{
+			name: "Returns the path of unknown types at any depth",
+			resource: `{"name":"listener","filter_chains":[{"filters":[{"name":"envoy.filters.network.http_connection_manager",
+				"typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+				"stat_prefix":"http","http_filters":[{"name":"envoy.filters.http.router",
+				"typed_config":{"@type":"type.googleapis.com/envoy.extensions.filters.http.router.v3.Routr"}}]}}]}]}`,
+			rType:     envoy.Listener,
+			wantPaths: []string{"value.filter_chains[0].filters[0].typed_config.http_filters[0].typed_config.@type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(tt.resource, envoy_serializer.JSON, envoy.APIv3, tt.rType, field.NewPath("value"))
+			paths := []string{}
+			for _, err := range errs {
+				paths = append(paths, err.Field)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("Validate() = %v, want violations in %v", errs, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestValidate_unknownTypeSuggestion(t *testing.T) {
+	resource := `
+name: envoy.filters.http.router
+typed_config:
+  "@type": type.googleapis.com/envoy.extensions.filters.http.router.v3.Routr
+`
+	errs := Validate(resource, envoy_serializer.YAML, envoy.APIv3, envoy.ExtensionConfig, field.NewPath("value"))
+	want := `value.typed_config.@type: Invalid value: "type.googleapis.com/envoy.extensions.filters.http.router.v3.Routr": ` +
+		`unknown type, did you mean 'type.googleapis.com/envoy.extensions.filters.http.router.v3.Router'?`
+	if len(errs) != 1 || errs[0].Error() != want {
+		t.Errorf("Validate() = %v, want %v", errs, want)
+	}
+}
+
+func Test_fieldPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Name", "value.name"},
+		{"HealthChecks[2]", "value.health_checks[2]"},
+		{"TypedExtensionProtocolOptions[key]", "value.typed_extension_protocol_options[key]"},
+		{"Unknown", "value.Unknown"},
+	}
+	desc := (&envoy_config_cluster_v3.Cluster{}).ProtoReflect().Descriptor()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := fieldPath(field.NewPath("value"), desc, tt.name); got.String() != tt.want {
+				t.Errorf("fieldPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
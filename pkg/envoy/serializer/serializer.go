@@ -27,10 +27,28 @@ type ResourceUnmarshaller interface {
 	Unmarshal(string, envoy.Resource) error
 }
 
-// NewResourceMarshaller returns a ResourceMarshaller for the given API version and encoding
+// NewResourceMarshaller returns a ResourceMarshaller for the given API version and encoding.
+// Fields that hold their default value are omitted from the output.
 func NewResourceMarshaller(encoding Serialization, version envoy.APIVersion) ResourceMarshaller {
-	return envoy_serializer_v3.JSON{}
+	return newResourceMarshaller(encoding, version, false)
+}
+
+// NewResourceMarshallerWithDefaults returns a ResourceMarshaller for the given API version
+// and encoding that also outputs the fields that hold their default value
+func NewResourceMarshallerWithDefaults(encoding Serialization, version envoy.APIVersion) ResourceMarshaller {
+	return newResourceMarshaller(encoding, version, true)
+}
 
+// newResourceMarshaller returns the ResourceMarshaller for the encoding, defaulting to JSON
+func newResourceMarshaller(encoding Serialization, version envoy.APIVersion, emitDefaults bool) ResourceMarshaller {
+	switch encoding {
+	case YAML:
+		return envoy_serializer_v3.YAML{EmitDefaults: emitDefaults}
+	case B64JSON:
+		return envoy_serializer_v3.B64JSON{EmitDefaults: emitDefaults}
+	default:
+		return envoy_serializer_v3.JSON{EmitDefaults: emitDefaults}
+	}
 }
 
 // NewResourceUnmarshaller returns a ResourceUnmarshaller for the given api version and encoding
@@ -9,13 +9,19 @@ import (
 	_ "github.com/3scale-ops/marin3r/pkg/envoy/protos/v3"
 	"github.com/ghodss/yaml"
 	"google.golang.org/protobuf/encoding/protojson"
+	goyaml "sigs.k8s.io/yaml/goyaml.v2"
 )
 
-type JSON struct{}
+type JSON struct {
+	// EmitDefaults makes Marshal output the fields that hold their default value
+	EmitDefaults bool
+}
 
 func (s JSON) Marshal(res envoy.Resource) (string, error) {
 
-	opts := protojson.MarshalOptions{UseProtoNames: true, Indent: ""}
+	// protojson outputs the fields in declaration order and the
+	// map keys sorted, so the output is deterministic
+	opts := protojson.MarshalOptions{UseProtoNames: true, Indent: "", EmitUnpopulated: s.EmitDefaults}
 	data, err := opts.Marshal(res)
 	if err != nil {
 		return "", err
@@ -44,7 +50,19 @@ func (s JSON) Unmarshal(str string, res envoy.Resource) error {
 	return nil
 }
 
-type B64JSON struct{}
+type B64JSON struct {
+	// EmitDefaults makes Marshal output the fields that hold their default value
+	EmitDefaults bool
+}
+
+func (s B64JSON) Marshal(res envoy.Resource) (string, error) {
+	js, err := JSON{EmitDefaults: s.EmitDefaults}.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(js)), nil
+}
 
 func (s B64JSON) Unmarshal(str string, res envoy.Resource) error {
 	b, err := base64.StdEncoding.DecodeString(str)
@@ -61,7 +79,31 @@ func (s B64JSON) Unmarshal(str string, res envoy.Resource) error {
 	return nil
 }
 
-type YAML struct{}
+type YAML struct {
+	// EmitDefaults makes Marshal output the fields that hold their default value
+	EmitDefaults bool
+}
+
+func (s YAML) Marshal(res envoy.Resource) (string, error) {
+	js, err := JSON{EmitDefaults: s.EmitDefaults}.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+
+	// Decode into a MapSlice instead of a map so the keys keep the order of the json
+	// output. Otherwise they would be sorted alphabetically and the output would not
+	// follow the field order of the envoy API.
+	obj := goyaml.MapSlice{}
+	if err := goyaml.Unmarshal([]byte(js), &obj); err != nil {
+		return "", fmt.Errorf("error converting json to yaml: '%s'", err)
+	}
+	data, err := goyaml.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("error converting json to yaml: '%s'", err)
+	}
+
+	return string(data), nil
+}
 
 func (s YAML) Unmarshal(str string, res envoy.Resource) error {
 	b, err := yaml.YAMLToJSON([]byte(str))
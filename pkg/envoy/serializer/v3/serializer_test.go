@@ -1,6 +1,7 @@
 package envoy
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -444,3 +445,88 @@ func TestYAML_Unmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestYAML_Marshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       YAML
+		res     envoy.Resource
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Serialize cluster to yaml keeping the field order",
+			s:    YAML{},
+			res:  cluster,
+			want: "name: cluster1\ntype: STRICT_DNS\nconnect_timeout: 2s\nload_assignment:\n  cluster_name: cluster1\n",
+		},
+		{
+			name: "Serialize runtime to yaml",
+			s:    YAML{},
+			res:  runtime,
+			want: "name: runtime1\nlayer:\n  static_layer_0: value\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.Marshal(tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("YAML.Marshal() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("YAML.Marshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_roundTrip(t *testing.T) {
+	resources := map[string]envoy.Resource{
+		"listener": listener, "endpoint": endpoint, "cluster": cluster, "secret": secret,
+		"route": route, "scopedRoute": scopedRoute, "runtime": runtime, "extensionConfig": extensionConfig,
+	}
+	serializers := map[string]interface {
+		Marshal(envoy.Resource) (string, error)
+		Unmarshal(string, envoy.Resource) error
+	}{
+		"json": JSON{}, "b64json": B64JSON{}, "yaml": YAML{},
+		"json with defaults": JSON{EmitDefaults: true}, "b64json with defaults": B64JSON{EmitDefaults: true},
+		"yaml with defaults": YAML{EmitDefaults: true},
+	}
+
+	for sName, s := range serializers {
+		for rName, res := range resources {
+			t.Run(sName+"/"+rName, func(t *testing.T) {
+				str, err := s.Marshal(res)
+				if err != nil {
+					t.Fatalf("Marshal() error = %v", err)
+				}
+				again, err := s.Marshal(res)
+				if err != nil || again != str {
+					t.Errorf("Marshal() output is not stable: %v != %v", again, str)
+				}
+				got := res.ProtoReflect().New().Interface()
+				if err := s.Unmarshal(str, got); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				if !proto.Equal(got, res) {
+					t.Errorf("Unmarshal(Marshal()) = %v, want %v", got, res)
+				}
+			})
+		}
+	}
+}
+
+func TestJSON_Marshal_emitDefaults(t *testing.T) {
+	res := &envoy_config_cluster_v3.Cluster{Name: "cluster"}
+
+	without, _ := JSON{}.Marshal(res)
+	with, _ := JSON{EmitDefaults: true}.Marshal(res)
+	if without != `{"name":"cluster"}` {
+		t.Errorf("JSON.Marshal() = %v, want only the name", without)
+	}
+	if !strings.Contains(with, `"per_connection_buffer_limit_bytes":null`) || !strings.Contains(with, `"lb_policy":"ROUND_ROBIN"`) {
+		t.Errorf("JSON{EmitDefaults: true}.Marshal() = %v, want the default fields", with)
+	}
+}
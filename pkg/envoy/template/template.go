@@ -0,0 +1,80 @@
+package envoy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const delimLeft = "{{"
+
+// Render executes the Go templates found in the string values of a JSON document
+// using the given parameter values. Templates are evaluated value by value, so the
+// rendered document is always valid JSON and values containing quotes or newlines do
+// not need to be escaped. Numeric proto fields accept quoted values, so a template
+// can also be used for those, e.g. '"port_value": "{{ .port }}"'. Referencing a
+// parameter that has not been declared is an error.
+func Render(raw []byte, values map[string]string) ([]byte, error) {
+	if !bytes.Contains(raw, []byte(delimLeft)) {
+		return raw, nil
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// keep numbers as they are written in the source document
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	rendered, err := render(doc, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(rendered)
+}
+
+func render(node interface{}, values map[string]string) (interface{}, error) {
+	switch v := node.(type) {
+
+	case string:
+		if !strings.Contains(v, delimLeft) {
+			return v, nil
+		}
+		tpl, err := template.New("").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", v, err)
+		}
+		var out strings.Builder
+		if err := tpl.Execute(&out, values); err != nil {
+			return nil, fmt.Errorf("error rendering template %q: %w", v, err)
+		}
+		return out.String(), nil
+
+	case map[string]interface{}:
+		for key, value := range v {
+			rendered, err := render(value, values)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+		return v, nil
+
+	case []interface{}:
+		for i, value := range v {
+			rendered, err := render(value, values)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+		return v, nil
+
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,76 @@
+package envoy
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	type args struct {
+		raw    string
+		values map[string]string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Returns the document untouched if there are no templates",
+			args: args{
+				raw:    `{"name": "cluster", "connect_timeout": "2s"}`,
+				values: map[string]string{},
+			},
+			want:    `{"name": "cluster", "connect_timeout": "2s"}`,
+			wantErr: false,
+		},
+		{
+			name: "Renders templates in nested string values",
+			args: args{
+				raw:    `{"name":"{{ .name }}","address":{"socket_address":{"address":"0.0.0.0","port_value":"{{ .port }}"}},"filters":[{"name":"{{ .name }}-filter"}],"weight":10}`,
+				values: map[string]string{"name": "http", "port": "8080"},
+			},
+			want:    `{"address":{"socket_address":{"address":"0.0.0.0","port_value":"8080"}},"filters":[{"name":"http-filter"}],"name":"http","weight":10}`,
+			wantErr: false,
+		},
+		{
+			name: "Values with quotes are correctly escaped",
+			args: args{
+				raw:    `{"inline_string":"{{ .body }}"}`,
+				values: map[string]string{"body": `{"a": "b"}`},
+			},
+			want:    `{"inline_string":"{\"a\": \"b\"}"}`,
+			wantErr: false,
+		},
+		{
+			name: "Fails for undeclared parameters",
+			args: args{
+				raw:    `{"name":"{{ .missing }}"}`,
+				values: map[string]string{"name": "http"},
+			},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name: "Fails for invalid templates",
+			args: args{
+				raw:    `{"name":"{{ .name "}`,
+				values: map[string]string{"name": "http"},
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render([]byte(tt.args.raw), tt.args.values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Render() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("Render() = %v, want %v", string(got), tt.want)
+			}
+		})
+	}
+}
@@ -61,6 +61,13 @@ const (
 	ExtensionConfig Type = "extensionConfig"
 )
 
+// Reference is a reference from an envoy resource to another
+// envoy resource, identified by its type and name
+type Reference struct {
+	Type Type
+	Name string
+}
+
 type EndpointHealthStatus int32
 
 const (
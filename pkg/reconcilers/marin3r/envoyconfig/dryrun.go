@@ -0,0 +1,99 @@
+package reconcilers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	xdss_v3 "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/v3"
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
+	envoy_resources_v3 "github.com/3scale-ops/marin3r/pkg/envoy/resources/v3"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	envoyconfigrevision "github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfigrevision"
+	"github.com/ghodss/yaml"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// snapshotTypes are the resource types included in a rendered snapshot
+var snapshotTypes = []envoy.Type{envoy.Endpoint, envoy.Cluster, envoy.Route, envoy.ScopedRoute,
+	envoy.Listener, envoy.Secret, envoy.Runtime, envoy.ExtensionConfig}
+
+// DryRun generates the snapshot that the discovery service would serve for the EnvoyConfig,
+// without writing it to the xDS cache. The resources of the referenced EnvoyResourceLibraries
+// are imported and the resources generated from Secrets and EndpointSlices are loaded from
+// the cluster, the same way the controllers do. The given EnvoyConfig is not modified.
+func DryRun(ctx context.Context, logger logr.Logger, c client.Client, ec *marin3rv1alpha1.EnvoyConfig) (xdss.Snapshot, error) {
+	ec = ec.DeepCopy()
+
+	if ec.Spec.EnvoyResources != nil {
+		resources, err := ec.Spec.EnvoyResources.Resources(ec.GetSerialization())
+		if err != nil {
+			return nil, err
+		}
+		ec.Spec.Resources = resources
+		ec.Spec.EnvoyResources = nil
+	}
+
+	if err := ImportLibraries(ctx, c, ec); err != nil {
+		return nil, err
+	}
+
+	version := ec.GetEnvoyAPIVersion()
+	cacheReconciler := envoyconfigrevision.NewCacheReconciler(ctx, logger, c, xdss_v3.NewCache(),
+		envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, version),
+		envoy_resources.NewGenerator(version),
+	)
+
+	key := types.NamespacedName{Name: ec.GetName(), Namespace: ec.GetNamespace()}
+	return cacheReconciler.GenerateSnapshot(key, ec.Spec.Resources, ec.Spec.Parameters)
+}
+
+// RenderSnapshot serializes the resources of the snapshot, grouped by type and sorted by
+// name, using the given encoding. Only the JSON and YAML encodings are supported. The
+// secret material is redacted from all the resources, including the inline certificates
+// and keys of the listeners' and clusters' transport sockets.
+func RenderSnapshot(snap xdss.Snapshot, encoding envoy_serializer.Serialization) (string, error) {
+	marshaller := envoy_serializer.NewResourceMarshaller(envoy_serializer.JSON, envoy.APIv3)
+
+	out := map[envoy.Type][]json.RawMessage{}
+	for _, rType := range snapshotTypes {
+		resources := snap.GetResources(rType)
+		names := make([]string, 0, len(resources))
+		for name := range resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			data, err := marshaller.Marshal(envoy_resources_v3.Redact(resources[name]))
+			if err != nil {
+				return "", fmt.Errorf("unable to serialize %s '%s': %w", rType, name, err)
+			}
+			out[rType] = append(out[rType], json.RawMessage(data))
+		}
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	switch encoding {
+	case envoy_serializer.JSON:
+		return string(data), nil
+	case envoy_serializer.YAML:
+		data, err := yaml.JSONToYAML(data)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("unsupported encoding '%s'", encoding)
+	}
+}
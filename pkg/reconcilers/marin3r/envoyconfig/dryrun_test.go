@@ -0,0 +1,79 @@
+package reconcilers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources_v3 "github.com/3scale-ops/marin3r/pkg/envoy/resources/v3"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	k8sutil "github.com/3scale-ops/marin3r/pkg/util/k8s"
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDryRun(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		&marin3rv1alpha1.EnvoyResourceLibrary{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusters", Namespace: "test"},
+			Spec: marin3rv1alpha1.EnvoyResourceLibrarySpec{
+				Resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension(`{"name":"cluster","transport_socket":{"name":"envoy.transport_sockets.tls","typed_config":{"@type":"type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext","common_tls_context":{"tls_certificates":[{"private_key":{"inline_string":"inline-key"}}]}}}}`)},
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "test"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")},
+		},
+	).Build()
+
+	ec := &marin3rv1alpha1.EnvoyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+		Spec: marin3rv1alpha1.EnvoyConfigSpec{
+			NodeID: "test",
+			Resources: []marin3rv1alpha1.Resource{
+				{Type: envoy.Listener, Value: k8sutil.StringtoRawExtension(`{"name":"listener"}`)},
+				{Type: envoy.Secret, GenerateFromTlsSecret: pointer.New("secret")},
+			},
+			Libraries: []marin3rv1alpha1.LibraryReference{{Name: "clusters"}},
+		},
+	}
+
+	snap, err := DryRun(context.TODO(), ctrl.Log.WithName("test"), cl, ec)
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+	for _, rType := range []envoy.Type{envoy.Listener, envoy.Cluster, envoy.Secret} {
+		if len(snap.GetResources(rType)) != 1 {
+			t.Errorf("DryRun() %s resources = %v, want 1", rType, snap.GetResources(rType))
+		}
+	}
+	if len(ec.Spec.Resources) != 2 {
+		t.Errorf("DryRun() modified the given EnvoyConfig")
+	}
+
+	for _, encoding := range []envoy_serializer.Serialization{envoy_serializer.JSON, envoy_serializer.YAML} {
+		out, err := RenderSnapshot(snap, encoding)
+		if err != nil {
+			t.Fatalf("RenderSnapshot() error = %v", err)
+		}
+		if !strings.Contains(out, envoy_resources_v3.Redacted) || strings.Contains(out, "a2V5") ||
+			strings.Contains(out, "inline-key") {
+			t.Errorf("RenderSnapshot() secret material was not redacted:\n%s", out)
+		}
+		if !strings.Contains(out, "listener") || !strings.Contains(out, "cluster") {
+			t.Errorf("RenderSnapshot() = %s, want the listener and the cluster", out)
+		}
+	}
+
+	if _, err := RenderSnapshot(snap, envoy_serializer.B64JSON); err == nil {
+		t.Errorf("RenderSnapshot() error = nil for an unsupported encoding")
+	}
+}
@@ -0,0 +1,33 @@
+package reconcilers
+
+import (
+	"context"
+	"fmt"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ImportLibraries resolves the EnvoyResourceLibraries referenced by the EnvoyConfig and adds
+// their resources in front of the ones in spec.resources. This is only done in memory so the
+// resources version of the EnvoyConfig, and the revisions generated from it, cover the
+// imported content.
+func ImportLibraries(ctx context.Context, c client.Client, ec *marin3rv1alpha1.EnvoyConfig) error {
+	if len(ec.Spec.Libraries) == 0 {
+		return nil
+	}
+
+	resources := []marin3rv1alpha1.Resource{}
+	for _, ref := range ec.Spec.Libraries {
+		lib := &marin3rv1alpha1.EnvoyResourceLibrary{}
+		key := types.NamespacedName{Name: ref.Name, Namespace: ec.GetNamespace()}
+		if err := c.Get(ctx, key, lib); err != nil {
+			return fmt.Errorf("unable to import EnvoyResourceLibrary '%s': %w", ref.Name, err)
+		}
+		resources = append(resources, lib.Spec.Resources...)
+	}
+
+	ec.Spec.Resources = append(resources, ec.Spec.Resources...)
+	return nil
+}
@@ -0,0 +1,99 @@
+package reconcilers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	k8sutil "github.com/3scale-ops/marin3r/pkg/util/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestImportLibraries(t *testing.T) {
+	libraries := []client.Object{
+		&marin3rv1alpha1.EnvoyResourceLibrary{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusters", Namespace: "test"},
+			Spec: marin3rv1alpha1.EnvoyResourceLibrarySpec{
+				Resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension(`{"name":"cluster"}`)},
+				},
+			},
+		},
+		&marin3rv1alpha1.EnvoyResourceLibrary{
+			ObjectMeta: metav1.ObjectMeta{Name: "runtimes", Namespace: "test"},
+			Spec: marin3rv1alpha1.EnvoyResourceLibrarySpec{
+				Resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Runtime, Value: k8sutil.StringtoRawExtension(`{"name":"runtime"}`)},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ec      *marin3rv1alpha1.EnvoyConfig
+		want    []marin3rv1alpha1.Resource
+		wantErr bool
+	}{
+		{
+			name: "Adds the library resources in front of the EnvoyConfig ones",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					Resources: []marin3rv1alpha1.Resource{
+						{Type: envoy.Listener, Value: k8sutil.StringtoRawExtension(`{"name":"listener"}`)},
+					},
+					Libraries: []marin3rv1alpha1.LibraryReference{{Name: "runtimes"}, {Name: "clusters"}},
+				},
+			},
+			want: []marin3rv1alpha1.Resource{
+				{Type: envoy.Runtime, Value: k8sutil.StringtoRawExtension(`{"name":"runtime"}`)},
+				{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension(`{"name":"cluster"}`)},
+				{Type: envoy.Listener, Value: k8sutil.StringtoRawExtension(`{"name":"listener"}`)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Does nothing if no libraries are imported",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					Resources: []marin3rv1alpha1.Resource{
+						{Type: envoy.Listener, Value: k8sutil.StringtoRawExtension(`{"name":"listener"}`)},
+					},
+				},
+			},
+			want: []marin3rv1alpha1.Resource{
+				{Type: envoy.Listener, Value: k8sutil.StringtoRawExtension(`{"name":"listener"}`)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Fails if a library does not exist",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "other"},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					Libraries: []marin3rv1alpha1.LibraryReference{{Name: "clusters"}},
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(s).WithObjects(libraries...).Build()
+			if err := ImportLibraries(context.TODO(), cl, tt.ec); (err != nil) != tt.wantErr {
+				t.Errorf("ImportLibraries() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(tt.ec.Spec.Resources, tt.want) {
+				t.Errorf("ImportLibraries() resources = %v, want %v", tt.ec.Spec.Resources, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package reconcilers
+
+import (
+	"context"
+	"fmt"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveParameters replaces the parameters of the EnvoyConfig that read their value from a
+// ConfigMap with literal values. This is only done in memory so the resources version of the
+// EnvoyConfig covers the resolved values and a change in a ConfigMap generates a new revision,
+// which holds a snapshot of the values it was rendered with.
+func ResolveParameters(ctx context.Context, c client.Client, ec *marin3rv1alpha1.EnvoyConfig) error {
+	if len(ec.Spec.Parameters) == 0 {
+		return nil
+	}
+
+	parameters := make([]marin3rv1alpha1.Parameter, 0, len(ec.Spec.Parameters))
+	for _, param := range ec.Spec.Parameters {
+		if param.Value != nil || param.ConfigMapKeyRef == nil {
+			parameters = append(parameters, param)
+			continue
+		}
+
+		cm := &corev1.ConfigMap{}
+		key := types.NamespacedName{Name: param.ConfigMapKeyRef.Name, Namespace: ec.GetNamespace()}
+		if err := c.Get(ctx, key, cm); err != nil {
+			return fmt.Errorf("parameter %q: %w", param.Name, err)
+		}
+		value, ok := cm.Data[param.ConfigMapKeyRef.Key]
+		if !ok {
+			return fmt.Errorf("parameter %q: key '%s' not found in ConfigMap '%s'", param.Name, param.ConfigMapKeyRef.Key, param.ConfigMapKeyRef.Name)
+		}
+		parameters = append(parameters, marin3rv1alpha1.Parameter{Name: param.Name, Value: pointer.New(value)})
+	}
+
+	ec.Spec.Parameters = parameters
+	return nil
+}
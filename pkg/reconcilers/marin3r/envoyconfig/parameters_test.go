@@ -0,0 +1,98 @@
+package reconcilers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveParameters(t *testing.T) {
+	configMaps := []client.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "params", Namespace: "test"},
+			Data:       map[string]string{"host": "example.com"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ec      *marin3rv1alpha1.EnvoyConfig
+		want    []marin3rv1alpha1.Parameter
+		wantErr bool
+	}{
+		{
+			name: "Replaces ConfigMap references with their values",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					Parameters: []marin3rv1alpha1.Parameter{
+						{Name: "port", Value: pointer.New("8080")},
+						{Name: "host", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "host"}},
+					},
+				},
+			},
+			want: []marin3rv1alpha1.Parameter{
+				{Name: "port", Value: pointer.New("8080")},
+				{Name: "host", Value: pointer.New("example.com")},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Does nothing if no parameters are declared",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+			},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "Fails if the key does not exist",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					Parameters: []marin3rv1alpha1.Parameter{
+						{Name: "host", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "other"}},
+					},
+				},
+			},
+			want: []marin3rv1alpha1.Parameter{
+				{Name: "host", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "other"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Fails if the ConfigMap does not exist",
+			ec: &marin3rv1alpha1.EnvoyConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "other"},
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{
+					Parameters: []marin3rv1alpha1.Parameter{
+						{Name: "host", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "host"}},
+					},
+				},
+			},
+			want: []marin3rv1alpha1.Parameter{
+				{Name: "host", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "host"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(s).WithObjects(configMaps...).Build()
+			if err := ResolveParameters(context.TODO(), cl, tt.ec); (err != nil) != tt.wantErr {
+				t.Errorf("ResolveParameters() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(tt.ec.Spec.Parameters, tt.want) {
+				t.Errorf("ResolveParameters() parameters = %v, want %v", tt.ec.Spec.Parameters, tt.want)
+			}
+		})
+	}
+}
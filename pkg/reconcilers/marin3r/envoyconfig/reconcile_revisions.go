@@ -3,14 +3,20 @@ package reconcilers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	reconcilerutil "github.com/3scale-ops/basereconciler/util"
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	"github.com/3scale-ops/marin3r/pkg/envoy"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfig/filters"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfig/revisions"
+	envoyconfigrevision "github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfigrevision"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -19,10 +25,6 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-const (
-	maxRevisions = 10
-)
-
 // RevisionReconciler is a struct with methods to reconcile EnvoyConfig revisions
 type RevisionReconciler struct {
 	ctx    context.Context
@@ -37,13 +39,33 @@ type RevisionReconciler struct {
 	publishedVersion *string
 	cacheState       *string
 	revisionList     *marin3rv1alpha1.EnvoyConfigRevisionList
+	canaryVersion    *string
+	pinError         error
+
+	// dStats and xdsNodeID are used to check the envoy clients
+	// that receive a canary revision, see SetDiscoveryStats()
+	dStats    *stats.Stats
+	xdsNodeID string
 }
 
+// canaryRecheckInterval is the interval at which a canary revision that has
+// not been received by any envoy client is checked again for promotion
+const canaryRecheckInterval time.Duration = 30 * time.Second
+
 // NewRevisionReconciler returns a new RevisionReconciler
 func NewRevisionReconciler(ctx context.Context, logger logr.Logger, client client.Client,
 	s *runtime.Scheme, ec *marin3rv1alpha1.EnvoyConfig) RevisionReconciler {
 
-	return RevisionReconciler{ctx, logger, client, s, ec, nil, nil, nil, nil}
+	return RevisionReconciler{ctx, logger, client, s, ec, nil, nil, nil, nil, nil, nil, nil, ""}
+}
+
+// SetDiscoveryStats sets the discovery service stats used to check that a canary revision
+// has been received by some envoy client before promoting it. The xdsNodeID is the nodeID
+// the discovery service uses for the envoy clients of the EnvoyConfig. If not set, canary
+// revisions are promoted once the analysis period is over.
+func (r *RevisionReconciler) SetDiscoveryStats(dStats *stats.Stats, xdsNodeID string) {
+	r.dStats = dStats
+	r.xdsNodeID = xdsNodeID
 }
 
 // Instance returns the EnvoyConfig the reconciler has been instantiated with
@@ -92,6 +114,22 @@ func (r *RevisionReconciler) PublishedVersion() string {
 	return *r.publishedVersion
 }
 
+// CanaryVersion returns the version of the revision being published as
+// a canary, or an empty string if there is none. If Reconcile has not been
+// successfully invoked it will return an empty string.
+func (r *RevisionReconciler) CanaryVersion() string {
+	if r.canaryVersion == nil {
+		return ""
+	}
+	return *r.canaryVersion
+}
+
+// PinError returns the reason why the pinned version could not be
+// published, or nil if there is no pinned version or it has been published.
+func (r *RevisionReconciler) PinError() error {
+	return r.pinError
+}
+
 // GetCacheState returns the status of the EnvoyConfig the reconciler
 // has been instantiated with. If Reconcile has not been successfully
 // invoked it will return nil.
@@ -134,10 +172,52 @@ func (r *RevisionReconciler) Reconcile() (ctrl.Result, error) {
 		log.Error(err, "unable to list revisions", "Phase", "BuildRevisionList")
 		return ctrl.Result{}, err
 	}
+
+	// Propagate the taint threshold to all the revisions
+	for idx := range list.Items {
+		ecr := &list.Items[idx]
+		if threshold := r.Instance().GetRevisionHistory().GetTaintThreshold(); !equality.Semantic.DeepEqual(ecr.Spec.TaintThreshold, threshold) {
+			ecr.Spec.TaintThreshold = threshold.DeepCopy()
+			if err := r.client.Update(r.ctx, ecr); err != nil {
+				log.Error(err, "unable to update revision", "Phase", "PropagateTaintThreshold", "Name/Namespace", reconcilerutil.ObjectKey(ecr))
+				return ctrl.Result{}, err
+			}
+			log.Info("updated the taint threshold of EnvoyConfigRevision", "Namespace/Name", reconcilerutil.ObjectKey(ecr))
+		}
+	}
+
 	r.revisionList = revisions.SortByPublication(r.DesiredVersion(), list)
 	publishedVersion, cacheState := r.getVersionToPublish()
+
+	if r.Instance().Spec.PinnedVersion != nil {
+		if pinnedVersion, err := r.getPinnedVersion(); err != nil {
+			log.Error(err, "unable to publish the pinned version", "Phase", "PinRevision")
+			r.pinError = err
+			// a version is pinned to stop the rollout of the other ones, so if
+			// it can't be published the currently published revision is kept
+			publishedVersion, cacheState = r.currentlyPublishedVersion(), marin3rv1alpha1.PinnedState
+		} else {
+			publishedVersion, cacheState = pinnedVersion, marin3rv1alpha1.PinnedState
+		}
+	}
+
+	var requeueAfter time.Duration
+	canaryVersion, stableVersion, remaining := r.getCanaryVersion(cacheState, time.Now())
+	if canaryVersion != "" {
+		publishedVersion, cacheState, requeueAfter = stableVersion, marin3rv1alpha1.CanaryState, remaining
+	}
 	r.cacheState = &cacheState
 	r.publishedVersion = &publishedVersion
+	r.canaryVersion = &canaryVersion
+
+	var canaryTarget *marin3rv1alpha1.CanaryTarget
+	if canaryVersion != "" {
+		if canaryTarget, err = r.getCanaryTarget(); err != nil {
+			log.Error(err, "unable to compute canary target", "Phase", "ReconcileCanaryRevision")
+			return ctrl.Result{}, err
+		}
+	}
+	canaryUpdates := r.isRevisionCanaryConditionReconciled(canaryVersion, canaryTarget)
 
 	shouldBeTrue, shouldBeFalse := r.isRevisionPublishedConditionReconciled(r.PublishedVersion())
 
@@ -147,6 +227,7 @@ func (r *RevisionReconciler) Reconcile() (ctrl.Result, error) {
 			log.Error(err, "unable to update revision", "Phase", "UnpublishOldRevisions", "Name/Namespace", reconcilerutil.ObjectKey(&ecr))
 			return ctrl.Result{}, err
 		}
+		delete(canaryUpdates, ecr.GetName())
 	}
 
 	if shouldBeTrue != nil {
@@ -155,9 +236,27 @@ func (r *RevisionReconciler) Reconcile() (ctrl.Result, error) {
 			return ctrl.Result{}, err
 		}
 		log.Info("updated the published EnvoyConfigRevision", "Namespace/Name", reconcilerutil.ObjectKey(shouldBeTrue))
+		delete(canaryUpdates, shouldBeTrue.GetName())
+	}
+
+	// Revisions that only had the canary status changed are updated after the published
+	// ones so a promoted canary never stops being served before being published
+	for idx := range r.revisionList.Items {
+		ecr := &r.revisionList.Items[idx]
+		if _, ok := canaryUpdates[ecr.GetName()]; !ok {
+			continue
+		}
+		if err := r.client.Status().Update(r.ctx, ecr); err != nil {
+			log.Error(err, "unable to update revision", "Phase", "ReconcileCanaryRevision", "Name/Namespace", reconcilerutil.ObjectKey(ecr))
+			return ctrl.Result{}, err
+		}
+		log.Info("updated the canary status of EnvoyConfigRevision", "Namespace/Name", reconcilerutil.ObjectKey(ecr))
 	}
 
-	shouldBeDeleted := r.isRevisionRetentionReconciled(maxRevisions)
+	shouldBeDeleted := append(
+		r.isRevisionRetentionReconciled(r.Instance().GetRevisionHistory().GetLimit()),
+		r.isRevisionMaxAgeReconciled(r.Instance().GetRevisionHistory().GetMaxAge(), time.Now())...,
+	)
 	for _, ecr := range shouldBeDeleted {
 		if err := r.client.Delete(r.ctx, &ecr); err != nil {
 			log.Error(err, "unable to delete revision", "Phase", "ApplyRevisionRetention", "Name/Namespace", reconcilerutil.ObjectKey(&ecr))
@@ -167,7 +266,7 @@ func (r *RevisionReconciler) Reconcile() (ctrl.Result, error) {
 	}
 
 	log.Info(fmt.Sprintf("CacheState is %s after revision reconcile", cacheState))
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 // getVersionToPublish takes an EnvoyConfigRevisionList and returns the version that should be
@@ -199,6 +298,164 @@ func (r *RevisionReconciler) getVersionToPublish() (string, string) {
 
 }
 
+// getPinnedVersion returns the pinned version if the revision for it exists and is not
+// tainted. Otherwise an error explaining why the pinned version cannot be published is returned.
+func (r *RevisionReconciler) getPinnedVersion() (string, error) {
+	pinnedVersion := r.pinnedVersion()
+
+	for _, ecr := range r.revisionList.Items {
+		if ecr.Spec.Version == pinnedVersion {
+			if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition) {
+				return "", fmt.Errorf("the revision for pinned version '%s' is tainted", pinnedVersion)
+			}
+			return pinnedVersion, nil
+		}
+	}
+
+	return "", fmt.Errorf("the revision for pinned version '%s' does not exist", pinnedVersion)
+}
+
+// currentlyPublishedVersion returns the version of the revision
+// that is currently published, or an empty string if none is
+func (r *RevisionReconciler) currentlyPublishedVersion() string {
+	for _, ecr := range r.revisionList.Items {
+		if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
+			return ecr.Spec.Version
+		}
+	}
+	return ""
+}
+
+// pinnedVersion returns the pinned version, or an empty string if none
+func (r *RevisionReconciler) pinnedVersion() string {
+	if r.ec == nil || r.ec.Spec.PinnedVersion == nil {
+		return ""
+	}
+	return *r.ec.Spec.PinnedVersion
+}
+
+// getCanaryVersion returns the version that should be published as a canary, if any, and the
+// version that should be published to the rest of the envoy clients while the canary is analyzed.
+// A new revision is published as a canary only if the EnvoyConfig has a canary rollout strategy,
+// the revision has never been published and there is a previous untainted revision that has been
+// published before to fall back to. The canary is not promoted when the analysis period is over
+// if no envoy client has received it, as it has not been analyzed. The third return value is the
+// remaining time of the canary analysis period.
+func (r *RevisionReconciler) getCanaryVersion(cacheState string, now time.Time) (string, string, time.Duration) {
+	strategy := r.Instance().GetCanaryRolloutStrategy()
+	if strategy == nil || cacheState != marin3rv1alpha1.InSyncState {
+		return "", "", 0
+	}
+
+	topIdx := len(r.revisionList.Items) - 1
+	top := r.revisionList.Items[topIdx]
+	if top.Status.LastPublishedAt != nil || meta.IsStatusConditionTrue(top.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
+		return "", "", 0
+	}
+
+	remaining := strategy.GetAnalysisPeriod()
+	if cond := meta.FindStatusCondition(top.Status.Conditions, marin3rv1alpha1.RevisionCanaryCondition); cond != nil && cond.Status == metav1.ConditionTrue {
+		remaining = cond.LastTransitionTime.Add(strategy.GetAnalysisPeriod()).Sub(now)
+	}
+	if remaining <= 0 {
+		if r.hasCanaryClients(&top) {
+			// analysis period is over, promote the canary
+			return "", "", 0
+		}
+		r.logger.Info("canary revision has not been received by any envoy client, promotion postponed",
+			"Version", top.Spec.Version)
+		remaining = canaryRecheckInterval
+	}
+
+	// only fall back to revisions that have already been served to the envoy clients
+	for idx := topIdx - 1; idx >= 0; idx-- {
+		ecr := r.revisionList.Items[idx]
+		if ecr.Status.LastPublishedAt != nil &&
+			!meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition) {
+			return top.Spec.Version, ecr.Spec.Version, remaining
+		}
+	}
+
+	return "", "", 0
+}
+
+// hasCanaryClients returns true if any of the envoy clients subscribed to the
+// discovery service is selected by the canary target of the given revision
+func (r *RevisionReconciler) hasCanaryClients(ecr *marin3rv1alpha1.EnvoyConfigRevision) bool {
+	if r.dStats == nil {
+		return true
+	}
+	target := envoyconfigrevision.CanaryTarget(ecr)
+	for pod := range r.dStats.GetSubscribedPods(r.xdsNodeID, "") {
+		if target.Selects(pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// getCanaryTarget returns the envoy clients that should receive the canary revision
+func (r *RevisionReconciler) getCanaryTarget() (*marin3rv1alpha1.CanaryTarget, error) {
+	strategy := r.Instance().GetCanaryRolloutStrategy()
+
+	if strategy.Selector == nil {
+		return &marin3rv1alpha1.CanaryTarget{Percentage: strategy.Percentage}, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(strategy.Selector)
+	if err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := r.client.List(r.ctx, pods, client.InNamespace(r.Namespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	target := &marin3rv1alpha1.CanaryTarget{Pods: make([]string, 0, len(pods.Items))}
+	for _, pod := range pods.Items {
+		target.Pods = append(target.Pods, pod.GetName())
+	}
+	sort.Strings(target.Pods)
+
+	return target, nil
+}
+
+// isRevisionCanaryConditionReconciled sets the RevisionCanary condition and the canary target in the
+// revision with the given canary version and removes them from any other revision. The revisions are
+// modified in the revision list and the names of the revisions that need to be updated are returned.
+func (r *RevisionReconciler) isRevisionCanaryConditionReconciled(canaryVersion string, target *marin3rv1alpha1.CanaryTarget) map[string]struct{} {
+
+	updates := map[string]struct{}{}
+	for idx := range r.revisionList.Items {
+		ecr := &r.revisionList.Items[idx]
+
+		if ecr.Spec.Version != canaryVersion {
+			if meta.FindStatusCondition(ecr.Status.Conditions, marin3rv1alpha1.RevisionCanaryCondition) != nil || ecr.Status.Canary != nil {
+				meta.RemoveStatusCondition(&ecr.Status.Conditions, marin3rv1alpha1.RevisionCanaryCondition)
+				ecr.Status.Canary = nil
+				updates[ecr.GetName()] = struct{}{}
+			}
+			continue
+		}
+
+		if !meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionCanaryCondition) {
+			meta.SetStatusCondition(&ecr.Status.Conditions, metav1.Condition{
+				Type:    marin3rv1alpha1.RevisionCanaryCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  "CanaryPublished",
+				Message: fmt.Sprintf("Version '%s' has been published as a canary", canaryVersion),
+			})
+			updates[ecr.GetName()] = struct{}{}
+		}
+		if !equality.Semantic.DeepEqual(ecr.Status.Canary, target) {
+			ecr.Status.Canary = target
+			updates[ecr.GetName()] = struct{}{}
+		}
+	}
+
+	return updates
+}
+
 // isRevisionPublishedConditionReconciled returns the revisions that need the RevisionPublished condition reconciled.
 // As the first return value returns the EnvoyConfigRevision that needs the condition set to true, nil if update
 // not required. As the second return value returns a list of the EnvoyConfigRevisions that need the condition
@@ -232,19 +489,58 @@ func (r *RevisionReconciler) isRevisionPublishedConditionReconciled(versionToPub
 }
 
 // isRevisionRetentionReconciled removes items from the revisionList until the list holds the number of items
-// determined by the 'retention' parameter
+// determined by the 'retention' parameter. The pinned revision is never removed.
 func (r *RevisionReconciler) isRevisionRetentionReconciled(retention int) []marin3rv1alpha1.EnvoyConfigRevision {
 
 	var toBeDeleted []marin3rv1alpha1.EnvoyConfigRevision = []marin3rv1alpha1.EnvoyConfigRevision{}
 	var revisionList *[]marin3rv1alpha1.EnvoyConfigRevision = &(r.GetRevisionList().Items)
 
 	for len(*revisionList) > retention {
+		// the pinned revision is never removed
+		if pinned := r.pinnedVersion(); pinned != "" && (*revisionList)[0].Spec.Version == pinned {
+			if len(*revisionList) == 1 {
+				break
+			}
+			rest := (*revisionList)[1:]
+			toBeDeleted = append(toBeDeleted, popRevision(&rest))
+			*revisionList = append([]marin3rv1alpha1.EnvoyConfigRevision{(*revisionList)[0]}, rest...)
+			continue
+		}
 		toBeDeleted = append(toBeDeleted, popRevision(revisionList))
 	}
 
 	return toBeDeleted
 }
 
+// isRevisionMaxAgeReconciled removes items from the revisionList that were last published, or created if never
+// published, longer than 'maxAge' ago. The published, canary and pinned revisions and the revision for the desired
+// resources are never removed. A zero 'maxAge' disables removal by age.
+func (r *RevisionReconciler) isRevisionMaxAgeReconciled(maxAge time.Duration, now time.Time) []marin3rv1alpha1.EnvoyConfigRevision {
+
+	var toBeDeleted []marin3rv1alpha1.EnvoyConfigRevision = []marin3rv1alpha1.EnvoyConfigRevision{}
+	if maxAge <= 0 {
+		return toBeDeleted
+	}
+
+	keep := make([]marin3rv1alpha1.EnvoyConfigRevision, 0, len(r.revisionList.Items))
+	for _, ecr := range r.revisionList.Items {
+		lastSeen := ecr.GetCreationTimestamp()
+		if !ecr.Status.LastPublishedAt.IsZero() {
+			lastSeen = *ecr.Status.LastPublishedAt
+		}
+
+		if ecr.Spec.Version != r.DesiredVersion() && ecr.Spec.Version != r.PublishedVersion() &&
+			ecr.Spec.Version != r.CanaryVersion() && ecr.Spec.Version != r.pinnedVersion() && now.Sub(lastSeen.Time) > maxAge {
+			toBeDeleted = append(toBeDeleted, ecr)
+		} else {
+			keep = append(keep, ecr)
+		}
+	}
+	r.revisionList.Items = keep
+
+	return toBeDeleted
+}
+
 // popRevision removes an EnvoyConfigRevision from a list of EnvoyConfigRevision resources, starting from
 // the lowest index in the slice. The removed element is returned as a return value and the list is
 // modified "in place".
@@ -268,10 +564,12 @@ func (r *RevisionReconciler) newRevisionForCurrentResources() *marin3rv1alpha1.E
 			},
 		},
 		Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
-			NodeID:    r.NodeID(),
-			EnvoyAPI:  pointer.New(r.EnvoyAPI()),
-			Version:   r.DesiredVersion(),
-			Resources: r.Instance().Spec.Resources,
+			NodeID:         r.NodeID(),
+			EnvoyAPI:       pointer.New(r.EnvoyAPI()),
+			Version:        r.DesiredVersion(),
+			Resources:      r.Instance().Spec.Resources,
+			Parameters:     r.Instance().Spec.Parameters,
+			TaintThreshold: r.Instance().GetRevisionHistory().GetTaintThreshold().DeepCopy(),
 		},
 	}
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	reconcilerutil "github.com/3scale-ops/basereconciler/util"
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
 	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfig/filters"
 	k8sutil "github.com/3scale-ops/marin3r/pkg/util/k8s"
@@ -30,13 +32,14 @@ func init() {
 		&marin3rv1alpha1.EnvoyConfigRevision{},
 		&marin3rv1alpha1.EnvoyConfigRevisionList{},
 		&marin3rv1alpha1.EnvoyConfig{},
+		&marin3rv1alpha1.EnvoyResourceLibrary{},
 	)
 }
 
 func testRevisionReconcilerBuilder(s *runtime.Scheme, instance *marin3rv1alpha1.EnvoyConfig, objs ...client.Object) RevisionReconciler {
 	return RevisionReconciler{context.TODO(), ctrl.Log.WithName("test"),
 		fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(&marin3rv1alpha1.EnvoyConfig{}).WithStatusSubresource(&marin3rv1alpha1.EnvoyConfigRevision{}).Build(),
-		s, instance, nil, nil, nil, nil}
+		s, instance, nil, nil, nil, nil, nil, nil, nil, ""}
 }
 
 func TestNewRevisionReconciler(t *testing.T) {
@@ -55,7 +58,7 @@ func TestNewRevisionReconciler(t *testing.T) {
 		{
 			name: "Returns a RevisionReconciler",
 			args: args{context.TODO(), logr.Logger{}, fake.NewFakeClient(), s, nil},
-			want: RevisionReconciler{context.TODO(), logr.Logger{}, fake.NewFakeClient(), s, nil, nil, nil, nil, nil},
+			want: RevisionReconciler{context.TODO(), logr.Logger{}, fake.NewFakeClient(), s, nil, nil, nil, nil, nil, nil, nil, nil, ""},
 		},
 	}
 	for _, tt := range tests {
@@ -492,6 +495,58 @@ func TestRevisionReconciler_Reconcile(t *testing.T) {
 	}
 }
 
+func TestRevisionReconciler_Reconcile_pinFailed(t *testing.T) {
+	revision := func(name, version string, published bool) *marin3rv1alpha1.EnvoyConfigRevision {
+		ecr := &marin3rv1alpha1.EnvoyConfigRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "test",
+				Labels: map[string]string{
+					filters.NodeIDTag:   "node",
+					filters.EnvoyAPITag: envoy.APIv3.String(),
+					filters.VersionTag:  version,
+				},
+			},
+			Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{NodeID: "node", Version: version, EnvoyAPI: pointer.New(envoy.APIv3)},
+		}
+		if published {
+			ecr.Status.Conditions = []metav1.Condition{{Type: marin3rv1alpha1.RevisionPublishedCondition, Status: metav1.ConditionTrue}}
+		}
+		return ecr
+	}
+	desired := reconcilerutil.Hash([]marin3rv1alpha1.Resource{})
+	cl := fake.NewClientBuilder().WithScheme(s).
+		WithObjects(revision("ecr1", "aaaa", true), revision("ecr2", desired, false)).
+		WithStatusSubresource(&marin3rv1alpha1.EnvoyConfigRevision{}).
+		Build()
+	r := NewRevisionReconciler(context.TODO(), ctrl.Log.WithName("test"), cl, s, &marin3rv1alpha1.EnvoyConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "test"},
+		Spec: marin3rv1alpha1.EnvoyConfigSpec{
+			NodeID:        "node",
+			EnvoyAPI:      pointer.New(envoy.APIv3),
+			Resources:     []marin3rv1alpha1.Resource{},
+			PinnedVersion: pointer.New("xxxx"),
+		},
+	})
+
+	if _, err := r.Reconcile(); err != nil {
+		t.Fatalf("RevisionReconciler.Reconcile() error = %v", err)
+	}
+	if r.PinError() == nil {
+		t.Errorf("RevisionReconciler.Reconcile() pin error = nil")
+	}
+	if r.PublishedVersion() != "aaaa" || r.GetCacheState() != marin3rv1alpha1.PinnedState {
+		t.Errorf("RevisionReconciler.Reconcile() published = %v/%v, want the currently published revision",
+			r.PublishedVersion(), r.GetCacheState())
+	}
+	ecr := &marin3rv1alpha1.EnvoyConfigRevision{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Name: "ecr2", Namespace: "test"}, ecr); err != nil {
+		t.Fatal(err)
+	}
+	if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
+		t.Errorf("RevisionReconciler.Reconcile() published the desired version while the pin failed")
+	}
+}
+
 func TestRevisionReconciler_getVersionToPublish(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -563,6 +618,371 @@ func TestRevisionReconciler_getVersionToPublish(t *testing.T) {
 	}
 }
 
+func TestRevisionReconciler_getPinnedVersion(t *testing.T) {
+	revisionList := &marin3rv1alpha1.EnvoyConfigRevisionList{
+		Items: []marin3rv1alpha1.EnvoyConfigRevision{
+			{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"},
+				Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+					Conditions: []metav1.Condition{{
+						Type:   marin3rv1alpha1.RevisionTaintedCondition,
+						Status: metav1.ConditionTrue,
+					}}}},
+			{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "bbbb"}},
+			{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "cccc"}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		pinnedVersion string
+		want          string
+		wantErr       bool
+	}{
+		{
+			name:          "Returns the pinned version",
+			pinnedVersion: "bbbb",
+			want:          "bbbb",
+			wantErr:       false,
+		},
+		{
+			name:          "Fails if the pinned revision is tainted",
+			pinnedVersion: "aaaa",
+			want:          "",
+			wantErr:       true,
+		},
+		{
+			name:          "Fails if the pinned revision does not exist",
+			pinnedVersion: "xxxx",
+			want:          "",
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRevisionReconcilerBuilder(s, &marin3rv1alpha1.EnvoyConfig{
+				Spec: marin3rv1alpha1.EnvoyConfigSpec{PinnedVersion: pointer.New(tt.pinnedVersion)}})
+			r.revisionList = revisionList
+			got, err := r.getPinnedVersion()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RevisionReconciler.getPinnedVersion() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RevisionReconciler.getPinnedVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevisionReconciler_getCanaryVersion(t *testing.T) {
+	now := time.Now()
+	published := marin3rv1alpha1.EnvoyConfigRevision{
+		Spec:   marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"},
+		Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{LastPublishedAt: pointer.New(metav1.NewTime(now.Add(-time.Hour)))},
+	}
+	canaryExpired := marin3rv1alpha1.EnvoyConfigRevision{
+		Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+		Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+			Conditions: []metav1.Condition{{
+				Type:               marin3rv1alpha1.RevisionCanaryCondition,
+				Status:             metav1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(now.Add(-11 * time.Minute)),
+			}},
+			Canary: &marin3rv1alpha1.CanaryTarget{Pods: []string{"pod1"}},
+		}}
+	canary := &marin3rv1alpha1.EnvoyConfig{
+		Spec: marin3rv1alpha1.EnvoyConfigSpec{
+			RolloutStrategy: &marin3rv1alpha1.RolloutStrategy{
+				Canary: &marin3rv1alpha1.CanaryRolloutStrategy{
+					Percentage:     pointer.New(int32(10)),
+					AnalysisPeriod: &metav1.Duration{Duration: 10 * time.Minute},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name          string
+		ec            *marin3rv1alpha1.EnvoyConfig
+		revisionList  *marin3rv1alpha1.EnvoyConfigRevisionList
+		cacheState    string
+		wantCanary    string
+		wantStable    string
+		wantRemaining time.Duration
+		dStats        *stats.Stats
+	}{
+		{
+			name: "No rollout strategy, no canary",
+			ec:   &marin3rv1alpha1.EnvoyConfig{},
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					published,
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"}},
+				},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+		},
+		{
+			name: "New revision is published as a canary",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					published,
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"}},
+				},
+			},
+			cacheState:    marin3rv1alpha1.InSyncState,
+			wantCanary:    "xxxx",
+			wantStable:    "aaaa",
+			wantRemaining: 10 * time.Minute,
+		},
+		{
+			name: "Canary within the analysis period",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					published,
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Conditions: []metav1.Condition{{
+								Type:               marin3rv1alpha1.RevisionCanaryCondition,
+								Status:             metav1.ConditionTrue,
+								LastTransitionTime: metav1.NewTime(now.Add(-4 * time.Minute)),
+							}}}},
+				},
+			},
+			cacheState:    marin3rv1alpha1.InSyncState,
+			wantCanary:    "xxxx",
+			wantStable:    "aaaa",
+			wantRemaining: 6 * time.Minute,
+		},
+		{
+			name: "Canary analysis period is over, promote",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					published,
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Conditions: []metav1.Condition{{
+								Type:               marin3rv1alpha1.RevisionCanaryCondition,
+								Status:             metav1.ConditionTrue,
+								LastTransitionTime: metav1.NewTime(now.Add(-11 * time.Minute)),
+							}}}},
+				},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+		},
+		{
+			name: "Canary analysis period is over but no envoy client received the canary, do not promote",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{published, canaryExpired},
+			},
+			cacheState:    marin3rv1alpha1.InSyncState,
+			dStats:        testStats("pod2"),
+			wantCanary:    "xxxx",
+			wantStable:    "aaaa",
+			wantRemaining: canaryRecheckInterval,
+		},
+		{
+			name: "Canary analysis period is over and envoy clients received the canary, promote",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{published, canaryExpired},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+			dStats:     testStats("pod1", "pod2"),
+		},
+		{
+			name: "Canary analysis period is over but only clients of another nodeID received the canary, do not promote",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{published, canaryExpired},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+			dStats: func() *stats.Stats {
+				s := testStats("pod2")
+				s.ReportRequest("node-internal", "type.googleapis.com/envoy.config.cluster.v3.Cluster", "pod1")
+				return s
+			}(),
+			wantCanary:    "xxxx",
+			wantStable:    "aaaa",
+			wantRemaining: canaryRecheckInterval,
+		},
+		{
+			name: "Previous revision was never published, no canary",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"}},
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"}},
+				},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+		},
+		{
+			name: "Revision already published, no canary",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					published,
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Conditions: []metav1.Condition{{
+								Type:   marin3rv1alpha1.RevisionPublishedCondition,
+								Status: metav1.ConditionTrue,
+							}}}},
+				},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+		},
+		{
+			name: "No untainted revision to fall back to, no canary",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Conditions: []metav1.Condition{{
+								Type:   marin3rv1alpha1.RevisionTaintedCondition,
+								Status: metav1.ConditionTrue,
+							}}}},
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"}},
+				},
+			},
+			cacheState: marin3rv1alpha1.InSyncState,
+		},
+		{
+			name: "Rollback, no canary",
+			ec:   canary,
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					published,
+					{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Conditions: []metav1.Condition{{
+								Type:   marin3rv1alpha1.RevisionTaintedCondition,
+								Status: metav1.ConditionTrue,
+							}}}},
+				},
+			},
+			cacheState: marin3rv1alpha1.RollbackState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRevisionReconcilerBuilder(s, tt.ec)
+			r.revisionList = tt.revisionList
+			r.SetDiscoveryStats(tt.dStats, "node")
+			gotCanary, gotStable, gotRemaining := r.getCanaryVersion(tt.cacheState, now)
+			if gotCanary != tt.wantCanary {
+				t.Errorf("RevisionReconciler.getCanaryVersion() got = %v, want %v", gotCanary, tt.wantCanary)
+			}
+			if gotStable != tt.wantStable {
+				t.Errorf("RevisionReconciler.getCanaryVersion() got1 = %v, want %v", gotStable, tt.wantStable)
+			}
+			if gotRemaining.Round(time.Second) != tt.wantRemaining {
+				t.Errorf("RevisionReconciler.getCanaryVersion() got2 = %v, want %v", gotRemaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+// testStats returns discovery stats with the given pods subscribed to the "node" nodeID
+func testStats(pods ...string) *stats.Stats {
+	s := stats.New()
+	for _, pod := range pods {
+		s.ReportRequest("node", "type.googleapis.com/envoy.config.cluster.v3.Cluster", pod)
+	}
+	return s
+}
+
+func TestRevisionReconciler_isRevisionCanaryConditionReconciled(t *testing.T) {
+	tests := []struct {
+		name          string
+		revisionList  *marin3rv1alpha1.EnvoyConfigRevisionList
+		canaryVersion string
+		target        *marin3rv1alpha1.CanaryTarget
+		want          map[string]struct{}
+	}{
+		{
+			name: "Sets the canary condition",
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr1"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr2"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"}},
+				},
+			},
+			canaryVersion: "xxxx",
+			target:        &marin3rv1alpha1.CanaryTarget{Percentage: pointer.New(int32(10))},
+			want:          map[string]struct{}{"ecr2": {}},
+		},
+		{
+			name: "Updates the canary target",
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr1"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr2"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Canary: &marin3rv1alpha1.CanaryTarget{Pods: []string{"pod1"}},
+							Conditions: []metav1.Condition{{
+								Type:   marin3rv1alpha1.RevisionCanaryCondition,
+								Status: metav1.ConditionTrue,
+							}}}},
+				},
+			},
+			canaryVersion: "xxxx",
+			target:        &marin3rv1alpha1.CanaryTarget{Pods: []string{"pod1", "pod2"}},
+			want:          map[string]struct{}{"ecr2": {}},
+		},
+		{
+			name: "Removes the canary condition",
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr1"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr2"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "xxxx"},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Canary: &marin3rv1alpha1.CanaryTarget{Pods: []string{"pod1"}},
+							Conditions: []metav1.Condition{{
+								Type:   marin3rv1alpha1.RevisionCanaryCondition,
+								Status: metav1.ConditionTrue,
+							}}}},
+				},
+			},
+			canaryVersion: "",
+			want:          map[string]struct{}{"ecr2": {}},
+		},
+		{
+			name: "Nothing to do",
+			revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr1"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"}},
+				},
+			},
+			canaryVersion: "",
+			want:          map[string]struct{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRevisionReconcilerBuilder(s, &marin3rv1alpha1.EnvoyConfig{})
+			r.revisionList = tt.revisionList
+			if got := r.isRevisionCanaryConditionReconciled(tt.canaryVersion, tt.target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RevisionReconciler.isRevisionCanaryConditionReconciled() = %v, want %v", got, tt.want)
+			}
+			for _, ecr := range r.revisionList.Items {
+				if ecr.Spec.Version == tt.canaryVersion {
+					if !ecr.Status.IsCanary() || !reflect.DeepEqual(ecr.Status.Canary, tt.target) {
+						t.Errorf("RevisionReconciler.isRevisionCanaryConditionReconciled() revision %s is not a canary", ecr.GetName())
+					}
+				} else if meta.FindStatusCondition(ecr.Status.Conditions, marin3rv1alpha1.RevisionCanaryCondition) != nil || ecr.Status.Canary != nil {
+					t.Errorf("RevisionReconciler.isRevisionCanaryConditionReconciled() revision %s is a canary", ecr.GetName())
+				}
+			}
+		})
+	}
+}
+
 func TestRevisionReconciler_isRevisionPublishedConditionReconciled(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -853,6 +1273,30 @@ func TestRevisionReconciler_isRevisionRetentionReconciled(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "The pinned revision is not trimmed",
+			fields: fields{nil, logr.Logger{}, nil, nil,
+				&marin3rv1alpha1.EnvoyConfig{Spec: marin3rv1alpha1.EnvoyConfigSpec{PinnedVersion: pointer.New("ecr1")}},
+				nil, nil, nil,
+				&marin3rv1alpha1.EnvoyConfigRevisionList{
+					Items: []marin3rv1alpha1.EnvoyConfigRevision{
+						{ObjectMeta: metav1.ObjectMeta{Name: "ecr1"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "ecr1"}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "ecr2"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "ecr2"}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "ecr3"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "ecr3"}},
+					},
+				},
+			},
+			args: args{retention: 2},
+			wantTrimmed: []marin3rv1alpha1.EnvoyConfigRevision{
+				{ObjectMeta: metav1.ObjectMeta{Name: "ecr2"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "ecr2"}},
+			},
+			wantList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+				Items: []marin3rv1alpha1.EnvoyConfigRevision{
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr1"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "ecr1"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "ecr3"}, Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "ecr3"}},
+				},
+			},
+		},
 		{
 			name: "List is not modified if elements within 'retention' parameter",
 			fields: fields{nil, logr.Logger{}, nil, nil, nil, nil, nil, nil,
@@ -894,6 +1338,75 @@ func TestRevisionReconciler_isRevisionRetentionReconciled(t *testing.T) {
 	}
 }
 
+func TestRevisionReconciler_isRevisionMaxAgeReconciled(t *testing.T) {
+	now := time.Now()
+	old := metav1.NewTime(now.Add(-2 * time.Hour))
+	recent := metav1.NewTime(now.Add(-30 * time.Minute))
+
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		wantDeleted []string
+		wantKept    []string
+	}{
+		{
+			name:        "Max age disabled",
+			maxAge:      0,
+			wantDeleted: []string{},
+			wantKept:    []string{"old", "old-recently-published", "published", "recent", "desired"},
+		},
+		{
+			name:        "Deletes revisions older than max age",
+			maxAge:      time.Hour,
+			wantDeleted: []string{"old"},
+			wantKept:    []string{"old-recently-published", "published", "recent", "desired"},
+		},
+		{
+			name:        "Never deletes the published or desired revisions",
+			maxAge:      time.Minute,
+			wantDeleted: []string{"old", "old-recently-published", "recent"},
+			wantKept:    []string{"published", "desired"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RevisionReconciler{
+				desiredVersion:   pointer.New("desired"),
+				publishedVersion: pointer.New("published"),
+				revisionList: &marin3rv1alpha1.EnvoyConfigRevisionList{
+					Items: []marin3rv1alpha1.EnvoyConfigRevision{
+						{ObjectMeta: metav1.ObjectMeta{Name: "old", CreationTimestamp: old},
+							Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "old"}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "old-recently-published", CreationTimestamp: old},
+							Spec:   marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "old-recently-published"},
+							Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{LastPublishedAt: &recent}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "published", CreationTimestamp: old},
+							Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "published"}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "recent", CreationTimestamp: recent},
+							Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "recent"}},
+						{ObjectMeta: metav1.ObjectMeta{Name: "desired", CreationTimestamp: old},
+							Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "desired"}},
+					},
+				},
+			}
+			gotDeleted := []string{}
+			for _, ecr := range r.isRevisionMaxAgeReconciled(tt.maxAge, now) {
+				gotDeleted = append(gotDeleted, ecr.GetName())
+			}
+			if !reflect.DeepEqual(gotDeleted, tt.wantDeleted) {
+				t.Errorf("RevisionReconciler.isRevisionMaxAgeReconciled() = %v, want %v", gotDeleted, tt.wantDeleted)
+			}
+			gotKept := []string{}
+			for _, ecr := range r.GetRevisionList().Items {
+				gotKept = append(gotKept, ecr.GetName())
+			}
+			if !reflect.DeepEqual(gotKept, tt.wantKept) {
+				t.Errorf("RevisionReconciler.isRevisionMaxAgeReconciled() kept = %v, want %v", gotKept, tt.wantKept)
+			}
+		})
+	}
+}
+
 func Test_popRevision(t *testing.T) {
 	type args struct {
 		list *[]marin3rv1alpha1.EnvoyConfigRevision
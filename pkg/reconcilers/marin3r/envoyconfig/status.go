@@ -10,7 +10,7 @@ import (
 )
 
 // IsStatusReconciled calculates the status of the resource
-func IsStatusReconciled(ec *marin3rv1alpha1.EnvoyConfig, cacheState, publishedVersion string, list *marin3rv1alpha1.EnvoyConfigRevisionList) bool {
+func IsStatusReconciled(ec *marin3rv1alpha1.EnvoyConfig, cacheState, publishedVersion, canaryVersion string, pinError error, list *marin3rv1alpha1.EnvoyConfigRevisionList) bool {
 
 	ok := true
 
@@ -32,6 +32,14 @@ func IsStatusReconciled(ec *marin3rv1alpha1.EnvoyConfig, cacheState, publishedVe
 		ok = false
 	}
 
+	if canaryVersion == "" && ec.Status.CanaryVersion != nil {
+		ec.Status.CanaryVersion = nil
+		ok = false
+	} else if canaryVersion != "" && (ec.Status.CanaryVersion == nil || *ec.Status.CanaryVersion != canaryVersion) {
+		ec.Status.CanaryVersion = &canaryVersion
+		ok = false
+	}
+
 	if ec.Status.CacheState == nil || *ec.Status.CacheState != cacheState {
 		ec.Status.CacheState = &cacheState
 		ok = false
@@ -42,8 +50,10 @@ func IsStatusReconciled(ec *marin3rv1alpha1.EnvoyConfig, cacheState, publishedVe
 		ok = false
 	}
 
-	// Reconcile the CacheOutOfSyncCondition
-	if desiredVersion != publishedVersion && !meta.IsStatusConditionTrue(ec.Status.Conditions, marin3rv1alpha1.CacheOutOfSyncCondition) {
+	// Reconcile the CacheOutOfSyncCondition. The desired version not being
+	// published while it is being analyzed as a canary or while a version is pinned is expected.
+	if desiredVersion != publishedVersion && cacheState != marin3rv1alpha1.CanaryState &&
+		cacheState != marin3rv1alpha1.PinnedState && !meta.IsStatusConditionTrue(ec.Status.Conditions, marin3rv1alpha1.CacheOutOfSyncCondition) {
 		meta.SetStatusCondition(&ec.Status.Conditions, metav1.Condition{
 			Type:    marin3rv1alpha1.CacheOutOfSyncCondition,
 			Status:  metav1.ConditionTrue,
@@ -82,6 +92,29 @@ func IsStatusReconciled(ec *marin3rv1alpha1.EnvoyConfig, cacheState, publishedVe
 		ok = false
 	}
 
+	// Reconcile the PinFailedCondition
+	if pinError != nil {
+		if cond := meta.FindStatusCondition(ec.Status.Conditions, marin3rv1alpha1.PinFailedCondition); cond == nil ||
+			cond.Status != metav1.ConditionTrue || cond.Message != pinError.Error() {
+			meta.SetStatusCondition(&ec.Status.Conditions, metav1.Condition{
+				Type:    marin3rv1alpha1.PinFailedCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  "CantPublishPinnedVersion",
+				Message: pinError.Error(),
+			})
+			ok = false
+		}
+
+	} else if meta.IsStatusConditionTrue(ec.Status.Conditions, marin3rv1alpha1.PinFailedCondition) {
+		meta.SetStatusCondition(&ec.Status.Conditions, metav1.Condition{
+			Type:    marin3rv1alpha1.PinFailedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Recovered",
+			Message: "Recovered from PinFailed condition",
+		})
+		ok = false
+	}
+
 	// Temporary fix for RollbackFailedCondition conditions that are missing  the .Message property, which
 	// will be required in an upcoming release
 	if cond := meta.FindStatusCondition(ec.Status.Conditions, marin3rv1alpha1.RollbackFailedCondition); cond != nil && cond.Message == "" {
@@ -1,6 +1,7 @@
 package reconcilers
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
@@ -15,6 +16,8 @@ func TestIsStatusReconciled(t *testing.T) {
 		ec               *marin3rv1alpha1.EnvoyConfig
 		cacheState       string
 		publishedVersion string
+		canaryVersion    string
+		pinError         error
 		list             *marin3rv1alpha1.EnvoyConfigRevisionList
 	}
 	tests := []struct {
@@ -57,6 +60,51 @@ func TestIsStatusReconciled(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "Canary status already up to date, returns true",
+			args: args{
+				ec: &marin3rv1alpha1.EnvoyConfig{
+					Status: marin3rv1alpha1.EnvoyConfigStatus{
+						DesiredVersion:   pointer.New("6758fd786c"),
+						PublishedVersion: pointer.New("1"),
+						CanaryVersion:    pointer.New("6758fd786c"),
+						CacheState:       pointer.New(marin3rv1alpha1.CanaryState),
+						ConfigRevisions:  []marin3rv1alpha1.ConfigRevisionRef{},
+						Conditions: []metav1.Condition{
+							{Type: marin3rv1alpha1.CacheOutOfSyncCondition, Status: metav1.ConditionFalse, Message: "a"},
+							{Type: marin3rv1alpha1.RollbackFailedCondition, Status: metav1.ConditionFalse, Message: "a"},
+						},
+					},
+				},
+				cacheState:       marin3rv1alpha1.CanaryState,
+				publishedVersion: "1",
+				canaryVersion:    "6758fd786c",
+				list:             &marin3rv1alpha1.EnvoyConfigRevisionList{},
+			},
+			want: true,
+		},
+		{
+			name: "CanaryVersion needs to be removed, returns false",
+			args: args{
+				ec: &marin3rv1alpha1.EnvoyConfig{
+					Status: marin3rv1alpha1.EnvoyConfigStatus{
+						DesiredVersion:   pointer.New("6758fd786c"),
+						PublishedVersion: pointer.New("6758fd786c"),
+						CanaryVersion:    pointer.New("6758fd786c"),
+						CacheState:       pointer.New(marin3rv1alpha1.InSyncState),
+						ConfigRevisions:  []marin3rv1alpha1.ConfigRevisionRef{},
+						Conditions: []metav1.Condition{
+							{Type: marin3rv1alpha1.CacheOutOfSyncCondition, Status: metav1.ConditionFalse, Message: "a"},
+							{Type: marin3rv1alpha1.RollbackFailedCondition, Status: metav1.ConditionFalse, Message: "a"},
+						},
+					},
+				},
+				cacheState:       marin3rv1alpha1.InSyncState,
+				publishedVersion: "6758fd786c",
+				list:             &marin3rv1alpha1.EnvoyConfigRevisionList{},
+			},
+			want: false,
+		},
 		{
 			name: "RollbackFailedCondition needs to be inactive, returns false",
 			args: args{
@@ -242,10 +290,62 @@ func TestIsStatusReconciled(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "Pinned version published, desired version not published is expected",
+			args: args{
+				ec: &marin3rv1alpha1.EnvoyConfig{
+					Status: marin3rv1alpha1.EnvoyConfigStatus{
+						DesiredVersion:   pointer.New("6758fd786c"),
+						PublishedVersion: pointer.New("1"),
+						CacheState:       pointer.New(marin3rv1alpha1.PinnedState),
+						ConfigRevisions: []marin3rv1alpha1.ConfigRevisionRef{
+							{Version: "1", Ref: corev1.ObjectReference{Name: "ecr1", Namespace: "test"}},
+						},
+						Conditions: []metav1.Condition{
+							{Type: marin3rv1alpha1.CacheOutOfSyncCondition, Status: metav1.ConditionFalse, Message: "a"},
+							{Type: marin3rv1alpha1.RollbackFailedCondition, Status: metav1.ConditionFalse, Message: "a"},
+						},
+					},
+				},
+				cacheState:       marin3rv1alpha1.PinnedState,
+				publishedVersion: "1",
+				list: &marin3rv1alpha1.EnvoyConfigRevisionList{
+					Items: []marin3rv1alpha1.EnvoyConfigRevision{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "ecr1", Namespace: "test"},
+							Spec:       marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "1"},
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Pinned version cannot be published, returns false",
+			args: args{
+				ec: &marin3rv1alpha1.EnvoyConfig{
+					Status: marin3rv1alpha1.EnvoyConfigStatus{
+						DesiredVersion:   pointer.New("6758fd786c"),
+						PublishedVersion: pointer.New("6758fd786c"),
+						CacheState:       pointer.New(marin3rv1alpha1.InSyncState),
+						ConfigRevisions:  []marin3rv1alpha1.ConfigRevisionRef{},
+						Conditions: []metav1.Condition{
+							{Type: marin3rv1alpha1.CacheOutOfSyncCondition, Status: metav1.ConditionFalse, Message: "a"},
+							{Type: marin3rv1alpha1.RollbackFailedCondition, Status: metav1.ConditionFalse, Message: "a"},
+						},
+					},
+				},
+				cacheState:       marin3rv1alpha1.InSyncState,
+				publishedVersion: "6758fd786c",
+				pinError:         errors.New("the revision for pinned version 'xxxx' does not exist"),
+				list:             &marin3rv1alpha1.EnvoyConfigRevisionList{Items: []marin3rv1alpha1.EnvoyConfigRevision{}},
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := IsStatusReconciled(tt.args.ec, tt.args.cacheState, tt.args.publishedVersion, tt.args.list); got != tt.want {
+			if got := IsStatusReconciled(tt.args.ec, tt.args.cacheState, tt.args.publishedVersion, tt.args.canaryVersion, tt.args.pinError, tt.args.list); got != tt.want {
 				t.Errorf("IsStatusReconciled() = %v, want %v", got, tt.want)
 			}
 		})
@@ -0,0 +1,127 @@
+package reconcilers
+
+import (
+	"sort"
+	"time"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsSyncStatusReconciled calculates the sync status of the envoy clients connected
+// to the discovery service with the given nodeID, which is the EnvoyConfig's nodeID
+// unless the discovery service uses namespaced nodeIDs
+func IsSyncStatusReconciled(ec *marin3rv1alpha1.EnvoyConfig, nodeID, publishedVersion string,
+	list *marin3rv1alpha1.EnvoyConfigRevisionList, dStats *stats.Stats) bool {
+
+	syncStatus := calculateSyncStatus(ec, nodeID, publishedVersion, list, dStats)
+	if !equality.Semantic.DeepEqual(ec.Status.SyncStatus, syncStatus) {
+		ec.Status.SyncStatus = syncStatus
+		return false
+	}
+	return true
+}
+
+func calculateSyncStatus(ec *marin3rv1alpha1.EnvoyConfig, nodeID, publishedVersion string,
+	list *marin3rv1alpha1.EnvoyConfigRevisionList, dStats *stats.Stats) *marin3rv1alpha1.SyncStatus {
+
+	if dStats == nil || list == nil {
+		return nil
+	}
+
+	var vt *marin3rv1alpha1.VersionTracker
+	for _, ecr := range list.Items {
+		if ecr.Spec.Version == publishedVersion {
+			vt = ecr.Status.ProvidesVersions
+			break
+		}
+	}
+	if vt == nil {
+		return nil
+	}
+
+	syncStatus := &marin3rv1alpha1.SyncStatus{}
+	connected := map[string]bool{}
+	outOfSync := map[string]*marin3rv1alpha1.PodSyncStatus{}
+	lastACK := map[string]time.Time{}
+
+	for _, rv := range []struct {
+		rType   envoy.Type
+		version string
+	}{
+		{envoy.Endpoint, vt.Endpoints}, {envoy.Cluster, vt.Clusters}, {envoy.Route, vt.Routes},
+		{envoy.ScopedRoute, vt.ScopedRoutes}, {envoy.Listener, vt.Listeners}, {envoy.Secret, vt.Secrets},
+		{envoy.Runtime, vt.Runtimes}, {envoy.ExtensionConfig, vt.ExtensionConfigs},
+	} {
+		if rv.version == "" {
+			continue
+		}
+
+		typeURL := envoy_resources.TypeURL(rv.rType, ec.GetEnvoyAPIVersion())
+		subscribed := dStats.GetSubscribedPods(nodeID, typeURL)
+		if len(subscribed) == 0 {
+			continue
+		}
+		acks := dStats.GetLastACKs(nodeID, typeURL)
+		nacks := dStats.GetNACKErrors(nodeID, typeURL, rv.version)
+
+		rts := marin3rv1alpha1.ResourceTypeSyncStatus{
+			Type:           rv.rType,
+			Version:        rv.version,
+			SubscribedPods: int32(len(subscribed)),
+		}
+		for pod := range subscribed {
+			connected[pod] = true
+			ack, acked := acks[pod]
+			if acked && ack.Time.After(lastACK[pod]) {
+				lastACK[pod] = ack.Time
+			}
+			if acked && ack.Version == rv.version {
+				rts.SyncedPods++
+				continue
+			}
+
+			pss, ok := outOfSync[pod]
+			if !ok {
+				pss = &marin3rv1alpha1.PodSyncStatus{Name: pod, State: marin3rv1alpha1.PodLagging, Types: []envoy.Type{}}
+				outOfSync[pod] = pss
+			}
+			pss.Types = append(pss.Types, rv.rType)
+			if _, ok := nacks[pod]; ok {
+				pss.State = marin3rv1alpha1.PodRejecting
+			}
+		}
+		syncStatus.ResourceTypes = append(syncStatus.ResourceTypes, rts)
+	}
+
+	syncStatus.ConnectedPods = int32(len(connected))
+	syncStatus.SyncedPods = int32(len(connected) - len(outOfSync))
+
+	pods := make([]marin3rv1alpha1.PodSyncStatus, 0, len(outOfSync))
+	for _, pss := range outOfSync {
+		if t, ok := lastACK[pss.Name]; ok {
+			// status times have a precision of seconds
+			pss.LastACK = &metav1.Time{Time: t.Truncate(time.Second)}
+		}
+		pods = append(pods, *pss)
+	}
+	// rejecting pods go first so they are not left out of the list when it is capped
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].State != pods[j].State {
+			return pods[i].State == marin3rv1alpha1.PodRejecting
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	if len(pods) > marin3rv1alpha1.MaxOutOfSyncPods {
+		pods = pods[:marin3rv1alpha1.MaxOutOfSyncPods]
+	}
+	if len(pods) > 0 {
+		syncStatus.OutOfSyncPods = pods
+	}
+
+	return syncStatus
+}
@@ -0,0 +1,136 @@
+package reconcilers
+
+import (
+	"testing"
+	"time"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/stats"
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
+	kv "github.com/patrickmn/go-cache"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsSyncStatusReconciled(t *testing.T) {
+	endpointURL := envoy_resources.TypeURL(envoy.Endpoint, envoy.APIv3)
+	clusterURL := envoy_resources.TypeURL(envoy.Cluster, envoy.APIv3)
+
+	list := &marin3rv1alpha1.EnvoyConfigRevisionList{
+		Items: []marin3rv1alpha1.EnvoyConfigRevision{
+			{
+				Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{Version: "aaaa"},
+				Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+					ProvidesVersions: &marin3rv1alpha1.VersionTracker{Endpoints: "eeee", Clusters: "cccc"},
+				},
+			},
+		},
+	}
+
+	items := map[string]kv.Item{
+		"node:" + endpointURL + ":*:pod-1:request_counter": {Object: int64(2)},
+		"node:" + clusterURL + ":*:pod-1:request_counter":  {Object: int64(2)},
+		"node:" + endpointURL + ":eeee:pod-1:info":         {Object: int64(100000)},
+		"node:" + clusterURL + ":cccc:pod-1:info":          {Object: int64(100000)},
+		"node:" + endpointURL + ":*:pod-2:request_counter": {Object: int64(2)},
+		"node:" + clusterURL + ":*:pod-2:request_counter":  {Object: int64(2)},
+		"node:" + endpointURL + ":eeee:pod-2:info":         {Object: int64(100000)},
+		"node:" + clusterURL + ":xxxx:pod-2:info":          {Object: int64(200500)},
+		"node:" + clusterURL + ":cccc:pod-2:nack_counter":  {Object: int64(1)},
+		"node:" + clusterURL + ":cccc:pod-2:nack_error":    {Object: stats.NACKError{Message: "error"}},
+		"node:" + endpointURL + ":*:pod-3:request_counter": {Object: int64(1)},
+	}
+
+	type args struct {
+		ec               *marin3rv1alpha1.EnvoyConfig
+		publishedVersion string
+		list             *marin3rv1alpha1.EnvoyConfigRevisionList
+		dStats           *stats.Stats
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       bool
+		wantStatus *marin3rv1alpha1.SyncStatus
+	}{
+		{
+			name: "Calculates the sync status of the envoy clients",
+			args: args{
+				ec:               &marin3rv1alpha1.EnvoyConfig{Spec: marin3rv1alpha1.EnvoyConfigSpec{NodeID: "node"}},
+				publishedVersion: "aaaa",
+				list:             list,
+				dStats:           stats.NewWithItems(items, time.Now()),
+			},
+			want: false,
+			wantStatus: &marin3rv1alpha1.SyncStatus{
+				ConnectedPods: 3,
+				SyncedPods:    1,
+				ResourceTypes: []marin3rv1alpha1.ResourceTypeSyncStatus{
+					{Type: envoy.Endpoint, Version: "eeee", SubscribedPods: 3, SyncedPods: 2},
+					{Type: envoy.Cluster, Version: "cccc", SubscribedPods: 2, SyncedPods: 1},
+				},
+				OutOfSyncPods: []marin3rv1alpha1.PodSyncStatus{
+					{Name: "pod-2", State: marin3rv1alpha1.PodRejecting, Types: []envoy.Type{envoy.Cluster},
+						LastACK: &metav1.Time{Time: time.UnixMilli(200000)}},
+					{Name: "pod-3", State: marin3rv1alpha1.PodLagging, Types: []envoy.Type{envoy.Endpoint}},
+				},
+			},
+		},
+		{
+			name: "Status already up to date, returns true",
+			args: args{
+				ec: &marin3rv1alpha1.EnvoyConfig{
+					Spec: marin3rv1alpha1.EnvoyConfigSpec{NodeID: "node"},
+					Status: marin3rv1alpha1.EnvoyConfigStatus{
+						SyncStatus: &marin3rv1alpha1.SyncStatus{
+							ConnectedPods: 1,
+							SyncedPods:    1,
+							ResourceTypes: []marin3rv1alpha1.ResourceTypeSyncStatus{
+								{Type: envoy.Endpoint, Version: "eeee", SubscribedPods: 1, SyncedPods: 1},
+							},
+						},
+					},
+				},
+				publishedVersion: "aaaa",
+				list:             list,
+				dStats: stats.NewWithItems(map[string]kv.Item{
+					"node:" + endpointURL + ":*:pod-1:request_counter": {Object: int64(2)},
+					"node:" + endpointURL + ":eeee:pod-1:info":         {Object: int64(100000)},
+				}, time.Now()),
+			},
+			want: true,
+			wantStatus: &marin3rv1alpha1.SyncStatus{
+				ConnectedPods: 1,
+				SyncedPods:    1,
+				ResourceTypes: []marin3rv1alpha1.ResourceTypeSyncStatus{
+					{Type: envoy.Endpoint, Version: "eeee", SubscribedPods: 1, SyncedPods: 1},
+				},
+			},
+		},
+		{
+			name: "Clears the sync status if the published revision is not found",
+			args: args{
+				ec: &marin3rv1alpha1.EnvoyConfig{
+					Spec:   marin3rv1alpha1.EnvoyConfigSpec{NodeID: "node"},
+					Status: marin3rv1alpha1.EnvoyConfigStatus{SyncStatus: &marin3rv1alpha1.SyncStatus{ConnectedPods: 1}},
+				},
+				publishedVersion: "bbbb",
+				list:             list,
+				dStats:           stats.NewWithItems(items, time.Now()),
+			},
+			want:       false,
+			wantStatus: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSyncStatusReconciled(tt.args.ec, tt.args.ec.Spec.NodeID, tt.args.publishedVersion, tt.args.list, tt.args.dStats); got != tt.want {
+				t.Errorf("IsSyncStatusReconciled() = %v, want %v", got, tt.want)
+			}
+			if !equality.Semantic.DeepEqual(tt.args.ec.Status.SyncStatus, tt.wantStatus) {
+				t.Errorf("IsSyncStatusReconciled() status = %+v, want %+v", tt.args.ec.Status.SyncStatus, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -3,17 +3,19 @@ package reconcilers
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
 	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
 	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
 	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
 	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	envoy_template "github.com/3scale-ops/marin3r/pkg/envoy/template"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/marin3r/envoyconfigrevision/discover"
-	"github.com/davecgh/go-spew/spew"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -41,9 +43,9 @@ func NewCacheReconciler(ctx context.Context, logger logr.Logger, client client.C
 }
 
 func (r *CacheReconciler) Reconcile(ctx context.Context, req types.NamespacedName, resources []marin3rv1alpha1.Resource,
-	nodeID, version string) (*marin3rv1alpha1.VersionTracker, error) {
+	parameters []marin3rv1alpha1.Parameter, nodeID, version string) (*marin3rv1alpha1.VersionTracker, error) {
 
-	snap, err := r.GenerateSnapshot(req, resources)
+	snap, err := r.GenerateSnapshot(req, resources, parameters)
 
 	if err != nil {
 		return nil, err
@@ -52,13 +54,63 @@ func (r *CacheReconciler) Reconcile(ctx context.Context, req types.NamespacedNam
 	oldSnap, err := r.xdsCache.GetSnapshot(nodeID)
 	if err != nil || areDifferent(snap, oldSnap) {
 
-		r.logger.Info("Writing new snapshot to xDS cache", "Revision", version, "NodeID", nodeID)
+		logValues := []interface{}{"Revision", version, "NodeID", nodeID}
+		if err == nil {
+			oldVT, newVT := versionTracker(oldSnap), versionTracker(snap)
+			logValues = append(logValues, "ChangedResources", oldVT.ChangedResources(newVT))
+			if untracked := oldVT.UntrackedChanges(newVT); len(untracked) > 0 {
+				logValues = append(logValues, "ChangedUntrackedResourceTypes", untracked)
+			}
+		}
+		r.logger.Info("Writing new snapshot to xDS cache", logValues...)
 		if err := r.xdsCache.SetSnapshot(ctx, nodeID, snap); err != nil {
 			return nil, err
 		}
+		r.logDanglingReferences(snap, nodeID, version)
 
 	}
 
+	return versionTracker(snap), nil
+}
+
+// ReconcileCanary writes the snapshot for the given resources as the canary snapshot
+// of the nodeID, served only to the envoy clients selected by the CanaryTarget
+func (r *CacheReconciler) ReconcileCanary(ctx context.Context, req types.NamespacedName, resources []marin3rv1alpha1.Resource,
+	parameters []marin3rv1alpha1.Parameter, nodeID string, target xdss.CanaryTarget) (*marin3rv1alpha1.VersionTracker, error) {
+
+	snap, err := r.GenerateSnapshot(req, resources, parameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	oldSnap, oldTarget, err := r.xdsCache.GetCanarySnapshot(nodeID)
+	if err != nil || areDifferent(snap, oldSnap) || !reflect.DeepEqual(target, oldTarget) {
+
+		r.logger.Info("Writing new canary snapshot to xDS cache", "Revision", target.Version, "NodeID", nodeID)
+		if err := r.xdsCache.SetCanarySnapshot(ctx, nodeID, target, snap); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return versionTracker(snap), nil
+}
+
+// CanaryTarget returns the xDS cache CanaryTarget for a canary EnvoyConfigRevision
+func CanaryTarget(ecr *marin3rv1alpha1.EnvoyConfigRevision) xdss.CanaryTarget {
+	target := xdss.CanaryTarget{Version: ecr.Spec.Version}
+	if ecr.Status.Canary != nil {
+		if ecr.Status.Canary.Percentage != nil {
+			target.Percentage = *ecr.Status.Canary.Percentage
+		}
+		target.Pods = ecr.Status.Canary.Pods
+	}
+	return target
+}
+
+func versionTracker(snap xdss.Snapshot) *marin3rv1alpha1.VersionTracker {
+	resources, untracked := resourceVersions(snap)
 	return &marin3rv1alpha1.VersionTracker{
 		Endpoints:        snap.GetVersion(envoy.Endpoint),
 		Clusters:         snap.GetVersion(envoy.Cluster),
@@ -68,12 +120,35 @@ func (r *CacheReconciler) Reconcile(ctx context.Context, req types.NamespacedNam
 		Secrets:          snap.GetVersion(envoy.Secret),
 		Runtimes:         snap.GetVersion(envoy.Runtime),
 		ExtensionConfigs: snap.GetVersion(envoy.ExtensionConfig),
-	}, nil
+		Resources:        resources,
+		Untracked:        untracked,
+	}
+}
+
+// logDanglingReferences logs the references between the resources of the snapshot
+// that cannot be resolved, as the envoy clients will wait for these resources forever
+func (r *CacheReconciler) logDanglingReferences(snap xdss.Snapshot, nodeID, version string) {
+	resources := make(map[envoy.Type][]envoy.Resource, len(snapshotTypes))
+	for _, rType := range snapshotTypes {
+		for _, res := range snap.GetResources(rType) {
+			resources[rType] = append(resources[rType], res)
+		}
+	}
+
+	for _, msg := range envoy_resources.AnalyzeReferences(resources).Dangling {
+		r.logger.Info("Dangling reference in snapshot", "Revision", version, "NodeID", nodeID, "Reference", msg)
+	}
 }
 
-func (r *CacheReconciler) GenerateSnapshot(req types.NamespacedName, resources []marin3rv1alpha1.Resource) (xdss.Snapshot, error) {
+func (r *CacheReconciler) GenerateSnapshot(req types.NamespacedName, resources []marin3rv1alpha1.Resource,
+	parameters []marin3rv1alpha1.Parameter) (xdss.Snapshot, error) {
 	snap := r.xdsCache.NewSnapshot()
 
+	values, err := r.parameterValues(req, parameters)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoints := make([]envoy.Resource, 0, len(resources))
 	clusters := make([]envoy.Resource, 0, len(resources))
 	routes := make([]envoy.Resource, 0, len(resources))
@@ -84,6 +159,20 @@ func (r *CacheReconciler) GenerateSnapshot(req types.NamespacedName, resources [
 	secrets := make([]envoy.Resource, 0, len(resources))
 
 	for idx, resourceDefinition := range resources {
+
+		// Render the templates in the resource value, if any
+		if len(parameters) > 0 && resourceDefinition.Value != nil {
+			raw, err := envoy_template.Render(resourceDefinition.Value.Raw, values)
+			if err != nil {
+				return nil,
+					resourceLoaderError(
+						req, string(resourceDefinition.Value.Raw), field.NewPath("spec", "resources").Index(idx).Child("value"),
+						fmt.Sprintf("Invalid envoy resource template: '%s'", err),
+					)
+			}
+			resourceDefinition.Value = &runtime.RawExtension{Raw: raw}
+		}
+
 		switch resourceDefinition.Type {
 
 		case envoy.Endpoint:
@@ -187,11 +276,6 @@ func (r *CacheReconciler) GenerateSnapshot(req types.NamespacedName, resources [
 					return nil, fmt.Errorf("expected Secret of '%s' type", corev1.SecretTypeOpaque)
 				}
 				res = r.generator.NewGenericSecret(resourceDefinition.GenerateFromOpaqueSecret.Alias, string(s.Data[resourceDefinition.GenerateFromOpaqueSecret.Key]))
-				m := envoy_serializer.NewResourceMarshaller(envoy_serializer.YAML, envoy.APIv3)
-				yaml, _ := m.Marshal(res)
-				fmt.Println("###################################")
-				spew.Dump(yaml)
-				fmt.Println("###################################")
 
 			} else {
 				return nil, resourceLoaderError(
@@ -242,6 +326,39 @@ func (r *CacheReconciler) GenerateSnapshot(req types.NamespacedName, resources [
 	return snap, nil
 }
 
+// parameterValues resolves the values of the parameters, reading them
+// from ConfigMaps when required
+func (r *CacheReconciler) parameterValues(req types.NamespacedName, parameters []marin3rv1alpha1.Parameter) (map[string]string, error) {
+	values := make(map[string]string, len(parameters))
+
+	for idx, param := range parameters {
+		switch {
+		case param.Value != nil:
+			values[param.Name] = *param.Value
+
+		case param.ConfigMapKeyRef != nil:
+			cm := &corev1.ConfigMap{}
+			key := types.NamespacedName{Name: param.ConfigMapKeyRef.Name, Namespace: req.Namespace}
+			if err := r.client.Get(r.ctx, key, cm); err != nil {
+				return nil, fmt.Errorf("parameter %q: %w", param.Name, err)
+			}
+			value, ok := cm.Data[param.ConfigMapKeyRef.Key]
+			if !ok {
+				return nil, fmt.Errorf("key '%s' not found in ConfigMap '%s'", param.ConfigMapKeyRef.Key, param.ConfigMapKeyRef.Name)
+			}
+			values[param.Name] = value
+
+		default:
+			return nil, resourceLoaderError(
+				req, param, field.NewPath("spec", "parameters").Index(idx),
+				"one of 'value', 'configMapKeyRef' must be set",
+			)
+		}
+	}
+
+	return values, nil
+}
+
 func resourceLoaderError(req types.NamespacedName, value interface{}, resPath *field.Path, msg string) error {
 	return errors.NewInvalid(
 		schema.GroupKind{Group: "envoy", Kind: "EnvoyConfig"},
@@ -250,12 +367,40 @@ func resourceLoaderError(req types.NamespacedName, value interface{}, resPath *f
 	)
 }
 
+var snapshotTypes = []envoy.Type{envoy.Endpoint, envoy.Cluster, envoy.Route, envoy.ScopedRoute,
+	envoy.Listener, envoy.Secret, envoy.Runtime, envoy.ExtensionConfig}
+
+// areDifferent compares two snapshots resource by resource
 func areDifferent(a, b xdss.Snapshot) bool {
-	for _, rType := range []envoy.Type{envoy.Endpoint, envoy.Cluster, envoy.Route, envoy.ScopedRoute,
-		envoy.Listener, envoy.Secret, envoy.Runtime, envoy.ExtensionConfig} {
+	for _, rType := range snapshotTypes {
 		if a.GetVersion(rType) != b.GetVersion(rType) {
 			return true
 		}
+		if !reflect.DeepEqual(a.GetResourceVersions(rType), b.GetResourceVersions(rType)) {
+			return true
+		}
 	}
 	return false
 }
+
+// resourceVersions returns the version of each resource in the snapshot,
+// indexed by resource type and name. Types without resources are omitted and
+// types with more than MaxTrackedResourceVersions resources are returned as
+// untracked instead.
+func resourceVersions(snap xdss.Snapshot) (map[envoy.Type]map[string]string, []envoy.Type) {
+	versions := map[envoy.Type]map[string]string{}
+	var untracked []envoy.Type
+	for _, rType := range snapshotTypes {
+		v := snap.GetResourceVersions(rType)
+		switch {
+		case len(v) > marin3rv1alpha1.MaxTrackedResourceVersions:
+			untracked = append(untracked, rType)
+		case len(v) > 0:
+			versions[rType] = v
+		}
+	}
+	if len(versions) == 0 {
+		return nil, untracked
+	}
+	return versions, untracked
+}
@@ -2,6 +2,7 @@ package reconcilers
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -85,10 +86,11 @@ func TestCacheReconciler_Reconcile(t *testing.T) {
 		generator envoy_resources.Generator
 	}
 	type args struct {
-		req       types.NamespacedName
-		resources []marin3rv1alpha1.Resource
-		nodeID    string
-		version   string
+		req        types.NamespacedName
+		resources  []marin3rv1alpha1.Resource
+		parameters []marin3rv1alpha1.Parameter
+		nodeID     string
+		version    string
 	}
 	tests := []struct {
 		name        string
@@ -121,7 +123,10 @@ func TestCacheReconciler_Reconcile(t *testing.T) {
 				nodeID:  "node2",
 			},
 
-			want:    &marin3rv1alpha1.VersionTracker{Endpoints: "845f965864"},
+			want: &marin3rv1alpha1.VersionTracker{
+				Endpoints: "845f965864",
+				Resources: map[envoy.Type]map[string]string{envoy.Endpoint: {"endpoint": "64d9ddfcf4"}},
+			},
 			wantErr: false,
 			wantSnap: xdss_v3.NewSnapshot().SetResources(envoy.Endpoint, []envoy.Resource{
 				&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "endpoint"},
@@ -138,7 +143,7 @@ func TestCacheReconciler_Reconcile(t *testing.T) {
 				decoder:   tt.fields.decoder,
 				generator: tt.fields.generator,
 			}
-			got, err := r.Reconcile(context.TODO(), tt.args.req, tt.args.resources, tt.args.nodeID, tt.args.version)
+			got, err := r.Reconcile(context.TODO(), tt.args.req, tt.args.resources, tt.args.parameters, tt.args.nodeID, tt.args.version)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CacheReconciler.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -169,8 +174,9 @@ func TestCacheReconciler_GenerateSnapshot(t *testing.T) {
 		generator envoy_resources.Generator
 	}
 	type args struct {
-		req       types.NamespacedName
-		resources []marin3rv1alpha1.Resource
+		req        types.NamespacedName
+		resources  []marin3rv1alpha1.Resource
+		parameters []marin3rv1alpha1.Parameter
 	}
 	tests := []struct {
 		name    string
@@ -430,6 +436,84 @@ func TestCacheReconciler_GenerateSnapshot(t *testing.T) {
 			wantErr: true,
 			want:    xdss_v3.NewSnapshot(),
 		},
+		{
+			name: "Renders templates in the resources",
+			fields: fields{
+				client: fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "params", Namespace: "xx"},
+					Data:       map[string]string{"endpoint": "endpoint"},
+				}).Build(),
+				ctx:       context.TODO(),
+				logger:    ctrl.Log.WithName("test"),
+				xdsCache:  xdss_v3.NewCache(),
+				decoder:   envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, envoy.APIv3),
+				generator: envoy_resources_v3.Generator{},
+			},
+			args: args{
+				req: types.NamespacedName{Name: "xx", Namespace: "xx"},
+				resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Endpoint, Value: k8sutil.StringtoRawExtension("{\"cluster_name\": \"{{ .endpoint }}\"}")},
+					{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension("{\"name\": \"{{ .cluster }}\"}")},
+				},
+				parameters: []marin3rv1alpha1.Parameter{
+					{Name: "cluster", Value: pointer.New("cluster")},
+					{Name: "endpoint", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "endpoint"}},
+				},
+			},
+			wantErr: false,
+			want: xdss_v3.NewSnapshot().
+				SetResources(envoy.Endpoint, []envoy.Resource{
+					&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "endpoint"}}).
+				SetResources(envoy.Cluster, []envoy.Resource{
+					&envoy_config_cluster_v3.Cluster{Name: "cluster"}}),
+		},
+		{
+			name: "Fails when a template references an undeclared parameter",
+			fields: fields{
+				client:    fake.NewClientBuilder().Build(),
+				ctx:       context.TODO(),
+				logger:    ctrl.Log.WithName("test"),
+				xdsCache:  xdss_v3.NewCache(),
+				decoder:   envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, envoy.APIv3),
+				generator: envoy_resources_v3.Generator{},
+			},
+			args: args{
+				req: types.NamespacedName{Name: "xx", Namespace: "xx"},
+				resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension("{\"name\": \"{{ .missing }}\"}")},
+				},
+				parameters: []marin3rv1alpha1.Parameter{
+					{Name: "cluster", Value: pointer.New("cluster")},
+				},
+			},
+			wantErr: true,
+			want:    xdss_v3.NewSnapshot(),
+		},
+		{
+			name: "Fails when the ConfigMap key does not exist",
+			fields: fields{
+				client: fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "params", Namespace: "xx"},
+					Data:       map[string]string{},
+				}).Build(),
+				ctx:       context.TODO(),
+				logger:    ctrl.Log.WithName("test"),
+				xdsCache:  xdss_v3.NewCache(),
+				decoder:   envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, envoy.APIv3),
+				generator: envoy_resources_v3.Generator{},
+			},
+			args: args{
+				req: types.NamespacedName{Name: "xx", Namespace: "xx"},
+				resources: []marin3rv1alpha1.Resource{
+					{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension("{\"name\": \"{{ .cluster }}\"}")},
+				},
+				parameters: []marin3rv1alpha1.Parameter{
+					{Name: "cluster", ConfigMapKeyRef: &marin3rv1alpha1.ConfigMapKeySelector{Name: "params", Key: "cluster"}},
+				},
+			},
+			wantErr: true,
+			want:    xdss_v3.NewSnapshot(),
+		},
 		{
 			name: "Fails when secret does not exist",
 			fields: fields{
@@ -460,7 +544,7 @@ func TestCacheReconciler_GenerateSnapshot(t *testing.T) {
 				decoder:   tt.fields.decoder,
 				generator: tt.fields.generator,
 			}
-			got, err := r.GenerateSnapshot(tt.args.req, tt.args.resources)
+			got, err := r.GenerateSnapshot(tt.args.req, tt.args.resources, tt.args.parameters)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CacheReconciler.GenerateSnapshot() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -478,3 +562,31 @@ func TestCacheReconciler_GenerateSnapshot(t *testing.T) {
 		})
 	}
 }
+
+func Test_resourceVersions(t *testing.T) {
+	endpoints := func(n int) []envoy.Resource {
+		list := []envoy.Resource{}
+		for i := 0; i < n; i++ {
+			list = append(list, &envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: fmt.Sprintf("endpoint%d", i)})
+		}
+		return list
+	}
+
+	snap := xdss_v3.NewSnapshot().
+		SetResources(envoy.Endpoint, endpoints(marin3rv1alpha1.MaxTrackedResourceVersions+1)).
+		SetResources(envoy.Cluster, []envoy.Resource{&envoy_config_cluster_v3.Cluster{Name: "cluster"}})
+
+	got, untracked := resourceVersions(snap)
+	if v, ok := got[envoy.Endpoint]; ok {
+		t.Errorf("resourceVersions() endpoints = %v, want no entry", v)
+	}
+	if !reflect.DeepEqual(untracked, []envoy.Type{envoy.Endpoint}) {
+		t.Errorf("resourceVersions() untracked = %v, want %v", untracked, []envoy.Type{envoy.Endpoint})
+	}
+	if len(got[envoy.Cluster]) != 1 {
+		t.Errorf("resourceVersions() clusters = %v, want 1 version", got[envoy.Cluster])
+	}
+	if _, ok := got[envoy.Listener]; ok {
+		t.Errorf("resourceVersions() listeners = %v, want no entry", got[envoy.Listener])
+	}
+}
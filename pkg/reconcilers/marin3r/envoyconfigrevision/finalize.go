@@ -16,4 +16,9 @@ func CleanupLogic(ecr *marin3rv1alpha1.EnvoyConfigRevision, xdssCache xdss.Cache
 		xdssCache.ClearSnapshot(ecr.Spec.NodeID)
 		log.Info("Successfully cleared xDS server cache", "XDSS", string(ecr.GetEnvoyAPIVersion()), "NodeID", ecr.Spec.NodeID)
 	}
+
+	if _, target, err := xdssCache.GetCanarySnapshot(ecr.Spec.NodeID); err == nil && target.Version == ecr.Spec.Version {
+		xdssCache.ClearCanary(ecr.Spec.NodeID)
+		log.Info("Successfully cleared canary from xDS server cache", "XDSS", string(ecr.GetEnvoyAPIVersion()), "NodeID", ecr.Spec.NodeID)
+	}
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
 	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
@@ -12,6 +15,7 @@ import (
 	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
 	k8sutil "github.com/3scale-ops/marin3r/pkg/util/k8s"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -26,21 +30,45 @@ func IsStatusReconciled(ecr *marin3rv1alpha1.EnvoyConfigRevision, vt *marin3rv1a
 		ok = false
 	}
 
-	// Note: tainted condition is never automatically removed to avoid retrying a bad config in the case of
-	// loss of statistics (i.e. a restart)
+	now := time.Now()
+
+	// Note: tainted condition is only automatically removed once the cool-down expires, to avoid retrying
+	// a bad config in the case of loss of statistics (i.e. a restart). The failure statistics are reset so
+	// the taint is re-evaluated using fresh statistics if the revision gets published again.
+	if isTaintCoolDownExpired(ecr, now) {
+		ResetFailureStats(ecr, dStats)
+		meta.RemoveStatusCondition(&ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition)
+		ecr.Status.RecordTaintEvent(marin3rv1alpha1.UntaintedEvent, "CoolDownExpired",
+			fmt.Sprintf("Taint removed after a cool-down of %s", ecr.GetTaintCoolDown()), now)
+		ok = false
+	}
+
 	var taintedCond *metav1.Condition
 	if vt != nil {
-		taintedCond = calculateRevisionTaintedCondition(ecr, ecr.Status.ProvidesVersions, dStats, 1)
+		taintedCond = calculateRevisionTaintedCondition(ecr, ecr.Status.ProvidesVersions, dStats, ecr.GetTaintFailingPercentage())
 	}
 
 	if taintedCond != nil {
 		equal := k8sutil.ConditionsEqual(taintedCond, meta.FindStatusCondition(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition))
 		if !equal {
+			if !meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition) {
+				ecr.Status.RecordTaintEvent(marin3rv1alpha1.TaintedEvent, taintedCond.Reason, taintedCond.Message, now)
+			}
 			meta.SetStatusCondition(&ecr.Status.Conditions, *taintedCond)
 			ok = false
 		}
 	}
 
+	// The list of rejections is kept when there are no errors in the stats (i.e. after
+	// a restart or after the stats have been reset) so the reasons are not lost
+	if vt != nil {
+		if rejections := calculateRecentRejections(ecr, ecr.Status.ProvidesVersions, dStats); len(rejections) > 0 &&
+			!equality.Semantic.DeepEqual(rejections, ecr.Status.RecentRejections) {
+			ecr.Status.RecentRejections = rejections
+			ok = false
+		}
+	}
+
 	inSyncCond := calculateResourcesInSyncCondition(ecr, xdssCache)
 	if inSyncCond != nil {
 		equal := k8sutil.ConditionsEqual(inSyncCond, meta.FindStatusCondition(ecr.Status.Conditions, marin3rv1alpha1.ResourcesInSyncCondition))
@@ -77,6 +105,42 @@ func IsStatusReconciled(ecr *marin3rv1alpha1.EnvoyConfigRevision, vt *marin3rv1a
 	return ok
 }
 
+// TaintCoolDownRemaining returns the time left for the taint cool-down of the
+// revision to expire. Zero is returned if the revision is not tainted or if it
+// doesn't have a cool-down configured.
+func TaintCoolDownRemaining(ecr *marin3rv1alpha1.EnvoyConfigRevision, now time.Time) time.Duration {
+	cond := meta.FindStatusCondition(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition)
+	if cond == nil || cond.Status != metav1.ConditionTrue || ecr.GetTaintCoolDown() == 0 {
+		return 0
+	}
+	if remaining := cond.LastTransitionTime.Add(ecr.GetTaintCoolDown()).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// isTaintCoolDownExpired returns true if the revision is tainted and has been
+// so for longer than its cool-down
+func isTaintCoolDownExpired(ecr *marin3rv1alpha1.EnvoyConfigRevision, now time.Time) bool {
+	return meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionTaintedCondition) &&
+		ecr.GetTaintCoolDown() > 0 && TaintCoolDownRemaining(ecr, now) == 0
+}
+
+// ResetFailureStats deletes the NACK counters of all the resource
+// versions that the revision publishes in the xDS server cache
+func ResetFailureStats(ecr *marin3rv1alpha1.EnvoyConfigRevision, dStats *stats.Stats) {
+	vt := ecr.Status.ProvidesVersions
+	if vt == nil {
+		return
+	}
+	for _, version := range []string{vt.Endpoints, vt.Clusters, vt.Routes, vt.ScopedRoutes,
+		vt.Listeners, vt.Secrets, vt.Runtimes, vt.ExtensionConfigs} {
+		if version != "" {
+			dStats.ResetNACKs(ecr.Spec.NodeID, version)
+		}
+	}
+}
+
 func calculateResourcesInSyncCondition(ecr *marin3rv1alpha1.EnvoyConfigRevision, xdssCache xdss.Cache) *metav1.Condition {
 
 	if meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition) {
@@ -105,14 +169,14 @@ func calculateResourcesInSyncCondition(ecr *marin3rv1alpha1.EnvoyConfigRevision,
 
 func calculateRevisionTaintedCondition(ecr *marin3rv1alpha1.EnvoyConfigRevision, vt *marin3rv1alpha1.VersionTracker, dStats *stats.Stats, threshold float64) *metav1.Condition {
 
-	if dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.Endpoint, ecr.GetEnvoyAPIVersion()), vt.Endpoints) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.Cluster, ecr.GetEnvoyAPIVersion()), vt.Clusters) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.Route, ecr.GetEnvoyAPIVersion()), vt.Routes) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.ScopedRoute, ecr.GetEnvoyAPIVersion()), vt.ScopedRoutes) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.Listener, ecr.GetEnvoyAPIVersion()), vt.Listeners) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.Secret, ecr.GetEnvoyAPIVersion()), vt.Secrets) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.Runtime, ecr.GetEnvoyAPIVersion()), vt.Runtimes) == threshold ||
-		dStats.GetPercentageFailing(ecr.Spec.NodeID, envoy_resources.TypeURL(envoy.ExtensionConfig, ecr.GetEnvoyAPIVersion()), vt.ExtensionConfigs) == threshold {
+	if percentageFailing(ecr, envoy.Endpoint, vt.Endpoints, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.Cluster, vt.Clusters, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.Route, vt.Routes, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.ScopedRoute, vt.ScopedRoutes, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.Listener, vt.Listeners, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.Secret, vt.Secrets, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.Runtime, vt.Runtimes, dStats) >= threshold ||
+		percentageFailing(ecr, envoy.ExtensionConfig, vt.ExtensionConfigs, dStats) >= threshold {
 		return &metav1.Condition{
 			Type:    marin3rv1alpha1.RevisionTaintedCondition,
 			Reason:  "ResourcesFailing",
@@ -123,3 +187,111 @@ func calculateRevisionTaintedCondition(ecr *marin3rv1alpha1.EnvoyConfigRevision,
 
 	return nil
 }
+
+// maxRejectionMessageLength is the maximum length of the error messages
+// stored in the status, as envoy error messages can be very long
+const maxRejectionMessageLength int = 1024
+
+// calculateRecentRejections groups the errors reported by the envoy clients that rejected the resources
+// of the revision by resource type, code and message. The most recent MaxRecentRejections are returned.
+func calculateRecentRejections(ecr *marin3rv1alpha1.EnvoyConfigRevision, vt *marin3rv1alpha1.VersionTracker, dStats *stats.Stats) []marin3rv1alpha1.Rejection {
+
+	rejections := []marin3rv1alpha1.Rejection{}
+	for _, rv := range []struct {
+		rType   envoy.Type
+		version string
+	}{
+		{envoy.Endpoint, vt.Endpoints}, {envoy.Cluster, vt.Clusters}, {envoy.Route, vt.Routes},
+		{envoy.ScopedRoute, vt.ScopedRoutes}, {envoy.Listener, vt.Listeners}, {envoy.Secret, vt.Secrets},
+		{envoy.Runtime, vt.Runtimes}, {envoy.ExtensionConfig, vt.ExtensionConfigs},
+	} {
+		if rv.version == "" {
+			continue
+		}
+
+		grouped := map[stats.NACKError]*marin3rv1alpha1.Rejection{}
+		typeURL := envoy_resources.TypeURL(rv.rType, ecr.GetEnvoyAPIVersion())
+		for pod, nackErr := range dStats.GetNACKErrors(ecr.Spec.NodeID, typeURL, rv.version) {
+			// status times have a precision of seconds
+			t := nackErr.Time.Truncate(time.Second)
+			nackErr.Time = time.Time{}
+			if len(nackErr.Message) > maxRejectionMessageLength {
+				nackErr.Message = strings.ToValidUTF8(nackErr.Message[:maxRejectionMessageLength], "")
+			}
+			r, ok := grouped[nackErr]
+			if !ok {
+				r = &marin3rv1alpha1.Rejection{Type: rv.rType, Code: nackErr.Code, Message: nackErr.Message, Pods: []string{}}
+				grouped[nackErr] = r
+			}
+			r.Pods = append(r.Pods, pod)
+			if t.After(r.LastSeen.Time) {
+				r.LastSeen = metav1.NewTime(t)
+			}
+		}
+
+		for _, r := range grouped {
+			sort.Strings(r.Pods)
+			rejections = append(rejections, *r)
+		}
+	}
+
+	sort.SliceStable(rejections, func(i, j int) bool {
+		if !rejections[i].LastSeen.Equal(&rejections[j].LastSeen) {
+			return rejections[j].LastSeen.Before(&rejections[i].LastSeen)
+		}
+		if rejections[i].Type != rejections[j].Type {
+			return rejections[i].Type < rejections[j].Type
+		}
+		if rejections[i].Message != rejections[j].Message {
+			return rejections[i].Message < rejections[j].Message
+		}
+		return rejections[i].Code < rejections[j].Code
+	})
+
+	if len(rejections) > marin3rv1alpha1.MaxRecentRejections {
+		rejections = rejections[:marin3rv1alpha1.MaxRecentRejections]
+	}
+	return rejections
+}
+
+// NewRejections returns the rejections in 'current' that have a resource
+// type, code and message not present in 'previous'
+func NewRejections(previous, current []marin3rv1alpha1.Rejection) []marin3rv1alpha1.Rejection {
+	type key struct {
+		rType envoy.Type
+		code  int32
+		msg   string
+	}
+	seen := map[key]struct{}{}
+	for _, r := range previous {
+		seen[key{r.Type, r.Code, r.Message}] = struct{}{}
+	}
+
+	added := []marin3rv1alpha1.Rejection{}
+	for _, r := range current {
+		if _, ok := seen[key{r.Type, r.Code, r.Message}]; !ok {
+			added = append(added, r)
+		}
+	}
+	return added
+}
+
+// percentageFailing returns the percentage of envoy clients failing to apply the given version
+// of a resource type. For canary revisions only the envoy clients that receive the canary are
+// taken into account.
+func percentageFailing(ecr *marin3rv1alpha1.EnvoyConfigRevision, rType envoy.Type, version string, dStats *stats.Stats) float64 {
+	typeURL := envoy_resources.TypeURL(rType, ecr.GetEnvoyAPIVersion())
+
+	if !ecr.Status.IsCanary() {
+		return dStats.GetPercentageFailing(ecr.Spec.NodeID, typeURL, version, ecr.GetTaintNackCount())
+	}
+
+	target := CanaryTarget(ecr)
+	pods := map[string]int8{}
+	for pod := range dStats.GetSubscribedPods(ecr.Spec.NodeID, typeURL) {
+		if target.Selects(pod) {
+			pods[pod] = 1
+		}
+	}
+	return dStats.GetPercentageFailingForPods(ecr.Spec.NodeID, typeURL, version, pods, ecr.GetTaintNackCount())
+}
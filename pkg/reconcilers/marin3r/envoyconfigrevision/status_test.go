@@ -15,6 +15,7 @@ import (
 	resource_v3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/patrickmn/go-cache"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -298,6 +299,64 @@ func TestIsStatusReconciled(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name: "Revision tainted, cool-down not expired",
+			args: args{
+				envoyConfigRevisionFactory: func() *marin3rv1alpha1.EnvoyConfigRevision {
+					return &marin3rv1alpha1.EnvoyConfigRevision{
+						Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+							Version:        "xxxx",
+							NodeID:         "test",
+							TaintThreshold: &marin3rv1alpha1.TaintThreshold{CoolDown: &metav1.Duration{Duration: time.Hour}},
+						},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Tainted: pointer.New(true),
+							Conditions: []metav1.Condition{
+								{Type: marin3rv1alpha1.RevisionTaintedCondition, Status: metav1.ConditionTrue,
+									LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute))},
+							},
+						},
+					}
+				},
+				versionTrackerFactory: func() *marin3rv1alpha1.VersionTracker { return nil },
+				xdssCacheFactory: func() xdss.Cache {
+					cache := xdss_v3.NewCache()
+					cache.SetSnapshot(context.TODO(), "test", cache.NewSnapshot())
+					return cache
+				},
+				dStats: stats.New,
+			},
+			want: true,
+		},
+		{
+			name: "Revision tainted, cool-down expired, status needs update",
+			args: args{
+				envoyConfigRevisionFactory: func() *marin3rv1alpha1.EnvoyConfigRevision {
+					return &marin3rv1alpha1.EnvoyConfigRevision{
+						Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+							Version:        "xxxx",
+							NodeID:         "test",
+							TaintThreshold: &marin3rv1alpha1.TaintThreshold{CoolDown: &metav1.Duration{Duration: time.Hour}},
+						},
+						Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+							Tainted: pointer.New(true),
+							Conditions: []metav1.Condition{
+								{Type: marin3rv1alpha1.RevisionTaintedCondition, Status: metav1.ConditionTrue,
+									LastTransitionTime: metav1.NewTime(time.Now().Add(-2 * time.Hour))},
+							},
+						},
+					}
+				},
+				versionTrackerFactory: func() *marin3rv1alpha1.VersionTracker { return nil },
+				xdssCacheFactory: func() xdss.Cache {
+					cache := xdss_v3.NewCache()
+					cache.SetSnapshot(context.TODO(), "test", cache.NewSnapshot())
+					return cache
+				},
+				dStats: stats.New,
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -310,6 +369,92 @@ func TestIsStatusReconciled(t *testing.T) {
 	}
 }
 
+func TestIsStatusReconciled_TaintCoolDown(t *testing.T) {
+	now := time.Now()
+	ecr := &marin3rv1alpha1.EnvoyConfigRevision{
+		Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+			Version:        "xxxx",
+			NodeID:         "test",
+			TaintThreshold: &marin3rv1alpha1.TaintThreshold{CoolDown: &metav1.Duration{Duration: time.Hour}},
+		},
+		Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+			Tainted:          pointer.New(true),
+			ProvidesVersions: &marin3rv1alpha1.VersionTracker{Endpoints: "aaaa"},
+			Conditions: []metav1.Condition{
+				{Type: marin3rv1alpha1.RevisionTaintedCondition, Status: metav1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Hour))},
+			},
+		},
+	}
+	dStats := stats.NewWithItems(map[string]cache.Item{
+		"test:" + resource_v3.EndpointType + ":*:pod-aaaa:request_counter:stream_1": {Object: int64(2), Expiration: int64(0)},
+		"test:" + resource_v3.EndpointType + ":aaaa:pod-aaaa:nack_counter":          {Object: int64(10), Expiration: int64(0)},
+	}, now)
+
+	IsStatusReconciled(ecr, nil, xdss_v3.NewCache(), dStats)
+
+	if ecr.Status.IsTainted() {
+		t.Errorf("IsStatusReconciled() revision still tainted after the cool-down expired")
+	}
+	if len(ecr.Status.TaintHistory) != 1 || ecr.Status.TaintHistory[0].Type != marin3rv1alpha1.UntaintedEvent {
+		t.Errorf("IsStatusReconciled() got taint history = %v", ecr.Status.TaintHistory)
+	}
+	if _, err := dStats.GetCounter("test", resource_v3.EndpointType, "aaaa", "pod-aaaa", "nack_counter"); err == nil {
+		t.Errorf("IsStatusReconciled() failure stats not reset after the cool-down expired")
+	}
+}
+
+func TestTaintCoolDownRemaining(t *testing.T) {
+	now := time.Now()
+	tainted := func(coolDown *metav1.Duration, since time.Duration) *marin3rv1alpha1.EnvoyConfigRevision {
+		return &marin3rv1alpha1.EnvoyConfigRevision{
+			Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+				TaintThreshold: &marin3rv1alpha1.TaintThreshold{CoolDown: coolDown},
+			},
+			Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+				Conditions: []metav1.Condition{
+					{Type: marin3rv1alpha1.RevisionTaintedCondition, Status: metav1.ConditionTrue,
+						LastTransitionTime: metav1.NewTime(now.Add(-since))},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		ecr  *marin3rv1alpha1.EnvoyConfigRevision
+		want time.Duration
+	}{
+		{
+			name: "Not tainted",
+			ecr:  &marin3rv1alpha1.EnvoyConfigRevision{},
+			want: 0,
+		},
+		{
+			name: "No cool-down",
+			ecr:  tainted(nil, time.Minute),
+			want: 0,
+		},
+		{
+			name: "Cool-down not expired",
+			ecr:  tainted(&metav1.Duration{Duration: time.Hour}, 20*time.Minute),
+			want: 40 * time.Minute,
+		},
+		{
+			name: "Cool-down expired",
+			ecr:  tainted(&metav1.Duration{Duration: time.Hour}, 2*time.Hour),
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TaintCoolDownRemaining(tt.ecr, now); got != tt.want {
+				t.Errorf("TaintCoolDownRemaining() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_calculateResourcesInSyncCondition(t *testing.T) {
 	type args struct {
 		envoyConfigRevisionFactory func() *marin3rv1alpha1.EnvoyConfigRevision
@@ -451,6 +596,39 @@ func Test_calculateRevisionTaintedCondition(t *testing.T) {
 			},
 			want: corev1.ConditionTrue,
 		},
+		{
+			name: "All canary endpoints fail, return taint",
+			args: args{
+				ecr: &marin3rv1alpha1.EnvoyConfigRevision{
+					ObjectMeta: metav1.ObjectMeta{Name: "ecr", Namespace: "test"},
+					Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+						NodeID:   "node",
+						EnvoyAPI: pointer.New(envoy.APIv3),
+						Version:  "xxxx",
+					},
+					Status: marin3rv1alpha1.EnvoyConfigRevisionStatus{
+						Canary: &marin3rv1alpha1.CanaryTarget{Pods: []string{"pod-aaaa", "pod-bbbb"}},
+						Conditions: []metav1.Condition{{
+							Type:   marin3rv1alpha1.RevisionCanaryCondition,
+							Status: metav1.ConditionTrue,
+						}},
+					},
+				},
+				vt: &marin3rv1alpha1.VersionTracker{
+					Endpoints: "xxxx",
+				},
+				dStats: stats.NewWithItems(map[string]cache.Item{
+					"node:" + resource_v3.EndpointType + ":*:pod-bbbb:request_counter:stream_2": {Object: int64(5), Expiration: int64(0)},
+					"node:" + resource_v3.EndpointType + ":*:pod-cccc:request_counter:stream_3": {Object: int64(1), Expiration: int64(0)},
+					"node:" + resource_v3.EndpointType + ":*:pod-dddd:request_counter:stream_4": {Object: int64(1), Expiration: int64(0)},
+					"node:" + resource_v3.EndpointType + ":*:pod-aaaa:request_counter:stream_1": {Object: int64(2), Expiration: int64(0)},
+					"node:" + resource_v3.EndpointType + ":xxxx:pod-aaaa:nack_counter":          {Object: int64(5), Expiration: int64(0)},
+					"node:" + resource_v3.EndpointType + ":xxxx:pod-bbbb:nack_counter":          {Object: int64(10), Expiration: int64(0)},
+				}, time.Now()),
+				thresshold: 1,
+			},
+			want: corev1.ConditionTrue,
+		},
 		{
 			name: "Less than half of endpoints fail, return nil",
 			args: args{
@@ -510,3 +688,78 @@ func Test_calculateRevisionTaintedCondition(t *testing.T) {
 		})
 	}
 }
+
+func Test_calculateRecentRejections(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tests := []struct {
+		name   string
+		vt     *marin3rv1alpha1.VersionTracker
+		dStats func() *stats.Stats
+		want   []marin3rv1alpha1.Rejection
+	}{
+		{
+			name: "Groups the errors by type, code and message, most recent first",
+			vt:   &marin3rv1alpha1.VersionTracker{Endpoints: "aaaa", Clusters: "bbbb"},
+			dStats: func() *stats.Stats {
+				return stats.NewWithItems(map[string]cache.Item{
+					"test:" + resource_v3.EndpointType + ":aaaa:pod-bbbb:nack_error": {Object: stats.NACKError{Code: 3, Message: "bad endpoint", Time: now.Add(-time.Minute)}},
+					"test:" + resource_v3.EndpointType + ":aaaa:pod-aaaa:nack_error": {Object: stats.NACKError{Code: 3, Message: "bad endpoint", Time: now.Add(-2 * time.Minute)}},
+					"test:" + resource_v3.EndpointType + ":xxxx:pod-aaaa:nack_error": {Object: stats.NACKError{Code: 3, Message: "old version", Time: now}},
+					"test:" + resource_v3.ClusterType + ":bbbb:pod-aaaa:nack_error":  {Object: stats.NACKError{Code: 3, Message: "bad cluster", Time: now}},
+				}, now)
+			},
+			want: []marin3rv1alpha1.Rejection{
+				{Type: envoy.Cluster, Code: 3, Message: "bad cluster", Pods: []string{"pod-aaaa"}, LastSeen: metav1.NewTime(now)},
+				{Type: envoy.Endpoint, Code: 3, Message: "bad endpoint", Pods: []string{"pod-aaaa", "pod-bbbb"}, LastSeen: metav1.NewTime(now.Add(-time.Minute))},
+			},
+		},
+		{
+			name: "No errors",
+			vt:   &marin3rv1alpha1.VersionTracker{Endpoints: "aaaa"},
+			dStats: func() *stats.Stats {
+				return stats.NewWithItems(map[string]cache.Item{}, now)
+			},
+			want: []marin3rv1alpha1.Rejection{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ecr := &marin3rv1alpha1.EnvoyConfigRevision{Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{NodeID: "test"}}
+			if got := calculateRecentRejections(ecr, tt.vt, tt.dStats()); !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("calculateRecentRejections() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous []marin3rv1alpha1.Rejection
+		current  []marin3rv1alpha1.Rejection
+		want     []marin3rv1alpha1.Rejection
+	}{
+		{
+			name:     "Returns the rejections not previously seen",
+			previous: []marin3rv1alpha1.Rejection{{Type: envoy.Cluster, Code: 3, Message: "a", Pods: []string{"pod-a"}}},
+			current: []marin3rv1alpha1.Rejection{
+				{Type: envoy.Cluster, Code: 3, Message: "a", Pods: []string{"pod-a", "pod-b"}},
+				{Type: envoy.Cluster, Code: 3, Message: "b", Pods: []string{"pod-a"}},
+			},
+			want: []marin3rv1alpha1.Rejection{{Type: envoy.Cluster, Code: 3, Message: "b", Pods: []string{"pod-a"}}},
+		},
+		{
+			name:     "No new rejections",
+			previous: []marin3rv1alpha1.Rejection{{Type: envoy.Cluster, Code: 3, Message: "a"}},
+			current:  []marin3rv1alpha1.Rejection{{Type: envoy.Cluster, Code: 3, Message: "a"}},
+			want:     []marin3rv1alpha1.Rejection{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRejections(tt.previous, tt.current); !equality.Semantic.DeepEqual(got, tt.want) {
+				t.Errorf("NewRejections() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package reconcilers
+
+import (
+	"context"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
+	envoy "github.com/3scale-ops/marin3r/pkg/envoy"
+	envoy_resources "github.com/3scale-ops/marin3r/pkg/envoy/resources"
+	envoy_serializer "github.com/3scale-ops/marin3r/pkg/envoy/serializer"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WarmUp loads the resources of the published and canary EnvoyConfigRevisions of the
+// namespaces into the xDS cache, so a restarted discovery service can serve the current
+// config to the envoy clients without waiting for every revision to be reconciled.
+// NodeIDs that already have a snapshot in the cache are skipped, as the snapshot was
+// written by the EnvoyConfigRevision controller and might be more recent. Revisions
+// that fail to load are logged and skipped, their controller will taint them. If
+// namespacedNodeIDs is true, the nodeIDs are prefixed with the revision's namespace.
+func WarmUp(ctx context.Context, logger logr.Logger, cl client.Client, xdsCache xdss.Cache,
+	namespaces []string, namespacedNodeIDs bool, version envoy.APIVersion) error {
+
+	list := &marin3rv1alpha1.EnvoyConfigRevisionList{}
+	for _, namespace := range namespaces {
+		nsList := &marin3rv1alpha1.EnvoyConfigRevisionList{}
+		if err := cl.List(ctx, nsList, client.InNamespace(namespace)); err != nil {
+			return err
+		}
+		list.Items = append(list.Items, nsList.Items...)
+	}
+
+	cacheReconciler := NewCacheReconciler(ctx, logger, cl, xdsCache,
+		envoy_serializer.NewResourceUnmarshaller(envoy_serializer.JSON, version),
+		envoy_resources.NewGenerator(version),
+	)
+
+	for idx := range list.Items {
+		ecr := &list.Items[idx]
+		if !ecr.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		published := meta.IsStatusConditionTrue(ecr.Status.Conditions, marin3rv1alpha1.RevisionPublishedCondition)
+		if !published && !ecr.Status.IsCanary() {
+			continue
+		}
+
+		if namespacedNodeIDs {
+			ecr.Spec.NodeID = xdss.NamespacedNodeID(ecr.GetNamespace(), ecr.Spec.NodeID)
+		}
+
+		resources := ecr.Spec.Resources
+		if ecr.Spec.EnvoyResources != nil {
+			var err error
+			if resources, err = ecr.Spec.EnvoyResources.Resources(ecr.GetSerialization()); err != nil {
+				logger.Error(err, "unable to load EnvoyConfigRevision resources", "name", ecr.GetName())
+				continue
+			}
+		}
+
+		key := types.NamespacedName{Name: ecr.GetName(), Namespace: ecr.GetNamespace()}
+		var err error
+		if published {
+			if _, cerr := xdsCache.GetSnapshot(ecr.Spec.NodeID); cerr == nil {
+				continue
+			}
+			_, err = cacheReconciler.Reconcile(ctx, key, resources, ecr.Spec.Parameters, ecr.Spec.NodeID, ecr.Spec.Version)
+		} else {
+			if _, _, cerr := xdsCache.GetCanarySnapshot(ecr.Spec.NodeID); cerr == nil {
+				continue
+			}
+			_, err = cacheReconciler.ReconcileCanary(ctx, key, resources, ecr.Spec.Parameters, ecr.Spec.NodeID, CanaryTarget(ecr))
+		}
+		if err != nil {
+			logger.Error(err, "unable to load EnvoyConfigRevision into the xDS cache", "name", ecr.GetName())
+			continue
+		}
+		logger.Info("loaded EnvoyConfigRevision into the xDS cache", "name", ecr.GetName(), "NodeID", ecr.Spec.NodeID)
+	}
+
+	return nil
+}
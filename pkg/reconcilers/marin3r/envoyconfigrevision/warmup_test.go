@@ -0,0 +1,104 @@
+package reconcilers
+
+import (
+	"context"
+	"testing"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	xdss_v3 "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss/v3"
+	"github.com/3scale-ops/marin3r/pkg/envoy"
+	k8sutil "github.com/3scale-ops/marin3r/pkg/util/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testWarmUpRevision(name, nodeID string, published bool) *marin3rv1alpha1.EnvoyConfigRevision {
+	ecr := &marin3rv1alpha1.EnvoyConfigRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Spec: marin3rv1alpha1.EnvoyConfigRevisionSpec{
+			NodeID:  nodeID,
+			Version: "xxxx",
+			Resources: []marin3rv1alpha1.Resource{
+				{Type: envoy.Cluster, Value: k8sutil.StringtoRawExtension("{\"name\": \"" + name + "\"}")},
+			},
+		},
+	}
+	if published {
+		ecr.Status.Conditions = []metav1.Condition{{
+			Type:   marin3rv1alpha1.RevisionPublishedCondition,
+			Status: metav1.ConditionTrue,
+			Reason: "test",
+		}}
+	}
+	return ecr
+}
+
+func TestWarmUp(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := marin3rv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		testWarmUpRevision("published", "node-a", true),
+		testWarmUpRevision("unpublished", "node-b", false),
+		testWarmUpRevision("cached", "node-c", true),
+	).Build()
+
+	xdsCache := xdss_v3.NewCache()
+	cached := xdsCache.NewSnapshot()
+	if err := xdsCache.SetSnapshot(context.TODO(), "node-c", cached); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WarmUp(context.TODO(), ctrl.Log.WithName("test"), cl, xdsCache, []string{"test"}, false, envoy.APIv3); err != nil {
+		t.Fatalf("WarmUp() error = %v", err)
+	}
+
+	if snap, err := xdsCache.GetSnapshot("node-a"); err != nil {
+		t.Errorf("WarmUp() did not load the published revision: %v", err)
+	} else if len(snap.GetResources(envoy.Cluster)) != 1 {
+		t.Errorf("WarmUp() loaded unexpected resources for the published revision: %v", snap.GetResources(envoy.Cluster))
+	}
+
+	if _, err := xdsCache.GetSnapshot("node-b"); err == nil {
+		t.Errorf("WarmUp() loaded an unpublished revision")
+	}
+
+	if snap, err := xdsCache.GetSnapshot("node-c"); err != nil {
+		t.Errorf("WarmUp() removed an existing snapshot: %v", err)
+	} else if len(snap.GetResources(envoy.Cluster)) != 0 {
+		t.Errorf("WarmUp() overwrote an existing snapshot")
+	}
+}
+
+func TestWarmUp_namespacedNodeIDs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := marin3rv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	other := testWarmUpRevision("other", "node-a", true)
+	other.SetNamespace("other")
+	ignored := testWarmUpRevision("ignored", "node-a", true)
+	ignored.SetNamespace("ignored")
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		testWarmUpRevision("published", "node-a", true), other, ignored,
+	).Build()
+
+	xdsCache := xdss_v3.NewCache()
+	if err := WarmUp(context.TODO(), ctrl.Log.WithName("test"), cl, xdsCache, []string{"test", "other"}, true, envoy.APIv3); err != nil {
+		t.Fatalf("WarmUp() error = %v", err)
+	}
+
+	for _, nodeID := range []string{"test/node-a", "other/node-a"} {
+		if _, err := xdsCache.GetSnapshot(nodeID); err != nil {
+			t.Errorf("WarmUp() did not load the revision for nodeID %q: %v", nodeID, err)
+		}
+	}
+	for _, nodeID := range []string{"node-a", "ignored/node-a"} {
+		if _, err := xdsCache.GetSnapshot(nodeID); err == nil {
+			t.Errorf("WarmUp() loaded a revision for nodeID %q", nodeID)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package reconcilers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"math"
+	"time"
+
+	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/util/clock"
+	"github.com/3scale-ops/marin3r/pkg/util/pki"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	tlsCertificateKey = "tls.crt"
+	// bundlePropagationDelay is the time to wait for a change in the CA bundle
+	// to reach the pods, accounting for the kubelet Secret volume sync period
+	// and the TLS reload interval of the discovery service
+	bundlePropagationDelay time.Duration = 2 * time.Minute
+	// reissueCheckInterval is the interval to check if the certificates
+	// have been reissued under the new root
+	reissueCheckInterval time.Duration = 10 * time.Second
+)
+
+// RootCARotationReconciler is a struct with methods to drive the staged
+// rotation of the root CA of a DiscoveryService
+type RootCARotationReconciler struct {
+	ctx      context.Context
+	logger   logr.Logger
+	client   client.Client
+	ds       *operatorv1alpha1.DiscoveryService
+	rootName string
+	nextName string
+	clock    clock.Clock
+
+	// Calculated fields
+	rotation   *operatorv1alpha1.RootCARotationStatus
+	conditions []metav1.Condition
+	bundle     []byte
+	nextRoot   bool
+}
+
+// NewRootCARotationReconciler returns a new RootCARotationReconciler. The rootName and nextName
+// parameters are the names of the DiscoveryServiceCertificates (and Secrets) of the current root
+// CA and the root CA that replaces it during a rotation.
+func NewRootCARotationReconciler(ctx context.Context, logger logr.Logger, client client.Client,
+	ds *operatorv1alpha1.DiscoveryService, rootName, nextName string) RootCARotationReconciler {
+
+	return RootCARotationReconciler{ctx, logger, client, ds, rootName, nextName, clock.Real{},
+		ds.Status.RootCARotation.DeepCopy(), copyConditions(ds.Status.Conditions), nil, false}
+}
+
+// GetBundle returns the PEM encoded bundle of root CAs that the discovery service
+// must trust. Returns nil if the root CA has not been issued yet.
+// Should be invoked only after running Reconcile()
+func (r *RootCARotationReconciler) GetBundle() []byte {
+	return r.bundle
+}
+
+// IsNextRootRequired returns true if the root CA that replaces the current
+// one is required. Should be invoked only after running Reconcile()
+func (r *RootCARotationReconciler) IsNextRootRequired() bool {
+	return r.nextRoot
+}
+
+// MutateStatus writes the rotation status and conditions into the DiscoveryService status.
+// Returns true if the status changed. Should be invoked only after running Reconcile()
+func (r *RootCARotationReconciler) MutateStatus() bool {
+	status := &r.ds.Status
+	if equality.Semantic.DeepEqual(status.RootCARotation, r.rotation) &&
+		equality.Semantic.DeepEqual(status.Conditions, r.conditions) {
+		return false
+	}
+	status.RootCARotation = r.rotation
+	status.Conditions = r.conditions
+	return true
+}
+
+// Reconcile moves the root CA rotation through its phases:
+//   - IssuingNewRoot: a new root CA is issued alongside the current one
+//   - PublishingBundle: the discovery service trusts both the old and the new roots
+//   - ReissuingCertificates: the new root replaces the old one and all the certificates
+//     signed by the root are reissued under the new root
+//   - RetiringOldRoot: the old root is removed from the bundle once clients have had
+//     time to pick up their new certificates
+//
+// A rotation is started when the root CA is within the last 20% of its lifetime, when its
+// private key does not match the PKI configuration or when the DiscoveryService is annotated
+// with the RootCARotationAnnotation.
+func (r *RootCARotationReconciler) Reconcile() (ctrl.Result, error) {
+
+	rootPEM, root, err := r.getCertificate(r.ds.GetNamespace(), r.rootName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// The root CA hasn't been issued yet
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if r.rotation == nil {
+		result, start := r.shouldStart(root)
+		if !start {
+			r.bundle = rootPEM
+			return result, nil
+		}
+		if err := r.start(rootPEM); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	logger := r.logger.WithValues("phase", r.rotation.Phase)
+	previousPEM := []byte(r.rotation.PreviousRootCertificate)
+
+	switch r.rotation.Phase {
+
+	case operatorv1alpha1.RootCARotationIssuing:
+		r.nextRoot = true
+		r.bundle = previousPEM
+
+		dsc := &operatorv1alpha1.DiscoveryServiceCertificate{}
+		if err := r.client.Get(r.ctx, types.NamespacedName{Name: r.nextName, Namespace: r.ds.GetNamespace()}, dsc); err != nil {
+			if errors.IsNotFound(err) {
+				return ctrl.Result{Requeue: true}, nil
+			}
+			return ctrl.Result{}, err
+		}
+		if !dsc.Status.IsReady() {
+			// the DiscoveryServiceCertificate status change will trigger a new reconcile
+			return ctrl.Result{}, nil
+		}
+		r.setCondition(operatorv1alpha1.NewRootCAIssuedCondition, metav1.ConditionTrue, "Issued", "the new root CA has been issued")
+		r.nextPhase(operatorv1alpha1.RootCARotationPublishing)
+		logger.Info("new root CA issued")
+		return ctrl.Result{Requeue: true}, nil
+
+	case operatorv1alpha1.RootCARotationPublishing:
+		r.nextRoot = true
+		nextPEM, _, err := r.getCertificate(r.ds.GetNamespace(), r.nextName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.bundle = concatPEM(previousPEM, nextPEM)
+		r.setCondition(operatorv1alpha1.RootCABundlePublishedCondition, metav1.ConditionTrue, "Published",
+			"the bundle with the old and new root CAs has been published")
+
+		if wait := r.remaining(bundlePropagationDelay); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		// replace the root with the new one. This triggers the reissue
+		// of all the certificates signed by the root.
+		if err := r.promoteNextRoot(); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.nextPhase(operatorv1alpha1.RootCARotationReissuing)
+		logger.Info("new root CA promoted")
+		return ctrl.Result{Requeue: true}, nil
+
+	case operatorv1alpha1.RootCARotationReissuing:
+		r.nextRoot = true
+		r.bundle = concatPEM(previousPEM, rootPEM)
+
+		pending, err := r.pendingCertificates(root)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if pending > 0 {
+			logger.V(1).Info("waiting for certificates to be reissued", "pending", pending)
+			return ctrl.Result{RequeueAfter: reissueCheckInterval}, nil
+		}
+		r.setCondition(operatorv1alpha1.CertificatesReissuedCondition, metav1.ConditionTrue, "Reissued",
+			"all the certificates have been reissued under the new root CA")
+		r.nextPhase(operatorv1alpha1.RootCARotationRetiring)
+		logger.Info("certificates reissued under the new root CA")
+		return ctrl.Result{Requeue: true}, nil
+
+	case operatorv1alpha1.RootCARotationRetiring:
+		r.bundle = concatPEM(previousPEM, rootPEM)
+
+		if wait := r.remaining(bundlePropagationDelay); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		r.bundle = rootPEM
+		r.setCondition(operatorv1alpha1.OldRootCARetiredCondition, metav1.ConditionTrue, "Retired",
+			"the old root CA is no longer trusted")
+		r.rotation = nil
+		logger.Info("old root CA retired, rotation completed")
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// shouldStart returns true if a rotation needs to be started. If not, it also
+// returns the result to schedule the reconcile when the rotation is due.
+func (r *RootCARotationReconciler) shouldStart(root *x509.Certificate) (ctrl.Result, bool) {
+	if _, ok := r.ds.GetAnnotations()[operatorv1alpha1.RootCARotationAnnotation]; ok {
+		return ctrl.Result{}, true
+	}
+
+	// the root needs to be replaced if the private key configuration has changed
+	if pk := r.ds.GetPrivateKeyConfig(); pk != nil {
+		if err := pki.VerifyKey(root, pk.KeyOptions()); err != nil {
+			r.logger.Info("root CA private key does not match the configuration", "reason", err.Error())
+			return ctrl.Result{}, true
+		}
+	}
+
+	// rotate the root when 20% or less of its duration is left
+	duration := root.NotAfter.Sub(root.NotBefore)
+	rotateBefore := time.Duration(int64(math.Floor(float64(duration) * 0.20)))
+	timeToRotate := root.NotAfter.Sub(r.clock.Now()) - rotateBefore
+	if timeToRotate <= 0 {
+		return ctrl.Result{}, true
+	}
+	return ctrl.Result{RequeueAfter: timeToRotate}, false
+}
+
+// start initializes the rotation status and removes the annotation
+// that requested the rotation, if present
+func (r *RootCARotationReconciler) start(rootPEM []byte) error {
+	if _, ok := r.ds.GetAnnotations()[operatorv1alpha1.RootCARotationAnnotation]; ok {
+		patch := client.MergeFrom(r.ds.DeepCopy())
+		annotations := r.ds.GetAnnotations()
+		delete(annotations, operatorv1alpha1.RootCARotationAnnotation)
+		r.ds.SetAnnotations(annotations)
+		if err := r.client.Patch(r.ctx, r.ds, patch); err != nil {
+			return err
+		}
+	}
+
+	r.rotation = &operatorv1alpha1.RootCARotationStatus{
+		Phase:                   operatorv1alpha1.RootCARotationIssuing,
+		PhaseStartTime:          metav1.NewTime(r.clock.Now()),
+		PreviousRootCertificate: string(rootPEM),
+	}
+	for _, condition := range []string{
+		operatorv1alpha1.NewRootCAIssuedCondition,
+		operatorv1alpha1.RootCABundlePublishedCondition,
+		operatorv1alpha1.CertificatesReissuedCondition,
+		operatorv1alpha1.OldRootCARetiredCondition,
+	} {
+		r.setCondition(condition, metav1.ConditionFalse, "RotationInProgress", "root CA rotation in progress")
+	}
+	r.logger.Info("started root CA rotation")
+	return nil
+}
+
+func (r *RootCARotationReconciler) nextPhase(phase operatorv1alpha1.RootCARotationPhase) {
+	r.rotation.Phase = phase
+	r.rotation.PhaseStartTime = metav1.NewTime(r.clock.Now())
+}
+
+// remaining returns the time left until the given delay
+// has passed since the start of the current phase
+func (r *RootCARotationReconciler) remaining(delay time.Duration) time.Duration {
+	return r.rotation.PhaseStartTime.Add(delay).Sub(r.clock.Now())
+}
+
+func (r *RootCARotationReconciler) setCondition(condition string, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&r.conditions, metav1.Condition{
+		Type:               condition,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		LastTransitionTime: metav1.NewTime(r.clock.Now()),
+	})
+}
+
+// promoteNextRoot copies the new root CA into the Secret of the current root
+func (r *RootCARotationReconciler) promoteNextRoot() error {
+	next := &corev1.Secret{}
+	if err := r.client.Get(r.ctx, types.NamespacedName{Name: r.nextName, Namespace: r.ds.GetNamespace()}, next); err != nil {
+		return err
+	}
+	root := &corev1.Secret{}
+	if err := r.client.Get(r.ctx, types.NamespacedName{Name: r.rootName, Namespace: r.ds.GetNamespace()}, root); err != nil {
+		return err
+	}
+	root.Data = next.Data
+	return r.client.Update(r.ctx, root)
+}
+
+// pendingCertificates returns the number of certificates signed by the
+// root CA that have not yet been reissued under the given root. In multi-namespace
+// mode the client certificates issued in the watched namespaces are also checked.
+func (r *RootCARotationReconciler) pendingCertificates(root *x509.Certificate) (int, error) {
+	list := &operatorv1alpha1.DiscoveryServiceCertificateList{}
+	opts := []client.ListOption{}
+	if !r.ds.IsMultiNamespace() {
+		opts = append(opts, client.InNamespace(r.ds.GetNamespace()))
+	}
+	if err := r.client.List(r.ctx, list, opts...); err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, dsc := range list.Items {
+		if dsc.Spec.Signer.CASigned == nil || dsc.Spec.Signer.CASigned.SecretRef.Name != r.rootName {
+			continue
+		}
+		if ns := dsc.Spec.Signer.CASigned.SecretRef.Namespace; (ns == "" && dsc.GetNamespace() != r.ds.GetNamespace()) ||
+			(ns != "" && ns != r.ds.GetNamespace()) {
+			// signed by the root CA of another DiscoveryService
+			continue
+		}
+		_, cert, err := r.getCertificate(dsc.GetNamespace(), dsc.Spec.SecretRef.Name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				// not issued yet, it will be issued under the new root
+				continue
+			}
+			return 0, err
+		}
+		if err := pki.Verify(cert, root); err != nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (r *RootCARotationReconciler) getCertificate(namespace, name string) ([]byte, *x509.Certificate, error) {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(r.ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		return nil, nil, err
+	}
+	cert, err := pki.LoadX509Certificate(secret.Data[tlsCertificateKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	return secret.Data[tlsCertificateKey], cert, nil
+}
+
+func concatPEM(certs ...[]byte) []byte {
+	bundle := []byte{}
+	for _, cert := range certs {
+		bundle = append(bundle, bytes.TrimSpace(cert)...)
+		bundle = append(bundle, '\n')
+	}
+	return bundle
+}
+
+func copyConditions(conditions []metav1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	out := make([]metav1.Condition, len(conditions))
+	copy(out, conditions)
+	return out
+}
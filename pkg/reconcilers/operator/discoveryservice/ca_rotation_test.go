@@ -0,0 +1,274 @@
+package reconcilers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/util/clock"
+	"github.com/3scale-ops/marin3r/pkg/util/pki"
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var s *runtime.Scheme = scheme.Scheme
+
+func init() {
+	s.AddKnownTypes(operatorv1alpha1.GroupVersion,
+		&operatorv1alpha1.DiscoveryService{},
+		&operatorv1alpha1.DiscoveryServiceCertificate{},
+		&operatorv1alpha1.DiscoveryServiceCertificateList{},
+	)
+}
+
+func testCASecret(t *testing.T, name string, validFor time.Duration) (*corev1.Secret, *x509.Certificate) {
+	crt, key, err := pki.GenerateCertificate(nil, nil, "marin3r-ca-test", validFor, false, true, pki.KeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := pki.LoadX509Certificate(crt)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{"tls.crt": crt, "tls.key": key},
+	}, cert
+}
+
+func testLeafSecret(t *testing.T, name string, issuer *corev1.Secret) *corev1.Secret {
+	issuerCert, _ := pki.LoadX509Certificate(issuer.Data["tls.crt"])
+	issuerKey, _ := pki.DecodePrivateKeyBytes(issuer.Data["tls.key"])
+	crt, key, err := pki.GenerateCertificate(issuerCert, issuerKey, name, time.Hour, false, false, pki.KeyOptions{}, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{"tls.crt": crt, "tls.key": key},
+	}
+}
+
+func TestRootCARotationReconciler_Reconcile_not_due(t *testing.T) {
+	root, cert := testCASecret(t, "root", 100*time.Hour)
+	ds := &operatorv1alpha1.DiscoveryService{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		pkiConfig    *operatorv1alpha1.PKIConfig
+		want         ctrl.Result
+		wantRotation bool
+	}{
+		{
+			name: "Schedules the rotation when 20% of the root duration is left",
+			now:  cert.NotBefore.Add(50 * time.Hour),
+			want: ctrl.Result{RequeueAfter: cert.NotAfter.Add(-20 * time.Hour).Sub(cert.NotBefore.Add(50 * time.Hour))},
+		},
+		{
+			name:         "Starts the rotation when the root is close to expiration",
+			now:          cert.NotBefore.Add(90 * time.Hour),
+			wantRotation: true,
+		},
+		{
+			name: "Starts the rotation when the private key configuration changes",
+			now:  cert.NotBefore.Add(50 * time.Hour),
+			pkiConfig: &operatorv1alpha1.PKIConfig{
+				PrivateKey: &operatorv1alpha1.PrivateKeyConfig{Algorithm: operatorv1alpha1.ECDSAPrivateKeyAlgorithm},
+			},
+			wantRotation: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := ds.DeepCopy()
+			ds.Spec.PKIConfig = tt.pkiConfig
+			cl := fake.NewClientBuilder().WithScheme(s).WithObjects(ds.DeepCopy(), root.DeepCopy()).Build()
+			r := NewRootCARotationReconciler(context.TODO(), ctrl.Log, cl, ds, "root", "next")
+			r.clock = clock.NewTest(tt.now)
+			got, err := r.Reconcile()
+			if err != nil {
+				t.Fatalf("RootCARotationReconciler.Reconcile() error = %v", err)
+			}
+			if !tt.wantRotation && got != tt.want {
+				t.Errorf("RootCARotationReconciler.Reconcile() = %v, want %v", got, tt.want)
+			}
+			if (r.rotation != nil) != tt.wantRotation {
+				t.Errorf("RootCARotationReconciler.Reconcile() rotation = %v, want started = %v", r.rotation, tt.wantRotation)
+			}
+		})
+	}
+}
+
+func TestRootCARotationReconciler_Reconcile(t *testing.T) {
+	root, oldRoot := testCASecret(t, "root", 100*time.Hour)
+	next, newRoot := testCASecret(t, "next", 100*time.Hour)
+	oldPEM := root.Data["tls.crt"]
+	newPEM := next.Data["tls.crt"]
+	ds := &operatorv1alpha1.DiscoveryService{ObjectMeta: metav1.ObjectMeta{
+		Name: "test", Namespace: "default",
+		Annotations: map[string]string{operatorv1alpha1.RootCARotationAnnotation: "true"},
+	}}
+	leafDSC := &operatorv1alpha1.DiscoveryServiceCertificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "leaf", Namespace: "default"},
+		Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+			SecretRef: corev1.SecretReference{Name: "leaf"},
+			Signer: operatorv1alpha1.DiscoveryServiceCertificateSigner{
+				CASigned: &operatorv1alpha1.CASignedConfig{SecretRef: corev1.SecretReference{Name: "root"}},
+			},
+		},
+	}
+	nextDSC := &operatorv1alpha1.DiscoveryServiceCertificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "next", Namespace: "default"},
+	}
+
+	cl := fake.NewClientBuilder().WithScheme(s).
+		WithObjects(ds, root, leafDSC, testLeafSecret(t, "leaf", root)).
+		WithStatusSubresource(&operatorv1alpha1.DiscoveryService{}, &operatorv1alpha1.DiscoveryServiceCertificate{}).
+		Build()
+	now := oldRoot.NotBefore.Add(time.Hour)
+
+	// reconcile runs the rotation reconciler with the status stored by the previous run
+	reconcile := func(t *testing.T) (RootCARotationReconciler, ctrl.Result) {
+		if err := cl.Get(context.TODO(), types.NamespacedName{Name: "test", Namespace: "default"}, ds); err != nil {
+			t.Fatal(err)
+		}
+		r := NewRootCARotationReconciler(context.TODO(), ctrl.Log, cl, ds, "root", "next")
+		r.clock = clock.NewTest(now)
+		result, err := r.Reconcile()
+		if err != nil {
+			t.Fatalf("RootCARotationReconciler.Reconcile() error = %v", err)
+		}
+		if r.MutateStatus() {
+			if err := cl.Status().Update(context.TODO(), ds); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return r, result
+	}
+	assertPhase := func(t *testing.T, want operatorv1alpha1.RootCARotationPhase) {
+		if ds.Status.RootCARotation == nil || ds.Status.RootCARotation.Phase != want {
+			t.Fatalf("RootCARotationReconciler.Reconcile() rotation = %v, want phase %v", ds.Status.RootCARotation, want)
+		}
+	}
+	assertCondition := func(t *testing.T, condition string) {
+		if !meta.IsStatusConditionTrue(ds.Status.Conditions, condition) {
+			t.Errorf("RootCARotationReconciler.Reconcile() condition %s is not true", condition)
+		}
+	}
+	assertBundle := func(t *testing.T, r RootCARotationReconciler, want ...*x509.Certificate) {
+		got := []*x509.Certificate{}
+		for rest := r.GetBundle(); len(bytes.TrimSpace(rest)) > 0; {
+			cert, err := pki.LoadX509Certificate(rest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, cert)
+			rest = rest[bytes.Index(rest, []byte("-----END CERTIFICATE-----"))+len("-----END CERTIFICATE-----"):]
+		}
+		if len(got) != len(want) {
+			t.Fatalf("RootCARotationReconciler.GetBundle() has %d certificates, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if !got[i].Equal(want[i]) {
+				t.Errorf("RootCARotationReconciler.GetBundle() certificate %d = %s, want %s", i, got[i].SerialNumber, want[i].SerialNumber)
+			}
+		}
+	}
+
+	t.Run("Starts the rotation when the annotation is present", func(t *testing.T) {
+		r, _ := reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationIssuing)
+		if _, ok := ds.GetAnnotations()[operatorv1alpha1.RootCARotationAnnotation]; ok {
+			t.Errorf("RootCARotationReconciler.Reconcile() the annotation was not removed")
+		}
+		if !r.IsNextRootRequired() {
+			t.Errorf("RootCARotationReconciler.IsNextRootRequired() = false")
+		}
+		if ds.Status.RootCARotation.PreviousRootCertificate != string(oldPEM) {
+			t.Errorf("RootCARotationReconciler.Reconcile() previous root not stored")
+		}
+		assertBundle(t, r, oldRoot)
+	})
+
+	t.Run("Waits for the new root to be issued", func(t *testing.T) {
+		nextDSC.Status.Ready = pointer.New(true)
+		for _, o := range []client.Object{nextDSC, next} {
+			if err := cl.Create(context.TODO(), o); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cl.Status().Update(context.TODO(), nextDSC); err != nil {
+			t.Fatal(err)
+		}
+		reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationPublishing)
+		assertCondition(t, operatorv1alpha1.NewRootCAIssuedCondition)
+	})
+
+	t.Run("Publishes the bundle with both roots", func(t *testing.T) {
+		r, result := reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationPublishing)
+		assertCondition(t, operatorv1alpha1.RootCABundlePublishedCondition)
+		assertBundle(t, r, oldRoot, newRoot)
+		if result.RequeueAfter != bundlePropagationDelay {
+			t.Errorf("RootCARotationReconciler.Reconcile() = %v, want requeue after %v", result, bundlePropagationDelay)
+		}
+	})
+
+	t.Run("Promotes the new root after the propagation delay", func(t *testing.T) {
+		now = now.Add(bundlePropagationDelay)
+		reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationReissuing)
+		secret := &corev1.Secret{}
+		cl.Get(context.TODO(), types.NamespacedName{Name: "root", Namespace: "default"}, secret)
+		if !bytes.Equal(secret.Data["tls.crt"], newPEM) {
+			t.Errorf("RootCARotationReconciler.Reconcile() the root Secret holds the old root")
+		}
+	})
+
+	t.Run("Waits for the certificates to be reissued", func(t *testing.T) {
+		r, result := reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationReissuing)
+		assertBundle(t, r, oldRoot, newRoot)
+		if result.RequeueAfter != reissueCheckInterval {
+			t.Errorf("RootCARotationReconciler.Reconcile() = %v, want requeue after %v", result, reissueCheckInterval)
+		}
+
+		leaf := testLeafSecret(t, "leaf", next)
+		secret := &corev1.Secret{}
+		cl.Get(context.TODO(), types.NamespacedName{Name: "leaf", Namespace: "default"}, secret)
+		secret.Data = leaf.Data
+		if err := cl.Update(context.TODO(), secret); err != nil {
+			t.Fatal(err)
+		}
+		reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationRetiring)
+		assertCondition(t, operatorv1alpha1.CertificatesReissuedCondition)
+	})
+
+	t.Run("Retires the old root after the propagation delay", func(t *testing.T) {
+		r, _ := reconcile(t)
+		assertPhase(t, operatorv1alpha1.RootCARotationRetiring)
+		assertBundle(t, r, oldRoot, newRoot)
+
+		now = now.Add(bundlePropagationDelay)
+		r, _ = reconcile(t)
+		if ds.Status.RootCARotation != nil {
+			t.Errorf("RootCARotationReconciler.Reconcile() rotation = %v, want nil", ds.Status.RootCARotation)
+		}
+		assertCondition(t, operatorv1alpha1.OldRootCARetiredCondition)
+		assertBundle(t, r, newRoot)
+		if r.IsNextRootRequired() {
+			t.Errorf("RootCARotationReconciler.IsNextRootRequired() = true")
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package generators
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CABundle returns a function that generates the Secret holding the bundle of root
+// CAs trusted by the discovery service. It usually holds just the current root, but
+// holds both the old and the new roots while a root CA rotation is in progress.
+func (cfg *GeneratorOptions) CABundle(bundle []byte) func() *corev1.Secret {
+
+	return func() *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cfg.CABundleName(),
+				Namespace: cfg.Namespace,
+				Labels:    cfg.labels(),
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				"tls.crt": bundle,
+			},
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package generators
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGeneratorOptions_CABundle(t *testing.T) {
+	type args struct {
+		bundle []byte
+	}
+	tests := []struct {
+		name string
+		opts GeneratorOptions
+		args args
+		want *corev1.Secret
+	}{
+		{"Generates the Secret for the CA bundle",
+			GeneratorOptions{
+				InstanceName: "test",
+				Namespace:    "default",
+			},
+			args{bundle: []byte("old-root\nnew-root\n")},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "marin3r-ca-bundle-test",
+					Namespace: "default",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":       "marin3r",
+						"app.kubernetes.io/managed-by": "marin3r-operator",
+						"app.kubernetes.io/component":  "discovery-service",
+						"app.kubernetes.io/instance":   "test",
+					},
+				},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{
+					"tls.crt": []byte("old-root\nnew-root\n"),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.opts.CABundle(tt.args.bundle)(), tt.want); len(diff) > 0 {
+				t.Errorf("GeneratorOptions.CABundle() DIFF:\n %v", diff)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package generators
 
 import (
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	xdss "github.com/3scale-ops/marin3r/pkg/discoveryservice/xdss"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -27,6 +28,40 @@ func (cfg *GeneratorOptions) ClientCertificate() *operatorv1alpha1.DiscoveryServ
 			SecretRef: corev1.SecretReference{
 				Name: cfg.ClientCertName(),
 			},
+			PrivateKey: cfg.PrivateKey.DeepCopy(),
 		},
 	}
 }
+
+// NodeClientCertificate returns a client certificate for the envoy sidecars of the given
+// nodeID. The nodeID is added to the certificate's hosts so the discovery service can
+// verify that clients only request the configuration of their own nodeID.
+func (cfg *GeneratorOptions) NodeClientCertificate(nodeID string) func() *operatorv1alpha1.DiscoveryServiceCertificate {
+
+	return func() *operatorv1alpha1.DiscoveryServiceCertificate {
+		dsc := cfg.ClientCertificate()
+		dsc.ObjectMeta.Name = cfg.NodeClientCertName(nodeID)
+		dsc.ObjectMeta.Annotations = map[string]string{NodeIDAnnotation: nodeID}
+		dsc.Spec.CommonName = cfg.NodeClientCertName(nodeID)
+		dsc.Spec.Hosts = []string{nodeID}
+		if cfg.MultiNamespace {
+			dsc.Spec.Hosts = []string{xdss.NamespacedNodeID(cfg.Namespace, nodeID)}
+		}
+		dsc.Spec.SecretRef.Name = cfg.NodeClientCertName(nodeID)
+		return dsc
+	}
+}
+
+// NamespaceNodeClientCertificate returns a client certificate for the envoy sidecars of the
+// given nodeID in one of the watched namespaces. As nodeIDs are namespaced in multi-namespace
+// mode, the certificate is only valid for the nodeID in that namespace.
+func (cfg *GeneratorOptions) NamespaceNodeClientCertificate(namespace, nodeID string) func() *operatorv1alpha1.DiscoveryServiceCertificate {
+
+	return func() *operatorv1alpha1.DiscoveryServiceCertificate {
+		dsc := cfg.NodeClientCertificate(nodeID)()
+		dsc.ObjectMeta.Namespace = namespace
+		dsc.ObjectMeta.Labels = cfg.NamespaceLabels()
+		dsc.Spec.Hosts = []string{xdss.NamespacedNodeID(namespace, nodeID)}
+		return dsc
+	}
+}
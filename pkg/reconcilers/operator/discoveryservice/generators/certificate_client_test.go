@@ -60,3 +60,106 @@ func TestGeneratorOptions_ClientCertificate(t *testing.T) {
 		})
 	}
 }
+
+func TestGeneratorOptions_NodeClientCertificate(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   GeneratorOptions
+		nodeID string
+		want   *operatorv1alpha1.DiscoveryServiceCertificate
+	}{
+		{
+			name: "Generates DSC resource for the nodeID",
+			opts: GeneratorOptions{
+				InstanceName:              "instance",
+				Namespace:                 "default",
+				RootCertificateNamePrefix: "signing-cert",
+				ClientCertificateDuration: time.Duration(20 * time.Second),
+			},
+			nodeID: "node1",
+			want: &operatorv1alpha1.DiscoveryServiceCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "envoy-sidecar-client-cert-node1",
+					Namespace:   "default",
+					Annotations: map[string]string{"marin3r.3scale.net/node-id": "node1"},
+					Labels: map[string]string{
+						"app.kubernetes.io/name":       "marin3r",
+						"app.kubernetes.io/managed-by": "marin3r-operator",
+						"app.kubernetes.io/component":  "discovery-service",
+						"app.kubernetes.io/instance":   "instance",
+					},
+				},
+				Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+					CommonName: "envoy-sidecar-client-cert-node1",
+					ValidFor:   int64(time.Duration(20 * time.Second).Seconds()),
+					Hosts:      []string{"node1"},
+					Signer: operatorv1alpha1.DiscoveryServiceCertificateSigner{
+						CASigned: &operatorv1alpha1.CASignedConfig{
+							SecretRef: corev1.SecretReference{
+								Name:      "signing-cert-instance",
+								Namespace: "default",
+							}},
+					},
+					SecretRef: corev1.SecretReference{
+						Name: "envoy-sidecar-client-cert-node1",
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.opts.NodeClientCertificate(tt.nodeID)(), tt.want); len(diff) > 0 {
+				t.Errorf("GeneratorOptions.NodeClientCertificate() DIFF:\n %v", diff)
+			}
+		})
+	}
+}
+
+func TestGeneratorOptions_NamespaceNodeClientCertificate(t *testing.T) {
+	opts := GeneratorOptions{
+		InstanceName:              "instance",
+		Namespace:                 "default",
+		RootCertificateNamePrefix: "signing-cert",
+		ClientCertificateDuration: time.Duration(20 * time.Second),
+		MultiNamespace:            true,
+		WatchNamespaces:           []string{"other"},
+	}
+	want := &operatorv1alpha1.DiscoveryServiceCertificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "envoy-sidecar-client-cert-node1",
+			Namespace:   "other",
+			Annotations: map[string]string{"marin3r.3scale.net/node-id": "node1"},
+			Labels: map[string]string{
+				"app.kubernetes.io/name":                         "marin3r",
+				"app.kubernetes.io/managed-by":                   "marin3r-operator",
+				"app.kubernetes.io/component":                    "discovery-service",
+				"app.kubernetes.io/instance":                     "instance",
+				"marin3r.3scale.net/discovery-service-namespace": "default",
+			},
+		},
+		Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+			CommonName: "envoy-sidecar-client-cert-node1",
+			ValidFor:   int64(time.Duration(20 * time.Second).Seconds()),
+			Hosts:      []string{"other/node1"},
+			Signer: operatorv1alpha1.DiscoveryServiceCertificateSigner{
+				CASigned: &operatorv1alpha1.CASignedConfig{
+					SecretRef: corev1.SecretReference{
+						Name:      "signing-cert-instance",
+						Namespace: "default",
+					}},
+			},
+			SecretRef: corev1.SecretReference{
+				Name: "envoy-sidecar-client-cert-node1",
+			},
+		},
+	}
+	if diff := cmp.Diff(opts.NamespaceNodeClientCertificate("other", "node1")(), want); len(diff) > 0 {
+		t.Errorf("GeneratorOptions.NamespaceNodeClientCertificate() DIFF:\n %v", diff)
+	}
+
+	// the certificates of the discovery service's own namespace are also namespaced
+	if got := opts.NodeClientCertificate("node1")().Spec.Hosts; !cmp.Equal(got, []string{"default/node1"}) {
+		t.Errorf("GeneratorOptions.NodeClientCertificate() hosts = %v, want %v", got, []string{"default/node1"})
+	}
+}
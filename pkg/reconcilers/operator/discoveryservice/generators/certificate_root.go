@@ -9,11 +9,24 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// RootCertificationAuthority returns the DiscoveryServiceCertificate for the root CA. Renewal
+// is disabled because renewing the root would invalidate all the certificates at once, the
+// root is replaced by the staged root CA rotation instead.
 func (cfg *GeneratorOptions) RootCertificationAuthority() *operatorv1alpha1.DiscoveryServiceCertificate {
+	return cfg.rootCertificationAuthority(cfg.RootCertName())
+}
+
+// NextRootCertificationAuthority returns the DiscoveryServiceCertificate for the
+// root CA that replaces the current one during a root CA rotation
+func (cfg *GeneratorOptions) NextRootCertificationAuthority() *operatorv1alpha1.DiscoveryServiceCertificate {
+	return cfg.rootCertificationAuthority(cfg.NextRootCertName())
+}
+
+func (cfg *GeneratorOptions) rootCertificationAuthority(name string) *operatorv1alpha1.DiscoveryServiceCertificate {
 
 	return &operatorv1alpha1.DiscoveryServiceCertificate{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      cfg.RootCertName(),
+			Name:      name,
 			Namespace: cfg.Namespace,
 			Labels:    cfg.labels(),
 		},
@@ -25,12 +38,13 @@ func (cfg *GeneratorOptions) RootCertificationAuthority() *operatorv1alpha1.Disc
 				SelfSigned: &operatorv1alpha1.SelfSignedConfig{},
 			},
 			SecretRef: corev1.SecretReference{
-				Name:      cfg.RootCertName(),
+				Name:      name,
 				Namespace: cfg.Namespace,
 			},
 			CertificateRenewalConfig: &operatorv1alpha1.CertificateRenewalConfig{
-				Enabled: true,
+				Enabled: false,
 			},
+			PrivateKey: cfg.PrivateKey.DeepCopy(),
 		},
 	}
 }
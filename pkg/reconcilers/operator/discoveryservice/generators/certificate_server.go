@@ -32,6 +32,7 @@ func (cfg *GeneratorOptions) ServerCertificate() *operatorv1alpha1.DiscoveryServ
 				Name:      cfg.ServerCertName(),
 				Namespace: cfg.Namespace,
 			},
+			PrivateKey: cfg.PrivateKey.DeepCopy(),
 		},
 	}
 }
@@ -2,6 +2,7 @@ package generators
 
 import (
 	"fmt"
+	"strings"
 
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
 	"github.com/3scale-ops/marin3r/pkg/util/pointer"
@@ -22,7 +23,7 @@ func (cfg *GeneratorOptions) Deployment(hash string) func() *appsv1.Deployment {
 				Labels:    cfg.labels(),
 			},
 			Spec: appsv1.DeploymentSpec{
-				Replicas: pointer.New(int32(1)),
+				Replicas: pointer.New(cfg.replicas()),
 				Selector: &metav1.LabelSelector{
 					MatchLabels: cfg.labels(),
 				},
@@ -50,7 +51,7 @@ func (cfg *GeneratorOptions) Deployment(hash string) func() *appsv1.Deployment {
 								Name: "ca-cert",
 								VolumeSource: corev1.VolumeSource{
 									Secret: &corev1.SecretVolumeSource{
-										SecretName:  cfg.RootCertName(),
+										SecretName:  cfg.CABundleName(),
 										DefaultMode: pointer.New(int32(420)),
 									},
 								},
@@ -82,6 +83,19 @@ func (cfg *GeneratorOptions) Deployment(hash string) func() *appsv1.Deployment {
 									if cfg.Debug {
 										args = append(args, "--debug")
 									}
+									if cfg.EnforceNodeIDBinding {
+										args = append(args, "--enforce-node-id-binding")
+									}
+									if cfg.replicas() > 1 {
+										args = append(args, "--leader-elect")
+									}
+									if cfg.StatsCheckpointEnabled() {
+										args = append(args, fmt.Sprintf("--stats-checkpoint-configmap=%s", cfg.StatsCheckpointName()))
+									}
+									if cfg.MultiNamespace {
+										args = append(args, fmt.Sprintf("--watch-namespaces=%s",
+											strings.Join(append([]string{cfg.Namespace}, cfg.WatchNamespaces...), ",")))
+									}
 									return
 								}(),
 								Ports: []corev1.ContainerPort{
@@ -157,9 +171,7 @@ func (cfg *GeneratorOptions) Deployment(hash string) func() *appsv1.Deployment {
 						DeprecatedServiceAccount:      cfg.ResourceName(),
 					},
 				},
-				Strategy: appsv1.DeploymentStrategy{
-					Type: appsv1.RecreateDeploymentStrategyType,
-				},
+				Strategy: cfg.deploymentStrategy(),
 			},
 		}
 
@@ -170,3 +182,28 @@ func (cfg *GeneratorOptions) Deployment(hash string) func() *appsv1.Deployment {
 		return deployment
 	}
 }
+
+func (cfg *GeneratorOptions) replicas() int32 {
+	if cfg.Replicas < 1 {
+		return 1
+	}
+	return cfg.Replicas
+}
+
+// deploymentStrategy returns the Recreate strategy for a single replica, as there is
+// no other replica to keep serving the envoy clients during the rollout. With several
+// replicas, they are rolled one by one so the xDS server is always available.
+func (cfg *GeneratorOptions) deploymentStrategy() appsv1.DeploymentStrategy {
+	if cfg.replicas() == 1 {
+		return appsv1.DeploymentStrategy{
+			Type: appsv1.RecreateDeploymentStrategyType,
+		}
+	}
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: pointer.New(intstr.FromInt32(0)),
+			MaxSurge:       pointer.New(intstr.FromInt32(1)),
+		},
+	}
+}
@@ -1,6 +1,7 @@
 package generators
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -42,6 +43,7 @@ func TestGeneratorOptions_Deployment(t *testing.T) {
 				DeploymentResources:               corev1.ResourceRequirements{},
 				Debug:                             true,
 				PodPriorityClass:                  pointer.New("highest"),
+				EnforceNodeIDBinding:              true,
 			},
 			args{hash: "hash"},
 			&appsv1.Deployment{
@@ -90,7 +92,7 @@ func TestGeneratorOptions_Deployment(t *testing.T) {
 									Name: "ca-cert",
 									VolumeSource: corev1.VolumeSource{
 										Secret: &corev1.SecretVolumeSource{
-											SecretName:  "ca-cert-test",
+											SecretName:  "marin3r-ca-bundle-test",
 											DefaultMode: pointer.New(int32(420)),
 										},
 									},
@@ -118,6 +120,8 @@ func TestGeneratorOptions_Deployment(t *testing.T) {
 										"--metrics-bind-address=:1001",
 										"--health-probe-bind-address=:1002",
 										"--debug",
+										"--enforce-node-id-binding",
+										"--stats-checkpoint-configmap=marin3r-test-stats-checkpoint",
 									},
 									Ports: []corev1.ContainerPort{
 										{
@@ -208,3 +212,104 @@ func TestGeneratorOptions_Deployment(t *testing.T) {
 		})
 	}
 }
+
+func TestGeneratorOptions_Deployment_replicas(t *testing.T) {
+	tests := []struct {
+		name           string
+		replicas       int32
+		wantReplicas   int32
+		wantStrategy   appsv1.DeploymentStrategy
+		wantLeader     bool
+		wantCheckpoint bool
+	}{
+		{
+			name:           "Defaults to a single replica",
+			replicas:       0,
+			wantReplicas:   1,
+			wantStrategy:   appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			wantLeader:     false,
+			wantCheckpoint: true,
+		},
+		{
+			name:         "Several replicas with leader election",
+			replicas:     3,
+			wantReplicas: 3,
+			wantStrategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: pointer.New(intstr.FromInt32(0)),
+					MaxSurge:       pointer.New(intstr.FromInt32(1)),
+				},
+			},
+			wantLeader:     true,
+			wantCheckpoint: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := GeneratorOptions{InstanceName: "test", Namespace: "default", Replicas: tt.replicas}
+			dep := opts.Deployment("hash")()
+			if *dep.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("GeneratorOptions.Deployment() replicas = %v, want %v", *dep.Spec.Replicas, tt.wantReplicas)
+			}
+			if diff := cmp.Diff(dep.Spec.Strategy, tt.wantStrategy); len(diff) > 0 {
+				t.Errorf("GeneratorOptions.Deployment() strategy DIFF:\n %v", diff)
+			}
+			gotLeader, gotCheckpoint := false, false
+			for _, arg := range dep.Spec.Template.Spec.Containers[0].Args {
+				if arg == "--leader-elect" {
+					gotLeader = true
+				}
+				if strings.HasPrefix(arg, "--stats-checkpoint-configmap=") {
+					gotCheckpoint = true
+				}
+			}
+			if gotLeader != tt.wantLeader {
+				t.Errorf("GeneratorOptions.Deployment() leader election = %v, want %v", gotLeader, tt.wantLeader)
+			}
+			if gotCheckpoint != tt.wantCheckpoint {
+				t.Errorf("GeneratorOptions.Deployment() stats checkpoint = %v, want %v", gotCheckpoint, tt.wantCheckpoint)
+			}
+			if opts.StatsCheckpointEnabled() != tt.wantCheckpoint {
+				t.Errorf("GeneratorOptions.StatsCheckpointEnabled() = %v, want %v", opts.StatsCheckpointEnabled(), tt.wantCheckpoint)
+			}
+		})
+	}
+}
+
+func TestGeneratorOptions_Deployment_watchNamespaces(t *testing.T) {
+	tests := []struct {
+		name string
+		opts GeneratorOptions
+		want string
+	}{
+		{
+			name: "Single namespace mode",
+			opts: GeneratorOptions{InstanceName: "test", Namespace: "default"},
+			want: "",
+		},
+		{
+			name: "Multi-namespace mode",
+			opts: GeneratorOptions{InstanceName: "test", Namespace: "default", MultiNamespace: true, WatchNamespaces: []string{"ns1", "ns2"}},
+			want: "--watch-namespaces=default,ns1,ns2",
+		},
+		{
+			name: "Multi-namespace mode without other namespaces selected",
+			opts: GeneratorOptions{InstanceName: "test", Namespace: "default", MultiNamespace: true},
+			want: "--watch-namespaces=default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			for _, arg := range tt.opts.Deployment("hash")().Spec.Template.Spec.Containers[0].Args {
+				if strings.HasPrefix(arg, "--watch-namespaces") {
+					got = arg
+				}
+			}
+			if got != tt.want {
+				t.Errorf("GeneratorOptions.Deployment() watch namespaces arg = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
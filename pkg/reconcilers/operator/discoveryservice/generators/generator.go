@@ -9,6 +9,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+const (
+	// NodeIDAnnotation is the annotation that holds the nodeID
+	// of the client certificates issued for a nodeID
+	NodeIDAnnotation string = "marin3r.3scale.net/node-id"
+	// DiscoveryServiceNamespaceLabelKey is the label that holds the namespace of the
+	// DiscoveryService that manages a resource in one of its watched namespaces
+	DiscoveryServiceNamespaceLabelKey string = "marin3r.3scale.net/discovery-service-namespace"
+)
+
 type GeneratorOptions struct {
 	InstanceName                      string
 	Namespace                         string
@@ -19,6 +28,7 @@ type GeneratorOptions struct {
 	ServerCertificateCommonNamePrefix string
 	ServerCertificateDuration         time.Duration
 	ClientCertificateDuration         time.Duration
+	PrivateKey                        *operatorv1alpha1.PrivateKeyConfig
 	XdsServerPort                     int32
 	MetricsServerPort                 int32
 	ProbePort                         int32
@@ -27,6 +37,12 @@ type GeneratorOptions struct {
 	DeploymentResources               corev1.ResourceRequirements
 	Debug                             bool
 	PodPriorityClass                  *string
+	EnforceNodeIDBinding              bool
+	Replicas                          int32
+	// MultiNamespace is true if the discovery service serves the EnvoyConfigs of
+	// the WatchNamespaces, in addition to the ones in its own namespace
+	MultiNamespace  bool
+	WatchNamespaces []string
 }
 
 func (cfg *GeneratorOptions) labels() map[string]string {
@@ -38,10 +54,30 @@ func (cfg *GeneratorOptions) labels() map[string]string {
 	}
 }
 
+// NamespaceLabels returns the labels of the resources that the
+// discovery service requires in each of its watched namespaces
+func (cfg *GeneratorOptions) NamespaceLabels() map[string]string {
+	labels := cfg.labels()
+	labels[DiscoveryServiceNamespaceLabelKey] = cfg.Namespace
+	return labels
+}
+
 func (cfg *GeneratorOptions) RootCertName() string {
 	return fmt.Sprintf("%s-%s", cfg.RootCertificateNamePrefix, cfg.InstanceName)
 }
 
+func (cfg *GeneratorOptions) NextRootCertName() string {
+	return fmt.Sprintf("%s-next", cfg.RootCertName())
+}
+
+func (cfg *GeneratorOptions) CABundleName() string {
+	return fmt.Sprintf("%s-%s", "marin3r-ca-bundle", cfg.InstanceName)
+}
+
+func (cfg *GeneratorOptions) StatsCheckpointName() string {
+	return fmt.Sprintf("%s-stats-checkpoint", cfg.ResourceName())
+}
+
 func (cfg *GeneratorOptions) ServerCertName() string {
 	return fmt.Sprintf("%s-%s", cfg.ServerCertificateNamePrefix, cfg.InstanceName)
 }
@@ -50,6 +86,17 @@ func (cfg *GeneratorOptions) ClientCertName() string {
 	return defaults.SidecarClientCertificate
 }
 
+func (cfg *GeneratorOptions) NodeClientCertName(nodeID string) string {
+	return defaults.SidecarNodeClientCertificate(nodeID)
+}
+
 func (cfg *GeneratorOptions) ResourceName() string {
 	return fmt.Sprintf("%s-%s", "marin3r", cfg.InstanceName)
 }
+
+// NamespaceResourceName returns the name of the resources in the watched namespaces. The
+// namespace of the discovery service is included as several discovery services can watch
+// the same namespace.
+func (cfg *GeneratorOptions) NamespaceResourceName() string {
+	return fmt.Sprintf("%s-%s-%s", "marin3r", cfg.Namespace, cfg.InstanceName)
+}
@@ -2,6 +2,7 @@ package generators
 
 import (
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -19,9 +20,22 @@ func (cfg *GeneratorOptions) Role() *rbacv1.Role {
 		Rules: []rbacv1.PolicyRule{
 			{
 				APIGroups: []string{corev1.SchemeGroupVersion.Group},
-				Resources: []string{"secrets", "pods"},
+				Resources: []string{"secrets", "pods", "configmaps"},
 				Verbs:     []string{"get", "list", "watch"},
 			},
+			{
+				// the replicas of the discovery service share their stats through ConfigMaps,
+				// and a single replica checkpoints them to a ConfigMap
+				APIGroups: []string{corev1.SchemeGroupVersion.Group},
+				Resources: []string{"configmaps"},
+				Verbs:     []string{"create", "update", "delete"},
+			},
+			{
+				// leader election between the replicas of the discovery service
+				APIGroups: []string{coordinationv1.SchemeGroupVersion.Group},
+				Resources: []string{"leases"},
+				Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+			},
 			{
 				APIGroups: []string{marin3rv1alpha1.GroupVersion.Group},
 				Resources: []string{rbacv1.ResourceAll},
@@ -32,6 +46,50 @@ func (cfg *GeneratorOptions) Role() *rbacv1.Role {
 				Resources: []string{"endpointslices"},
 				Verbs:     []string{"get", "list", "watch"},
 			},
+			{
+				APIGroups: []string{corev1.SchemeGroupVersion.Group},
+				Resources: []string{"events"},
+				Verbs:     []string{"create", "patch"},
+			},
 		},
 	}
 }
+
+// NamespaceRole returns the Role that grants the discovery service access to the
+// EnvoyConfigs, and the resources they reference, of one of its watched namespaces.
+// Stats are shared and leader election takes place in the discovery service's own
+// namespace, so write access to ConfigMaps and Leases is not required.
+func (cfg *GeneratorOptions) NamespaceRole(namespace string) func() *rbacv1.Role {
+
+	return func() *rbacv1.Role {
+		return &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cfg.NamespaceResourceName(),
+				Namespace: namespace,
+				Labels:    cfg.NamespaceLabels(),
+			},
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{corev1.SchemeGroupVersion.Group},
+					Resources: []string{"secrets", "pods", "configmaps"},
+					Verbs:     []string{"get", "list", "watch"},
+				},
+				{
+					APIGroups: []string{marin3rv1alpha1.GroupVersion.Group},
+					Resources: []string{rbacv1.ResourceAll},
+					Verbs:     []string{rbacv1.VerbAll},
+				},
+				{
+					APIGroups: []string{discoveryv1.SchemeGroupVersion.Group},
+					Resources: []string{"endpointslices"},
+					Verbs:     []string{"get", "list", "watch"},
+				},
+				{
+					APIGroups: []string{corev1.SchemeGroupVersion.Group},
+					Resources: []string{"events"},
+					Verbs:     []string{"create", "patch"},
+				},
+			},
+		}
+	}
+}
@@ -27,3 +27,19 @@ func (cfg *GeneratorOptions) RoleBinding() *rbacv1.RoleBinding {
 		},
 	}
 }
+
+// NamespaceRoleBinding returns the RoleBinding that binds the NamespaceRole
+// of one of the watched namespaces to the discovery service's ServiceAccount
+func (cfg *GeneratorOptions) NamespaceRoleBinding(namespace string) func() *rbacv1.RoleBinding {
+
+	return func() *rbacv1.RoleBinding {
+		rb := cfg.RoleBinding()
+		rb.ObjectMeta = metav1.ObjectMeta{
+			Name:      cfg.NamespaceResourceName(),
+			Namespace: namespace,
+			Labels:    cfg.NamespaceLabels(),
+		}
+		rb.RoleRef.Name = cfg.NamespaceResourceName()
+		return rb
+	}
+}
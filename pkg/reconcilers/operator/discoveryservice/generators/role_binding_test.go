@@ -74,3 +74,35 @@ func TestGeneratorOptions_RoleBinding(t *testing.T) {
 		})
 	}
 }
+
+func TestGeneratorOptions_NamespaceRoleBinding(t *testing.T) {
+	opts := GeneratorOptions{InstanceName: "test", Namespace: "default"}
+	want := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marin3r-default-test",
+			Namespace: "other",
+			Labels: map[string]string{
+				"app.kubernetes.io/name":                         "marin3r",
+				"app.kubernetes.io/managed-by":                   "marin3r-operator",
+				"app.kubernetes.io/component":                    "discovery-service",
+				"app.kubernetes.io/instance":                     "test",
+				"marin3r.3scale.net/discovery-service-namespace": "default",
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.SchemeGroupVersion.Group,
+			Kind:     "Role",
+			Name:     "marin3r-default-test",
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      "marin3r-test",
+				Namespace: "default",
+			},
+		},
+	}
+	if diff := cmp.Diff(opts.NamespaceRoleBinding("other")(), want); len(diff) > 0 {
+		t.Errorf("GeneratorOptions.NamespaceRoleBinding() DIFF:\n %v", diff)
+	}
+}
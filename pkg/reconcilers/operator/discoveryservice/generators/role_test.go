@@ -7,6 +7,7 @@ import (
 	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
 	"github.com/google/go-cmp/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -56,9 +57,19 @@ func TestGeneratorOptions_Role(t *testing.T) {
 				Rules: []rbacv1.PolicyRule{
 					{
 						APIGroups: []string{corev1.SchemeGroupVersion.Group},
-						Resources: []string{"secrets", "pods"},
+						Resources: []string{"secrets", "pods", "configmaps"},
 						Verbs:     []string{"get", "list", "watch"},
 					},
+					{
+						APIGroups: []string{corev1.SchemeGroupVersion.Group},
+						Resources: []string{"configmaps"},
+						Verbs:     []string{"create", "update", "delete"},
+					},
+					{
+						APIGroups: []string{coordinationv1.SchemeGroupVersion.Group},
+						Resources: []string{"leases"},
+						Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+					},
 					{
 						APIGroups: []string{marin3rv1alpha1.GroupVersion.Group},
 						Resources: []string{rbacv1.ResourceAll},
@@ -69,6 +80,11 @@ func TestGeneratorOptions_Role(t *testing.T) {
 						Resources: []string{"endpointslices"},
 						Verbs:     []string{"get", "list", "watch"},
 					},
+					{
+						APIGroups: []string{corev1.SchemeGroupVersion.Group},
+						Resources: []string{"events"},
+						Verbs:     []string{"create", "patch"},
+					},
 				},
 			},
 		},
@@ -81,3 +97,45 @@ func TestGeneratorOptions_Role(t *testing.T) {
 		})
 	}
 }
+
+func TestGeneratorOptions_NamespaceRole(t *testing.T) {
+	opts := GeneratorOptions{InstanceName: "test", Namespace: "default"}
+	want := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "marin3r-default-test",
+			Namespace: "other",
+			Labels: map[string]string{
+				"app.kubernetes.io/name":                         "marin3r",
+				"app.kubernetes.io/managed-by":                   "marin3r-operator",
+				"app.kubernetes.io/component":                    "discovery-service",
+				"app.kubernetes.io/instance":                     "test",
+				"marin3r.3scale.net/discovery-service-namespace": "default",
+			},
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{corev1.SchemeGroupVersion.Group},
+				Resources: []string{"secrets", "pods", "configmaps"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{marin3rv1alpha1.GroupVersion.Group},
+				Resources: []string{rbacv1.ResourceAll},
+				Verbs:     []string{rbacv1.VerbAll},
+			},
+			{
+				APIGroups: []string{discoveryv1.SchemeGroupVersion.Group},
+				Resources: []string{"endpointslices"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{corev1.SchemeGroupVersion.Group},
+				Resources: []string{"events"},
+				Verbs:     []string{"create", "patch"},
+			},
+		},
+	}
+	if diff := cmp.Diff(opts.NamespaceRole("other")(), want); len(diff) > 0 {
+		t.Errorf("GeneratorOptions.NamespaceRole() DIFF:\n %v", diff)
+	}
+}
@@ -0,0 +1,26 @@
+package generators
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StatsCheckpoint returns the ConfigMap where the discovery service checkpoints its
+// stats, so they survive the replacement of the pod. Its data is owned by the
+// discovery service.
+func (cfg *GeneratorOptions) StatsCheckpoint() *corev1.ConfigMap {
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cfg.StatsCheckpointName(),
+			Namespace: cfg.Namespace,
+			Labels:    cfg.labels(),
+		},
+	}
+}
+
+// StatsCheckpointEnabled returns true if the discovery service checkpoints its stats. With
+// several replicas the stats are replicated between them, which already covers rollouts.
+func (cfg *GeneratorOptions) StatsCheckpointEnabled() bool {
+	return cfg.replicas() == 1
+}
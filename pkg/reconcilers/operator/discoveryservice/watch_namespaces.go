@@ -0,0 +1,227 @@
+package reconcilers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/envoy/container/defaults"
+	"github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservice/generators"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// WatchNamespaces returns the sorted list of namespaces, other than its own, whose EnvoyConfigs
+// are served by the DiscoveryService. Namespaces that don't exist or are being deleted are
+// not returned. Returns nil if the DiscoveryService is not in multi-namespace mode.
+func WatchNamespaces(ctx context.Context, cl client.Client, ds *operatorv1alpha1.DiscoveryService) ([]string, error) {
+	if !ds.IsMultiNamespace() {
+		return nil, nil
+	}
+
+	names := map[string]struct{}{}
+	for _, name := range ds.Spec.WatchNamespaces.Names {
+		names[name] = struct{}{}
+	}
+	var selector labels.Selector
+	if ds.Spec.WatchNamespaces.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(ds.Spec.WatchNamespaces.Selector); err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %w", err)
+		}
+	}
+
+	list := &corev1.NamespaceList{}
+	if err := cl.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		if ns.GetName() == ds.GetNamespace() || ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		_, named := names[ns.GetName()]
+		if named || (selector != nil && selector.Matches(labels.Set(ns.GetLabels()))) {
+			namespaces = append(namespaces, ns.GetName())
+		}
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, nil
+}
+
+// NodeIDs returns the sorted list of nodeIDs of the EnvoyConfigs in the namespace. NodeIDs
+// that cannot be used in the name of a client certificate are not returned.
+func NodeIDs(ctx context.Context, cl client.Client, namespace string) ([]string, error) {
+	list := &marin3rv1alpha1.EnvoyConfigList{}
+	if err := cl.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	unique := map[string]struct{}{}
+	for _, ec := range list.Items {
+		if defaults.ValidateSidecarNodeClientCertificate(ec.Spec.NodeID) != nil {
+			continue
+		}
+		unique[ec.Spec.NodeID] = struct{}{}
+	}
+	nodeIDs := make([]string, 0, len(unique))
+	for nodeID := range unique {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	return nodeIDs, nil
+}
+
+// WatchNamespacesReconciler is a struct with methods to manage the resources that a
+// DiscoveryService requires in each of its watched namespaces: the Role and RoleBinding
+// that grant access to the namespace and the client certificates of the nodeIDs in the
+// namespace. Resources in other namespaces cannot be owned by the DiscoveryService, so they
+// are labeled and deleted by this reconciler when no longer required.
+type WatchNamespacesReconciler struct {
+	ctx    context.Context
+	logger logr.Logger
+	client client.Client
+	gen    generators.GeneratorOptions
+}
+
+// NewWatchNamespacesReconciler returns a new WatchNamespacesReconciler. The
+// watched namespaces are read from the WatchNamespaces field of the generator.
+func NewWatchNamespacesReconciler(ctx context.Context, logger logr.Logger, client client.Client,
+	gen generators.GeneratorOptions) WatchNamespacesReconciler {
+
+	return WatchNamespacesReconciler{ctx, logger, client, gen}
+}
+
+// Reconcile creates or updates the resources in the watched namespaces
+// and deletes the ones in namespaces that are no longer watched
+func (r *WatchNamespacesReconciler) Reconcile() error {
+
+	desired := []client.Object{}
+	for _, namespace := range r.gen.WatchNamespaces {
+		desired = append(desired, r.gen.NamespaceRole(namespace)(), r.gen.NamespaceRoleBinding(namespace)())
+
+		nodeIDs, err := NodeIDs(r.ctx, r.client, namespace)
+		if err != nil {
+			return err
+		}
+		for _, nodeID := range nodeIDs {
+			dsc := r.gen.NamespaceNodeClientCertificate(namespace, nodeID)()
+			dsc.Default()
+			desired = append(desired, dsc)
+		}
+	}
+
+	for _, obj := range desired {
+		if err := r.apply(obj); err != nil {
+			return err
+		}
+	}
+
+	return r.prune(desired)
+}
+
+// Cleanup deletes all the resources of the DiscoveryService in the watched
+// namespaces. It is invoked when the DiscoveryService is deleted.
+func (r *WatchNamespacesReconciler) Cleanup() error {
+	return r.prune(nil)
+}
+
+func (r *WatchNamespacesReconciler) apply(desired client.Object) error {
+	var live client.Object
+	var mutate controllerutil.MutateFn
+
+	key := metav1.ObjectMeta{Name: desired.GetName(), Namespace: desired.GetNamespace()}
+	switch o := desired.(type) {
+	case *rbacv1.Role:
+		role := &rbacv1.Role{ObjectMeta: key}
+		live, mutate = role, func() error {
+			role.SetLabels(o.GetLabels())
+			role.Rules = o.Rules
+			return nil
+		}
+	case *rbacv1.RoleBinding:
+		rb := &rbacv1.RoleBinding{ObjectMeta: key}
+		live, mutate = rb, func() error {
+			rb.SetLabels(o.GetLabels())
+			rb.RoleRef = o.RoleRef
+			rb.Subjects = o.Subjects
+			return nil
+		}
+	case *operatorv1alpha1.DiscoveryServiceCertificate:
+		dsc := &operatorv1alpha1.DiscoveryServiceCertificate{ObjectMeta: key}
+		live, mutate = dsc, func() error {
+			dsc.SetLabels(o.GetLabels())
+			dsc.SetAnnotations(o.GetAnnotations())
+			dsc.Spec = o.Spec
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported resource type %T", desired)
+	}
+
+	op, err := controllerutil.CreateOrUpdate(r.ctx, r.client, live, mutate)
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		r.logger.Info(fmt.Sprintf("resource %s", op), "kind", fmt.Sprintf("%T", live),
+			"name", live.GetName(), "namespace", live.GetNamespace())
+	}
+	return nil
+}
+
+// prune deletes the labeled resources of the DiscoveryService in
+// other namespaces that are not in the list of desired resources
+func (r *WatchNamespacesReconciler) prune(desired []client.Object) error {
+
+	keep := map[string]struct{}{}
+	for _, obj := range desired {
+		keep[fmt.Sprintf("%T/%s/%s", obj, obj.GetNamespace(), obj.GetName())] = struct{}{}
+	}
+
+	roles := &rbacv1.RoleList{}
+	bindings := &rbacv1.RoleBindingList{}
+	dscs := &operatorv1alpha1.DiscoveryServiceCertificateList{}
+	for _, list := range []client.ObjectList{roles, bindings, dscs} {
+		if err := r.client.List(r.ctx, list, client.MatchingLabels(r.gen.NamespaceLabels())); err != nil {
+			return err
+		}
+	}
+
+	live := []client.Object{}
+	for idx := range roles.Items {
+		live = append(live, &roles.Items[idx])
+	}
+	for idx := range bindings.Items {
+		live = append(live, &bindings.Items[idx])
+	}
+	for idx := range dscs.Items {
+		live = append(live, &dscs.Items[idx])
+	}
+
+	for _, obj := range live {
+		if obj.GetNamespace() == r.gen.Namespace {
+			continue
+		}
+		if _, ok := keep[fmt.Sprintf("%T/%s/%s", obj, obj.GetNamespace(), obj.GetName())]; ok {
+			continue
+		}
+		if err := r.client.Delete(r.ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		r.logger.Info("resource deleted", "kind", fmt.Sprintf("%T", obj), "name", obj.GetName(), "namespace", obj.GetNamespace())
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package reconcilers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	marin3rv1alpha1 "github.com/3scale-ops/marin3r/apis/marin3r/v1alpha1"
+	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservice/generators"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testWatchNamespacesScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme, operatorv1alpha1.AddToScheme, marin3rv1alpha1.AddToScheme,
+	} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return scheme
+}
+
+func testNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestWatchNamespaces(t *testing.T) {
+	terminating := testNamespace("terminating", map[string]string{"tenant": "true"})
+	terminating.Status.Phase = corev1.NamespaceTerminating
+	cl := fake.NewClientBuilder().WithScheme(testWatchNamespacesScheme(t)).WithObjects(
+		testNamespace("default", map[string]string{"tenant": "true"}),
+		testNamespace("ns1", nil),
+		testNamespace("ns2", map[string]string{"tenant": "true"}),
+		testNamespace("ns3", map[string]string{"tenant": "false"}),
+		terminating,
+	).Build()
+
+	tests := []struct {
+		name string
+		spec operatorv1alpha1.DiscoveryServiceSpec
+		want []string
+	}{
+		{
+			name: "Single namespace mode",
+			spec: operatorv1alpha1.DiscoveryServiceSpec{},
+			want: nil,
+		},
+		{
+			name: "Selects namespaces by name, ignoring the ones that don't exist",
+			spec: operatorv1alpha1.DiscoveryServiceSpec{WatchNamespaces: &operatorv1alpha1.WatchNamespacesConfig{
+				Names: []string{"ns3", "ns1", "missing"},
+			}},
+			want: []string{"ns1", "ns3"},
+		},
+		{
+			name: "Selects namespaces by label",
+			spec: operatorv1alpha1.DiscoveryServiceSpec{WatchNamespaces: &operatorv1alpha1.WatchNamespacesConfig{
+				Names:    []string{"ns1"},
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}},
+			}},
+			want: []string{"ns1", "ns2"},
+		},
+		{
+			name: "An empty selector selects all namespaces",
+			spec: operatorv1alpha1.DiscoveryServiceSpec{WatchNamespaces: &operatorv1alpha1.WatchNamespacesConfig{
+				Selector: &metav1.LabelSelector{},
+			}},
+			want: []string{"ns1", "ns2", "ns3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := &operatorv1alpha1.DiscoveryService{
+				ObjectMeta: metav1.ObjectMeta{Name: "ds", Namespace: "default"},
+				Spec:       tt.spec,
+			}
+			got, err := WatchNamespaces(context.TODO(), cl, ds)
+			if err != nil {
+				t.Fatalf("WatchNamespaces() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WatchNamespaces() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchNamespacesReconciler(t *testing.T) {
+	gen := generators.GeneratorOptions{
+		InstanceName:              "ds",
+		Namespace:                 "default",
+		RootCertificateNamePrefix: "marin3r-ca-cert",
+		MultiNamespace:            true,
+		WatchNamespaces:           []string{"ns1", "ns2"},
+	}
+	cl := fake.NewClientBuilder().WithScheme(testWatchNamespacesScheme(t)).WithObjects(
+		&marin3rv1alpha1.EnvoyConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "ec", Namespace: "ns1"},
+			Spec:       marin3rv1alpha1.EnvoyConfigSpec{NodeID: "node1"},
+		},
+		// a nodeID that can't be used in the name of a client certificate
+		&marin3rv1alpha1.EnvoyConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "ns1"},
+			Spec:       marin3rv1alpha1.EnvoyConfigSpec{NodeID: "Node_1"},
+		},
+		// a resource of a namespace that is no longer watched
+		gen.NamespaceRole("ns3")(),
+		// a resource of another discovery service
+		(&generators.GeneratorOptions{InstanceName: "ds", Namespace: "other"}).NamespaceRole("ns3")(),
+	).Build()
+
+	r := NewWatchNamespacesReconciler(context.TODO(), ctrl.Log.WithName("test"), cl, gen)
+	if err := r.Reconcile(); err != nil {
+		t.Fatalf("WatchNamespacesReconciler.Reconcile() error = %v", err)
+	}
+
+	exists := func(obj client.Object, namespace, name string) bool {
+		return cl.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, obj) == nil
+	}
+	for _, ns := range []string{"ns1", "ns2"} {
+		if !exists(&rbacv1.Role{}, ns, "marin3r-default-ds") || !exists(&rbacv1.RoleBinding{}, ns, "marin3r-default-ds") {
+			t.Errorf("WatchNamespacesReconciler.Reconcile() missing RBAC in namespace %s", ns)
+		}
+	}
+	dsc := &operatorv1alpha1.DiscoveryServiceCertificate{}
+	if !exists(dsc, "ns1", "envoy-sidecar-client-cert-node1") {
+		t.Errorf("WatchNamespacesReconciler.Reconcile() missing client certificate for nodeID node1")
+	} else if !reflect.DeepEqual(dsc.Spec.Hosts, []string{"ns1/node1"}) {
+		t.Errorf("WatchNamespacesReconciler.Reconcile() client certificate hosts = %v", dsc.Spec.Hosts)
+	}
+	if list := (&operatorv1alpha1.DiscoveryServiceCertificateList{}); cl.List(context.TODO(), list, client.InNamespace("ns1")) != nil || len(list.Items) != 1 {
+		t.Errorf("WatchNamespacesReconciler.Reconcile() got %d client certificates in ns1, want 1", len(list.Items))
+	}
+	if exists(&rbacv1.Role{}, "ns3", "marin3r-default-ds") {
+		t.Errorf("WatchNamespacesReconciler.Reconcile() did not delete the Role of an unwatched namespace")
+	}
+	if !exists(&rbacv1.Role{}, "ns3", "marin3r-other-ds") {
+		t.Errorf("WatchNamespacesReconciler.Reconcile() deleted the Role of another DiscoveryService")
+	}
+
+	if err := r.Cleanup(); err != nil {
+		t.Fatalf("WatchNamespacesReconciler.Cleanup() error = %v", err)
+	}
+	for _, ns := range []string{"ns1", "ns2"} {
+		if exists(&rbacv1.Role{}, ns, "marin3r-default-ds") || exists(&rbacv1.RoleBinding{}, ns, "marin3r-default-ds") {
+			t.Errorf("WatchNamespacesReconciler.Cleanup() did not delete the RBAC in namespace %s", ns)
+		}
+	}
+	if exists(&operatorv1alpha1.DiscoveryServiceCertificate{}, "ns1", "envoy-sidecar-client-cert-node1") {
+		t.Errorf("WatchNamespacesReconciler.Cleanup() did not delete the client certificate")
+	}
+}
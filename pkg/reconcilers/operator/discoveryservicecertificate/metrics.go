@@ -0,0 +1,46 @@
+package reconcilers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	certificateNotAfter = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "marin3r_discoveryservicecertificate_not_after_timestamp_seconds",
+			Help: "Expiration time of the certificate, as a unix timestamp",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	certificateRenewalFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "marin3r_discoveryservicecertificate_renewal_failures_total",
+			Help: "Number of failed attempts to renew the certificate",
+		},
+		[]string{"namespace", "name"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(certificateNotAfter, certificateRenewalFailures)
+}
+
+func observeNotAfter(namespace, name string, notAfter time.Time) {
+	certificateNotAfter.WithLabelValues(namespace, name).Set(float64(notAfter.Unix()))
+}
+
+func observeRenewalFailure(namespace, name string) {
+	certificateRenewalFailures.WithLabelValues(namespace, name).Inc()
+}
+
+// DeleteMetrics removes the metrics of the DiscoveryServiceCertificate
+// with the given namespace and name. It should be called once the
+// DiscoveryServiceCertificate has been deleted.
+func DeleteMetrics(namespace, name string) {
+	certificateNotAfter.DeleteLabelValues(namespace, name)
+	certificateRenewalFailures.DeleteLabelValues(namespace, name)
+}
@@ -0,0 +1,345 @@
+package providers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"time"
+
+	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/util/pki"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	tlsCertificateKey = "tls.crt"
+	tlsPrivateKeyKey  = "tls.key"
+	caCertificateKey  = "ca.crt"
+
+	defaultIssuerKind  = "Issuer"
+	defaultIssuerGroup = "cert-manager.io"
+)
+
+// CertificateGVK is the GroupVersionKind of cert-manager Certificates. The
+// cert-manager API is used through unstructured objects so marin3r does not
+// need to depend on cert-manager's go module.
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// CertificateProvider is a certificate provider that delegates the
+// issuance of certificates to cert-manager
+type CertificateProvider struct {
+	ctx    context.Context
+	logger logr.Logger
+	client client.Client
+	scheme *runtime.Scheme
+	dsc    *operatorv1alpha1.DiscoveryServiceCertificate
+}
+
+// NewCertificateProvider returns a CertificateProvider struct for the given parameters
+func NewCertificateProvider(ctx context.Context, logger logr.Logger, client client.Client,
+	scheme *runtime.Scheme, dsc *operatorv1alpha1.DiscoveryServiceCertificate) *CertificateProvider {
+
+	return &CertificateProvider{
+		ctx:    ctx,
+		logger: logger,
+		client: client,
+		scheme: scheme,
+		dsc:    dsc,
+	}
+}
+
+// CreateCertificate creates the cert-manager Certificate for the
+// DiscoveryServiceCertificate. The certificate is issued asynchronously
+// by cert-manager so no certificate data is returned.
+func (cp *CertificateProvider) CreateCertificate() ([]byte, []byte, error) {
+	logger := cp.logger.WithValues("method", "CreateCertificate")
+
+	if err := cp.applyCertificate(); err != nil {
+		logger.Error(err, "unable to apply cert-manager Certificate")
+		return nil, nil, err
+	}
+
+	logger.V(1).Info("requested certificate to cert-manager")
+	return nil, nil, nil
+}
+
+// GetCertificate loads a certificate form the Secret referred in the
+// DiscoveryServiceCertificate resource, which is written by cert-manager
+func (cp *CertificateProvider) GetCertificate() ([]byte, []byte, error) {
+	logger := cp.logger.WithValues("method", "GetCertificate")
+
+	secret, err := cp.getSecret()
+	if err != nil {
+		logger.Error(err, "unable to get Secret")
+		return nil, nil, err
+	}
+
+	return secret.Data[tlsCertificateKey], secret.Data[tlsPrivateKeyKey], nil
+}
+
+// UpdateCertificate makes cert-manager reissue the certificate. It does so the
+// same way cmctl does, by setting the Issuing condition of the Certificate.
+func (cp *CertificateProvider) UpdateCertificate() ([]byte, []byte, error) {
+	logger := cp.logger.WithValues("method", "UpdateCertificate")
+
+	if err := cp.applyCertificate(); err != nil {
+		logger.Error(err, "unable to apply cert-manager Certificate")
+		return nil, nil, err
+	}
+
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(CertificateGVK)
+	if err := cp.client.Get(cp.ctx, cp.certificateKey(), cert); err != nil {
+		logger.Error(err, "unable to get cert-manager Certificate")
+		return nil, nil, err
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(cert.Object, "status", "conditions")
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]interface{}); ok && cond["type"] == "Issuing" && cond["status"] == "True" {
+			// Already reissuing
+			return cp.GetCertificate()
+		}
+	}
+	conditions = append(conditions, map[string]interface{}{
+		"type":               "Issuing",
+		"status":             "True",
+		"reason":             "ManuallyTriggered",
+		"message":            "Certificate re-issuance requested by marin3r",
+		"lastTransitionTime": metav1.Now().UTC().Format(time.RFC3339),
+	})
+	if err := unstructured.SetNestedSlice(cert.Object, conditions, "status", "conditions"); err != nil {
+		return nil, nil, err
+	}
+	if err := cp.client.Status().Update(cp.ctx, cert); err != nil {
+		logger.Error(err, "unable to trigger reissue of cert-manager Certificate")
+		return nil, nil, err
+	}
+
+	logger.V(1).Info("requested certificate reissue to cert-manager")
+	return cp.GetCertificate()
+}
+
+// VerifyCertificate verifies the validity of a certificate. Returns
+// 'nil' if verification is correct, an error otherwise.
+func (cp *CertificateProvider) VerifyCertificate() error {
+	logger := cp.logger.WithValues("method", "VerifyCertificate")
+
+	secret, err := cp.getSecret()
+	if err != nil {
+		logger.Error(err, "unable to get Secret")
+		return err
+	}
+
+	cert, err := pki.LoadX509Certificate(secret.Data[tlsCertificateKey])
+	if err != nil {
+		logger.Error(err, "unable to load certificate from Secret")
+		return err
+	}
+
+	if err := verifyChain(cert, secret.Data[tlsCertificateKey], secret.Data[caCertificateKey]); err != nil {
+		return err
+	}
+
+	if err := pki.VerifyHosts(cert, cp.dsc.GetHosts()...); err != nil {
+		return err
+	}
+
+	if cp.dsc.Spec.PrivateKey != nil {
+		return pki.VerifyKey(cert, cp.dsc.Spec.PrivateKey.KeyOptions())
+	}
+	return nil
+}
+
+func (cp *CertificateProvider) getSecret() (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{
+		Name:      cp.dsc.Spec.SecretRef.Name,
+		Namespace: cp.dsc.GetNamespace(),
+	}
+	if err := cp.client.Get(cp.ctx, key, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (cp *CertificateProvider) certificateKey() types.NamespacedName {
+	return types.NamespacedName{Name: cp.dsc.GetName(), Namespace: cp.dsc.GetNamespace()}
+}
+
+// applyCertificate creates or updates the cert-manager Certificate
+// to match the spec of the DiscoveryServiceCertificate
+func (cp *CertificateProvider) applyCertificate() error {
+
+	desired, err := cp.genCertificate()
+	if err != nil {
+		return err
+	}
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(CertificateGVK)
+	if err := cp.client.Get(cp.ctx, cp.certificateKey(), current); err != nil {
+		if errors.IsNotFound(err) {
+			return cp.client.Create(cp.ctx, desired)
+		}
+		return err
+	}
+
+	if fmt.Sprint(current.Object["spec"]) == fmt.Sprint(desired.Object["spec"]) &&
+		metav1.IsControlledBy(current, cp.dsc) {
+		return nil
+	}
+	current.Object["spec"] = desired.Object["spec"]
+	if err := controllerutil.SetControllerReference(cp.dsc, current, cp.scheme); err != nil {
+		return err
+	}
+	return cp.client.Update(cp.ctx, current)
+}
+
+func (cp *CertificateProvider) genCertificate() (*unstructured.Unstructured, error) {
+
+	cfg := cp.dsc.Spec.Signer.CertManager
+	if cfg == nil {
+		return nil, fmt.Errorf("certManager signer not configured")
+	}
+
+	pk := cp.dsc.GetPrivateKeyConfig()
+	keyOpts := pk.KeyOptions()
+	if keyOpts.Algorithm == "" {
+		keyOpts.Algorithm = pki.RSAKeyAlgorithm
+	}
+
+	// cert-manager renews the certificate using the same renewal
+	// window as the DiscoveryServiceCertificate controller
+	duration := time.Duration(cp.dsc.Spec.ValidFor) * time.Second
+	renewBefore, err := cp.dsc.GetCertificateRenewalConfig().RenewBeforeDuration(duration)
+	if err != nil {
+		return nil, err
+	}
+
+	issuerRef := map[string]interface{}{
+		"name":  cfg.IssuerRef.Name,
+		"kind":  defaultIssuerKind,
+		"group": defaultIssuerGroup,
+	}
+	if cfg.IssuerRef.Kind != "" {
+		issuerRef["kind"] = cfg.IssuerRef.Kind
+	}
+	if cfg.IssuerRef.Group != "" {
+		issuerRef["group"] = cfg.IssuerRef.Group
+	}
+
+	privateKey := map[string]interface{}{
+		"algorithm":      string(keyOpts.Algorithm),
+		"rotationPolicy": "Always",
+	}
+	if keyOpts.Size != 0 && keyOpts.Algorithm != pki.Ed25519KeyAlgorithm {
+		privateKey["size"] = int64(keyOpts.Size)
+	}
+
+	spec := map[string]interface{}{
+		"secretName":  cp.dsc.Spec.SecretRef.Name,
+		"commonName":  cp.dsc.Spec.CommonName,
+		"duration":    duration.String(),
+		"renewBefore": renewBefore.String(),
+		"isCA":        cp.dsc.IsCA(),
+		"usages":      usages(keyOpts.Algorithm, cp.dsc.IsServerCertificate(), cp.dsc.IsCA()),
+		"privateKey":  privateKey,
+		"issuerRef":   issuerRef,
+	}
+
+	dnsNames, ipAddresses := []interface{}{}, []interface{}{}
+	for _, host := range cp.dsc.GetHosts() {
+		if net.ParseIP(host) != nil {
+			ipAddresses = append(ipAddresses, host)
+		} else {
+			dnsNames = append(dnsNames, host)
+		}
+	}
+	if len(dnsNames) > 0 {
+		spec["dnsNames"] = dnsNames
+	}
+	if len(ipAddresses) > 0 {
+		spec["ipAddresses"] = ipAddresses
+	}
+
+	cert := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	cert.SetGroupVersionKind(CertificateGVK)
+	cert.SetName(cp.dsc.GetName())
+	cert.SetNamespace(cp.dsc.GetNamespace())
+	if err := controllerutil.SetControllerReference(cp.dsc, cert, cp.scheme); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// usages returns the cert-manager key usages matching the ones
+// that the internal provider sets in its certificates
+func usages(alg pki.KeyAlgorithm, isServer, isCA bool) []interface{} {
+	u := []interface{}{"digital signature"}
+	if alg == pki.RSAKeyAlgorithm {
+		u = append(u, "key encipherment")
+	}
+	if isCA {
+		u = append(u, "cert sign")
+	}
+	if isServer {
+		u = append(u, "server auth")
+	}
+	return u
+}
+
+// verifyChain validates the certificate against the CA that cert-manager
+// stores in the Secret, using any intermediates bundled in the certificate
+// chain. Issuers that do not publish the CA (i.e. ACME) only get the validity
+// period of the certificate checked.
+func verifyChain(cert *x509.Certificate, chain, ca []byte) error {
+
+	if len(ca) == 0 {
+		now := time.Now()
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return pki.NewVerifyError(fmt.Sprintf("certificate is not valid at %s", now.UTC().Format(time.RFC3339)))
+		}
+		return nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return pki.NewVerifyError("unable to load CA certificates from Secret")
+	}
+
+	intermediates := x509.NewCertPool()
+	rest := chain
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if c, err := x509.ParseCertificate(block.Bytes); err == nil && !c.Equal(cert) {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return pki.NewVerifyError(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package providers
+
+import (
+	"context"
+	"crypto/x509"
+	"reflect"
+	"testing"
+	"time"
+
+	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
+	"github.com/3scale-ops/marin3r/pkg/util/pki"
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var s *runtime.Scheme = scheme.Scheme
+
+func init() {
+	s.AddKnownTypes(operatorv1alpha1.GroupVersion,
+		&operatorv1alpha1.DiscoveryServiceCertificate{},
+	)
+}
+
+func testDSC() *operatorv1alpha1.DiscoveryServiceCertificate {
+	return &operatorv1alpha1.DiscoveryServiceCertificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+		Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+			CommonName:          "test",
+			IsServerCertificate: pointer.New(true),
+			ValidFor:            3600,
+			Hosts:               []string{"example.com", "127.0.0.1"},
+			Signer: operatorv1alpha1.DiscoveryServiceCertificateSigner{
+				CertManager: &operatorv1alpha1.CertManagerConfig{
+					IssuerRef: operatorv1alpha1.CertManagerIssuerReference{Name: "vault", Kind: "ClusterIssuer"},
+				},
+			},
+			SecretRef: corev1.SecretReference{Name: "test-secret"},
+		},
+	}
+}
+
+func testCertificateObject() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(CertificateGVK)
+	return u
+}
+
+func testSecret(t *testing.T, hosts []string, withCA bool) *corev1.Secret {
+	caCrt, caKey, err := pki.GenerateCertificate(nil, nil, "ca", time.Hour, false, true, pki.KeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := pki.LoadX509Certificate(caCrt)
+	signer, _ := pki.DecodePrivateKeyBytes(caKey)
+	crt, key, err := pki.GenerateCertificate(ca, signer, "test", time.Hour, true, false, pki.KeyOptions{}, hosts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string][]byte{tlsCertificateKey: crt, tlsPrivateKeyKey: key}
+	if withCA {
+		data[caCertificateKey] = caCrt
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       data,
+	}
+}
+
+func TestCertificateProvider_CreateCertificate(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []client.Object
+		wantErr  bool
+	}{
+		{
+			name:     "Creates the cert-manager Certificate",
+			existing: []client.Object{},
+		},
+		{
+			name: "Updates an outdated cert-manager Certificate",
+			existing: []client.Object{func() client.Object {
+				u := testCertificateObject()
+				u.SetName("test")
+				u.SetNamespace("default")
+				u.Object["spec"] = map[string]interface{}{"secretName": "old"}
+				return u
+			}()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithScheme(s).WithObjects(tt.existing...).Build()
+			cp := NewCertificateProvider(context.TODO(), ctrl.Log.WithName("test"), cl, s, testDSC())
+			crt, key, err := cp.CreateCertificate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CertificateProvider.CreateCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if crt != nil || key != nil {
+				t.Errorf("CertificateProvider.CreateCertificate() returned certificate data")
+			}
+
+			got := testCertificateObject()
+			if err := cl.Get(context.TODO(), types.NamespacedName{Name: "test", Namespace: "default"}, got); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]interface{}{
+				"secretName":  "test-secret",
+				"commonName":  "test",
+				"duration":    "1h0m0s",
+				"renewBefore": "12m0s",
+				"isCA":        false,
+				"usages":      []interface{}{"digital signature", "key encipherment", "server auth"},
+				"privateKey":  map[string]interface{}{"algorithm": "RSA", "size": int64(2048), "rotationPolicy": "Always"},
+				"issuerRef":   map[string]interface{}{"name": "vault", "kind": "ClusterIssuer", "group": "cert-manager.io"},
+				"dnsNames":    []interface{}{"example.com"},
+				"ipAddresses": []interface{}{"127.0.0.1"},
+			}
+			if !reflect.DeepEqual(got.Object["spec"], want) {
+				t.Errorf("CertificateProvider.CreateCertificate() spec = %v, want %v", got.Object["spec"], want)
+			}
+			if refs := got.GetOwnerReferences(); len(refs) != 1 || refs[0].Name != "test" {
+				t.Errorf("CertificateProvider.CreateCertificate() ownerReferences = %v", refs)
+			}
+		})
+	}
+}
+
+func TestCertificateProvider_UpdateCertificate(t *testing.T) {
+	cl := fake.NewClientBuilder().WithScheme(s).
+		WithObjects(testSecret(t, []string{"example.com", "127.0.0.1"}, true)).
+		WithStatusSubresource(testCertificateObject()).
+		Build()
+	cp := NewCertificateProvider(context.TODO(), ctrl.Log.WithName("test"), cl, s, testDSC())
+
+	crt, _, err := cp.UpdateCertificate()
+	if err != nil {
+		t.Fatalf("CertificateProvider.UpdateCertificate() error = %v", err)
+	}
+	if crt == nil {
+		t.Errorf("CertificateProvider.UpdateCertificate() did not return the current certificate")
+	}
+
+	got := testCertificateObject()
+	if err := cl.Get(context.TODO(), types.NamespacedName{Name: "test", Namespace: "default"}, got); err != nil {
+		t.Fatal(err)
+	}
+	conditions, _, _ := unstructured.NestedSlice(got.Object, "status", "conditions")
+	if len(conditions) != 1 {
+		t.Fatalf("CertificateProvider.UpdateCertificate() conditions = %v", conditions)
+	}
+	cond := conditions[0].(map[string]interface{})
+	if cond["type"] != "Issuing" || cond["status"] != "True" {
+		t.Errorf("CertificateProvider.UpdateCertificate() condition = %v", cond)
+	}
+}
+
+func TestCertificateProvider_VerifyCertificate(t *testing.T) {
+	tests := []struct {
+		name            string
+		secret          *corev1.Secret
+		privateKey      *operatorv1alpha1.PrivateKeyConfig
+		wantErr         bool
+		wantVerifyError bool
+	}{
+		{
+			name:   "Valid certificate signed by the CA in the Secret",
+			secret: testSecret(t, []string{"example.com", "127.0.0.1"}, true),
+		},
+		{
+			name:   "Valid certificate without CA in the Secret",
+			secret: testSecret(t, []string{"example.com", "127.0.0.1"}, false),
+		},
+		{
+			name: "Certificate not signed by the CA in the Secret",
+			secret: func() *corev1.Secret {
+				secret := testSecret(t, []string{"example.com", "127.0.0.1"}, true)
+				secret.Data[caCertificateKey] = testSecret(t, nil, true).Data[caCertificateKey]
+				return secret
+			}(),
+			wantErr:         true,
+			wantVerifyError: true,
+		},
+		{
+			name:            "Hosts do not match",
+			secret:          testSecret(t, []string{"example.com"}, true),
+			wantErr:         true,
+			wantVerifyError: true,
+		},
+		{
+			name:            "Private key does not match",
+			secret:          testSecret(t, []string{"example.com", "127.0.0.1"}, true),
+			privateKey:      &operatorv1alpha1.PrivateKeyConfig{Algorithm: operatorv1alpha1.ECDSAPrivateKeyAlgorithm},
+			wantErr:         true,
+			wantVerifyError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsc := testDSC()
+			dsc.Spec.PrivateKey = tt.privateKey
+			cl := fake.NewClientBuilder().WithScheme(s).WithObjects(tt.secret).Build()
+			cp := NewCertificateProvider(context.TODO(), ctrl.Log.WithName("test"), cl, s, dsc)
+			err := cp.VerifyCertificate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CertificateProvider.VerifyCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantVerifyError && !pki.IsVerifyError(err) {
+				t.Errorf("CertificateProvider.VerifyCertificate() error = %v, want a VerifyError", err)
+			}
+		})
+	}
+}
+
+func Test_verifyChain(t *testing.T) {
+	secret := testSecret(t, nil, false)
+	cert, _ := pki.LoadX509Certificate(secret.Data[tlsCertificateKey])
+	expired := *cert
+	expired.NotAfter = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		wantErr bool
+	}{
+		{name: "Within validity period", cert: cert, wantErr: false},
+		{name: "Expired", cert: &expired, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyChain(tt.cert, nil, nil); (err != nil) != tt.wantErr {
+				t.Errorf("verifyChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
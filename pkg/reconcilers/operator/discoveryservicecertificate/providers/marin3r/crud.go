@@ -188,7 +188,22 @@ func (cp *CertificateProvider) VerifyCertificate() error {
 		root = cert
 	}
 
-	return pki.Verify(cert, root)
+	if err := pki.Verify(cert, root); err != nil {
+		return err
+	}
+
+	// Hosts can change, for example when the nodeID of an EnvoyDeployment
+	// changes, so the certificate needs to be reissued
+	if err := pki.VerifyHosts(cert, cp.dsc.GetHosts()...); err != nil {
+		return err
+	}
+
+	// The key algorithm or size can also change. Certificates without an explicit
+	// private key configuration are not checked, so they are not reissued.
+	if cp.dsc.Spec.PrivateKey != nil {
+		return pki.VerifyKey(cert, cp.dsc.Spec.PrivateKey.KeyOptions())
+	}
+	return nil
 }
 
 // getIssuerCertificate returns the issuer certificate for a DiscoveryServiceCertificate resource
@@ -223,6 +238,7 @@ func (cp *CertificateProvider) getIssuerCertificate() (*x509.Certificate, interf
 
 func (cp *CertificateProvider) genSecret(issuerCert *x509.Certificate, issuerKey interface{}) (*corev1.Secret, error) {
 
+	pk := cp.dsc.GetPrivateKeyConfig()
 	crt, key, err := pki.GenerateCertificate(
 		issuerCert,
 		issuerKey,
@@ -230,6 +246,7 @@ func (cp *CertificateProvider) genSecret(issuerCert *x509.Certificate, issuerKey
 		time.Duration(cp.dsc.Spec.ValidFor)*time.Second,
 		cp.dsc.IsServerCertificate(),
 		cp.dsc.IsCA(),
+		pk.KeyOptions(),
 		cp.dsc.GetHosts()...,
 	)
 	if err != nil {
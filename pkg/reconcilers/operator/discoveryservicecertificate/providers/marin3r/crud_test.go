@@ -383,6 +383,41 @@ func TestCertificateProvider_VerifyCertificate(t *testing.T) {
 					}}},
 			wantErr: true,
 		},
+		{
+			name: "Verify returns an error (private key does not match)",
+			fields: fields{
+				ctx:    context.TODO(),
+				logger: ctrl.Log.WithName("test"),
+				client: fake.NewClientBuilder().WithScheme(s).WithObjects(
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "issuer", Namespace: "test"},
+						Data: map[string][]byte{
+							tlsCertificateKey: test.TestIssuerCertificate(),
+							tlsPrivateKeyKey:  test.TestIssuerKey(),
+						}},
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "test"},
+						Data: map[string][]byte{
+							tlsCertificateKey: test.TestValidCertificate(),
+							tlsPrivateKeyKey:  []byte("xxxx"),
+						}},
+				).Build(),
+				scheme: s,
+				dsc: &operatorv1alpha1.DiscoveryServiceCertificate{
+					ObjectMeta: metav1.ObjectMeta{Name: "dsc", Namespace: "test"},
+					Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+						Signer: operatorv1alpha1.DiscoveryServiceCertificateSigner{
+							CASigned: &operatorv1alpha1.CASignedConfig{
+								SecretRef: corev1.SecretReference{Name: "issuer", Namespace: "test"},
+							},
+						},
+						SecretRef: corev1.SecretReference{Name: "secret"},
+						PrivateKey: &operatorv1alpha1.PrivateKeyConfig{
+							Algorithm: operatorv1alpha1.ECDSAPrivateKeyAlgorithm,
+						},
+					}}},
+			wantErr: true,
+		},
 		{
 			name: "Verify returns an error (secret not found)",
 			fields: fields{
@@ -2,18 +2,20 @@ package reconcilers
 
 import (
 	"context"
-	"math"
 	"time"
 
 	reconcilerutil "github.com/3scale-ops/basereconciler/util"
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers"
+	certmanager_provider "github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers/certmanager"
 	internal_provider "github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers/marin3r"
 	"github.com/3scale-ops/marin3r/pkg/util/clock"
 	"github.com/3scale-ops/marin3r/pkg/util/pki"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -26,6 +28,7 @@ type CertificateReconciler struct {
 	scheme   *runtime.Scheme
 	dsc      *operatorv1alpha1.DiscoveryServiceCertificate
 	provider providers.CertificateProvider
+	recorder record.EventRecorder
 	clock    clock.Clock
 
 	// Calculated fields
@@ -36,14 +39,16 @@ type CertificateReconciler struct {
 	schedule  *time.Duration
 }
 
-// Ensure the provider implements the CertificateProvider interface
+// Ensure the providers implement the CertificateProvider interface
 var _ providers.CertificateProvider = &internal_provider.CertificateProvider{}
+var _ providers.CertificateProvider = &certmanager_provider.CertificateProvider{}
 
 // NewCertificateReconciler returns a new RevisionReconciler
 func NewCertificateReconciler(ctx context.Context, logger logr.Logger, client client.Client,
-	s *runtime.Scheme, dsc *operatorv1alpha1.DiscoveryServiceCertificate, provider providers.CertificateProvider) CertificateReconciler {
+	s *runtime.Scheme, dsc *operatorv1alpha1.DiscoveryServiceCertificate, provider providers.CertificateProvider,
+	recorder record.EventRecorder) CertificateReconciler {
 
-	return CertificateReconciler{ctx, logger, client, s, dsc, provider, clock.Real{}, false, "", nil, nil, nil}
+	return CertificateReconciler{ctx, logger, client, s, dsc, provider, recorder, clock.Real{}, false, "", nil, nil, nil}
 }
 
 // IsReady returns true if the certificate is ready after the
@@ -104,6 +109,7 @@ func (r *CertificateReconciler) Reconcile() (ctrl.Result, error) {
 		if pki.IsVerifyError(err) {
 			// The certificate is invalid
 			r.logger.Info("certificate failed validation", "reason", err.Error())
+			r.event(corev1.EventTypeWarning, "CertificateVerificationFailed", "certificate failed validation: %s", err.Error())
 			r.ready = false
 		} else {
 			// Some other failure occurred during the verify process
@@ -117,23 +123,32 @@ func (r *CertificateReconciler) Reconcile() (ctrl.Result, error) {
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	observeNotAfter(r.dsc.GetNamespace(), r.dsc.GetName(), cert.NotAfter)
 
 	// time to certificate expiration is used to calculate next reconcile schedule
 	timeToExpire := cert.NotAfter.Sub(r.clock.Now())
 	// total duration of the certificate, used to calculate when to start trying renewal
 	duration := cert.NotAfter.Sub(cert.NotBefore)
 
-	if r.dsc.GetCertificateRenewalConfig().Enabled {
-		// renew the certificate when 20% or less of certificate's duration has passed
-		renewBefore := time.Duration(int64(math.Floor(float64(duration) * 0.20)))
+	if renewal := r.dsc.GetCertificateRenewalConfig(); renewal.Enabled {
+		// renew the certificate when it enters the configured renewal window
+		renewBefore, err := renewal.RenewBeforeDuration(duration)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
 
 		// If certificate is not valid or is within the renewal window, reissue it
 		if r.ready == false || timeToExpire < renewBefore {
 			certBytes, _, err = r.provider.UpdateCertificate()
 			if err != nil {
+				observeRenewalFailure(r.dsc.GetNamespace(), r.dsc.GetName())
+				r.event(corev1.EventTypeWarning, "CertificateRenewalFailed", "unable to reissue certificate: %s", err.Error())
 				return ctrl.Result{}, err
 			}
-			r.logger.Info("reissued certificate")
+			// some providers issue the certificate asynchronously, so the
+			// reissue is reported once the new certificate is observed
+			r.logger.Info("requested certificate reissue")
+			r.event(corev1.EventTypeNormal, "CertificateReissueRequested", "certificate reissue requested")
 			return ctrl.Result{Requeue: true}, nil
 		}
 
@@ -156,9 +171,24 @@ func (r *CertificateReconciler) Reconcile() (ctrl.Result, error) {
 	// store the certificate hash for status reconciliation
 	r.hash = reconcilerutil.Hash(certBytes)
 
+	// the certificate has been reissued if its hash has changed, either
+	// at the request of this controller or by the provider on its own
+	if previous := r.dsc.Status.GetCertificateHash(); previous != "" && previous != r.hash {
+		r.logger.Info("reissued certificate")
+		r.event(corev1.EventTypeNormal, "CertificateReissued", "certificate reissued")
+	}
+
 	//store certificate validity times for status reconciliation
 	r.notBefore = &cert.NotBefore
 	r.notAfter = &cert.NotAfter
 
 	return ctrl.Result{}, nil
 }
+
+// event emits a Kubernetes Event for the DiscoveryServiceCertificate
+func (r *CertificateReconciler) event(eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Eventf(r.dsc, eventtype, reason, messageFmt, args...)
+}
@@ -4,20 +4,25 @@ import (
 	"context"
 	"crypto/x509"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	reconcilerutil "github.com/3scale-ops/basereconciler/util"
 	operatorv1alpha1 "github.com/3scale-ops/marin3r/apis/operator.marin3r/v1alpha1"
 	"github.com/3scale-ops/marin3r/pkg/reconcilers/operator/discoveryservicecertificate/providers"
 	"github.com/3scale-ops/marin3r/pkg/util/clock"
 	"github.com/3scale-ops/marin3r/pkg/util/pki"
+	"github.com/3scale-ops/marin3r/pkg/util/pointer"
 	"github.com/MakeNowJust/heredoc"
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -90,7 +95,9 @@ func TestNewCertificateReconciler(t *testing.T) {
 		s        *runtime.Scheme
 		dsc      *operatorv1alpha1.DiscoveryServiceCertificate
 		provider providers.CertificateProvider
+		recorder record.EventRecorder
 	}
+	recorder := record.NewFakeRecorder(1)
 	tests := []struct {
 		name string
 		args args
@@ -105,6 +112,7 @@ func TestNewCertificateReconciler(t *testing.T) {
 				s:        s,
 				dsc:      &operatorv1alpha1.DiscoveryServiceCertificate{},
 				provider: &testCertificateProvider{},
+				recorder: recorder,
 			},
 			want: CertificateReconciler{
 				ctx:      context.TODO(),
@@ -113,6 +121,7 @@ func TestNewCertificateReconciler(t *testing.T) {
 				scheme:   s,
 				dsc:      &operatorv1alpha1.DiscoveryServiceCertificate{},
 				provider: &testCertificateProvider{},
+				recorder: recorder,
 				clock:    clock.Real{},
 				ready:    false,
 				hash:     "",
@@ -121,7 +130,7 @@ func TestNewCertificateReconciler(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewCertificateReconciler(tt.args.ctx, tt.args.logger, tt.args.client, tt.args.s, tt.args.dsc, tt.args.provider); !reflect.DeepEqual(got, tt.want) {
+			if got := NewCertificateReconciler(tt.args.ctx, tt.args.logger, tt.args.client, tt.args.s, tt.args.dsc, tt.args.provider, tt.args.recorder); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewCertificateReconciler() = %v, want %v", got, tt.want)
 			}
 		})
@@ -271,6 +280,47 @@ func TestCertificateReconciler_Reconcile(t *testing.T) {
 			wantNotAfter:  func() *time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:01:40Z"); return &t }(),
 			wantSchedule:  func() *time.Duration { d := time.Duration(20 * time.Second); return &d }(),
 		},
+		{
+			name: "Verifies a certificate, schedules renewal with the configured renewBefore",
+			r: &CertificateReconciler{
+				ctx:    context.TODO(),
+				logger: ctrl.Log.WithName("test"),
+				client: fake.NewClientBuilder().WithScheme(s).Build(),
+				scheme: s,
+				dsc: &operatorv1alpha1.DiscoveryServiceCertificate{
+					ObjectMeta: metav1.ObjectMeta{Name: "dsc", Namespace: "test"},
+					Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+						CertificateRenewalConfig: &operatorv1alpha1.CertificateRenewalConfig{Enabled: true, RenewBefore: "30s"},
+					}},
+				provider: &testCertificateProvider{
+					index: 0,
+					// go run hack/gen_cert.go --not-before=2021-01-01T00:00:00Z --not-after=2021-01-01T00:01:40Z --key-size 512
+					certificates: [][]byte{
+						[]byte(heredoc.Doc(`
+						-----BEGIN CERTIFICATE-----
+						MIIBdjCCASCgAwIBAgIQFS94k33VgPtanU/j0OvC8DANBgkqhkiG9w0BAQsFADAr
+						MRUwEwYDVQQKEwxtYXJpbjNyLnRlc3QxEjAQBgNVBAMTCWxvY2FsaG9zdDAeFw0y
+						MTAxMDEwMDAwMDBaFw0yMTAxMDEwMDAxNDBaMCsxFTATBgNVBAoTDG1hcmluM3Iu
+						dGVzdDESMBAGA1UEAxMJbG9jYWxob3N0MFwwDQYJKoZIhvcNAQEBBQADSwAwSAJB
+						AK1ShFw1t1r8vrn5cVJj98ei4UYAwIy7hymr7oCXom1TcWCLURZsMfKG2A8YKUBC
+						iKQWT/zAknqKOrV8qn9bSUkCAwEAAaMgMB4wDgYDVR0PAQH/BAQDAgWgMAwGA1Ud
+						EwEB/wQCMAAwDQYJKoZIhvcNAQELBQADQQBVv03X7BjjcTqpkcCCiejTyJYTc1pN
+						kfwbx8mNF+Zx5V763W74/+fr2Z5+Q0l7O1k3gcsnaWSoGfV9PST7iNpQ
+						-----END CERTIFICATE-----
+						`)),
+					},
+					currentTime: func() time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:01:00Z"); return t }(),
+				},
+				clock: clock.NewTest(func() time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:01:00Z"); return t }()),
+			},
+			want:          ctrl.Result{},
+			wantErr:       false,
+			wantIsReady:   true,
+			wantHash:      "5c78c58c76",
+			wantNotBefore: func() *time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:00:00Z"); return &t }(),
+			wantNotAfter:  func() *time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:01:40Z"); return &t }(),
+			wantSchedule:  func() *time.Duration { d := time.Duration(10 * time.Second); return &d }(),
+		},
 		{
 			name: "Verifies a certificate, renewal disabled",
 			r: &CertificateReconciler{
@@ -492,3 +542,83 @@ func TestCertificateReconciler_Reconcile(t *testing.T) {
 	}
 
 }
+
+func TestCertificateReconciler_Reconcile_EventsAndMetrics(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &CertificateReconciler{
+		ctx:    context.TODO(),
+		logger: ctrl.Log.WithName("test"),
+		client: fake.NewClientBuilder().WithScheme(s).Build(),
+		scheme: s,
+		dsc: &operatorv1alpha1.DiscoveryServiceCertificate{
+			ObjectMeta: metav1.ObjectMeta{Name: "events", Namespace: "test"},
+			Spec:       operatorv1alpha1.DiscoveryServiceCertificateSpec{},
+		},
+		provider: &testCertificateProvider{
+			index: 0,
+			// go run hack/gen_cert.go --not-before=2021-01-01T00:00:00Z --not-after=2021-01-01T00:01:40Z --key-size 512
+			certificates: [][]byte{
+				[]byte(heredoc.Doc(`
+				-----BEGIN CERTIFICATE-----
+				MIIBdjCCASCgAwIBAgIQFS94k33VgPtanU/j0OvC8DANBgkqhkiG9w0BAQsFADAr
+				MRUwEwYDVQQKEwxtYXJpbjNyLnRlc3QxEjAQBgNVBAMTCWxvY2FsaG9zdDAeFw0y
+				MTAxMDEwMDAwMDBaFw0yMTAxMDEwMDAxNDBaMCsxFTATBgNVBAoTDG1hcmluM3Iu
+				dGVzdDESMBAGA1UEAxMJbG9jYWxob3N0MFwwDQYJKoZIhvcNAQEBBQADSwAwSAJB
+				AK1ShFw1t1r8vrn5cVJj98ei4UYAwIy7hymr7oCXom1TcWCLURZsMfKG2A8YKUBC
+				iKQWT/zAknqKOrV8qn9bSUkCAwEAAaMgMB4wDgYDVR0PAQH/BAQDAgWgMAwGA1Ud
+				EwEB/wQCMAAwDQYJKoZIhvcNAQELBQADQQBVv03X7BjjcTqpkcCCiejTyJYTc1pN
+				kfwbx8mNF+Zx5V763W74/+fr2Z5+Q0l7O1k3gcsnaWSoGfV9PST7iNpQ
+				-----END CERTIFICATE-----
+				`)),
+				// go run hack/gen_cert.go --not-before=2021-01-01T00:02:00Z --not-after=2021-01-01T00:03:40Z --key-size 512
+				[]byte(heredoc.Doc(`
+				-----BEGIN CERTIFICATE-----
+				MIIBdjCCASCgAwIBAgIQPLCk1wrD/xwhGeYY+8PyXzANBgkqhkiG9w0BAQsFADAr
+				MRUwEwYDVQQKEwxtYXJpbjNyLnRlc3QxEjAQBgNVBAMTCWxvY2FsaG9zdDAeFw0y
+				MTAxMDEwMDAyMDBaFw0yMTAxMDEwMDAzNDBaMCsxFTATBgNVBAoTDG1hcmluM3Iu
+				dGVzdDESMBAGA1UEAxMJbG9jYWxob3N0MFwwDQYJKoZIhvcNAQEBBQADSwAwSAJB
+				ALar6qCiHa0rU/FYLrfp0AxWncC2cPcrbWeAg0fl9sj9i7pPUnWKwDtPtF7XOVbr
+				IJNLS2eiVwY51t33ZzJSJ0cCAwEAAaMgMB4wDgYDVR0PAQH/BAQDAgWgMAwGA1Ud
+				EwEB/wQCMAAwDQYJKoZIhvcNAQELBQADQQBX9jIA4PgYKa4O1GAC95xXYkPQtwWJ
+				GLdoN+PINhm0k1dg/nzRYQrefXlkju3o98iSUvi9RjjTT2xeW9LIiBUo
+				-----END CERTIFICATE-----
+				`)),
+			},
+			currentTime: func() time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:02:00Z"); return t }(),
+		},
+		recorder: recorder,
+		clock:    clock.NewTest(func() time.Time { t, _ := time.Parse(time.RFC3339, "2021-01-01T00:02:00Z"); return t }()),
+	}
+	defer DeleteMetrics("test", "events")
+
+	if _, err := r.Reconcile(); err != nil {
+		t.Fatalf("CertificateReconciler.Reconcile() error = %v", err)
+	}
+	notAfter, _ := time.Parse(time.RFC3339, "2021-01-01T00:01:40Z")
+	if got := testutil.ToFloat64(certificateNotAfter.WithLabelValues("test", "events")); got != float64(notAfter.Unix()) {
+		t.Errorf("CertificateReconciler.Reconcile() not after metric = %v, want %v", got, notAfter.Unix())
+	}
+
+	// the reissue is reported once the new certificate is observed
+	r.dsc.Status.CertificateHash = pointer.New(reconcilerutil.Hash(r.provider.(*testCertificateProvider).certificates[0]))
+	if _, err := r.Reconcile(); err != nil {
+		t.Fatalf("CertificateReconciler.Reconcile() error = %v", err)
+	}
+
+	wantEvents := []string{"Warning CertificateVerificationFailed", "Normal CertificateReissueRequested", "Normal CertificateReissued"}
+	for _, want := range wantEvents {
+		select {
+		case got := <-recorder.Events:
+			if !strings.HasPrefix(got, want) {
+				t.Errorf("CertificateReconciler.Reconcile() event = %v, want %v", got, want)
+			}
+		default:
+			t.Errorf("CertificateReconciler.Reconcile() missing event %v", want)
+		}
+	}
+
+	notAfter, _ = time.Parse(time.RFC3339, "2021-01-01T00:03:40Z")
+	if got := testutil.ToFloat64(certificateNotAfter.WithLabelValues("test", "events")); got != float64(notAfter.Unix()) {
+		t.Errorf("CertificateReconciler.Reconcile() not after metric = %v, want %v", got, notAfter.Unix())
+	}
+}
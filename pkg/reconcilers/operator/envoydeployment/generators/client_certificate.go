@@ -8,7 +8,7 @@ import (
 
 func (cfg *GeneratorOptions) ClientCertificate() *operatorv1alpha1.DiscoveryServiceCertificate {
 
-	return &operatorv1alpha1.DiscoveryServiceCertificate{
+	dsc := &operatorv1alpha1.DiscoveryServiceCertificate{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cfg.ClientCertificateName,
 			Namespace: cfg.Namespace,
@@ -27,6 +27,16 @@ func (cfg *GeneratorOptions) ClientCertificate() *operatorv1alpha1.DiscoveryServ
 			SecretRef: corev1.SecretReference{
 				Name: cfg.ClientCertificateName,
 			},
+			PrivateKey: cfg.PrivateKey.DeepCopy(),
 		},
 	}
+
+	// the nodeID is only added to the certificate when the discovery service verifies
+	// that clients only request the configuration of their own nodeID, as changing the
+	// hosts causes the reissue of the certificate
+	if cfg.EnforceNodeIDBinding {
+		dsc.Spec.Hosts = []string{cfg.EnvoyNodeID}
+	}
+
+	return dsc
 }
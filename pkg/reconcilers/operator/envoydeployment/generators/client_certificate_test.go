@@ -25,6 +25,7 @@ func TestGeneratorOptions_ClientCertificate(t *testing.T) {
 				ClientCertificateName:     "cert",
 				ClientCertificateDuration: time.Duration(20 * time.Second),
 				SigningCertificateName:    "signing-cert",
+				EnvoyNodeID:               "node1",
 			},
 			want: &operatorv1alpha1.DiscoveryServiceCertificate{
 				ObjectMeta: metav1.ObjectMeta{
@@ -53,6 +54,45 @@ func TestGeneratorOptions_ClientCertificate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Adds the nodeID to the hosts if binding is enforced",
+			opts: GeneratorOptions{
+				InstanceName:              "instance",
+				Namespace:                 "default",
+				ClientCertificateName:     "cert",
+				ClientCertificateDuration: time.Duration(20 * time.Second),
+				SigningCertificateName:    "signing-cert",
+				EnvoyNodeID:               "node1",
+				EnforceNodeIDBinding:      true,
+			},
+			want: &operatorv1alpha1.DiscoveryServiceCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cert",
+					Namespace: "default",
+					Labels: map[string]string{
+						"app.kubernetes.io/name":       "marin3r",
+						"app.kubernetes.io/managed-by": "marin3r-operator",
+						"app.kubernetes.io/component":  "envoy-deployment",
+						"app.kubernetes.io/instance":   "instance",
+					},
+				},
+				Spec: operatorv1alpha1.DiscoveryServiceCertificateSpec{
+					CommonName: "cert",
+					Hosts:      []string{"node1"},
+					ValidFor:   int64(time.Duration(20 * time.Second).Seconds()),
+					Signer: operatorv1alpha1.DiscoveryServiceCertificateSigner{
+						CASigned: &operatorv1alpha1.CASignedConfig{
+							SecretRef: corev1.SecretReference{
+								Name:      "signing-cert",
+								Namespace: "default",
+							}},
+					},
+					SecretRef: corev1.SecretReference{
+						Name: "cert",
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -50,6 +50,7 @@ func (cfg *GeneratorOptions) Deployment() *appsv1.Deployment {
 		XdssHost:           cfg.XdssAdress,
 		XdssPort:           cfg.XdssPort,
 		APIVersion:         cfg.EnvoyAPIVersion.String(),
+		DeltaXds:           cfg.DeltaXds,
 	}
 
 	if cfg.ShutdownManager != nil {
@@ -17,11 +17,14 @@ type GeneratorOptions struct {
 	XdssAdress                string
 	XdssPort                  int
 	EnvoyAPIVersion           envoy.APIVersion
+	DeltaXds                  bool
 	EnvoyNodeID               string
 	EnvoyClusterID            string
 	ClientCertificateName     string
 	ClientCertificateDuration time.Duration
 	SigningCertificateName    string
+	PrivateKey                *operatorv1alpha1.PrivateKeyConfig
+	EnforceNodeIDBinding      bool
 	DeploymentImage           string
 	DeploymentResources       corev1.ResourceRequirements
 	ExposedPorts              []operatorv1alpha1.ContainerPort
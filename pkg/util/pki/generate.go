@@ -1,30 +1,125 @@
 package pki
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"math/big"
 	"net"
 	"time"
 )
 
-// GeneratePrivateKey generates a new RSA private key
-func GeneratePrivateKey() (*rsa.PrivateKey, error) {
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+// KeyAlgorithm is the algorithm of a private key
+type KeyAlgorithm string
+
+const (
+	// RSAKeyAlgorithm is the RSA private key algorithm
+	RSAKeyAlgorithm KeyAlgorithm = "RSA"
+	// ECDSAKeyAlgorithm is the ECDSA private key algorithm
+	ECDSAKeyAlgorithm KeyAlgorithm = "ECDSA"
+	// Ed25519KeyAlgorithm is the Ed25519 private key algorithm
+	Ed25519KeyAlgorithm KeyAlgorithm = "Ed25519"
+)
+
+// KeyOptions holds the algorithm and size of a private key. The zero
+// value is a 2048 bits RSA key.
+type KeyOptions struct {
+	Algorithm KeyAlgorithm
+	// Size is the size in bits of the key. RSA keys default to 2048 bits
+	// and ECDSA keys to 256 bits. It is ignored for Ed25519 keys.
+	Size int
+}
+
+// withDefaults validates the options and returns them with the defaults applied
+func (opts KeyOptions) withDefaults() (KeyOptions, error) {
+	switch opts.Algorithm {
+	case "", RSAKeyAlgorithm:
+		opts.Algorithm = RSAKeyAlgorithm
+		if opts.Size == 0 {
+			opts.Size = 2048
+		}
+		if opts.Size != 2048 && opts.Size != 3072 && opts.Size != 4096 {
+			return opts, fmt.Errorf("unsupported RSA key size %d", opts.Size)
+		}
+	case ECDSAKeyAlgorithm:
+		if opts.Size == 0 {
+			opts.Size = 256
+		}
+		if _, err := curve(opts.Size); err != nil {
+			return opts, err
+		}
+	case Ed25519KeyAlgorithm:
+		opts.Size = 0
+	default:
+		return opts, fmt.Errorf("unsupported key algorithm %s", opts.Algorithm)
+	}
+	return opts, nil
+}
+
+func curve(size int) (elliptic.Curve, error) {
+	switch size {
+	case 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("unsupported ECDSA key size %d", size)
+}
+
+// GeneratePrivateKey generates a new private key with the given algorithm and size
+func GeneratePrivateKey(opts KeyOptions) (crypto.Signer, error) {
+	opts, err := opts.withDefaults()
 	if err != nil {
 		return nil, err
 	}
-	return priv, nil
+
+	switch opts.Algorithm {
+	case ECDSAKeyAlgorithm:
+		c, _ := curve(opts.Size)
+		return ecdsa.GenerateKey(c, rand.Reader)
+	case Ed25519KeyAlgorithm:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return priv, nil
+	default:
+		return rsa.GenerateKey(rand.Reader, opts.Size)
+	}
+}
+
+// keyUsage returns the key usage bits for a certificate. Key encipherment is
+// only valid for RSA keys, ECDSA and Ed25519 keys can only be used for signatures.
+func keyUsage(algorithm KeyAlgorithm, isCA bool) x509.KeyUsage {
+	if isCA {
+		return x509.KeyUsageCertSign
+	}
+	if algorithm == RSAKeyAlgorithm {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
 }
 
 // GenerateCertificate issues a new certificate with the passed options and signed by the parent certificate if one is given. A self-signed
-// is issued otherwise.
-func GenerateCertificate(issuerCert *x509.Certificate, signerKey interface{}, commonName string, validFor time.Duration, isServer, isCA bool, host ...string) ([]byte, []byte, error) {
+// is issued otherwise. The private key of the certificate is generated using the given key options.
+func GenerateCertificate(issuerCert *x509.Certificate, signerKey interface{}, commonName string, validFor time.Duration, isServer, isCA bool,
+	keyOpts KeyOptions, host ...string) ([]byte, []byte, error) {
+
+	keyOpts, err := keyOpts.withDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	priv, err := GeneratePrivateKey()
+	priv, err := GeneratePrivateKey(keyOpts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -47,7 +142,7 @@ func GenerateCertificate(issuerCert *x509.Certificate, signerKey interface{}, co
 		NotBefore: notBefore,
 		NotAfter:  notAfter,
 
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		KeyUsage:              keyUsage(keyOpts.Algorithm, isCA),
 		BasicConstraintsValid: true,
 	}
 
@@ -65,21 +160,20 @@ func GenerateCertificate(issuerCert *x509.Certificate, signerKey interface{}, co
 
 	if isCA {
 		template.IsCA = true
-		template.KeyUsage = x509.KeyUsageCertSign
 	}
 
 	var derBytes []byte
 
 	if issuerCert == nil {
 		// Self-signed
-		derBytes, err = x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+		derBytes, err = x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
 		if err != nil {
 			return nil, nil, err
 		}
 
 	} else {
 		// CA signed
-		derBytes, err = x509.CreateCertificate(rand.Reader, &template, issuerCert, &priv.PublicKey, signerKey)
+		derBytes, err = x509.CreateCertificate(rand.Reader, &template, issuerCert, priv.Public(), signerKey)
 		if err != nil {
 			return nil, nil, err
 		}
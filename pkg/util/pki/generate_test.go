@@ -27,12 +27,14 @@ func TestGenerateCertificate(t *testing.T) {
 		validFor   time.Duration
 		isServer   bool
 		isCA       bool
+		keyOpts    KeyOptions
 		host       []string
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name         string
+		args         args
+		wantKeyUsage x509.KeyUsage
+		wantErr      bool
 	}{
 		{
 			name: "Generates a self-signed certificate",
@@ -45,7 +47,8 @@ func TestGenerateCertificate(t *testing.T) {
 				isCA:       false,
 				host:       []string{"example.test"},
 			},
-			wantErr: false,
+			wantKeyUsage: x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			wantErr:      false,
 		},
 		{
 			name: "Generates a ca-signed server certificate",
@@ -58,7 +61,8 @@ func TestGenerateCertificate(t *testing.T) {
 				isCA:       false,
 				host:       []string{"example.test"},
 			},
-			wantErr: false,
+			wantKeyUsage: x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			wantErr:      false,
 		},
 		{
 			name: "Generates a self-signed CA certificate",
@@ -71,16 +75,62 @@ func TestGenerateCertificate(t *testing.T) {
 				isCA:       true,
 				host:       []string{"example.test"},
 			},
-			wantErr: false,
+			wantKeyUsage: x509.KeyUsageCertSign,
+			wantErr:      false,
+		},
+		{
+			name: "Generates a ca-signed ECDSA certificate",
+			args: args{
+				issuerCert: testIssuerCertificate(),
+				signerKey:  testIssuerKey(),
+				commonName: "test",
+				validFor:   300 * time.Second,
+				isServer:   true,
+				isCA:       false,
+				keyOpts:    KeyOptions{Algorithm: ECDSAKeyAlgorithm, Size: 384},
+				host:       []string{"example.test"},
+			},
+			wantKeyUsage: x509.KeyUsageDigitalSignature,
+			wantErr:      false,
+		},
+		{
+			name: "Generates a self-signed Ed25519 certificate",
+			args: args{
+				issuerCert: nil,
+				signerKey:  nil,
+				commonName: "test",
+				validFor:   300 * time.Second,
+				isServer:   false,
+				isCA:       false,
+				keyOpts:    KeyOptions{Algorithm: Ed25519KeyAlgorithm},
+				host:       []string{"example.test"},
+			},
+			wantKeyUsage: x509.KeyUsageDigitalSignature,
+			wantErr:      false,
+		},
+		{
+			name: "Fails with an unsupported key size",
+			args: args{
+				issuerCert: nil,
+				signerKey:  nil,
+				commonName: "test",
+				validFor:   300 * time.Second,
+				keyOpts:    KeyOptions{Algorithm: ECDSAKeyAlgorithm, Size: 1024},
+				host:       []string{"example.test"},
+			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _, err := GenerateCertificate(tt.args.issuerCert, tt.args.signerKey, tt.args.commonName, tt.args.validFor, tt.args.isServer, tt.args.isCA, tt.args.host...)
+			got, _, err := GenerateCertificate(tt.args.issuerCert, tt.args.signerKey, tt.args.commonName, tt.args.validFor, tt.args.isServer, tt.args.isCA, tt.args.keyOpts, tt.args.host...)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GenerateCertificate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 
 			cert, err := LoadX509Certificate(got)
 			if err != nil {
@@ -118,6 +168,12 @@ func TestGenerateCertificate(t *testing.T) {
 			} else if !tt.args.isCA && cert.IsCA {
 				t.Errorf("GenerateCertificate() got IsCA = %v, want %v", cert.IsCA, tt.args.isCA)
 			}
+			if cert.KeyUsage != tt.wantKeyUsage {
+				t.Errorf("GenerateCertificate() got KeyUsage = %v, want %v", cert.KeyUsage, tt.wantKeyUsage)
+			}
+			if err := VerifyKey(cert, tt.args.keyOpts); err != nil {
+				t.Errorf("GenerateCertificate() error validating key = %v", err)
+			}
 			if !reflect.DeepEqual(cert.DNSNames, tt.args.host) {
 				t.Errorf("GenerateCertificate() got Hosts = %v, want %v", cert.DNSNames, tt.args.host)
 			}
@@ -128,16 +184,43 @@ func TestGenerateCertificate(t *testing.T) {
 func TestGeneratePrivateKey(t *testing.T) {
 	tests := []struct {
 		name    string
+		opts    KeyOptions
 		wantErr bool
 	}{
 		{
 			name:    "Generates a new private key",
+			opts:    KeyOptions{},
 			wantErr: false,
 		},
+		{
+			name:    "Generates a new RSA 4096 private key",
+			opts:    KeyOptions{Algorithm: RSAKeyAlgorithm, Size: 4096},
+			wantErr: false,
+		},
+		{
+			name:    "Generates a new ECDSA private key",
+			opts:    KeyOptions{Algorithm: ECDSAKeyAlgorithm},
+			wantErr: false,
+		},
+		{
+			name:    "Generates a new Ed25519 private key",
+			opts:    KeyOptions{Algorithm: Ed25519KeyAlgorithm},
+			wantErr: false,
+		},
+		{
+			name:    "Fails with an unsupported RSA key size",
+			opts:    KeyOptions{Algorithm: RSAKeyAlgorithm, Size: 1024},
+			wantErr: true,
+		},
+		{
+			name:    "Fails with an unsupported algorithm",
+			opts:    KeyOptions{Algorithm: "DSA"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := GeneratePrivateKey()
+			_, err := GeneratePrivateKey(tt.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GeneratePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -20,7 +20,8 @@ func LoadX509Certificate(cert []byte) (*x509.Certificate, error) {
 }
 
 // DecodePrivateKeyBytes will decode a PEM encoded private key into a crypto.Signer.
-// It supports RSA private keys only. All other types will return err.
+// It supports PKCS#8 encoded RSA, ECDSA and Ed25519 keys, PKCS#1 encoded RSA keys
+// and SEC 1 encoded ECDSA keys. All other types will return err.
 func DecodePrivateKeyBytes(keyBytes []byte) (crypto.Signer, error) {
 	// decode the private key pem
 	block, _ := pem.Decode(keyBytes)
@@ -52,6 +53,13 @@ func DecodePrivateKeyBytes(keyBytes []byte) (crypto.Signer, error) {
 		}
 		return key, nil
 
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ec private key: %s", err.Error())
+		}
+		return key, nil
+
 	default:
 		return nil, fmt.Errorf("unknown private key type: %s", block.Type)
 	}
@@ -1,9 +1,26 @@
 package pki
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
 )
 
+func testECPrivateKey() []byte {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func testPKCS8PrivateKey(opts KeyOptions) []byte {
+	key, _ := GeneratePrivateKey(opts)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
 func testCertificate() []byte {
 	return []byte(`
 -----BEGIN CERTIFICATE-----
@@ -104,6 +121,21 @@ func TestDecodePrivateKeyBytes(t *testing.T) {
 			args:    args{keyBytes: testPrivateKey()},
 			wantErr: false,
 		},
+		{
+			name:    "Loads an ECDSA private key in SEC 1 format",
+			args:    args{keyBytes: testECPrivateKey()},
+			wantErr: false,
+		},
+		{
+			name:    "Loads an ECDSA private key in PKCS#8 format",
+			args:    args{keyBytes: testPKCS8PrivateKey(KeyOptions{Algorithm: ECDSAKeyAlgorithm})},
+			wantErr: false,
+		},
+		{
+			name:    "Loads an Ed25519 private key",
+			args:    args{keyBytes: testPKCS8PrivateKey(KeyOptions{Algorithm: Ed25519KeyAlgorithm})},
+			wantErr: false,
+		},
 		{
 			name:    "Returns an error",
 			args:    args{keyBytes: testCertificate()},
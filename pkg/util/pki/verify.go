@@ -1,7 +1,13 @@
 package pki
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/x509"
+	"fmt"
+	"net"
+	"sort"
 )
 
 // VerifyError is an error type returned when the
@@ -46,3 +52,59 @@ func Verify(certificate, root *x509.Certificate) error {
 
 	return nil
 }
+
+// VerifyHosts validates that the given certificate is issued
+// exactly for the given list of hosts
+func VerifyHosts(certificate *x509.Certificate, hosts ...string) error {
+
+	got := append([]string{}, certificate.DNSNames...)
+	for _, ip := range certificate.IPAddresses {
+		got = append(got, ip.String())
+	}
+	want := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		// IPs are compared in their canonical form
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		want = append(want, host)
+	}
+
+	sort.Strings(got)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return NewVerifyError(fmt.Sprintf("certificate hosts %v do not match the expected hosts %v", got, want))
+	}
+
+	return nil
+}
+
+// VerifyKey validates that the public key of the given certificate
+// matches the given key algorithm and size
+func VerifyKey(certificate *x509.Certificate, opts KeyOptions) error {
+
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	var algorithm KeyAlgorithm
+	var size int
+	switch key := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		algorithm, size = RSAKeyAlgorithm, key.N.BitLen()
+	case *ecdsa.PublicKey:
+		algorithm, size = ECDSAKeyAlgorithm, key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		algorithm = Ed25519KeyAlgorithm
+	default:
+		return NewVerifyError(fmt.Sprintf("unsupported public key type %T", key))
+	}
+
+	if algorithm != opts.Algorithm || size != opts.Size {
+		return NewVerifyError(fmt.Sprintf("certificate key %s/%d does not match the expected key %s/%d",
+			algorithm, size, opts.Algorithm, opts.Size))
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package pki
 import (
 	"crypto/x509"
 	"fmt"
+	"net"
 	"reflect"
 	"testing"
 
@@ -55,6 +56,130 @@ func TestVerify(t *testing.T) {
 	}
 }
 
+func TestVerifyHosts(t *testing.T) {
+	type args struct {
+		certificate *x509.Certificate
+		hosts       []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Hosts match",
+			args: args{
+				certificate: &x509.Certificate{DNSNames: []string{"node1", "example.com"}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}},
+				hosts:       []string{"127.0.0.1", "example.com", "node1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "IPs are compared in their canonical form",
+			args: args{
+				certificate: &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("::1")}},
+				hosts:       []string{"0:0::1"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Host missing in the certificate",
+			args: args{
+				certificate: &x509.Certificate{DNSNames: []string{"example.com"}},
+				hosts:       []string{"example.com", "node1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unexpected host in the certificate",
+			args: args{
+				certificate: &x509.Certificate{DNSNames: []string{"example.com", "node1"}},
+				hosts:       []string{"example.com"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyHosts(tt.args.certificate, tt.args.hosts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !IsVerifyError(err) {
+				t.Errorf("VerifyHosts() error is not a VerifyError")
+			}
+		})
+	}
+}
+
+func TestVerifyKey(t *testing.T) {
+	certificate := func(opts KeyOptions) *x509.Certificate {
+		key, _ := GeneratePrivateKey(opts)
+		return &x509.Certificate{PublicKey: key.Public()}
+	}
+	type args struct {
+		certificate *x509.Certificate
+		opts        KeyOptions
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Default RSA key matches",
+			args: args{
+				certificate: certificate(KeyOptions{Algorithm: RSAKeyAlgorithm, Size: 2048}),
+				opts:        KeyOptions{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ECDSA key matches",
+			args: args{
+				certificate: certificate(KeyOptions{Algorithm: ECDSAKeyAlgorithm, Size: 384}),
+				opts:        KeyOptions{Algorithm: ECDSAKeyAlgorithm, Size: 384},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Ed25519 key matches",
+			args: args{
+				certificate: certificate(KeyOptions{Algorithm: Ed25519KeyAlgorithm}),
+				opts:        KeyOptions{Algorithm: Ed25519KeyAlgorithm},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Algorithm does not match",
+			args: args{
+				certificate: certificate(KeyOptions{}),
+				opts:        KeyOptions{Algorithm: ECDSAKeyAlgorithm},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Size does not match",
+			args: args{
+				certificate: certificate(KeyOptions{Algorithm: ECDSAKeyAlgorithm, Size: 256}),
+				opts:        KeyOptions{Algorithm: ECDSAKeyAlgorithm, Size: 521},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyKey(tt.args.certificate, tt.args.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !IsVerifyError(err) {
+				t.Errorf("VerifyKey() error is not a VerifyError")
+			}
+		})
+	}
+}
+
 func TestVerifyError_Error(t *testing.T) {
 	type fields struct {
 		msg string
@@ -46,7 +46,11 @@ const (
 	paramClientCertificate    = "client-certificate"
 	paramEnvoyExtraArgs       = "envoy-extra-args"
 	paramEnvoyAPIVersion      = "envoy-api-version"
+	paramDeltaXds             = "delta-xds"
 	paramDiscoveryServiceName = "discovery-service.name"
+	// the namespace of the DiscoveryService, for discovery services
+	// that serve the EnvoyConfigs of other namespaces
+	paramDiscoveryServiceNamespace = "discovery-service.namespace"
 
 	// Annotations to allow configuration of Envoy's admin api
 	paramEnvoyAdminPort          = "admin.port"
@@ -97,7 +101,6 @@ func (esc *envoySidecarConfig) PopulateFromAnnotations(ctx context.Context, clnt
 	esc.generator.TLSVolume = getStringParam(paramTLSVolume, annotations)
 	esc.generator.NodeID = getNodeID(annotations)
 	esc.generator.ClusterID = getStringParam(paramClusterID, annotations)
-	esc.generator.ClientCertSecret = getStringParam(paramClientCertificate, annotations)
 	esc.generator.ExtraArgs = func() []string {
 		extraArgs := getStringParam(paramEnvoyExtraArgs, annotations)
 		if extraArgs != "" {
@@ -135,38 +138,55 @@ func (esc *envoySidecarConfig) PopulateFromAnnotations(ctx context.Context, clnt
 
 	esc.generator.InitManagerImage = getStringParam(paramInitMgrImage, annotations)
 
-	xdssHost, xdssPort, err := getDiscoveryServiceAddress(ctx, clnt, namespace, annotations)
+	ds, err := getDiscoveryService(ctx, clnt, namespace, annotations)
+	if err != nil {
+		return err
+	}
+	esc.generator.XdssHost = fmt.Sprintf("%s.%s.%s", ds.GetServiceConfig().Name, ds.GetNamespace(), "svc")
+	esc.generator.XdssPort = int(ds.GetXdsServerPort())
+	esc.generator.ClientCertSecret, err = getClientCertificate(annotations, ds.NodeIDBindingEnforced())
 	if err != nil {
 		return err
 	}
-	esc.generator.XdssHost = xdssHost
-	esc.generator.XdssPort = xdssPort
 	esc.generator.APIVersion = getStringParam(paramEnvoyAPIVersion, annotations)
+	esc.generator.DeltaXds = isDeltaXdsEnabled(annotations)
 
 	return nil
 }
 
 func getDiscoveryServiceAddress(ctx context.Context, clnt client.Client, namespace string, annotations map[string]string) (string, int, error) {
+	ds, err := getDiscoveryService(ctx, clnt, namespace, annotations)
+	if err != nil {
+		return "", -1, err
+	}
+	return fmt.Sprintf("%s.%s.%s", ds.GetServiceConfig().Name, ds.GetNamespace(), "svc"), int(ds.GetXdsServerPort()), nil
+}
+
+func getDiscoveryService(ctx context.Context, clnt client.Client, namespace string, annotations map[string]string) (*operatorv1alpha1.DiscoveryService, error) {
 
 	if dsName := getStringParam(paramDiscoveryServiceName, annotations); dsName != "" {
+		dsNamespace := namespace
+		if ns := getStringParam(paramDiscoveryServiceNamespace, annotations); ns != "" {
+			dsNamespace = ns
+		}
 		// Get the address of the DiscoveryService instance
 		ds := &operatorv1alpha1.DiscoveryService{}
-		dsKey := types.NamespacedName{Name: dsName, Namespace: namespace}
+		dsKey := types.NamespacedName{Name: dsName, Namespace: dsNamespace}
 		if err := clnt.Get(ctx, dsKey, ds); err != nil {
-			return "", -1, err
+			return nil, err
 		}
-		return fmt.Sprintf("%s.%s.%s", ds.GetServiceConfig().Name, namespace, "svc"), int(ds.GetXdsServerPort()), nil
+		return ds, nil
 	}
 
 	// If discovery service name is not specified, assume there is only one DiscoveryService in the namespace
 	dsList := &operatorv1alpha1.DiscoveryServiceList{}
 	if err := clnt.List(ctx, dsList, client.InNamespace(namespace)); err != nil {
-		return "", -1, err
+		return nil, err
 	}
 	if n := len(dsList.Items); n != 1 {
-		return "", -1, fmt.Errorf("expected just one DiscoveryService, got %d", n)
+		return nil, fmt.Errorf("expected just one DiscoveryService, got %d", n)
 	}
-	return fmt.Sprintf("%s.%s.%s", dsList.Items[0].GetServiceConfig().Name, namespace, "svc"), int(dsList.Items[0].GetXdsServerPort()), nil
+	return &dsList.Items[0], nil
 }
 
 func lookupMarin3rAnnotation(key string, annotations map[string]string) (string, bool) {
@@ -177,19 +197,20 @@ func lookupMarin3rAnnotation(key string, annotations map[string]string) (string,
 func getStringParam(key string, annotations map[string]string) string {
 
 	var defaults = map[string]string{
-		paramContainerName:           defaults.SidecarContainerName,
-		paramImage:                   defaults.Image,
-		paramConfigVolume:            defaults.SidecarConfigVolume,
-		paramTLSVolume:               defaults.SidecarTLSVolume,
-		paramClientCertificate:       defaults.SidecarClientCertificate,
-		paramEnvoyExtraArgs:          defaults.EnvoyExtraArgs,
-		paramEnvoyAPIVersion:         defaults.EnvoyAPIVersion,
-		paramShtdnMgrEnabled:         "false",
-		paramShtdnMgrImage:           defaults.ShtdnMgrImage(),
-		paramDiscoveryServiceName:    "",
-		paramEnvoyAdminBindAddress:   defaults.EnvoyAdminBindAddress,
-		paramEnvoyAdminAccessLogPath: defaults.EnvoyAdminAccessLogPath,
-		paramInitMgrImage:            defaults.InitMgrImage(),
+		paramContainerName:             defaults.SidecarContainerName,
+		paramImage:                     defaults.Image,
+		paramConfigVolume:              defaults.SidecarConfigVolume,
+		paramTLSVolume:                 defaults.SidecarTLSVolume,
+		paramEnvoyExtraArgs:            defaults.EnvoyExtraArgs,
+		paramEnvoyAPIVersion:           defaults.EnvoyAPIVersion,
+		paramDeltaXds:                  "false",
+		paramShtdnMgrEnabled:           "false",
+		paramShtdnMgrImage:             defaults.ShtdnMgrImage(),
+		paramDiscoveryServiceName:      "",
+		paramDiscoveryServiceNamespace: "",
+		paramEnvoyAdminBindAddress:     defaults.EnvoyAdminBindAddress,
+		paramEnvoyAdminAccessLogPath:   defaults.EnvoyAdminAccessLogPath,
+		paramInitMgrImage:              defaults.InitMgrImage(),
 	}
 
 	// return the value specified in the corresponding annotation, if any
@@ -241,6 +262,22 @@ func getNodeID(annotations map[string]string) string {
 	return res
 }
 
+func getClientCertificate(annotations map[string]string, enforceNodeIDBinding bool) (string, error) {
+	if value, ok := lookupMarin3rAnnotation(paramClientCertificate, annotations); ok {
+		return value, nil
+	}
+	if !enforceNodeIDBinding {
+		return defaults.SidecarClientCertificate, nil
+	}
+	// sidecars use the client certificate issued for their node-id when the discovery
+	// service verifies that clients only request their own configuration
+	nodeID := getNodeID(annotations)
+	if err := defaults.ValidateSidecarNodeClientCertificate(nodeID); err != nil {
+		return "", err
+	}
+	return defaults.SidecarNodeClientCertificate(nodeID), nil
+}
+
 func getContainerResourceRequirements(annotations map[string]string) (corev1.ResourceRequirements, error) {
 	var res corev1.ResourceRequirements
 	strCPURequests, okCPURequests := lookupMarin3rAnnotation(paramResourceRequestsCPU, annotations)
@@ -405,6 +442,14 @@ func isShtdnMgrEnabled(annotations map[string]string) bool {
 	return b
 }
 
+func isDeltaXdsEnabled(annotations map[string]string) bool {
+	b, err := strconv.ParseBool(getStringParam(paramDeltaXds, annotations))
+	if err != nil {
+		return false
+	}
+	return b
+}
+
 func (esc *envoySidecarConfig) containers() []corev1.Container {
 
 	return esc.generator.Containers()
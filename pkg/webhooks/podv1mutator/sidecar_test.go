@@ -521,6 +521,56 @@ func Test_getNodeID(t *testing.T) {
 	}
 }
 
+func Test_getClientCertificate(t *testing.T) {
+	type args struct {
+		annotations          map[string]string
+		enforceNodeIDBinding bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			"Return client-certificate from annotation",
+			args{map[string]string{"marin3r.3scale.net/node-id": "test-id", "marin3r.3scale.net/client-certificate": "cert"}, true},
+			"cert",
+			false,
+		},
+		{
+			"Return the shared client certificate by default",
+			args{map[string]string{"marin3r.3scale.net/node-id": "test-id"}, false},
+			"envoy-sidecar-client-cert",
+			false,
+		},
+		{
+			"Return the client certificate of the node-id if binding is enforced",
+			args{map[string]string{"marin3r.3scale.net/node-id": "test-id"}, true},
+			"envoy-sidecar-client-cert-test-id",
+			false,
+		},
+		{
+			"Fail if the node-id can't be used in the name of the client certificate",
+			args{map[string]string{"marin3r.3scale.net/node-id": "Test_ID"}, true},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getClientCertificate(tt.args.annotations, tt.args.enforceNodeIDBinding)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getClientCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("getClientCertificate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_lookupMarin3rAnnotation(t *testing.T) {
 	type args struct {
 		annotations map[string]string
@@ -748,6 +798,55 @@ func Test_isShtdnMgrEnabled(t *testing.T) {
 	}
 }
 
+func Test_isDeltaXdsEnabled(t *testing.T) {
+	type args struct {
+		annotations map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "Returns true (value: true)",
+			args: args{
+				annotations: map[string]string{
+					fmt.Sprintf("%s/%s", marin3rAnnotationsDomain, "other-stuff"): "aaaa",
+					fmt.Sprintf("%s/%s", marin3rAnnotationsDomain, paramDeltaXds): "true",
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Returns false (value: false)",
+			args: args{
+				annotations: map[string]string{
+					fmt.Sprintf("%s/%s", marin3rAnnotationsDomain, "other-stuff"): "aaaa",
+					fmt.Sprintf("%s/%s", marin3rAnnotationsDomain, paramDeltaXds): "false",
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Returns false (bad value)",
+			args: args{
+				annotations: map[string]string{
+					fmt.Sprintf("%s/%s", marin3rAnnotationsDomain, "other-stuff"): "aaaa",
+					fmt.Sprintf("%s/%s", marin3rAnnotationsDomain, paramDeltaXds): "bad_value",
+				},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDeltaXdsEnabled(tt.args.annotations); got != tt.want {
+				t.Errorf("isDeltaXdsEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_getPortOrDefault(t *testing.T) {
 	type args struct {
 		key         string
@@ -840,6 +939,31 @@ func Test_envoySidecarConfig_GetDiscoveryServiceAddress(t *testing.T) {
 			wantPort:   20000,
 			wantErr:    false,
 		},
+		{
+			name: "Returns the address of a DiscoveryService in another namespace",
+			args: args{
+				ctx: context.TODO(),
+				clnt: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+					&operatorv1alpha1.DiscoveryService{
+						ObjectMeta: metav1.ObjectMeta{Name: "ds", Namespace: "marin3r"},
+						Spec: operatorv1alpha1.DiscoveryServiceSpec{
+							XdsServerPort: func() *uint32 { var p uint32 = 20000; return &p }(),
+							ServiceConfig: &operatorv1alpha1.ServiceConfig{
+								Name: "example",
+							},
+						},
+					},
+				).WithStatusSubresource(&operatorv1alpha1.DiscoveryService{}).Build(),
+				namespace: "test",
+				annotations: map[string]string{
+					"marin3r.3scale.net/discovery-service.name":      "ds",
+					"marin3r.3scale.net/discovery-service.namespace": "marin3r",
+				},
+			},
+			wantServer: "example.marin3r.svc",
+			wantPort:   20000,
+			wantErr:    false,
+		},
 		{
 			name: "Returns the address without the 'discovery-service.name' annotation",
 			args: args{
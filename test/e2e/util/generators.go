@@ -171,7 +171,7 @@ func GenerateTLSSecret(k8skey types.NamespacedName, commonName, duration string)
 		return nil, err
 	}
 
-	crt, key, err := pki.GenerateCertificate(nil, nil, commonName, tDuration, true, false, commonName)
+	crt, key, err := pki.GenerateCertificate(nil, nil, commonName, tDuration, true, false, pki.KeyOptions{}, commonName)
 	if err != nil {
 		return nil, err
 	}